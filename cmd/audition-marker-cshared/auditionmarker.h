@@ -0,0 +1,37 @@
+/*
+ * auditionmarker.h - C API of libauditionmarker
+ *
+ * Build the library with:
+ *   go build -buildmode=c-shared -o libauditionmarker.so ./cmd/audition-marker-cshared
+ *
+ * Strings are UTF-8. Every non-NULL string returned by the library must be released with FreeString.
+ */
+#ifndef AUDITIONMARKER_H
+#define AUDITIONMARKER_H
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+/*
+ * Writes the markers of the Adobe Audition CSV file (a path or an HTTP(S) URL) as chapters of the MP3 file.
+ * output_path and preset may be NULL or empty for filename_with_chapters.mp3 and the default settings.
+ * An existing output file (or the input itself) is only replaced if overwrite is non-zero.
+ * Returns NULL on success, or an error message.
+ */
+char *AddChapters(char *csv_path, char *mp3_path, char *output_path, char *preset, int overwrite);
+
+/*
+ * Reads the chapters of the MP3 file as a JSON array of {"title": ..., "start_ms": ...} objects.
+ * Returns NULL on failure and stores an error message in *error_message if error_message is not NULL.
+ */
+char *ReadChapters(char *mp3_path, char **error_message);
+
+/* Releases a string returned by the library. */
+void FreeString(char *s);
+
+#ifdef __cplusplus
+}
+#endif
+
+#endif
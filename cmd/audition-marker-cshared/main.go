@@ -0,0 +1,78 @@
+// Command audition-marker-cshared is built with -buildmode=c-shared into a library exporting the chapter
+// writer and reader to C, so pipelines in other languages call the same implementation as the CLI.
+// See auditionmarker.h for the API.
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"context"
+	"encoding/json"
+	"unsafe"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/auditionmarker"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+)
+
+// chapterJSON is a chapter in the JSON returned by ReadChapters
+type chapterJSON struct {
+	Title     string `json:"title"`
+	StartTime int64  `json:"start_ms"`
+}
+
+// AddChapters writes the markers of the CSV file as chapters of the MP3 file and returns NULL on success,
+// or an error message to be released with FreeString
+//
+//export AddChapters
+func AddChapters(csvPath, mp3Path, outputPath, presetName *C.char, overwrite C.int) *C.char {
+	_, err := auditionmarker.TagMP3WithCSV(context.Background(), goString(csvPath), goString(mp3Path), auditionmarker.Options{
+		OutputPath: goString(outputPath),
+		Overwrite:  overwrite != 0,
+		Preset:     goString(presetName),
+	})
+	if err != nil {
+		return C.CString(err.Error())
+	}
+	return nil
+}
+
+// ReadChapters returns the chapters of the MP3 file as a JSON array to be released with FreeString.
+// On failure it returns NULL and stores an error message in *errorMessage (if not NULL), also released with FreeString.
+//
+//export ReadChapters
+func ReadChapters(mp3Path *C.char, errorMessage **C.char) *C.char {
+	chapters, err := id3tag.ReadChapters(goString(mp3Path))
+	if err != nil {
+		if errorMessage != nil {
+			*errorMessage = C.CString(err.Error())
+		}
+		return nil
+	}
+
+	entries := make([]chapterJSON, 0, len(chapters))
+	for _, chapter := range chapters {
+		entries = append(entries, chapterJSON{Title: chapter.Title, StartTime: chapter.StartTime.Milliseconds()})
+	}
+	data, _ := json.Marshal(entries)
+	return C.CString(string(data))
+}
+
+// FreeString releases a string returned by the library
+//
+//export FreeString
+func FreeString(s *C.char) {
+	C.free(unsafe.Pointer(s))
+}
+
+// goString converts a C string to a Go string, treating NULL as empty
+func goString(s *C.char) string {
+	if s == nil {
+		return ""
+	}
+	return C.GoString(s)
+}
+
+func main() {}
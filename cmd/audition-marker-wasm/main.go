@@ -0,0 +1,70 @@
+//go:build js && wasm
+
+// Command audition-marker-wasm exposes the marker parser and chapter tag writer to JavaScript,
+// so a web page can tag episodes entirely in the browser without uploading the audio.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"syscall/js"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+)
+
+func main() {
+	// Register the API and keep the program running to serve calls
+	js.Global().Set("addChapters", js.FuncOf(addChapters))
+	select {}
+}
+
+// addChapters implements addChapters(mp3Bytes, csvText), returning a Promise of the tagged MP3 as a Uint8Array
+func addChapters(this js.Value, args []js.Value) any {
+	promise := js.Global().Get("Promise")
+	return promise.New(js.FuncOf(func(this js.Value, callbacks []js.Value) any {
+		resolve, reject := callbacks[0], callbacks[1]
+		go func() {
+			tagged, err := tagMP3(args)
+			if err != nil {
+				reject.Invoke(js.Global().Get("Error").New(err.Error()))
+				return
+			}
+			result := js.Global().Get("Uint8Array").New(len(tagged))
+			js.CopyBytesToJS(result, tagged)
+			resolve.Invoke(result)
+		}()
+		return nil
+	}))
+}
+
+// tagMP3 adds the chapters of the marker CSV text to the MP3 bytes given as JavaScript arguments
+func tagMP3(args []js.Value) ([]byte, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("addChapters requires the MP3 data and the marker CSV text")
+	}
+	if args[0].Type() != js.TypeObject || args[0].Get("byteLength").Type() != js.TypeNumber {
+		return nil, fmt.Errorf("MP3 data must be a Uint8Array")
+	}
+	if args[1].Type() != js.TypeString {
+		return nil, fmt.Errorf("Marker CSV must be a string")
+	}
+
+	// Copy the MP3 data into Go memory
+	mp3 := make([]byte, args[0].Get("byteLength").Int())
+	js.CopyBytesToGo(mp3, args[0])
+
+	// Parse markers
+	markers, err := csvparser.ParseAuditionCSVReader(strings.NewReader(args[1].String()))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse CSV: %w", err)
+	}
+
+	// Add chapter tags
+	var output bytes.Buffer
+	if err := id3tag.AddChaptersTo(&output, bytes.NewReader(mp3), markers, id3tag.Options{}); err != nil {
+		return nil, err
+	}
+	return output.Bytes(), nil
+}
@@ -0,0 +1,131 @@
+package auditionmarker
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/config"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/pipeline"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/safefile"
+)
+
+// chapterFlags collects the markers of repeated -chapter TIME=TITLE options
+type chapterFlags []csvparser.MarkerEntry
+
+// String returns the chapters in their command line form
+func (c *chapterFlags) String() string {
+	items := make([]string, 0, len(*c))
+	for _, marker := range *c {
+		items = append(items, fmt.Sprintf("%s=%s", id3tag.FormatDuration(marker.StartTime), marker.Name))
+	}
+	return strings.Join(items, ", ")
+}
+
+// Set parses a TIME=TITLE value and adds it as a marker
+func (c *chapterFlags) Set(value string) error {
+	timeText, title, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("expected TIME=TITLE, e.g. 05:30='Interview'")
+	}
+	start, err := csvparser.ParseTime(timeText)
+	if err != nil {
+		return err
+	}
+	title = strings.TrimSpace(title)
+	if len(title) >= 2 && (title[0] == '\'' || title[0] == '"') && title[len(title)-1] == title[0] {
+		title = title[1 : len(title)-1]
+	}
+	if title == "" {
+		return fmt.Errorf("chapter at %s has no title", timeText)
+	}
+	*c = append(*c, csvparser.MarkerEntry{Name: title, StartTime: start})
+	return nil
+}
+
+// executeAdd adds chapters given on the command line to an audio file without a CSV file
+func (c *cli) executeAdd(args []string) int {
+	// Define add options
+	var chapters chapterFlags
+	flags := flag.NewFlagSet("add", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	flags.Var(&chapters, "chapter", "Chapter as TIME=TITLE, e.g. 05:30='Interview' (repeat for each chapter)")
+	outputPath := flags.String("output", "", "Path for output file with chapters (default: filename_with_chapters.mp3)")
+	followSymlinks := flags.Bool("follow-symlinks", false, followSymlinksUsage+" (with -output set to the input)")
+	presetName := flags.String("preset", "", "Compatibility preset for the target player: apple, spotify, generic, audiobook or a custom preset from the config file (MP3 only)")
+	configPath := flags.String("config", "", "Path to the config file (default: "+config.DefaultPath()+")")
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: %s add -chapter <TIME=TITLE> [-chapter <TIME=TITLE> ...] [-output <path>] [-follow-symlinks] <MP3/Ogg file>\n\n", c.name)
+		fmt.Fprintf(c.stderr, "Times are decimal seconds, MM:SS.mmm or HH:MM:SS.mmm; chapters are sorted by time.\n\n")
+		fmt.Fprintf(c.stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return parseErrorCode(err)
+	}
+
+	// Validate options
+	if len(chapters) == 0 || flags.NArg() != 1 {
+		fmt.Fprintln(c.stderr, "Error: at least one chapter and exactly one audio file are required")
+		flags.Usage()
+		return 1
+	}
+	inputPath := flags.Arg(0)
+	if !fileExists(inputPath) {
+		fmt.Fprintln(c.stderr, "Error:", notFoundError("Input file", inputPath))
+		return 1
+	}
+	isOgg := isOggFile(inputPath)
+	if !isOgg && !isMP3File(inputPath) {
+		fmt.Fprintf(c.stderr, "Error: Input file '%s' is not an MP3 or Ogg file\n", inputPath)
+		return 1
+	}
+	if isOgg && *presetName != "" {
+		fmt.Fprintln(c.stderr, "Error: Presets are only supported for MP3 input")
+		return 1
+	}
+
+	inputPath, target, err := resolveInPlacePaths(inputPath, determineOutputPath(inputPath, *outputPath), *followSymlinks)
+	if err == nil {
+		err = safefile.CheckWritable(target)
+	}
+	if err != nil {
+		fmt.Fprintln(c.stderr, "Error:", err)
+		return 1
+	}
+	*outputPath = target
+
+	markers := []csvparser.MarkerEntry(chapters)
+	sort.SliceStable(markers, func(i, j int) bool { return markers[i].StartTime < markers[j].StartTime })
+	pipeline.ReportMarkers(markers, cliReporter{c})
+
+	// Apply preset if requested
+	options := id3tag.Options{Prompt: c.prompter()}
+	if *presetName != "" {
+		p, err := loadPreset(*presetName, *configPath)
+		if err != nil {
+			fmt.Fprintln(c.stderr, "Error:", err)
+			return 1
+		}
+		fmt.Fprintf(c.stdout, "Using preset '%s': %s\n", p.Name, p.Description)
+		p.Apply(&options)
+	}
+
+	// Add chapter tags
+	fmt.Fprintln(c.stdout, "Adding chapter tags to audio file...")
+	if err := addChapters(nil, inputPath, markers, *outputPath, options); err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while adding chapter tags: %v\n", err)
+		return 1
+	}
+
+	targetFile := determineOutputPath(inputPath, *outputPath)
+	c.showSuccessMessage(targetFile)
+	if err := c.verifyAndShowChapters(targetFile, markers, 0, nil, listingOptions{}); err != nil {
+		fmt.Fprintf(c.stderr, "Error: Verification of the output file failed: %v\n", err)
+		return exitVerificationFailed
+	}
+	return 0
+}
@@ -0,0 +1,135 @@
+package auditionmarker
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/audit"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/importer"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/remote"
+)
+
+// auditSuffix is appended to the output path for the audit record written next to it
+const auditSuffix = ".audit.json"
+
+// startAudit returns a record with the hashes of the input and marker files, taken before anything is written, or nil if auditing is off
+func (c *cli) startAudit(config *Config) (*audit.Record, error) {
+	if !config.Audit && config.AuditLog == "" {
+		return nil, nil
+	}
+
+	input, err := audit.HashFile(config.InputMP3)
+	if err != nil {
+		return nil, err
+	}
+	record := &audit.Record{Input: input, Markers: []audit.File{}, Transforms: appliedTransforms(config)}
+	record.Tool, record.Version = audit.ToolVersion()
+
+	for _, csvPath := range config.CSVPaths {
+		source := audit.File{Path: csvPath}
+		if !remote.IsURL(csvPath) {
+			if source, err = audit.HashFile(csvPath); err != nil {
+				return nil, err
+			}
+		}
+		if format, err := importer.Detect(csvPath); err == nil {
+			source.Format = format.Name
+		}
+		record.Markers = append(record.Markers, source)
+	}
+	return record, nil
+}
+
+// finishAudit completes a record with the output file and writes it next to the output and to the audit log as requested.
+// It returns the path of the record written next to the output (empty if none).
+func (c *cli) finishAudit(config *Config, record *audit.Record, outputPath string, chapterCount int, verifyErr error) string {
+	if record == nil {
+		return ""
+	}
+
+	record.Time = time.Now().UTC()
+	record.Status = audit.StatusDone
+	if verifyErr != nil {
+		record.Status = audit.StatusFailed
+		record.Error = verifyErr.Error()
+	}
+	record.Chapters = chapterCount
+
+	output, err := audit.HashFile(outputPath)
+	if err != nil {
+		c.warnf(c.stderr, "Audit record: %v", err)
+		output = audit.File{Path: outputPath}
+	}
+	record.Output = output
+	if config.Sidecar == "" && isMP3File(outputPath) {
+		if tag, err := id3tag.ReadRawTag(outputPath); err == nil && tag != nil {
+			record.Frames = map[string]int{}
+			for _, frame := range tag.Frames {
+				record.Frames[frame.ID]++
+			}
+		}
+	}
+
+	if config.AuditLog != "" {
+		if err := audit.Append(config.AuditLog, *record); err != nil {
+			c.warnf(c.stderr, "%v", err)
+		}
+	}
+	if !config.Audit {
+		return ""
+	}
+	path := outputPath + auditSuffix
+	if err := audit.WriteFile(path, *record); err != nil {
+		c.warnf(c.stderr, "%v", err)
+		return ""
+	}
+	fmt.Fprintf(c.stdout, "Audit record saved to '%s'\n", path)
+	return path
+}
+
+// appliedTransforms lists the changes the options make to the markers, in the order they are applied
+func appliedTransforms(config *Config) []string {
+	transforms := []string{}
+	if len(config.CSVPaths) > 1 {
+		transforms = append(transforms, "merge-sources on-conflict="+config.OnConflict)
+	}
+	if config.Track != "" {
+		transforms = append(transforms, "track="+config.Track)
+	}
+	if len(config.Colors) > 0 {
+		transforms = append(transforms, "color="+strings.Join(config.Colors, ","))
+	}
+	if config.PartDelimiter != "" {
+		transforms = append(transforms, fmt.Sprintf("part-delimiter=%q", config.PartDelimiter))
+	}
+	if config.MinGap > 0 {
+		transforms = append(transforms, "min-gap="+config.MinGap.String())
+	}
+	if config.TitleCommand != "" {
+		transforms = append(transforms, "auto-title")
+	}
+	if config.Preset != "" {
+		transforms = append(transforms, "preset="+config.Preset)
+	}
+	if config.Snap > 0 {
+		transforms = append(transforms, "snap="+config.Snap.String())
+	}
+	if config.Round > 0 {
+		transforms = append(transforms, "round="+config.Round.String())
+	}
+	if config.Align {
+		transforms = append(transforms, "align")
+	}
+	if config.Number {
+		transforms = append(transforms, fmt.Sprintf("number start=%d width=%d", config.NumberStart, config.NumberWidth))
+	}
+	if config.Merge {
+		transforms = append(transforms, "merge-existing")
+	}
+	if config.Sidecar != "" {
+		transforms = append(transforms, "sidecar="+config.Sidecar)
+	}
+	return transforms
+}
@@ -0,0 +1,105 @@
+package auditionmarker
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/artwork"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/auphonic"
+)
+
+// executeAuphonic replaces the chapters of an Auphonic production with markers or the chapters of a tagged file
+func (c *cli) executeAuphonic(args []string) int {
+	// Define auphonic options
+	flags := flag.NewFlagSet("auphonic", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	production := flags.String("production", "", "UUID of the Auphonic production whose chapters are replaced (required)")
+	csvPath := flags.String("csv", "", "Path or HTTP(S) URL of a marker CSV file")
+	inputPath := flags.String("input", "", "Path to a tagged audio file whose chapters are sent (instead of -csv)")
+	token := flags.String("token", "", "Auphonic API key (default: the AUPHONIC_API_KEY environment variable)")
+	chapterURL := flags.String("chapter-url", "", "Link of each chapter; {number} and {slug} are replaced with the chapter number and slugified title, e.g. https://example.com/ep12#{slug}")
+	chapterImages := flags.String("chapter-images", "", "Directory with chapter images matched by number or slugified title, as for tagging; requires -image-base-url")
+	imageBaseURL := flags.String("image-base-url", "", "URL the -chapter-images files are published under; each chapter image is this URL followed by the file name")
+	apiURL := flags.String("api-url", auphonic.DefaultBaseURL, "Base URL of the Auphonic API")
+	dryRun := flags.Bool("dry-run", false, "Print the chapters as JSON instead of sending them")
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: %s auphonic -production <UUID> (-csv <CSV file> | -input <tagged audio file>) [-dry-run]\n\n", c.name)
+		fmt.Fprintf(c.stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return parseErrorCode(err)
+	}
+
+	// Validate options
+	if *production == "" || (*csvPath == "") == (*inputPath == "") {
+		fmt.Fprintln(c.stderr, "Error: production and exactly one of CSV path or input path are required")
+		flags.Usage()
+		return 1
+	}
+	if (*chapterImages == "") != (*imageBaseURL == "") {
+		fmt.Fprintln(c.stderr, "Error: -chapter-images and -image-base-url must be used together")
+		return 1
+	}
+	if *token == "" {
+		*token = os.Getenv("AUPHONIC_API_KEY")
+	}
+	if *token == "" && !*dryRun {
+		fmt.Fprintln(c.stderr, "Error: an API key is required: set -token or AUPHONIC_API_KEY")
+		return 1
+	}
+
+	// Load chapters
+	markers, err := loadMarkers(*csvPath, *inputPath)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while loading chapters: %v\n", err)
+		return 1
+	}
+	chapters := auphonic.NewChapters(markers)
+	if *chapterURL != "" {
+		for i := range chapters {
+			chapters[i].URL = strings.NewReplacer("{number}", strconv.Itoa(i+1), "{slug}", artwork.Slugify(chapters[i].Title)).Replace(*chapterURL)
+		}
+	}
+	if *chapterImages != "" {
+		titles := make([]string, len(chapters))
+		for i, chapter := range chapters {
+			titles[i] = chapter.Title
+		}
+		paths, err := artwork.MatchDirectory(*chapterImages, titles)
+		if err != nil {
+			fmt.Fprintln(c.stderr, "Error:", err)
+			return 1
+		}
+		for i, path := range paths {
+			if path != "" {
+				chapters[i].Image = strings.TrimSuffix(*imageBaseURL, "/") + "/" + filepath.Base(path)
+			}
+		}
+	}
+
+	if *dryRun {
+		encoder := json.NewEncoder(c.stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(chapters); err != nil {
+			fmt.Fprintln(c.stderr, "Error:", err)
+			return 1
+		}
+		return 0
+	}
+
+	// Send chapters
+	client := &auphonic.Client{BaseURL: *apiURL, Token: *token}
+	if err := client.SetChapters(*production, chapters); err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while updating Auphonic production '%s': %v\n", *production, err)
+		return 1
+	}
+
+	fmt.Fprintf(c.stdout, "Done! Sent %d chapters to Auphonic production '%s'\n", len(chapters), *production)
+	return 0
+}
@@ -0,0 +1,56 @@
+package auditionmarker
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/checksum"
+)
+
+// executeVerifyChecksums checks the files listed in checksum manifests written with -checksums
+func (c *cli) executeVerifyChecksums(args []string) int {
+	// Define verify-checksums options
+	flags := flag.NewFlagSet("verify-checksums", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	quiet := flags.Bool("quiet", false, "Only report files that are missing or do not match")
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: %s verify-checksums [-quiet] <manifest.sha256> ...\n\n", c.name)
+		fmt.Fprintf(c.stderr, "Relative paths in a manifest are resolved against the manifest's directory.\n")
+		fmt.Fprintf(c.stderr, "Exits with status 3 if any file is missing or does not match.\n\n")
+		fmt.Fprintf(c.stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return parseErrorCode(err)
+	}
+
+	if flags.NArg() == 0 {
+		fmt.Fprintln(c.stderr, "Error: at least one manifest is required")
+		flags.Usage()
+		return 1
+	}
+
+	// Verify each manifest
+	failed := 0
+	for _, manifest := range flags.Args() {
+		results, err := checksum.Verify(manifest)
+		if err != nil {
+			fmt.Fprintf(c.stderr, "Error occurred while verifying '%s': %v\n", manifest, err)
+			return 1
+		}
+		for _, result := range results {
+			if result.Err != nil {
+				failed++
+				fmt.Fprintf(c.stdout, "%s: FAILED (%v)\n", result.Path, result.Err)
+			} else if !*quiet {
+				fmt.Fprintf(c.stdout, "%s: OK\n", result.Path)
+			}
+		}
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(c.stderr, "Warning: %d files did not match their checksums\n", failed)
+		return exitVerificationFailed
+	}
+	return 0
+}
@@ -0,0 +1,108 @@
+package auditionmarker
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/exporter"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/importer"
+)
+
+// executeConvert converts a chapter file from one format to another without an audio file
+func (c *cli) executeConvert(args []string) int {
+	// Define convert options
+	flags := flag.NewFlagSet("convert", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	fromName := flags.String("from", "", "Input format: "+strings.Join(importer.Names(), ", ")+" (default: detected from the file extension)")
+	toName := flags.String("to", "", "Output format: "+strings.Join(exporter.Names(), ", ")+" (required)")
+	outputPath := flags.String("output", "", "Path for the converted file (default: standard output)")
+	duration := flags.Duration("duration", 0, "Audio length used as the end of the last chapter by formats that store end times, e.g. 1h2m30s")
+	language := flags.String("language", "", "Language code for formats that store one (default \"und\")")
+	audioFile := flags.String("audio-file", "", "Audio file name referenced by formats that store one, such as cue")
+	templatePath := flags.String("template", "", "Path to a Go template file replacing the layout of the markdown and html output formats, or rendered by the template format")
+	exportTemplate := flags.String("export-template", "", "Path to a Go text/template file rendered over the chapter list to produce any text format; selects the template format")
+	frameRate := flags.String("frame-rate", "", "Timecode frame rate of input formats that do not state one, such as edl, e.g. 25fps or '29.97fps drop'")
+	tempo := flags.Float64("tempo", 0, "Tempo in beats per minute of bar positions in input formats that use them, such as logic")
+	timeSignature := flags.String("time-signature", "", "Time signature of bar positions in input formats that use them, such as logic, e.g. 6/8 (default 4/4)")
+	colors := flags.String("color", "", "Comma-separated marker colors to read from input formats that store one, such as edl, e.g. blue,green (default: all markers)")
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: %s convert [-from <format>] -to <format> [-output <path>] <input file | ->\n\n", c.name)
+		fmt.Fprintf(c.stderr, "Reads standard input if the input file is '-', which requires -from.\n\n")
+		fmt.Fprintf(c.stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return parseErrorCode(err)
+	}
+	if *exportTemplate != "" {
+		if (*toName != "" && *toName != "template") || *templatePath != "" {
+			fmt.Fprintln(c.stderr, "Error: -export-template selects the template format and cannot be used with -to or -template")
+			return 1
+		}
+		*toName, *templatePath = "template", *exportTemplate
+	}
+
+	// Validate options
+	if *toName == "" || flags.NArg() != 1 {
+		fmt.Fprintln(c.stderr, "Error: output format and exactly one input file are required")
+		flags.Usage()
+		return 1
+	}
+	inputPath := flags.Arg(0)
+	to, err := exporter.Lookup(*toName)
+	if err != nil {
+		fmt.Fprintln(c.stderr, "Error:", err)
+		return 1
+	}
+	readOptions, err := importOptions(*frameRate, splitList(*colors), *tempo, *timeSignature)
+	if err != nil {
+		fmt.Fprintln(c.stderr, "Error:", err)
+		return 1
+	}
+	var from importer.Format
+	switch {
+	case *fromName != "":
+		from, err = importer.Lookup(*fromName)
+	case inputPath == "-":
+		err = fmt.Errorf("-from is required when reading standard input")
+	default:
+		from, err = importer.Detect(inputPath)
+	}
+	if err != nil {
+		fmt.Fprintln(c.stderr, "Error:", err)
+		return 1
+	}
+
+	// Read markers
+	var markers []csvparser.MarkerEntry
+	switch {
+	case inputPath == "-":
+		markers, err = from.Read(c.input, readOptions)
+	case !fileExists(inputPath):
+		err = fmt.Errorf("File not found")
+	default:
+		markers, err = importer.ReadFile(inputPath, from, readOptions)
+	}
+	if err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while reading %s file '%s': %v\n", from.Name, inputPath, err)
+		return 1
+	}
+
+	// Write converted file
+	options := exporter.Options{Duration: *duration, Language: *language, AudioFile: *audioFile}
+	if options.Template, err = readTemplate(*templatePath); err != nil {
+		fmt.Fprintln(c.stderr, "Error:", err)
+		return 1
+	}
+	if err := c.writeExport(to, *outputPath, markers, options); err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while converting chapters: %v\n", err)
+		return 1
+	}
+
+	if *outputPath != "" {
+		fmt.Fprintf(c.stdout, "Done! Converted %d chapters from %s to %s in '%s'\n", len(markers), from.Name, to.Name, *outputPath)
+	}
+	return 0
+}
@@ -0,0 +1,55 @@
+package auditionmarker
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/chapterstats"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/mp3frame"
+)
+
+// spanDescriptions describe the kinds of coverage problems in the report
+var spanDescriptions = map[chapterstats.SpanKind]string{
+	chapterstats.SpanLeading:  "uncovered audio before the first chapter",
+	chapterstats.SpanGap:      "uncovered audio between chapters",
+	chapterstats.SpanLong:     "no chapter starts for",
+	chapterstats.SpanTrailing: "uncovered audio after the last chapter",
+}
+
+// checkCoverage reports audio not covered by the markers and stretches without a chapter start longer than -max-span,
+// using the length of the input MP3. With -fail-on-gap, any finding is an error.
+func (c *cli) checkCoverage(config *Config, markers []csvparser.MarkerEntry) error {
+	duration := audioDuration(config.InputMP3)
+	chapters := make([]chapterstats.Chapter, len(markers))
+	for i, marker := range markers {
+		chapters[i] = chapterstats.Chapter{Title: marker.Name, Start: marker.StartTime, End: marker.EndTime}
+	}
+
+	spans := chapterstats.Coverage(chapters, duration, config.MaxSpan)
+	for _, span := range spans {
+		fmt.Fprintf(c.stdout, "Coverage: %s %s (%s - %s)\n", spanDescriptions[span.Kind],
+			id3tag.FormatDuration(span.Length()), id3tag.FormatDuration(span.Start), id3tag.FormatDuration(span.End))
+	}
+	if len(spans) == 0 {
+		fmt.Fprintln(c.stdout, "Coverage: the chapters cover the whole audio")
+		return nil
+	}
+	if config.FailOnGap {
+		return fmt.Errorf("%d coverage problems found (-fail-on-gap)", len(spans))
+	}
+	return nil
+}
+
+// audioDuration returns the length of an MP3 file, or 0 if it is unknown
+func audioDuration(path string) time.Duration {
+	if !isMP3File(path) {
+		return 0
+	}
+	info, err := mp3frame.ScanFile(path)
+	if err != nil {
+		return 0
+	}
+	return info.Duration()
+}
@@ -0,0 +1,66 @@
+package auditionmarker
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/exporter"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/silence"
+)
+
+// executeDetect proposes chapter markers at long silences and writes them as a marker CSV
+func (c *cli) executeDetect(args []string) int {
+	// Define detect options
+	flags := flag.NewFlagSet("detect", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	inputPath := flags.String("input", "", "Path to the MP3 file to analyze (required)")
+	outputPath := flags.String("output", "", "Path for the proposed marker CSV (default: standard output)")
+	threshold := flags.Float64("threshold", silence.DefaultThreshold, "Estimated level in dBFS below which audio counts as silent")
+	minSilence := flags.Duration("min-silence", silence.DefaultMinDuration, "Shortest silence that separates chapters")
+	nameFormat := flags.String("name", "Chapter %d", "Chapter name format; %d is replaced with the chapter number")
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: %s detect -input <MP3 file> [-output <CSV file>] [-threshold <dBFS>] [-min-silence <duration>]\n\n", c.name)
+		fmt.Fprintf(c.stderr, "Levels are estimated from the MP3 frame side information without decoding,\n")
+		fmt.Fprintf(c.stderr, "so review the proposed markers before tagging.\n\n")
+		fmt.Fprintf(c.stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return parseErrorCode(err)
+	}
+
+	// Validate options
+	if *inputPath == "" {
+		fmt.Fprintln(c.stderr, "Error: input path is required")
+		flags.Usage()
+		return 1
+	}
+	if !fileExists(*inputPath) {
+		fmt.Fprintf(c.stderr, "Error: Input MP3 file '%s' not found\n", *inputPath)
+		return 1
+	}
+
+	// Detect silences
+	result, err := silence.DetectFile(*inputPath, silence.Options{Threshold: *threshold, MinDuration: *minSilence})
+	if err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while detecting silences: %v\n", err)
+		return 1
+	}
+	for _, interval := range result.Silences {
+		fmt.Fprintf(c.stderr, "Silence: %s - %s\n", id3tag.FormatDuration(interval.Start), id3tag.FormatDuration(interval.End))
+	}
+
+	// Write proposed markers as Audition CSV
+	markers := result.Markers(*nameFormat)
+	format, _ := exporter.Lookup("audition")
+	if err := c.writeExport(format, *outputPath, markers, exporter.Options{Duration: result.Duration}); err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while writing markers: %v\n", err)
+		return 1
+	}
+
+	if *outputPath != "" {
+		fmt.Fprintf(c.stdout, "Done! Proposed %d chapters from %d silences in '%s'\n", len(markers), len(result.Silences), *outputPath)
+	}
+	return 0
+}
@@ -0,0 +1,133 @@
+package auditionmarker
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/chapterdiff"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/remote"
+)
+
+// diffEntry is a change in the JSON output of the diff subcommand
+type diffEntry struct {
+	Kind     string `json:"kind"`
+	OldTitle string `json:"old_title,omitempty"`
+	OldStart *int64 `json:"old_start_ms,omitempty"`
+	NewTitle string `json:"new_title,omitempty"`
+	NewStart *int64 `json:"new_start_ms,omitempty"`
+}
+
+// executeDiff compares the chapters of two sources and exits with code 1 if they differ
+func (c *cli) executeDiff(args []string) int {
+	// Define diff options
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	tolerance := flags.Duration("tolerance", 0, "Largest start time difference that is not reported, e.g. 50ms")
+	format := flags.String("format", "text", "Output format: text or json")
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: %s diff [-tolerance <duration>] [-format text|json] <old source> <new source>\n\n", c.name)
+		fmt.Fprintf(c.stderr, "Sources are marker CSV files (.csv, .txt or HTTP(S) URLs) or tagged MP3/Ogg files.\n")
+		fmt.Fprintf(c.stderr, "Exits with code 0 if the chapters match, 1 if they differ and 2 on errors.\n\n")
+		fmt.Fprintf(c.stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return parseErrorCode(err)
+	}
+
+	// Validate options
+	if flags.NArg() != 2 {
+		fmt.Fprintln(c.stderr, "Error: exactly two sources are required")
+		flags.Usage()
+		return 2
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(c.stderr, "Error: Unknown output format '%s'\n", *format)
+		return 2
+	}
+
+	// Load both sources
+	var sources [2][]csvparser.MarkerEntry
+	for i, path := range flags.Args() {
+		markers, err := loadDiffSource(path)
+		if err != nil {
+			fmt.Fprintf(c.stderr, "Error occurred while loading chapters from '%s': %v\n", path, err)
+			return 2
+		}
+		sources[i] = markers
+	}
+
+	// Compare and print changes
+	changes := chapterdiff.Compare(sources[0], sources[1], *tolerance)
+	if *format == "json" {
+		c.printDiffJSON(changes)
+	} else {
+		for _, change := range changes {
+			fmt.Fprintln(c.stdout, formatChange(change))
+		}
+		if len(changes) == 0 {
+			fmt.Fprintf(c.stdout, "Chapters match (%d chapters)\n", len(sources[0]))
+		} else {
+			fmt.Fprintf(c.stdout, "%d differences\n", len(changes))
+		}
+	}
+
+	if len(changes) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// loadDiffSource loads markers from a marker CSV file or URL, or from the chapters of a tagged audio file
+func loadDiffSource(path string) ([]csvparser.MarkerEntry, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if remote.IsURL(path) || ext == ".csv" || ext == ".txt" {
+		return parseMarkers(path)
+	}
+	if !fileExists(path) {
+		return nil, fmt.Errorf("File not found")
+	}
+	return readMarkersFromMP3(path)
+}
+
+// formatChange formats a change as a diff-like line
+func formatChange(change chapterdiff.Change) string {
+	switch change.Kind {
+	case chapterdiff.Added:
+		return fmt.Sprintf("+ added    %s  %s", id3tag.FormatDuration(change.New.StartTime), change.New.Name)
+	case chapterdiff.Removed:
+		return fmt.Sprintf("- removed  %s  %s", id3tag.FormatDuration(change.Old.StartTime), change.Old.Name)
+	case chapterdiff.Retimed:
+		shift := change.New.StartTime.Truncate(time.Millisecond) - change.Old.StartTime.Truncate(time.Millisecond)
+		return fmt.Sprintf("~ retimed  %s -> %s (%+.3fs)  %s", id3tag.FormatDuration(change.Old.StartTime), id3tag.FormatDuration(change.New.StartTime), shift.Seconds(), change.Old.Name)
+	default:
+		return fmt.Sprintf("~ renamed  %s  %q -> %q", id3tag.FormatDuration(change.Old.StartTime), change.Old.Name, change.New.Name)
+	}
+}
+
+// printDiffJSON prints the changes as a JSON array
+func (c *cli) printDiffJSON(changes []chapterdiff.Change) {
+	entries := make([]diffEntry, 0, len(changes))
+	for _, change := range changes {
+		entry := diffEntry{Kind: string(change.Kind)}
+		if change.Old != nil {
+			start := change.Old.StartTime.Milliseconds()
+			entry.OldTitle, entry.OldStart = change.Old.Name, &start
+		}
+		if change.New != nil {
+			start := change.New.StartTime.Milliseconds()
+			entry.NewTitle, entry.NewStart = change.New.Name, &start
+		}
+		entries = append(entries, entry)
+	}
+
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(entries)
+}
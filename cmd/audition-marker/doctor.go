@@ -0,0 +1,482 @@
+package auditionmarker
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/container"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/importer"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/mp3frame"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/pipeline"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/remote"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/safefile"
+)
+
+// closeMarkerGap is the distance below which doctor reports markers as suspiciously close
+const closeMarkerGap = time.Second
+
+// largeTagSize is the existing tag size above which doctor points out that the tag is large
+const largeTagSize = 1 << 20
+
+// doctor prints the findings of the doctor subcommand, one per line with its severity, and counts the problems
+type doctor struct {
+	c        *cli
+	errors   int
+	warnings int
+}
+
+// ok prints a check that passed, or a fact worth knowing
+func (d *doctor) ok(format string, args ...any) {
+	fmt.Fprintf(d.c.stdout, "ok       %s\n", fmt.Sprintf(format, args...))
+}
+
+// warn prints a likely problem that does not stop the run and what to do about it
+func (d *doctor) warn(hint, format string, args ...any) {
+	d.warnings++
+	d.print("warning", hint, fmt.Sprintf(format, args...))
+}
+
+// fail prints a problem that makes the run fail or give wrong chapters and what to do about it
+func (d *doctor) fail(hint, format string, args ...any) {
+	d.errors++
+	d.print("error", hint, fmt.Sprintf(format, args...))
+}
+
+// print prints a finding with its hint on the following line
+func (d *doctor) print(severity, hint, message string) {
+	fmt.Fprintf(d.c.stdout, "%-8s %s\n", severity, message)
+	if hint != "" {
+		fmt.Fprintf(d.c.stdout, "         Hint: %s\n", hint)
+	}
+}
+
+// executeDoctor checks a marker file and an audio file for the problems that most often make a run fail, explaining each one
+func (c *cli) executeDoctor(args []string) int {
+	// Define doctor options
+	flags := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	csvPath := flags.String("csv", "", "Marker file to check: an Audition marker CSV file or URL, or a file in another import format")
+	inputPath := flags.String("input", "", "Audio file to check")
+	columnMap := flags.String("map", "", "Columns of a CSV file that does not follow the Audition layout, as for the main command")
+	sampleRate := flags.Int("sample-rate", 0, "Sample rate in Hz of markers in samples, as for the main command")
+	frameRate := flags.String("frame-rate", "", "Timecode frame rate of marker files that do not state one, as for the main command")
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: %s doctor [-csv <marker file>] [-input <MP3 file>] [-map <columns>]\n\n", c.name)
+		fmt.Fprintf(c.stderr, "Checks a marker file and an audio file before adding chapters: the text encoding, format,\n")
+		fmt.Fprintf(c.stderr, "header row and time format of the marker file, the existing tag of the audio file,\n")
+		fmt.Fprintf(c.stderr, "and whether the markers fit the audio. Each problem is explained with what to do about it.\n")
+		fmt.Fprintf(c.stderr, "Exits with code 1 if a problem would make the run fail or give wrong chapters.\n\n")
+		fmt.Fprintf(c.stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return parseErrorCode(err)
+	}
+
+	// Validate options
+	if *csvPath == "" && *inputPath == "" {
+		fmt.Fprintln(c.stderr, "Error: set -csv, -input or both")
+		flags.Usage()
+		return 1
+	}
+	options := csvparser.ParseOptions{SampleRate: *sampleRate}
+	if *columnMap != "" {
+		columns, err := csvparser.ParseColumnMap(*columnMap)
+		if err != nil {
+			fmt.Fprintln(c.stderr, "Error:", err)
+			return 1
+		}
+		options.Columns = columns
+	}
+	importOpts, err := importOptions(*frameRate, nil, 0, "")
+	if err != nil {
+		fmt.Fprintln(c.stderr, "Error:", err)
+		return 1
+	}
+
+	d := &doctor{c: c}
+	var info *mp3frame.Info
+	if *inputPath != "" {
+		fmt.Fprintf(c.stdout, "Audio file '%s':\n", *inputPath)
+		info = d.checkAudio(*inputPath)
+		if options.SampleRate == 0 && info != nil {
+			options.SampleRate = info.SampleRate
+		}
+	}
+	var markers []csvparser.MarkerEntry
+	if *csvPath != "" {
+		if *inputPath != "" {
+			fmt.Fprintln(c.stdout)
+		}
+		fmt.Fprintf(c.stdout, "Marker file '%s':\n", *csvPath)
+		markers = d.checkMarkerFile(*csvPath, options, importOpts)
+		d.checkMarkers(markers)
+	}
+	if info != nil && len(markers) > 0 {
+		d.checkMarkersFitAudio(markers, info.Duration())
+	}
+
+	fmt.Fprintf(c.stdout, "\n%d errors, %d warnings\n", d.errors, d.warnings)
+	if d.errors > 0 {
+		return 1
+	}
+	if d.warnings == 0 {
+		fmt.Fprintln(c.stdout, "No problems found.")
+	}
+	return 0
+}
+
+// checkAudio checks the container, audio stream, existing tag and output directory of an audio file; it returns the stream of MP3 files
+func (d *doctor) checkAudio(path string) *mp3frame.Info {
+	if !fileExists(path) {
+		d.fail("Check the path; put it in quotes if it contains spaces.", "%v", notFoundError("Audio file", path))
+		return nil
+	}
+	kind, err := container.Detect(path)
+	if err != nil {
+		d.fail("", "%v", err)
+		return nil
+	}
+	if named := container.FromExtension(path); named != container.Unknown && kind != container.Unknown && named != kind {
+		d.warn("Rename the file to the extension of its content so players recognize it.", "The file has the extension of %s files but contains %s audio", named, kind)
+	}
+	switch kind {
+	case container.MP3:
+	case container.Ogg:
+		d.ok("Ogg audio, which chapters are written to as Vorbis comments")
+		return nil
+	case container.Unknown:
+		d.fail("Convert the audio to MP3, or check that the file was copied completely.", "The file is not a recognized audio file")
+		return nil
+	default:
+		d.warn("Set -ffmpeg to write chapters with ffmpeg, or convert the audio to MP3.", "%s audio has no native chapter writer", kind)
+		return nil
+	}
+
+	info, err := mp3frame.ScanFile(path)
+	if err != nil || len(info.Frames) == 0 {
+		d.fail("Check that the file was copied completely, or export it again.", "No MPEG audio frames found in the file")
+		return nil
+	}
+	d.ok("MP3 audio, %d Hz, %s long", info.SampleRate, id3tag.FormatDuration(info.Duration()))
+	d.checkTag(path)
+
+	// The default output is written next to the input
+	output := determineOutputPath(path, "")
+	if err := safefile.CheckWritable(output); err != nil {
+		d.fail("Choose an output file in a writable directory with -output.", "%v", err)
+	} else {
+		d.ok("The output '%s' can be written", output)
+	}
+	return info
+}
+
+// checkTag reports the version and size of the existing ID3v2 tag of an MP3 file, its chapters and its trailing tags
+func (d *doctor) checkTag(path string) {
+	if err := id3tag.CheckTag(path); errors.Is(err, id3tag.ErrCorruptTag) {
+		d.fail("Set -rebuild-tag to replace it with a fresh tag; the frames of the broken tag are lost.", "%v", err)
+		return
+	}
+	tag, err := id3tag.ReadRawTag(path)
+	if err != nil {
+		d.fail("Set -rebuild-tag to replace it with a fresh tag, or check the frames with the inspect subcommand.", "The existing tag cannot be read: %v", err)
+		return
+	}
+	if tag == nil {
+		d.ok("No ID3v2 tag yet; one is created")
+	} else {
+		d.ok("ID3v2.%d tag, %d bytes, %d frames", tag.Version, tag.Size, len(tag.Frames))
+		if tag.Size > largeTagSize {
+			d.warn("Check the frames with the inspect subcommand; large embedded artwork is the usual cause.", "The existing tag is %d bytes, which some players load slowly", tag.Size)
+		}
+	}
+
+	chapters, err := readChapters(nil, path)
+	switch {
+	case err != nil:
+		d.warn("Check the frames with the lint and inspect subcommands.", "The existing chapters cannot be read: %v", err)
+	case len(chapters) > 0:
+		d.warn("Set -merge to keep them and add the markers to them.", "The file already has %d chapters, which are replaced", len(chapters))
+	}
+
+	if trailing, err := id3tag.ReadTrailingTags(path); err == nil {
+		if trailing.ID3v1 != nil {
+			d.ok("ID3v1 tag at the end of the file (kept; see -trailing-tags)")
+		}
+		if trailing.APE != nil {
+			d.ok("APE tag at the end of the file (kept; see -trailing-tags)")
+		}
+	}
+}
+
+// checkMarkerFile checks the encoding, format, header row and time format of a marker file and returns its markers
+func (d *doctor) checkMarkerFile(path string, options csvparser.ParseOptions, importOpts importer.Options) []csvparser.MarkerEntry {
+	var data []byte
+	var err error
+	if remote.IsURL(path) {
+		data, err = remote.Fetch(path, remote.DefaultMaxSize)
+	} else if !fileExists(path) {
+		err = notFoundError("Marker file", path)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		d.fail("Check the path, or export the markers again from Audition's Markers panel.", "%v", err)
+		return nil
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		d.fail("Export the markers again from Audition's Markers panel.", "The file is empty")
+		return nil
+	}
+	if !d.checkEncoding(data) {
+		return nil
+	}
+
+	// Files in other import formats are read by their importer
+	if !pipeline.IsAuditionSource(path) {
+		format, _ := importer.Detect(path)
+		d.ok("Format: %s (%s), detected from the extension", format.Name, format.Description)
+		markers, err := format.Read(bytes.NewReader(data), importOpts)
+		if err != nil {
+			d.fail("Check the file in the program that wrote it, or export the markers as an Audition CSV file.", "The file cannot be read as %s: %v", format.Name, err)
+			return nil
+		}
+		return markers
+	}
+
+	layout, err := csvparser.DescribeLayout(bytes.NewReader(data), options)
+	if err != nil {
+		d.fail(headerHint(data, options), "%v", err)
+		return nil
+	}
+	d.checkLayout(layout, options)
+	if layout.Rows == 0 {
+		d.fail("Add markers in Audition (M key) before exporting them.", "No marker rows after the header row")
+		return nil
+	}
+	if !d.checkTimeFormat(layout) {
+		return nil
+	}
+
+	options.Source, options.Lenient = path, true
+	markers, err := csvparser.ParseAuditionCSVReaderWithOptions(bytes.NewReader(data), options)
+	var problems csvparser.ParseErrors
+	switch {
+	case errors.As(err, &problems):
+		for _, problem := range problems {
+			d.fail("Fix the row in a text editor, or set -lenient to skip rows that cannot be parsed.", "%v", problem)
+		}
+	case err != nil:
+		d.fail("", "%v", err)
+		return nil
+	}
+	return markers
+}
+
+// checkEncoding reports the text encoding of a marker file; it returns false if the file cannot be read as text
+func (d *doctor) checkEncoding(data []byte) bool {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}), bytes.HasPrefix(data, []byte{0xFE, 0xFF}), bytes.IndexByte(data, 0) >= 0:
+		d.fail("Save the file as UTF-8, e.g. with a text editor's \"Save As\" dialog.", "The file is UTF-16 text, which cannot be read")
+		return false
+	case !utf8.Valid(data):
+		line := bytes.Count(data[:invalidUTF8Offset(data)], []byte("\n")) + 1
+		d.warn("Save the file as UTF-8, e.g. with Excel's \"CSV UTF-8\" type or a text editor, converting it from Shift_JIS or Windows-1252.",
+			"The file is not UTF-8 text (first invalid byte on line %d); accented and Japanese characters in titles are garbled", line)
+	case bytes.HasPrefix(data, []byte("\ufeff")):
+		d.ok("UTF-8 text with a byte order mark")
+	default:
+		d.ok("UTF-8 text")
+	}
+	return true
+}
+
+// invalidUTF8Offset returns the offset of the first byte of data that is not valid UTF-8
+func invalidUTF8Offset(data []byte) int {
+	for offset := 0; offset < len(data); {
+		r, size := utf8.DecodeRune(data[offset:])
+		if r == utf8.RuneError && size <= 1 {
+			return offset
+		}
+		offset += size
+	}
+	return len(data)
+}
+
+// headerHint explains how to make the header row of a CSV file found, by its first line
+func headerHint(data []byte, options csvparser.ParseOptions) string {
+	first, _, _ := bytes.Cut(data, []byte("\n"))
+	first = bytes.TrimPrefix(bytes.TrimSpace(first), []byte("\ufeff"))
+	if options.Columns != nil {
+		return fmt.Sprintf("Check the column names of -map against the header row: %s", first)
+	}
+	if !bytes.Contains(first, []byte("\t")) && bytes.Contains(first, []byte(",")) {
+		columns := strings.Split(string(first), ",")
+		if len(columns) >= 2 {
+			return fmt.Sprintf("The file is comma-separated, but Audition exports tab-separated files. Map its columns with -map, e.g. -map 'name=%s,start=%s'.", strings.TrimSpace(columns[0]), strings.TrimSpace(columns[1]))
+		}
+	}
+	return "Audition marker files have 'Name' and 'Start' columns; export the markers again from Audition's Markers panel, or map the columns with -map."
+}
+
+// checkLayout reports the delimiter, header row and columns of an Audition CSV file
+func (d *doctor) checkLayout(layout *csvparser.Layout, options csvparser.ParseOptions) {
+	delimiter := "tab-separated"
+	if layout.Comma == ',' {
+		delimiter = "comma-separated"
+	}
+	if options.Columns != nil {
+		d.ok("Format: CSV file with the columns of -map (%s)", delimiter)
+	} else {
+		d.ok("Format: Audition marker CSV (%s)", delimiter)
+	}
+
+	var columns []string
+	for _, field := range []string{"name", "start", "end", "part", "track", "format"} {
+		index, ok := layout.Columns[field]
+		if !ok {
+			continue
+		}
+		if index < len(layout.Header) {
+			columns = append(columns, fmt.Sprintf("%s='%s'", field, strings.TrimSpace(strings.TrimPrefix(layout.Header[index], "\ufeff"))))
+		} else {
+			columns = append(columns, fmt.Sprintf("%s=column %d", field, index+1))
+		}
+	}
+	switch {
+	case layout.HeaderLine == 0:
+		d.ok("No header row; columns %s, %d rows", strings.Join(columns, ", "), layout.Rows)
+	case layout.HeaderLine > 1:
+		d.ok("Header row on line %d after %d metadata lines; columns %s, %d rows", layout.HeaderLine, layout.HeaderLine-1, strings.Join(columns, ", "), layout.Rows)
+	default:
+		d.ok("Header row on line 1; columns %s, %d rows", strings.Join(columns, ", "), layout.Rows)
+	}
+}
+
+// Patterns of start cells used to guess their time format
+var (
+	decimalTimePattern = regexp.MustCompile(`^\d+(:\d{1,2}){0,2}(\.\d+)?$`)
+	timecodePattern    = regexp.MustCompile(`^\d+:\d{2}:\d{2}[:;]\d{2}$`)
+	wholeNumberPattern = regexp.MustCompile(`^\d+$`)
+)
+
+// samplePositionThreshold is the whole number start from which whole-number start cells more likely are sample positions than seconds
+const samplePositionThreshold = 100000
+
+// checkTimeFormat reports the time format of the start cells, stated above the header row or guessed from the cells,
+// and whether the cells match it; it returns false if the markers cannot be parsed with it
+func (d *doctor) checkTimeFormat(layout *csvparser.Layout) bool {
+	example := ""
+	if len(layout.Starts) > 0 {
+		example = fmt.Sprintf(", e.g. '%s'", layout.Starts[len(layout.Starts)-1])
+	}
+	_, hasFormatColumn := layout.Columns["format"]
+	stated := layout.TimeFormat
+	switch {
+	case hasFormatColumn:
+		d.ok("Time format: given per row in the format column%s", example)
+		return true
+	case stated.Samples:
+		if layout.SampleRate == 0 {
+			d.fail("Set -sample-rate to the sample rate of the Audition session, or give -input so its sample rate is used.", "Start times are sample positions, but the sample rate is unknown")
+			return false
+		}
+		d.ok("Time format: sample positions at %d Hz%s", layout.SampleRate, example)
+		return true
+	case stated.FrameRate > 0:
+		d.ok("Time format: SMPTE timecode at %d fps%s", stated.FrameRate, example)
+		return true
+	}
+
+	var timecodes, large int
+	for _, cell := range layout.Starts {
+		if timecodePattern.MatchString(cell) {
+			timecodes++
+		}
+		if wholeNumberPattern.MatchString(cell) && len(cell) >= len(fmt.Sprint(samplePositionThreshold)) {
+			large++
+		}
+	}
+	switch {
+	case timecodes > 0:
+		d.fail("Set Audition's time display to Decimal (mm:ss.ddd) before exporting, or add a line such as 'Time Format<TAB>30fps' above the header row.",
+			"Start times look like SMPTE timecode (HH:MM:SS:FF), but no frame rate is stated%s", example)
+		return false
+	case large > 0 && large == len(layout.Starts):
+		d.warn("If they are sample positions, add a line 'Time Format<TAB>samples' above the header row and set -sample-rate.",
+			"Start times are large whole numbers, which are read as seconds%s", example)
+		return true
+	}
+	for _, cell := range layout.Starts {
+		if !decimalTimePattern.MatchString(cell) {
+			d.fail("Use decimal times such as 1:30.500 or 90.5, as Audition exports with its time display set to Decimal.", "Start time '%s' is not in a supported time format", cell)
+			return false
+		}
+	}
+	d.ok("Time format: decimal seconds, M:SS.mmm or H:MM:SS.mmm%s", example)
+	return true
+}
+
+// checkMarkers reports the markers read and the problems among them: untitled, duplicate and very close markers and several tracks
+func (d *doctor) checkMarkers(markers []csvparser.MarkerEntry) {
+	if markers == nil {
+		return
+	}
+	if len(markers) == 0 {
+		d.fail("Add markers in Audition (M key) before exporting them.", "The file has no markers")
+		return
+	}
+	last := markers[len(markers)-1]
+	d.ok("%d markers from %s to %s", len(markers), id3tag.FormatDuration(markers[0].StartTime), id3tag.FormatDuration(last.StartTime))
+
+	untitled := 0
+	for _, marker := range markers {
+		if pipeline.UntitledMarker.MatchString(marker.Name) {
+			untitled++
+		}
+	}
+	if untitled > 0 {
+		d.warn("Rename them in Audition, title them with -title-command, or number them with -preset audiobook.", "%d markers have Audition's default names such as 'Marker 01'", untitled)
+	}
+
+	for i := 1; i < len(markers); i++ {
+		gap := markers[i].StartTime - markers[i-1].StartTime
+		switch {
+		case gap == 0:
+			d.warn("Remove one of them; only one chapter can start at a time.", "'%s' and '%s' start at the same time %s", markers[i-1].Name, markers[i].Name, id3tag.FormatDuration(markers[i].StartTime))
+		case gap > 0 && gap < closeMarkerGap:
+			d.warn("Remove the accidental marker, or merge close markers with -min-gap.", "'%s' starts only %s after '%s'", markers[i].Name, gap, markers[i-1].Name)
+		}
+	}
+	if markers[0].StartTime > 0 {
+		d.warn("Add a marker at 0:00 so the start of the audio belongs to a chapter; some players show no chapter until the first one.", "The first marker starts at %s, not at the start of the audio", id3tag.FormatDuration(markers[0].StartTime))
+	}
+	if tracks := csvparser.Tracks(markers); len(tracks) > 1 {
+		d.warn("Select the track of the chapters with -track.", "The markers are on %d tracks (%s), which all become chapters", len(tracks), strings.Join(tracks, ", "))
+	}
+}
+
+// checkMarkersFitAudio reports markers starting after the end of the audio, which usually belong to another file or session
+func (d *doctor) checkMarkersFitAudio(markers []csvparser.MarkerEntry, duration time.Duration) {
+	outside := 0
+	for _, marker := range markers {
+		if marker.StartTime >= duration {
+			outside++
+		}
+	}
+	if outside > 0 {
+		d.fail("Check that the marker file belongs to this audio file, and that the audio was not trimmed after the markers were exported.",
+			"%d markers start after the end of the audio (%s)", outside, id3tag.FormatDuration(duration))
+		return
+	}
+	d.ok("All markers start within the audio")
+}
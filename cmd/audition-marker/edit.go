@@ -0,0 +1,87 @@
+package auditionmarker
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+)
+
+// executeEdit changes the title or start time of a single chapter of an MP3 file
+func (c *cli) executeEdit(args []string) int {
+	// Define edit options
+	flags := flag.NewFlagSet("edit", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	selector := flags.String("chapter", "", "Number of the chapter in start time order (as listed by read) or its element ID (required)")
+	title := flags.String("title", "", "New chapter title")
+	start := flags.String("start", "", "New start time: decimal seconds, MM:SS.mmm or HH:MM:SS.mmm")
+	outputPath := flags.String("output", "", "Path for the edited MP3 (default: edit the input file in place)")
+	followSymlinks := flags.Bool("follow-symlinks", false, followSymlinksUsage)
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: %s edit -chapter <number or ID> [-title <title>] [-start <time>] [-output <MP3 file>] [-follow-symlinks] <MP3 file>\n\n", c.name)
+		fmt.Fprintf(c.stderr, "Rewrites only the selected CHAP frame; the previous chapter's end time follows a changed start time.\n\n")
+		fmt.Fprintf(c.stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return parseErrorCode(err)
+	}
+
+	// Validate options
+	if *selector == "" || flags.NArg() != 1 {
+		fmt.Fprintln(c.stderr, "Error: chapter and exactly one MP3 file are required")
+		flags.Usage()
+		return 1
+	}
+	inputPath := flags.Arg(0)
+	inputPath, target, err := validateChapterEditPaths(inputPath, *outputPath, *followSymlinks)
+	if err != nil {
+		fmt.Fprintln(c.stderr, "Error:", err)
+		return 1
+	}
+	*outputPath = target
+
+	// Collect the changes given on the command line
+	var edit id3tag.ChapterEdit
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "title" {
+			edit.Title = title
+		}
+	})
+	if *start != "" {
+		startTime, err := csvparser.ParseTime(*start)
+		if err != nil {
+			fmt.Fprintf(c.stderr, "Error: Invalid start time '%s': %v\n", *start, err)
+			return 1
+		}
+		edit.Start = &startTime
+	}
+	if edit.Title == nil && edit.Start == nil {
+		fmt.Fprintln(c.stderr, "Error: at least one of title or start time is required")
+		return 1
+	}
+
+	// Edit chapter
+	changes, err := id3tag.EditChapter(inputPath, *outputPath, *selector, edit, c.prompter())
+	if err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while editing chapter: %v\n", err)
+		return 1
+	}
+	if len(changes) == 0 {
+		fmt.Fprintln(c.stdout, "Chapter already has the given values; nothing to change.")
+		return 0
+	}
+	for _, change := range changes {
+		fmt.Fprintln(c.stdout, change)
+	}
+
+	chapters, err := readChapters(nil, *outputPath)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while reading chapters: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(c.stdout, "Done! Edited chapter saved to '%s'\n", *outputPath)
+	c.printChapterTable(chapters, audioDuration(*outputPath), listingOptions{})
+	return 0
+}
@@ -0,0 +1,138 @@
+package auditionmarker
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/exporter"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/mp3frame"
+)
+
+// executeExport writes markers or chapters in another chapter file format
+func (c *cli) executeExport(args []string) int {
+	// Define export options
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	csvPath := flags.String("csv", "", "Path or HTTP(S) URL of a marker CSV file")
+	inputPath := flags.String("input", "", "Path to a tagged audio file whose chapters are exported (instead of -csv)")
+	formatName := flags.String("format", "", "Export format: "+strings.Join(exporter.Names(), ", ")+" (required)")
+	outputPath := flags.String("output", "", "Path for the exported file (default: standard output)")
+	language := flags.String("language", "", "Language code for formats that store one (default \"und\")")
+	templatePath := flags.String("template", "", "Path to a Go template file replacing the layout of the markdown and html formats, or rendered by the template format")
+	exportTemplate := flags.String("export-template", "", "Path to a Go text/template file rendered over the chapter list to produce any text format; selects the template format")
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: %s export -format <format> (-csv <CSV file> | -input <tagged audio file>) [-output <path>]\n\n", c.name)
+		fmt.Fprintf(c.stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return parseErrorCode(err)
+	}
+	if *exportTemplate != "" {
+		if (*formatName != "" && *formatName != "template") || *templatePath != "" {
+			fmt.Fprintln(c.stderr, "Error: -export-template selects the template format and cannot be used with -format or -template")
+			return 1
+		}
+		*formatName, *templatePath = "template", *exportTemplate
+	}
+
+	// Validate options
+	if *formatName == "" || (*csvPath == "") == (*inputPath == "") {
+		fmt.Fprintln(c.stderr, "Error: export format and exactly one of CSV path or input path are required")
+		flags.Usage()
+		return 1
+	}
+	format, err := exporter.Lookup(*formatName)
+	if err != nil {
+		fmt.Fprintln(c.stderr, "Error:", err)
+		return 1
+	}
+
+	options := exporter.Options{Language: *language, AudioFile: *inputPath}
+	if options.Template, err = readTemplate(*templatePath); err != nil {
+		fmt.Fprintln(c.stderr, "Error:", err)
+		return 1
+	}
+
+	// Load markers
+	markers, err := loadMarkers(*csvPath, *inputPath)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while loading chapters: %v\n", err)
+		return 1
+	}
+
+	// Write export
+	if err := c.writeExport(format, *outputPath, markers, options); err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while exporting chapters: %v\n", err)
+		return 1
+	}
+
+	if *outputPath != "" {
+		fmt.Fprintf(c.stdout, "Done! Exported %d chapters as %s to '%s'\n", len(markers), format.Name, *outputPath)
+	}
+	return 0
+}
+
+// writeExport writes markers in the given format to a file, or to standard output if path is empty
+func (c *cli) writeExport(format exporter.Format, path string, markers []csvparser.MarkerEntry, options exporter.Options) error {
+	var w io.Writer = c.stdout
+	if path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("Cannot create export file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	return format.Write(w, markers, options)
+}
+
+// readTemplate reads the -template file, if any
+func readTemplate(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("Cannot read template: %w", err)
+	}
+	return string(data), nil
+}
+
+// writeAlsoExports writes the markers in each format next to the audio file, e.g. episode_with_chapters.vtt, and returns the written paths
+// The MP3 length of the audio file is used as the end of the last chapter
+func (c *cli) writeAlsoExports(formatNames []string, audioPath string, csvPath string, markers []csvparser.MarkerEntry) ([]string, error) {
+	options := exporter.Options{AudioFile: audioPath}
+	if isMP3File(audioPath) {
+		info, err := mp3frame.ScanFile(audioPath)
+		if err != nil {
+			return nil, err
+		}
+		options.Duration = info.Duration()
+	}
+
+	base := audioPath[:len(audioPath)-len(filepath.Ext(audioPath))]
+	var paths []string
+	for _, name := range formatNames {
+		format, err := exporter.Lookup(name)
+		if err != nil {
+			return paths, err
+		}
+		path := base + format.Extension
+		if path == audioPath || path == csvPath {
+			return paths, fmt.Errorf("Export as %s would overwrite '%s'", format.Name, path)
+		}
+		if err := c.writeExport(format, path, markers, options); err != nil {
+			return paths, err
+		}
+		fmt.Fprintf(c.stdout, "Exported %d chapters as %s to '%s'\n", len(markers), format.Name, path)
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
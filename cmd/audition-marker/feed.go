@@ -0,0 +1,117 @@
+package auditionmarker
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/feed"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/importer"
+)
+
+// executeFeed injects chapters into the matching item of a podcast RSS feed
+func (c *cli) executeFeed(args []string) int {
+	// Define feed options
+	flags := flag.NewFlagSet("feed", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	feedPath := flags.String("feed", "", "Path to the RSS feed XML file (required)")
+	inputMP3 := flags.String("input", "", "Path to a tagged MP3 (or Ogg) file whose chapters are used")
+	csvPath := flags.String("csv", "", "Path or HTTP(S) URL of a marker CSV file used instead of -input")
+	match := flags.String("match", "", "GUID or enclosure file name of the item to patch (default: file name of -input)")
+	chaptersURL := flags.String("chapters-url", "", "URL of a JSON chapters file to link with podcast:chapters")
+	noPSC := flags.Bool("no-psc", false, "Do not write inline psc:chapters (only the podcast:chapters link)")
+	outputPath := flags.String("output", "", "Path for the patched feed (default: overwrite -feed)")
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: %s feed -feed <feed.xml> (-input <tagged MP3> | -csv <CSV file>) [-match <GUID or file name>] [-output <path>]\n\n", c.name)
+		fmt.Fprintf(c.stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return parseErrorCode(err)
+	}
+
+	// Validate options
+	if *feedPath == "" || (*inputMP3 == "" && *csvPath == "") {
+		fmt.Fprintln(c.stderr, "Error: feed path and either input MP3 or CSV path are required")
+		flags.Usage()
+		return 1
+	}
+	if *noPSC && *chaptersURL == "" {
+		fmt.Fprintln(c.stderr, "Error: -no-psc requires -chapters-url")
+		return 1
+	}
+	if *match == "" {
+		if *inputMP3 == "" {
+			fmt.Fprintln(c.stderr, "Error: -match is required when chapters come from a CSV file")
+			return 1
+		}
+		*match = filepath.Base(*inputMP3)
+	}
+	if *outputPath == "" {
+		*outputPath = *feedPath
+	}
+
+	// Load chapters
+	markers, err := loadMarkers(*csvPath, *inputMP3)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while loading chapters: %v\n", err)
+		return 1
+	}
+
+	// Patch feed
+	data, err := os.ReadFile(*feedPath)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "Error: Cannot read feed: %v\n", err)
+		return 1
+	}
+
+	patched, err := feed.InjectChapters(data, markers, feed.Options{
+		Match:       *match,
+		ChaptersURL: *chaptersURL,
+		SkipPSC:     *noPSC,
+	})
+	if err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while patching feed: %v\n", err)
+		return 1
+	}
+
+	if err := os.WriteFile(*outputPath, patched, 0644); err != nil {
+		fmt.Fprintf(c.stderr, "Error: Cannot write feed: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(c.stdout, "Done! Wrote %d chapters for item '%s' to '%s'\n", len(markers), *match, *outputPath)
+	return 0
+}
+
+// readMarkersFromMP3 reads the chapters of a tagged audio file as markers
+func readMarkersFromMP3(mp3Path string) ([]csvparser.MarkerEntry, error) {
+	chapters, err := readChapters(nil, mp3Path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Files for library platforms may only carry OverDrive MediaMarkers
+	if len(chapters) == 0 && isMP3File(mp3Path) {
+		mediaMarkers, err := id3tag.ReadMediaMarkers(mp3Path)
+		if err != nil {
+			return nil, err
+		}
+		if mediaMarkers != "" {
+			return importer.ReadOverDrive(strings.NewReader(mediaMarkers))
+		}
+	}
+
+	markers := make([]csvparser.MarkerEntry, 0, len(chapters))
+	for _, chapter := range chapters {
+		markers = append(markers, csvparser.MarkerEntry{
+			Name:      chapter.Title,
+			StartTime: chapter.StartTime,
+		})
+	}
+	return markers, nil
+}
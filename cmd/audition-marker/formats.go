@@ -0,0 +1,133 @@
+package auditionmarker
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/container"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/ffmpeg"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/importer"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/mp4tag"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/oggtag"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/sidecar"
+)
+
+// isMP3File reports whether path is an MP3 file by its content, or by its extension if it does not exist yet
+func isMP3File(path string) bool {
+	return container.Of(path) == container.MP3
+}
+
+// isOggFile reports whether path is an Ogg file by its content, or by its extension if it does not exist yet
+func isOggFile(path string) bool {
+	return container.Of(path) == container.Ogg
+}
+
+// isMP4File reports whether path is an MP4 file, such as M4A or M4B, by its content, or by its extension if it does not exist yet
+func isMP4File(path string) bool {
+	return container.Of(path) == container.MP4
+}
+
+// isSidecarFile reports whether path has the extension of a JSON or WebVTT sidecar chapter file
+func isSidecarFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".json" || ext == ".vtt"
+}
+
+// needsFFmpeg reports whether path is in a container without a native chapter writer, which tool handles if set
+func needsFFmpeg(tool *ffmpeg.Tool, path string) bool {
+	return tool != nil && !isMP3File(path) && !isOggFile(path) && !isSidecarFile(path)
+}
+
+// addChapters writes chapters with the writer matching the input container, or with tool for containers without one (tool may be nil)
+// The optional ID3 content in options is only written to MP3 files
+func addChapters(tool *ffmpeg.Tool, inputPath string, markers []csvparser.MarkerEntry, outputPath string, options id3tag.Options) error {
+	if isOggFile(inputPath) {
+		return oggtag.AddChapters(inputPath, markers, outputPath, options.Prompt)
+	}
+	if needsFFmpeg(tool, inputPath) {
+		return tool.AddChapters(inputPath, markers, determineOutputPath(inputPath, outputPath))
+	}
+	return id3tag.AddChaptersWithOptions(inputPath, markers, outputPath, options)
+}
+
+// readChapters reads chapters with the reader matching the file's container, or from a sidecar file;
+// tool reads the containers without a native reader (nil for none)
+func readChapters(tool *ffmpeg.Tool, path string) ([]id3tag.Chapter, error) {
+	if isSidecarFile(path) {
+		markers, err := sidecar.Read(path)
+		if err != nil {
+			return nil, err
+		}
+		chapters := make([]id3tag.Chapter, 0, len(markers))
+		for _, marker := range markers {
+			chapters = append(chapters, id3tag.Chapter{Title: marker.Name, StartTime: marker.StartTime})
+		}
+		return chapters, nil
+	}
+	if isMP4File(path) {
+		mp4Chapters, err := mp4tag.ReadChapters(path)
+		if err != nil {
+			return nil, err
+		}
+		chapters := make([]id3tag.Chapter, 0, len(mp4Chapters))
+		for _, chapter := range mp4Chapters {
+			chapters = append(chapters, id3tag.Chapter{Title: chapter.Title, StartTime: chapter.StartTime})
+		}
+		return chapters, nil
+	}
+	if needsFFmpeg(tool, path) {
+		ffChapters, err := tool.ReadChapters(path)
+		if err != nil {
+			return nil, err
+		}
+		chapters := make([]id3tag.Chapter, 0, len(ffChapters))
+		for _, chapter := range ffChapters {
+			chapters = append(chapters, id3tag.Chapter{Title: chapter.Title, StartTime: chapter.StartTime})
+		}
+		return chapters, nil
+	}
+	if !isOggFile(path) {
+		return id3tag.ReadChapters(path)
+	}
+
+	oggChapters, err := oggtag.ReadChapters(path)
+	if err != nil {
+		return nil, err
+	}
+
+	chapters := make([]id3tag.Chapter, 0, len(oggChapters))
+	for _, chapter := range oggChapters {
+		chapters = append(chapters, id3tag.Chapter{Title: chapter.Title, StartTime: chapter.StartTime})
+	}
+	return chapters, nil
+}
+
+// importOptions builds the import settings from the -frame-rate, -color, -tempo and -time-signature values
+func importOptions(frameRate string, colors []string, tempo float64, timeSignature string) (importer.Options, error) {
+	options := importer.Options{Colors: colors, Tempo: tempo}
+	if frameRate != "" {
+		format, err := csvparser.ParseTimeFormat(frameRate)
+		if err != nil || format.FrameRate == 0 {
+			return options, fmt.Errorf("Invalid -frame-rate '%s': use a frame rate such as 25fps or '29.97fps drop'", frameRate)
+		}
+		options.TimeFormat = format
+	}
+	if tempo < 0 || tempo > 1000 {
+		return options, fmt.Errorf("Invalid -tempo %g: use beats per minute between 0 and 1000", tempo)
+	}
+	if timeSignature != "" {
+		beats, unit, found := strings.Cut(timeSignature, "/")
+		perBar, err1 := strconv.Atoi(strings.TrimSpace(beats))
+		beatUnit, err2 := strconv.Atoi(strings.TrimSpace(unit))
+		if !found || err1 != nil || err2 != nil || perBar < 1 || perBar > 64 || !slices.Contains([]int{1, 2, 4, 8, 16}, beatUnit) {
+			return options, fmt.Errorf("Invalid -time-signature '%s': use a time signature such as 4/4 or 6/8", timeSignature)
+		}
+		options.Meter = [2]int{perBar, beatUnit}
+	}
+	return options, nil
+}
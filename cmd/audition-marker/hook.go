@@ -0,0 +1,44 @@
+package auditionmarker
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/pipeline"
+)
+
+// runPostHook executes the user-provided post-processing command for a successfully processed file
+func (c *cli) runPostHook(command string, config *Config, outputPath string, chapterCount int) error {
+	// Split command line into program and arguments
+	args, err := pipeline.SplitCommandLine(command)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("Post-hook command is empty")
+	}
+
+	// Substitute placeholders in each argument
+	replacer := strings.NewReplacer(
+		"{input}", config.InputMP3,
+		"{output}", outputPath,
+		"{csv}", config.CSVPath,
+		"{chapters}", strconv.Itoa(chapterCount),
+	)
+	for i, arg := range args {
+		args[i] = replacer.Replace(arg)
+	}
+
+	// Run the command with the tool's standard streams
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = c.stdin
+	cmd.Stdout = c.stdout
+	cmd.Stderr = c.stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("Post-hook '%s' failed: %w", args[0], err)
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package auditionmarker
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/artwork"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+)
+
+// pictureExtensions maps picture MIME types to file extensions
+var pictureExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/jpg":  ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// executeExtractImages writes the cover art and chapter images of an MP3 file to a directory
+func (c *cli) executeExtractImages(args []string) int {
+	// Define extract-images options
+	flags := flag.NewFlagSet("extract-images", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	inputPath := flags.String("input", "", "Path to a tagged MP3 file (required)")
+	outputDir := flags.String("dir", ".", "Directory to write the images to")
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: %s extract-images -input <tagged MP3> [-dir <directory>]\n\n", c.name)
+		fmt.Fprintf(c.stderr, "Chapter images are named after the chapter number and slugified title (01-intro.jpg),\n")
+		fmt.Fprintf(c.stderr, "the cover as cover.jpg and other pictures as picture-<type>.jpg.\n\n")
+		fmt.Fprintf(c.stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return parseErrorCode(err)
+	}
+
+	// Validate options
+	if *inputPath == "" {
+		fmt.Fprintln(c.stderr, "Error: input path is required")
+		flags.Usage()
+		return 1
+	}
+	if !fileExists(*inputPath) {
+		fmt.Fprintf(c.stderr, "Error: Input MP3 file '%s' not found\n", *inputPath)
+		return 1
+	}
+
+	// Read raw tag
+	tag, err := id3tag.ReadRawTag(*inputPath)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while reading tags: %v\n", err)
+		return 1
+	}
+	if tag == nil {
+		fmt.Fprintln(c.stdout, "No ID3v2 tag found.")
+		return 0
+	}
+	if err := os.MkdirAll(*outputDir, 0755); err != nil {
+		fmt.Fprintf(c.stderr, "Error: Failed to create output directory: %v\n", err)
+		return 1
+	}
+
+	// Write tag-level pictures
+	written := 0
+	for i, picture := range tag.Pictures() {
+		name := fmt.Sprintf("picture-%d", picture.PictureType)
+		if picture.PictureType == 3 {
+			name = "cover"
+		}
+		if i > 0 {
+			name = fmt.Sprintf("%s-%d", name, i+1)
+		}
+		if c.writePicture(*outputDir, name, picture) {
+			written++
+		}
+	}
+
+	// Write chapter pictures in chapter order
+	chapters := tag.Chapters()
+	sort.SliceStable(chapters, func(i, j int) bool { return chapters[i].StartTime < chapters[j].StartTime })
+	for i, chapter := range chapters {
+		picture := chapter.Picture()
+		if picture == nil {
+			continue
+		}
+		name := fmt.Sprintf("%02d", i+1)
+		if slug := artwork.Slugify(chapter.Title()); slug != "" {
+			name += "-" + slug
+		}
+		if c.writePicture(*outputDir, name, *picture) {
+			written++
+		}
+	}
+
+	fmt.Fprintf(c.stdout, "Done! Extracted %d images to '%s'\n", written, *outputDir)
+	return 0
+}
+
+// writePicture writes a picture to dir/name with an extension matching its MIME type and reports the result
+func (c *cli) writePicture(dir string, name string, picture id3tag.Picture) bool {
+	if picture.MimeType == "-->" {
+		return false // Linked picture without data
+	}
+	ext, ok := pictureExtensions[picture.MimeType]
+	if !ok {
+		ext = ".bin"
+	}
+
+	path := filepath.Join(dir, name+ext)
+	if err := os.WriteFile(path, picture.Data, 0644); err != nil {
+		fmt.Fprintf(c.stderr, "Warning: Failed to write '%s': %v\n", path, err)
+		return false
+	}
+	fmt.Fprintf(c.stdout, "%s (%s, %d bytes)\n", path, picture.MimeType, len(picture.Data))
+	return true
+}
@@ -0,0 +1,145 @@
+package auditionmarker
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/config"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/exporter"
+)
+
+// sampleMarkers are the markers of the example marker files written by init
+var sampleMarkers = []csvparser.MarkerEntry{
+	{Name: "Intro", StartTime: 0},
+	{Name: "News of the week", StartTime: 1*time.Minute + 30*time.Second + 500*time.Millisecond},
+	{Name: "Interview", StartTime: 12*time.Minute + 5*time.Second},
+	{Name: "Listener questions", StartTime: 38*time.Minute + 42*time.Second + 250*time.Millisecond},
+	{Name: "Outro", StartTime: 55*time.Minute + 10*time.Second},
+}
+
+// sampleConfig is the commented config file written by init
+const sampleConfig = `# Config file of audition-marker
+#
+# Pass this file with -config, or move it to the default location:
+#   %s
+#
+# Lines are "key = value" pairs under [section] headers. Lines starting with # or ; are comments;
+# comments cannot follow a value on the same line.
+
+# Custom compatibility presets, used with -preset <name>.
+# "base" is a built-in preset (apple, spotify, generic, audiobook) whose settings the other keys override:
+#   version         ID3v2 version: 2.3 or 2.4
+#   encoding        Text encoding: utf8 (ID3v2.4 only), utf16 or latin1
+#   end-times       Whether each chapter ends where the next one starts
+#   toc             Whether to write a table of contents (CTOC) frame
+#   chapter-titles  Whether to title untitled markers "Chapter 1", "Chapter 2", ...
+[preset.myplayer]
+base = apple
+description = "Example preset: ID3v2.3 without chapter end times"
+end-times = false
+
+# Publishers the chapters are sent to after tagging, used with -publish <name>.
+# {episode} is replaced with the -episode ID. Remove the # signs to enable a section.
+#
+# Podcasting 2.0 JSON chapters sent to an HTTP endpoint; method is POST, PUT or PATCH,
+# and token is sent as "Authorization: Bearer <token>":
+# [publish.myhost]
+# type = json
+# url = https://host.example.com/api/episodes/{episode}/chapters
+# method = PUT
+# token = xxxxxxxx
+#
+# Replace the chapters of an Auphonic production:
+# [publish.auphonic]
+# type = auphonic
+# token = xxxxxxxx
+# production = {episode}
+`
+
+// executeInit writes an example Audition marker CSV file, an example JSON chapters file and a commented config file
+func (c *cli) executeInit(args []string) int {
+	// Define init options
+	flags := flag.NewFlagSet("init", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	dir := flags.String("dir", ".", "Directory the example files are written to")
+	force := flags.Bool("force", false, "Overwrite example files that already exist")
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: %s init [-dir <directory>] [-force]\n\n", c.name)
+		fmt.Fprintf(c.stderr, "Writes example input files to start from: markers.csv in the layout Adobe Audition exports,\n")
+		fmt.Fprintf(c.stderr, "chapters.json in the Podcasting 2.0 JSON chapters format, and a commented %s.\n\n", config.DefaultFileName)
+		fmt.Fprintf(c.stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return parseErrorCode(err)
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(c.stderr, "Error: Unexpected argument '%s'\n", flags.Arg(0))
+		flags.Usage()
+		return 1
+	}
+
+	// Render the example files
+	files := []struct {
+		name        string
+		format      string // Export format of marker files (empty for the config file)
+		description string
+	}{
+		{"markers.csv", "audition", "Adobe Audition marker CSV (tab-separated)"},
+		{"chapters.json", "json", "Podcasting 2.0 JSON chapters"},
+		{config.DefaultFileName, "", "config file with example presets and publishers"},
+	}
+	contents := make([][]byte, len(files))
+	for i, file := range files {
+		if file.format == "" {
+			contents[i] = fmt.Appendf(nil, sampleConfig, config.DefaultPath())
+			continue
+		}
+		format, err := exporter.Lookup(file.format)
+		if err != nil {
+			fmt.Fprintln(c.stderr, "Error:", err)
+			return 1
+		}
+		var buf bytes.Buffer
+		if err := format.Write(&buf, sampleMarkers, exporter.Options{}); err != nil {
+			fmt.Fprintln(c.stderr, "Error:", err)
+			return 1
+		}
+		contents[i] = buf.Bytes()
+	}
+
+	// Refuse to overwrite files the user may have edited before anything is written
+	if !*force {
+		for _, file := range files {
+			if path := filepath.Join(*dir, file.name); fileExists(path) {
+				fmt.Fprintf(c.stderr, "Error: '%s' already exists; set -force to overwrite the example files\n", path)
+				return 1
+			}
+		}
+	}
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		fmt.Fprintf(c.stderr, "Error: Cannot create directory '%s': %v\n", *dir, err)
+		return 1
+	}
+	for i, file := range files {
+		path := filepath.Join(*dir, file.name)
+		if err := os.WriteFile(path, contents[i], 0644); err != nil {
+			fmt.Fprintf(c.stderr, "Error: Failed to write '%s': %v\n", path, err)
+			return 1
+		}
+		fmt.Fprintf(c.stdout, "Wrote %s: %s\n", path, file.description)
+	}
+
+	fmt.Fprintln(c.stdout)
+	fmt.Fprintln(c.stdout, "Next steps:")
+	fmt.Fprintf(c.stdout, "  Check the example markers:  %s doctor -csv %s\n", c.name, filepath.Join(*dir, "markers.csv"))
+	fmt.Fprintf(c.stdout, "  Add them to an episode:     %s -csv %s -input episode.mp3\n", c.name, filepath.Join(*dir, "markers.csv"))
+	fmt.Fprintf(c.stdout, "  With the example preset:    %s -csv %s -input episode.mp3 -config %s -preset myplayer\n",
+		c.name, filepath.Join(*dir, "chapters.json"), filepath.Join(*dir, config.DefaultFileName))
+	return 0
+}
@@ -0,0 +1,127 @@
+package auditionmarker
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+)
+
+// executeInspect lists every frame of the ID3v2 tag of an MP3 file
+func (c *cli) executeInspect(args []string) int {
+	// Define inspect options
+	flags := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	inputPath := flags.String("input", "", "Path to the MP3 file to inspect (required)")
+	dumpAll := flags.Bool("hex", false, "Show a hex dump of every frame, not only of frames that are not decoded")
+	hexLimit := flags.Int("hex-limit", 256, "Maximum number of bytes per hex dump (0 for no limit)")
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: %s inspect -input <MP3 file> [-hex] [-hex-limit <bytes>]\n\n", c.name)
+		fmt.Fprintf(c.stderr, "Lists the ID, size, flags and a decoded preview of every frame in the tag,\n")
+		fmt.Fprintf(c.stderr, "including the subframes of CHAP and CTOC frames, followed by trailing APE and ID3v1 tags.\n\n")
+		fmt.Fprintf(c.stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return parseErrorCode(err)
+	}
+
+	// Validate options
+	if *inputPath == "" {
+		fmt.Fprintln(c.stderr, "Error: input path is required")
+		flags.Usage()
+		return 1
+	}
+	if !fileExists(*inputPath) {
+		fmt.Fprintf(c.stderr, "Error: Input MP3 file '%s' not found\n", *inputPath)
+		return 1
+	}
+
+	// Read raw tag
+	tag, err := id3tag.ReadRawTag(*inputPath)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while reading tags: %v\n", err)
+		return 1
+	}
+	if tag == nil {
+		fmt.Fprintln(c.stdout, "No ID3v2 tag found.")
+	} else {
+		// Print frames
+		fmt.Fprintf(c.stdout, "ID3v2.%d tag, %d bytes, %d frames\n", tag.Version, tag.Size, len(tag.Frames)+len(tag.Skipped))
+		for _, frame := range tag.Frames {
+			c.printFrame(frame, tag.Version, "", *dumpAll, *hexLimit)
+			for _, subframe := range frame.Subframes(tag.Version) {
+				c.printFrame(subframe, tag.Version, "    ", *dumpAll, *hexLimit)
+			}
+		}
+		for _, frame := range tag.Skipped {
+			fmt.Fprintf(c.stdout, "%-4s  %7d bytes  (not loaded: the tag exceeds the read limit)\n", printableID(frame.ID), frame.Size)
+		}
+	}
+
+	// Print trailing tags
+	trailing, err := id3tag.ReadTrailingTags(*inputPath)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while reading trailing tags: %v\n", err)
+		return 1
+	}
+	c.printTrailingTags(trailing)
+	return 0
+}
+
+// printTrailingTags prints the ID3v1 and APE tags after the audio data
+func (c *cli) printTrailingTags(trailing *id3tag.TrailingTags) {
+	if ape := trailing.APE; ape != nil {
+		fmt.Fprintf(c.stdout, "APEv%d tag at offset %d, %d bytes, %d items\n", ape.Version/1000, ape.Offset, ape.Size, len(ape.Items))
+		for _, item := range ape.Items {
+			if item.Binary {
+				fmt.Fprintf(c.stdout, "    %s  (binary)\n", item.Key)
+			} else {
+				fmt.Fprintf(c.stdout, "    %s  %q\n", item.Key, item.Value)
+			}
+		}
+	}
+	if v1 := trailing.ID3v1; v1 != nil {
+		name := "ID3v1"
+		if v1.Track > 0 {
+			name = "ID3v1.1"
+		}
+		fmt.Fprintf(c.stdout, "%s tag at offset %d, 128 bytes\n", name, v1.Offset)
+		fmt.Fprintf(c.stdout, "    title %q, artist %q, album %q, year %q\n", v1.Title, v1.Artist, v1.Album, v1.Year)
+		fmt.Fprintf(c.stdout, "    comment %q, track %d, genre %d\n", v1.Comment, v1.Track, v1.Genre)
+	}
+}
+
+// printFrame prints a frame header line with its preview, followed by a hex dump if requested or not decoded
+func (c *cli) printFrame(frame id3tag.RawFrame, version byte, indent string, dumpAll bool, hexLimit int) {
+	preview := frame.Preview(version)
+	fmt.Fprintf(c.stdout, "%s%-4s  %7d bytes  flags %04x  %s\n", indent, printableID(frame.ID), len(frame.Body), frame.Flags, preview)
+	if preview != "" && !dumpAll {
+		return
+	}
+
+	data := frame.Body
+	if hexLimit > 0 && len(data) > hexLimit {
+		data = data[:hexLimit]
+	}
+	for _, line := range strings.Split(strings.TrimRight(hex.Dump(data), "\n"), "\n") {
+		if line != "" {
+			fmt.Fprintf(c.stdout, "%s      %s\n", indent, line)
+		}
+	}
+	if len(data) < len(frame.Body) {
+		fmt.Fprintf(c.stdout, "%s      ... %d more bytes\n", indent, len(frame.Body)-len(data))
+	}
+}
+
+// printableID replaces unprintable characters of a frame ID, which only occur in corrupt tags
+func printableID(id string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7E {
+			return '.'
+		}
+		return r
+	}, id)
+}
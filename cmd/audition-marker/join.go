@@ -0,0 +1,175 @@
+package auditionmarker
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/mp3frame"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/safefile"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/storage"
+)
+
+// executeJoin concatenates MP3 parts and merges their chapters
+func (c *cli) executeJoin(args []string) int {
+	// Define join options
+	flags := flag.NewFlagSet("join", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	outputPath := flags.String("output", "", "Path or storage URL for the joined MP3 file (required)")
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: %s join -output <joined MP3> <part1.mp3> <part2.mp3> ...\n\n", c.name)
+		fmt.Fprintf(c.stderr, "Chapters of each part are taken from a CSV file with the same base name (part1.csv)\n")
+		fmt.Fprintf(c.stderr, "if one exists, otherwise from the chapters embedded in the part.\n")
+		fmt.Fprintf(c.stderr, "Parts and the output may be storage URLs, e.g. s3://bucket/part1.mp3.\n\n")
+		fmt.Fprintf(c.stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return parseErrorCode(err)
+	}
+	parts := flags.Args()
+
+	// Validate options
+	if *outputPath == "" || len(parts) < 2 {
+		fmt.Fprintln(c.stderr, "Error: output path and at least two input parts are required")
+		flags.Usage()
+		return 1
+	}
+	if !isMP3File(*outputPath) {
+		fmt.Fprintf(c.stderr, "Error: Output file '%s' does not have MP3 extension\n", *outputPath)
+		return 1
+	}
+	*outputPath = localName(*outputPath)
+	for i, part := range parts {
+		parts[i] = localName(part)
+		if parts[i] == *outputPath {
+			fmt.Fprintf(c.stderr, "Error: Output file '%s' is also an input part\n", part)
+			return 1
+		}
+	}
+
+	// Remote parts and outputs, such as s3://bucket/key, are joined in a local directory
+	defer c.removeStagingDir()
+	for i, part := range parts {
+		if !storage.IsRemote(part) {
+			continue
+		}
+		local, err := c.stagePart(part, i)
+		if err != nil {
+			fmt.Fprintln(c.stderr, "Error:", err)
+			return 1
+		}
+		parts[i] = local
+	}
+	output := *outputPath
+	if storage.IsRemote(output) {
+		dir, err := c.stagingDirectory()
+		if err == nil {
+			output = filepath.Join(dir, "output", filepath.Base(output))
+			err = os.MkdirAll(filepath.Dir(output), 0755)
+		}
+		if err != nil {
+			fmt.Fprintln(c.stderr, "Error:", err)
+			return 1
+		}
+	}
+	for _, part := range parts {
+		if !fileExists(part) {
+			fmt.Fprintf(c.stderr, "Error: Input MP3 file '%s' not found\n", part)
+			return 1
+		}
+	}
+
+	// Load chapters of each part before writing anything
+	partMarkers := make([][]csvparser.MarkerEntry, len(parts))
+	for i, part := range parts {
+		markers, err := loadPartMarkers(part)
+		if err != nil {
+			fmt.Fprintf(c.stderr, "Error occurred while loading chapters of '%s': %v\n", part, err)
+			return 1
+		}
+		partMarkers[i] = markers
+	}
+
+	// Concatenate audio into a temporary file
+	fmt.Fprintf(c.stdout, "Joining %d parts...\n", len(parts))
+	joinedPath, err := safefile.TempPath(output)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while joining parts: %v\n", err)
+		return 1
+	}
+	starts, err := concatParts(parts, joinedPath)
+	if err != nil {
+		os.Remove(joinedPath)
+		fmt.Fprintf(c.stderr, "Error occurred while joining parts: %v\n", err)
+		return 1
+	}
+
+	// Offset chapters by the start time of their part
+	var markers []csvparser.MarkerEntry
+	for i, partMarker := range partMarkers {
+		fmt.Fprintf(c.stdout, "Part %d starts at %s (%d chapters)\n", i+1, id3tag.FormatDuration(starts[i]), len(partMarker))
+		for _, marker := range partMarker {
+			marker.StartTime += starts[i]
+			markers = append(markers, marker)
+		}
+	}
+
+	// Add merged chapters
+	fmt.Fprintln(c.stdout, "Adding chapter tags to MP3 file...")
+	err = id3tag.AddChaptersWithOptions(joinedPath, markers, output, id3tag.Options{Prompt: c.prompter()})
+	os.Remove(joinedPath)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while adding chapter tags: %v\n", err)
+		return 1
+	}
+
+	c.showSuccessMessage(*outputPath)
+	if err := c.verifyAndShowChapters(output, markers, 0, nil, listingOptions{}); err != nil {
+		fmt.Fprintf(c.stderr, "Error: Verification of the output file failed: %v\n", err)
+		return exitVerificationFailed
+	}
+	if output != *outputPath {
+		if err := storage.Upload(output, *outputPath); err != nil {
+			fmt.Fprintln(c.stderr, "Error:", err)
+			return 1
+		}
+		fmt.Fprintf(c.stdout, "Uploaded '%s'\n", *outputPath)
+	}
+	return 0
+}
+
+// loadPartMarkers loads the chapters of a part from a CSV file next to it, or from its embedded chapters
+func loadPartMarkers(partPath string) ([]csvparser.MarkerEntry, error) {
+	csvPath := strings.TrimSuffix(partPath, filepath.Ext(partPath)) + ".csv"
+	if fileExists(csvPath) {
+		return csvparser.ParseAuditionCSV(csvPath)
+	}
+	return readMarkersFromMP3(partPath)
+}
+
+// concatParts writes the joined audio of all parts to path and returns the start time of each part
+func concatParts(parts []string, path string) ([]time.Duration, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create temporary file: %w", err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	starts, err := mp3frame.Concat(writer, parts, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := writer.Flush(); err != nil {
+		return nil, fmt.Errorf("Failed to write joined file: %w", err)
+	}
+
+	return starts, nil
+}
@@ -0,0 +1,82 @@
+package auditionmarker
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+)
+
+// executeLint checks the chapter structure of an MP3 file and exits with a nonzero code on errors
+func (c *cli) executeLint(args []string) int {
+	// Define lint options
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	inputPath := flags.String("input", "", "Path to the MP3 file to check (required)")
+	format := flags.String("format", "text", "Output format: text or json")
+	strict := flags.Bool("strict", false, "Also exit with a nonzero code on warnings")
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: %s lint -input <MP3 file> [-format text|json] [-strict]\n\n", c.name)
+		fmt.Fprintf(c.stderr, "Checks CHAP and CTOC frames against the ID3v2 Chapter Addendum.\n")
+		fmt.Fprintf(c.stderr, "Exits with code 1 if errors (or with -strict, warnings) are found.\n\n")
+		fmt.Fprintf(c.stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return parseErrorCode(err)
+	}
+
+	// Validate options
+	if *inputPath == "" {
+		fmt.Fprintln(c.stderr, "Error: input path is required")
+		flags.Usage()
+		return 1
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(c.stderr, "Error: Unknown output format '%s'\n", *format)
+		return 1
+	}
+	if !fileExists(*inputPath) {
+		fmt.Fprintf(c.stderr, "Error: Input MP3 file '%s' not found\n", *inputPath)
+		return 1
+	}
+
+	// Check chapters
+	findings, err := id3tag.Lint(*inputPath)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while reading tags: %v\n", err)
+		return 1
+	}
+	counts := make(map[id3tag.Severity]int)
+	for _, finding := range findings {
+		counts[finding.Severity]++
+	}
+
+	// Print findings
+	if *format == "json" {
+		encoder := json.NewEncoder(c.stdout)
+		encoder.SetIndent("", "  ")
+		if findings == nil {
+			findings = []id3tag.Finding{}
+		}
+		encoder.Encode(findings)
+	} else {
+		writer := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+		for _, finding := range findings {
+			elementID := finding.ElementID
+			if elementID == "" {
+				elementID = "-"
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", finding.Severity, finding.Code, elementID, finding.Message)
+		}
+		writer.Flush()
+		fmt.Fprintf(c.stdout, "%d errors, %d warnings in '%s'\n", counts[id3tag.SeverityError], counts[id3tag.SeverityWarning], *inputPath)
+	}
+
+	if counts[id3tag.SeverityError] > 0 || (*strict && counts[id3tag.SeverityWarning] > 0) {
+		return 1
+	}
+	return 0
+}
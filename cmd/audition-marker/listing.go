@@ -0,0 +1,110 @@
+package auditionmarker
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+)
+
+// listingTimeFormats are the time formats of chapter tables: M:SS.mmm as elsewhere, zero-padded HH:MM:SS.mmm, decimal seconds and milliseconds
+var listingTimeFormats = []string{"clock", "hh:mm:ss.mmm", "seconds", "ms"}
+
+// listingLayouts are the layouts of chapter tables: an aligned text table, or tab- or comma-separated values for spreadsheets
+var listingLayouts = []string{"text", "tsv", "csv"}
+
+// listingOptions controls how chapter tables are printed; the zero value prints the text table with clock times
+type listingOptions struct {
+	TimeFormat string // One of listingTimeFormats (empty for clock)
+	Layout     string // One of listingLayouts (empty for text)
+}
+
+// parseListingOptions checks the -time-format and -table options
+func parseListingOptions(timeFormat, layout string) (listingOptions, error) {
+	if !slices.Contains(listingTimeFormats, timeFormat) {
+		return listingOptions{}, fmt.Errorf("Invalid -time-format '%s': use %s", timeFormat, strings.Join(listingTimeFormats, ", "))
+	}
+	if !slices.Contains(listingLayouts, layout) {
+		return listingOptions{}, fmt.Errorf("Invalid -table '%s': use %s", layout, strings.Join(listingLayouts, ", "))
+	}
+	return listingOptions{TimeFormat: timeFormat, Layout: layout}, nil
+}
+
+// formatTime formats a chapter time in the time format of the options
+func (options listingOptions) formatTime(d time.Duration) string {
+	switch options.TimeFormat {
+	case "hh:mm:ss.mmm":
+		ms := d.Milliseconds()
+		return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+	case "seconds":
+		return strconv.FormatFloat(float64(d.Milliseconds())/1000, 'f', 3, 64)
+	case "ms":
+		return strconv.FormatInt(d.Milliseconds(), 10)
+	}
+	return id3tag.FormatDuration(d)
+}
+
+// chapterEnd returns the end of chapter i: the start of the next chapter, or the audio length for the last one (0 if unknown)
+func chapterEnd(chapters []id3tag.Chapter, i int, duration time.Duration) time.Duration {
+	if i+1 < len(chapters) {
+		return chapters[i+1].StartTime
+	}
+	if duration > chapters[i].StartTime {
+		return duration
+	}
+	return 0
+}
+
+// chapterTableHeader is the header row of chapter tables written as separated values
+var chapterTableHeader = []string{"number", "start", "end", "duration", "title"}
+
+// chapterRows returns the table rows of chapters with their end times and durations, computed from the next chapter's start and
+// for the last chapter from the audio length (left empty if unknown)
+func chapterRows(chapters []id3tag.Chapter, duration time.Duration, options listingOptions) [][]string {
+	rows := make([][]string, 0, len(chapters))
+	for i, chapter := range chapters {
+		end, length := "", ""
+		if endTime := chapterEnd(chapters, i, duration); endTime > 0 {
+			end, length = options.formatTime(endTime), options.formatTime(endTime-chapter.StartTime)
+		}
+		rows = append(rows, []string{strconv.Itoa(i + 1), options.formatTime(chapter.StartTime), end, length, chapter.Title})
+	}
+	return rows
+}
+
+// printChapterTable prints chapters as a numbered table, or as separated values with a header row for the tsv and csv layouts
+func (c *cli) printChapterTable(chapters []id3tag.Chapter, duration time.Duration, options listingOptions) {
+	rows := chapterRows(chapters, duration, options)
+	if options.Layout == "tsv" || options.Layout == "csv" {
+		options.writeSeparated(c.stdout, chapterTableHeader, rows)
+		return
+	}
+
+	fmt.Fprintln(c.stdout, "--------------------------------------------------------------------------------")
+	fmt.Fprintf(c.stdout, "%-4s | %-12s | %-12s | %-12s | %s\n", "No.", "Start Time", "End Time", "Duration", "Title")
+	fmt.Fprintln(c.stdout, "--------------------------------------------------------------------------------")
+	for _, row := range rows {
+		for i := 2; i <= 3; i++ {
+			if row[i] == "" {
+				row[i] = "-"
+			}
+		}
+		fmt.Fprintf(c.stdout, "%-4s | %-12s | %-12s | %-12s | %s\n", row[0], row[1], row[2], row[3], row[4])
+	}
+	fmt.Fprintln(c.stdout, "--------------------------------------------------------------------------------")
+}
+
+// writeSeparated writes a header row and rows as CSV, or as TSV for the tsv layout
+func (options listingOptions) writeSeparated(w io.Writer, header []string, rows [][]string) {
+	writer := csv.NewWriter(w)
+	if options.Layout == "tsv" {
+		writer.Comma = '\t'
+	}
+	writer.Write(header)
+	writer.WriteAll(rows)
+}
@@ -1,79 +1,811 @@
 package auditionmarker
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/artwork"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/audit"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/chapterdiff"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/checksum"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/config"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/container"
 	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/exporter"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/ffmpeg"
 	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/importer"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/pipeline"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/preset"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/prompt"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/remote"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/report"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/safefile"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/sidecar"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/termcolor"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/tracing"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/transcript"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/webhook"
 )
 
 // Config holds the application settings
 type Config struct {
-	CSVPath   string // Path to the marker CSV file
-	InputMP3  string // Path to the original MP3 file
-	OutputMP3 string // Path for the output MP3 with chapters
+	CSVPath    string        // Path to the marker CSV file (the first of CSVPaths)
+	CSVPaths   []string      // Paths of all marker files, whose markers are merged
+	InputMP3   string        // Path to the original MP3 file
+	OutputMP3  string        // Path for the output MP3 with chapters
+	PostHook   string        // Command executed after a file has been processed successfully
+	Webhook    string        // URL notified with a JSON payload after the file has been processed
+	FFmpeg     string        // Path or name of the ffmpeg binary used for containers without a native writer (empty to disable)
+	Snap       time.Duration // Window for snapping markers to the nearest silence (0 disables snapping)
+	Align      bool          // Whether to round marker start times to MP3 frame boundaries
+	Round      time.Duration // Grid marker times are rounded to, e.g. 1s (0 disables rounding)
+	Preset     string        // Name of the compatibility preset for the ID3 writer (empty for the defaults)
+	ConfigPath string        // Path to the config file (empty for the default location)
+	Sidecar    string        // Sidecar format written next to the input instead of tagging it (empty to tag the audio)
+	AlsoExport []string      // Export formats written next to the output from the same markers
+	Publish    []string      // Publishers from the config file the chapters are pushed to after tagging
+	Episode    string        // Episode ID passed to publishers (empty for the output file name)
+	Audit      bool          // Whether to write a JSON audit record next to the output
+	AuditLog   string        // NDJSON log audit records are appended to (empty for none)
+	Checksums  string        // Manifest the SHA-256 hashes of the output files are recorded in (empty for none)
+	Report     string        // Path of the JSON summary of the run for dashboards (empty for none)
+	SampleRate int           // Sample rate of markers given in samples (0 to use the CSV metadata or the input MP3)
+	Track      string        // Track of a multitrack session whose markers become chapters (empty for all markers)
+	ColumnMap  string        // Mapping of marker fields to the columns of a non-Audition CSV file (empty for Audition's columns)
+	Lenient    bool          // Whether to skip CSV rows that cannot be parsed with a warning instead of failing
+	MaxRows    int           // Largest number of CSV rows after the header row accepted (0 for no limit)
+	FrameRate  string        // Timecode frame rate of marker files that do not state one, such as EDL (empty if unknown)
+	Colors     []string      // Marker colors read from marker files that store one (empty for all markers)
+	Tempo      float64       // Tempo of bar positions in marker files, such as Logic's (0 if unknown)
+	TimeSig    string        // Time signature of bar positions in marker files (empty for 4/4)
+
+	OnConflict       string        // Strategy for markers of different sources at the same time with different titles
+	ConflictWindow   time.Duration // Largest start time difference of markers treated as the same position
+	replacedChapters []string      // Element IDs of existing chapters dropped in favor of markers, set while parsing
+
+	Number      bool // Whether to prefix chapter titles with their number, e.g. "01. Intro"
+	NumberStart int  // Number of the first chapter
+	NumberWidth int  // Digits chapter numbers are zero-padded to
+
+	MinGap time.Duration // Markers starting closer than this to the preceding chapter are merged into it (0 to keep all)
+
+	OverDrive bool // Whether to store the chapters as OverDrive MediaMarkers XML in a TXXX frame as well
+
+	MaxSpan   time.Duration // Stretch without a chapter start reported by the coverage check (0 for none)
+	FailOnGap bool          // Whether coverage problems abort the run instead of only being reported
+
+	VerifyTolerance time.Duration // Largest start time difference accepted when verifying the output
+	VerifyMode      string        // How the output is read back for verification: full or fast
+	ListTimeFormat  string        // Time format of the chapter table printed by the verification (clock, hh:mm:ss.mmm, seconds or ms)
+	ListLayout      string        // Layout of the chapter table printed by the verification (text, tsv or csv)
+	DryRun          bool          // Whether to only preview the tag size without writing any file
+	FollowSymlinks  bool          // Whether to edit the input in place through a symbolic link, editing the linked file
+	Strict          bool          // Whether any warning fails the run before the output is written or published
+	MaxTagSize      int64         // Tag size in bytes above which writing is aborted (0 for no limit)
+	Deterministic   bool          // Whether to write byte-identical output for identical inputs
+	Padding         int           // Padding bytes left after the tag for later in-place edits
+	TrailingTags    string        // Handling of trailing ID3v1 and APE tags: keep, strip or upgrade
+	RebuildTag      bool          // Whether to replace a corrupt existing tag instead of failing
+	NoTOC           bool          // Whether to write only CHAP frames without a table of contents (CTOC) frame
+	TOCTopLevel     bool          // Top-level flag of the CTOC frame
+	TOCOrdered      bool          // Ordered flag of the CTOC frame
+	TOCID           string        // Element ID of the CTOC frame
+	TOCTitle        string        // Title of the CTOC frame (empty for no title subframe)
+	PartDelimiter   string        // Delimiter between part and chapter title in marker names (empty to disable)
+	Merge           bool          // Whether to keep the existing chapters of the input
+	ChapterIDPrefix string        // Prefix of chapter element IDs
+	RenameIDs       bool          // Whether to make element IDs used by existing frames unique instead of failing
+
+	TitleCommand string        // Transcription command used to title untitled markers (empty to disable)
+	TitleWindow  time.Duration // Length of audio after each untitled marker passed to the transcription command
+
+	Transcript         string // Path to an SRT/WebVTT transcript stored in the tag (empty for none)
+	TranscriptSync     bool   // Whether to store the transcript as synchronized lyrics as well
+	TranscriptLanguage string // ISO 639-2 language code of the transcript
+
+	ChapterImages    string // Directory with per-chapter artwork (empty for none)
+	ChapterImageSize int    // Maximum width and height of chapter artwork in pixels
+	ImageFormat      string // Encoding of embedded artwork (jpeg or png)
+	ImageQuality     int    // JPEG quality of embedded artwork
+	ImageBudget      int64  // Total artwork size in bytes above which a warning is shown (0 for no limit)
+	ImageBudgetError bool   // Whether exceeding the artwork budget is an error instead of a warning
+
+	TitleCards        bool   // Whether to generate title card artwork for chapters without an image
+	TitleCardColor    string // Background color of title cards (#RRGGBB)
+	TitleCardTemplate string // Background image of title cards (empty for a plain color)
+
+	OTLPEndpoint string // OTLP/HTTP endpoint trace spans of the run are sent to (empty for the OTEL_EXPORTER_OTLP_* variables)
+
+	remoteOutput string          // Storage URL the output and the files next to it are uploaded to, set while parsing (empty for local output)
+	tracer       *tracing.Tracer // Tracer of the run, set while parsing from -otlp-endpoint or the environment (nil without tracing)
+}
+
+// exitVerificationFailed is the exit code when the chapters read back from the output do not match the markers
+const exitVerificationFailed = 3
+
+// cli holds the standard streams of a command line run and the warnings reported so far
+type cli struct {
+	name   string        // Program name shown in usage and help output
+	stdin  io.Reader     // Standard input as passed to Run, handed to hooks
+	input  *bufio.Reader // Buffered standard input shared by all prompts, so no answer is lost in a discarded buffer
+	stdout io.Writer
+	stderr io.Writer
+
+	strict   bool           // Whether warnings fail the run (-strict)
+	warnings []string       // Warnings reported during the run
+	report   *report.Report // Summary of the main command written with -report (nil if not requested)
+
+	trace *tracing.Span // Root span of the main command (nil without tracing)
+	phase *tracing.Span // Span of the current phase of the main command (nil without tracing)
+
+	stagingDir string       // Temporary directory holding local copies of remote files (empty if there are none)
+	ffmpeg     *ffmpeg.Tool // Writer and reader of containers without a native chapter writer (nil unless -ffmpeg is set)
 }
 
-// Execute runs the main application logic
+// subcommands maps subcommand names to their entry points
+var subcommands = map[string]func(c *cli, args []string) int{
+	"serve":            (*cli).executeServe,
+	"feed":             (*cli).executeFeed,
+	"export":           (*cli).executeExport,
+	"join":             (*cli).executeJoin,
+	"detect":           (*cli).executeDetect,
+	"transcript":       (*cli).executeTranscript,
+	"extract-images":   (*cli).executeExtractImages,
+	"lint":             (*cli).executeLint,
+	"repair":           (*cli).executeRepair,
+	"diff":             (*cli).executeDiff,
+	"stats":            (*cli).executeStats,
+	"inspect":          (*cli).executeInspect,
+	"read":             (*cli).executeRead,
+	"apply-sidecar":    (*cli).executeApplySidecar,
+	"convert":          (*cli).executeConvert,
+	"add":              (*cli).executeAdd,
+	"edit":             (*cli).executeEdit,
+	"remove":           (*cli).executeRemove,
+	"set-image":        (*cli).executeSetImage,
+	"set-url":          (*cli).executeSetURL,
+	"auphonic":         (*cli).executeAuphonic,
+	"verify-checksums": (*cli).executeVerifyChecksums,
+	"doctor":           (*cli).executeDoctor,
+	"init":             (*cli).executeInit,
+}
+
+// Execute runs the application with the arguments and standard streams of the process and exits with its exit code
 func Execute() {
+	os.Exit(run(os.Args[0], os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// Run runs the application with the given arguments (without the program name) and streams and returns the exit code.
+// Confirmation prompts are written to stdout and answered from stdin.
+// Usage and help output name the program "audition-marker".
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	return run("audition-marker", args, stdin, stdout, stderr)
+}
+
+// run runs the application as Run does, naming the program name in usage and help output
+func run(name string, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	// -no-color applies to all subcommands, so it is taken out before their options are parsed
+	color := true
+	var rest []string
+	for i, arg := range args {
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		if arg == "-no-color" || arg == "--no-color" {
+			color = false
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	args = rest
+	if color && termcolor.Enabled(stdout) {
+		stdout = termcolor.NewWriter(stdout, outputColors...)
+	}
+	if color && termcolor.Enabled(stderr) {
+		stderr = termcolor.NewWriter(stderr, outputColors...)
+	}
+	c := &cli{name: name, stdin: stdin, input: bufio.NewReader(stdin), stdout: stdout, stderr: stderr}
+
+	// Dispatch to a subcommand if one is given
+	if len(args) > 0 {
+		if run, ok := subcommands[args[0]]; ok {
+			return run(c, args[1:])
+		}
+	}
+	return c.execute(args)
+}
+
+// outputColors color errors, warnings, verification results and diff lines on terminals
+var outputColors = []termcolor.Rule{
+	termcolor.Prefix(termcolor.Red, "Error", "Mismatch:", "- removed"),
+	termcolor.Prefix(termcolor.Yellow, "Warning", "~ retimed", "~ renamed"),
+	termcolor.Prefix(termcolor.Green, "+ added", "Done!", "Verified:"),
+	{Color: termcolor.Red, Match: func(line string) bool { return severityColumn(line, "error") || strings.Contains(line, ": FAILED (") }},
+	{Color: termcolor.Yellow, Match: func(line string) bool { return severityColumn(line, "warning") }},
+}
+
+// severityColumn reports whether a line is a lint finding of the severity, whose first column tabwriter may write on its own
+func severityColumn(line, severity string) bool {
+	rest, found := strings.CutPrefix(line, severity)
+	return found && (rest == "" || rest[0] == ' ')
+}
+
+// warnf prints a warning to w and records it
+func (c *cli) warnf(w io.Writer, format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	fmt.Fprintf(w, "Warning: %s\n", message)
+	c.recordWarning(message)
+}
+
+// cliReporter prints the progress of the marker pipeline to the standard output of the run and records its warnings
+type cliReporter struct {
+	c *cli
+}
+
+func (r cliReporter) Printf(format string, args ...any) {
+	fmt.Fprintf(r.c.stdout, format+"\n", args...)
+}
+func (r cliReporter) Warnf(format string, args ...any) { r.c.warnf(r.c.stdout, format, args...) }
+func (r cliReporter) Record(message string)            { r.c.recordWarning(message) }
+
+// recordWarning records a warning, or an event that -strict treats as one, such as merged markers, which is printed by the caller
+func (c *cli) recordWarning(message string) {
+	c.warnings = append(c.warnings, message)
+}
+
+// strictError returns the error failing a -strict run once warnings were reported, or nil
+func (c *cli) strictError() error {
+	if !c.strict || len(c.warnings) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d warnings reported with -strict", len(c.warnings))
+}
+
+// parseErrorCode returns the exit code for an error from parsing options: 0 for -help, otherwise 2
+func parseErrorCode(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	return 2
+}
+
+// execute adds chapters from a marker CSV file to an audio file
+func (c *cli) execute(args []string) int {
 	// Parse and validate command line arguments
-	config, err := parseAndValidateArgs()
+	flags := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	defer c.removeStagingDir()
+	config, err := c.parseAndValidateArgs(flags, args)
+	var parseErr *flagParseError
+	if errors.As(err, &parseErr) {
+		return parseErrorCode(parseErr.err)
+	}
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error:", err)
-		flag.Usage()
-		os.Exit(1)
+		fmt.Fprintln(c.stderr, "Error:", err)
+		flags.Usage()
+		return 1
 	}
+	c.strict = config.Strict
+	if config.Report != "" {
+		tool, version := audit.ToolVersion()
+		c.report = report.New(tool, version, "add-chapters")
+	}
+	c.startTrace(config)
+	code := c.process(config)
+	c.endTrace(config, code)
+	return c.writeReport(config, code)
+}
 
-	// Parse markers from CSV file
-	fmt.Printf("Parsing CSV file '%s'...\n", config.CSVPath)
-	markers, err := csvparser.ParseAuditionCSV(config.CSVPath)
+// process runs the steps of the main command with the parsed options and returns the exit code
+func (c *cli) process(config *Config) int {
+	// Check the ffmpeg fallback before doing any work
+	c.startPhase("prepare")
+	if config.FFmpeg != "" {
+		tool, err := ffmpeg.Detect(config.FFmpeg)
+		if err != nil {
+			fmt.Fprintln(c.stderr, "Error:", err)
+			c.fail(err)
+			return 1
+		}
+		fmt.Fprintf(c.stdout, "Using %s for containers without a native chapter writer\n", tool.Version)
+		c.ffmpeg = tool
+	}
+
+	started := time.Now()
+
+	// Hash the input before it can be overwritten if an audit record is requested
+	record, err := c.startAudit(config)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error occurred while parsing CSV: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(c.stderr, "Error occurred while preparing the audit record: %v\n", err)
+		c.fail(err)
+		return 1
 	}
+	c.report.Phase("prepare")
+	c.startPhase("parse")
 
-	// Display marker information
-	showMarkerInfo(markers)
+	// Parse markers from CSV file
+	markers, err := c.parseSessionMarkers(config)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while parsing CSV: %v\n", err)
+		c.finishRun(config, "", 0, started, err)
+		return 1
+	}
+	c.report.AddFile(config.InputMP3, report.RoleInput)
+	for _, path := range config.CSVPaths {
+		c.report.AddFile(path, report.RoleMarkers)
+	}
+	c.report.SetMarkers(len(markers))
+	c.report.Phase("parse")
+	c.phase.SetAttribute("audition_marker.markers", len(markers))
+	c.startPhase("transform")
 
-	// Add chapter tags to MP3 file
-	fmt.Println("Adding chapter tags to MP3 file...")
-	err = id3tag.AddChapters(config.InputMP3, markers, config.OutputMP3)
+	// Apply the transforms of the marker pipeline; the preset may title untitled markers "Chapter N"
+	options := c.pipelineOptions(config)
+	if config.Preset != "" {
+		p, err := loadPreset(config.Preset, config.ConfigPath)
+		if err != nil {
+			fmt.Fprintf(c.stderr, "Error occurred while loading preset: %v\n", err)
+			c.finishRun(config, "", len(markers), started, err)
+			return 1
+		}
+		options.ChapterTitles = p.ChapterTitles
+	}
+	markers, err = pipeline.Transform(context.Background(), markers, config.InputMP3, options)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error occurred while adding chapter tags: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(c.stderr, "Error occurred while transforming markers: %v\n", err)
+		c.finishRun(config, "", len(markers), started, err)
+		return 1
+	}
+
+	// Check that the chapters cover the audio if requested
+	if config.MaxSpan > 0 || config.FailOnGap {
+		if err := c.checkCoverage(config, markers); err != nil {
+			fmt.Fprintf(c.stderr, "Error occurred while checking chapter coverage: %v\n", err)
+			c.finishRun(config, "", len(markers), started, err)
+			return 1
+		}
 	}
 
-	// Determine output file path
-	targetFile := determineOutputPath(config.InputMP3, config.OutputMP3)
+	c.report.Phase("transform")
+	c.startPhase("write")
+
+	var targetFile string
+	var written *id3tag.WrittenChapters // Chapters as encoded by the writer, recorded with -verify-mode fast
+	expected := markers                 // Chapters expected in the output
+	if config.Sidecar != "" {
+		if err := c.strictError(); err != nil {
+			fmt.Fprintln(c.stderr, "Error:", err)
+			c.finishRun(config, "", len(markers), started, err)
+			return 1
+		}
+
+		// Write chapters to a sidecar file, leaving the audio file untouched
+		fmt.Fprintln(c.stdout, "Writing chapters to sidecar file...")
+		targetFile, err = writeSidecar(config.InputMP3, config.Sidecar, markers)
+		if err != nil {
+			fmt.Fprintf(c.stderr, "Error occurred while writing sidecar file: %v\n", err)
+			c.finishRun(config, "", len(markers), started, err)
+			return 1
+		}
+		fmt.Fprintf(c.stdout, "Done! Chapters have been saved to '%s' (the audio file was not modified)\n", targetFile)
+	} else {
+		// Load transcript and chapter artwork if requested
+		tagOptions, err := c.loadTagOptions(config, markers)
+		if err != nil {
+			fmt.Fprintf(c.stderr, "Error occurred while loading tag content: %v\n", err)
+			c.finishRun(config, "", len(markers), started, err)
+			return 1
+		}
+
+		// Check the existing tag and preview the tag size before anything is written or confirmed
+		if !isOggFile(config.InputMP3) && !needsFFmpeg(c.ffmpeg, config.InputMP3) {
+			if err := c.checkExistingTag(config); err != nil {
+				fmt.Fprintf(c.stderr, "Error occurred while reading the existing tag: %v\n", err)
+				c.finishRun(config, "", len(markers), started, err)
+				return 1
+			}
+			if err := c.previewTagSize(config, markers, tagOptions); err != nil {
+				fmt.Fprintf(c.stderr, "Error occurred while previewing tag size: %v\n", err)
+				c.finishRun(config, "", len(markers), started, err)
+				return 1
+			}
+		}
+		if err := c.strictError(); err != nil {
+			fmt.Fprintln(c.stderr, "Error:", err)
+			c.finishRun(config, "", len(markers), started, err)
+			return 1
+		}
+		if config.DryRun {
+			fmt.Fprintln(c.stdout, "Dry run: no files were written")
+			c.finishRun(config, "", chapterCount(markers), started, nil)
+			return c.strictExitCode()
+		}
+
+		// Existing chapters remain in the output when merging
+		if config.Merge {
+			chapters, err := readChapters(c.ffmpeg, config.InputMP3)
+			if err != nil {
+				fmt.Fprintf(c.stderr, "Error occurred while reading existing chapters: %v\n", err)
+				c.finishRun(config, "", len(markers), started, err)
+				return 1
+			}
+			chapters = keptChapters(chapters, config.replacedChapters)
+			fmt.Fprintf(c.stdout, "Merging with %d existing chapters\n", len(chapters))
+			expected = mergeMarkers(chapters, markers)
+		}
+
+		// Add chapter tags to audio file, recording what is written for fast verification
+		fmt.Fprintln(c.stdout, "Adding chapter tags to audio file...")
+		tagOptions.Trace = c.phase
+		if config.VerifyMode == verifyFast && !isOggFile(config.InputMP3) && !needsFFmpeg(c.ffmpeg, config.InputMP3) {
+			written = &id3tag.WrittenChapters{}
+			tagOptions.Written = written
+		}
+		err = addChapters(c.ffmpeg, config.InputMP3, markers, config.OutputMP3, tagOptions)
+		if err != nil {
+			fmt.Fprintf(c.stderr, "Error occurred while adding chapter tags: %v\n", err)
+			c.finishRun(config, "", len(markers), started, err)
+			return 1
+		}
+
+		// Determine output file path
+		targetFile = determineOutputPath(config.InputMP3, config.OutputMP3)
 
-	// Display success message
-	showSuccessMessage(targetFile)
+		// Display success message
+		c.showSuccessMessage(targetFile)
+	}
+	chapters := len(expected) // Sidecar files keep markers with empty names
+	if config.Sidecar == "" {
+		chapters = chapterCount(expected)
+	}
+	c.report.AddFile(targetFile, report.RoleOutput)
+	c.report.SetChapters(chapters)
+	c.report.Phase("write")
+	c.trace.SetAttribute("audition_marker.output", targetFile)
+	c.trace.SetAttribute("audition_marker.chapters", chapters)
+	c.startPhase("verify")
 
 	// Verify and display chapters from output file
-	verifyAndShowChapters(targetFile)
+	if err := c.verifyAndShowChapters(targetFile, expected, config.VerifyTolerance, written, listingOptions{TimeFormat: config.ListTimeFormat, Layout: config.ListLayout}); err != nil {
+		fmt.Fprintf(c.stderr, "Error: Verification of the output file failed: %v\n", err)
+		c.finishAudit(config, record, targetFile, chapters, err)
+		c.finishRun(config, targetFile, chapters, started, err)
+		return exitVerificationFailed
+	}
+	c.report.Phase("verify")
+	c.startPhase("export")
+	outputs := []string{targetFile}
+	if auditPath := c.finishAudit(config, record, targetFile, chapters, nil); auditPath != "" {
+		outputs = append(outputs, auditPath)
+		c.report.AddFile(auditPath, report.RoleAudit)
+	}
+
+	// Write additional exports next to the tagged file, or next to the input in sidecar mode
+	if len(config.AlsoExport) > 0 {
+		audioPath := targetFile
+		if config.Sidecar != "" {
+			audioPath = config.InputMP3
+		}
+		exported, err := c.writeAlsoExports(config.AlsoExport, audioPath, config.CSVPath, markers)
+		if err != nil {
+			fmt.Fprintf(c.stderr, "Error occurred while exporting chapters: %v\n", err)
+			c.finishRun(config, targetFile, chapters, started, err)
+			return 1
+		}
+		outputs = append(outputs, exported...)
+		for _, path := range exported {
+			c.report.AddFile(path, report.RoleExport)
+		}
+	}
+
+	// Record the hashes of all output files if requested
+	if config.Checksums != "" {
+		if err := checksum.Update(config.Checksums, outputs); err != nil {
+			fmt.Fprintf(c.stderr, "Error occurred while writing checksums: %v\n", err)
+			c.finishRun(config, targetFile, chapters, started, err)
+			return 1
+		}
+		fmt.Fprintf(c.stdout, "Recorded checksums of %d files in '%s'\n", len(outputs), config.Checksums)
+		c.report.AddFile(config.Checksums, report.RoleChecksums)
+	}
+	c.report.Phase("export")
+
+	// Warnings while writing, such as a failed audit record, keep the chapters from being published
+	if err := c.strictError(); err != nil {
+		fmt.Fprintln(c.stderr, "Error:", err)
+		c.finishRun(config, targetFile, chapters, started, err)
+		return 1
+	}
+
+	// Upload the output and the files written next to it to remote storage
+	if config.remoteOutput != "" {
+		c.startPhase("upload")
+		if err := c.uploadOutputs(config, outputs); err != nil {
+			fmt.Fprintf(c.stderr, "Error occurred while uploading the output: %v\n", err)
+			c.finishRun(config, targetFile, chapters, started, err)
+			return 1
+		}
+		c.report.Phase("upload")
+	}
+
+	// Push chapters to hosting platforms; in sidecar mode the audio is published unchanged
+	if len(config.Publish) > 0 {
+		c.startPhase("publish")
+		audioPath := targetFile
+		if config.Sidecar != "" {
+			audioPath = config.InputMP3
+		}
+		if err := c.publishChapters(config, audioPath, expected); err != nil {
+			fmt.Fprintf(c.stderr, "Error occurred while publishing chapters: %v\n", err)
+			c.finishRun(config, targetFile, chapters, started, err)
+			return 1
+		}
+		c.report.Phase("publish")
+	}
+
+	// Run post-processing hook if configured
+	if config.PostHook != "" {
+		c.startPhase("post-hook")
+		if err := c.runPostHook(config.PostHook, config, targetFile, chapters); err != nil {
+			fmt.Fprintf(c.stderr, "Error occurred while running post-hook: %v\n", err)
+			c.finishRun(config, targetFile, chapters, started, err)
+			return 1
+		}
+		c.report.Phase("post-hook")
+	}
+
+	// Notify webhook if configured, last so that it reports the final status
+	c.finishRun(config, targetFile, chapters, started, nil)
+	return c.strictExitCode()
+}
+
+// strictExitCode returns the exit code of a run that succeeded so far: 1 if -strict is set and warnings were reported, such as a failed webhook, otherwise 0
+func (c *cli) strictExitCode() int {
+	if err := c.strictError(); err != nil {
+		fmt.Fprintln(c.stderr, "Error:", err)
+		c.fail(err)
+		return 1
+	}
+	return 0
+}
+
+// chapterCount returns the number of chapters tagged for markers, as the tag writers skip markers with empty names
+func chapterCount(markers []csvparser.MarkerEntry) int {
+	count := 0
+	for _, marker := range markers {
+		if strings.TrimSpace(marker.Name) != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// finishRun records the error ending the run, if any, in the run report and trace and notifies the webhook
+func (c *cli) finishRun(config *Config, outputPath string, chapterCount int, started time.Time, processErr error) {
+	c.fail(processErr)
+	c.notifyWebhook(config, outputPath, chapterCount, started, processErr)
+}
+
+// writeReport writes the run report requested with -report and returns the exit code of the run, or 1 if the report cannot be written
+func (c *cli) writeReport(config *Config, code int) int {
+	if c.report == nil {
+		return code
+	}
+	status := report.StatusFailed
+	switch {
+	case code == 0 && config.DryRun:
+		status = report.StatusDryRun
+	case code == 0:
+		status = report.StatusDone
+	case code == exitVerificationFailed:
+		status = report.StatusVerificationFailed
+	}
+	c.report.Finish(code, status, c.warnings)
+	if err := report.WriteFile(config.Report, c.report); err != nil {
+		fmt.Fprintln(c.stderr, "Error:", err)
+		if code == 0 {
+			return 1
+		}
+		return code
+	}
+	fmt.Fprintf(c.stdout, "Run report written to '%s'\n", config.Report)
+	return code
+}
+
+// flagParseError is returned by parseAndValidateArgs when the options cannot be parsed; the flag set has already reported it
+type flagParseError struct {
+	err error
+}
+
+func (e *flagParseError) Error() string {
+	return e.err.Error()
 }
 
 // parseAndValidateArgs parses and validates command line arguments
-func parseAndValidateArgs() (*Config, error) {
+func (c *cli) parseAndValidateArgs(flags *flag.FlagSet, args []string) (*Config, error) {
 	// Define command line options
-	csvPath := flag.String("csv", "", "Path to CSV file containing Adobe Audition markers (required)")
-	inputMP3 := flag.String("input", "", "Path to original MP3 file to add chapters to (required)")
-	outputMP3 := flag.String("output", "", "Path for output MP3 file with chapters (if not specified, will output as filename_with_chapters.mp3)")
+	var csvPaths listFlags
+	flags.Var(&csvPaths, "csv", "Path or HTTP(S) URL of CSV file containing Adobe Audition markers (required); repeat to merge the markers of several files, which may also be in other import formats ("+strings.Join(importer.Names(), ", ")+")")
+	inputMP3 := flags.String("input", "", "Path to original MP3 (or Ogg Opus/Vorbis) file to add chapters to (required); s3://bucket/key and HTTP(S) URLs are downloaded first")
+	outputMP3 := flags.String("output", "", "Path for output file with chapters (if not specified, will output as filename_with_chapters.mp3); s3://bucket/key and HTTP(S) URLs (PUT) are uploaded after the run")
+	followSymlinks := flags.Bool("follow-symlinks", false, followSymlinksUsage+" (with -output set to the input)")
+	ffmpegPath := flags.String("ffmpeg", "", "Path or name of an ffmpeg binary used to write chapters to inputs without a native writer, such as M4A, WAV or FLAC, and ffprobe next to it to verify them (default: no fallback)")
+	webhookURL := flags.String("webhook", "", "URL to POST a JSON notification to after the file has been processed")
+	snap := flags.Duration("snap", 0, "Shift each marker to the nearest silence within this window, e.g. 2s (MP3 only)")
+	align := flags.Bool("align", false, "Round each marker start to the nearest MP3 frame boundary and report the adjustment (MP3 only)")
+	minGap := flags.Duration("min-gap", 0, "Merge each marker starting within this time of the preceding chapter into it, e.g. 10s for noisy auto-generated markers, and list the merged markers")
+	round := flags.Duration("round", 0, "Round chapter start and end times to a grid, e.g. 1s or 500ms, as some publishing guidelines require")
+	overDrive := flags.Bool("overdrive", false, "Also store the chapters as OverDrive MediaMarkers XML in a TXXX frame, for library audiobook platforms (MP3 only)")
+	maxSpan := flags.Duration("max-span", 0, "Report stretches of audio longer than this without a chapter start, e.g. 15m, along with audio before the first and after the last chapter")
+	failOnGap := flags.Bool("fail-on-gap", false, "Abort before writing if the coverage check finds uncovered audio or a stretch longer than -max-span, for CI")
+	number := flags.Bool("number", false, "Prefix chapter titles with their number, e.g. '01. Intro', counted after the markers are merged and filtered")
+	numberStart := flags.Int("number-start", 1, "Number of the first chapter with -number")
+	numberWidth := flags.Int("number-width", 2, "Digits chapter numbers are zero-padded to with -number (0 or 1 for no padding)")
+	titleCommand := flags.String("title-command", "", "Transcription command for titling markers with Audition's default names (\"Marker 01\"); {audio} is replaced with an excerpt MP3 (MP3 only)")
+	titleWindow := flags.Duration("title-window", 15*time.Second, "Length of audio after each untitled marker passed to -title-command")
+	transcriptPath := flags.String("transcript", "", "Path to an SRT or WebVTT transcript to store as unsynchronized lyrics (USLT) (MP3 only)")
+	transcriptSync := flags.Bool("transcript-sync", false, "Also store the transcript as synchronized lyrics (SYLT)")
+	transcriptLanguage := flags.String("transcript-language", "und", "ISO 639-2 language code of the transcript, e.g. jpn")
+	chapterImages := flags.String("chapter-images", "", "Directory with chapter artwork matched by chapter number (01.png) or slugified title (MP3 only)")
+	chapterImageSize := flags.Int("chapter-image-size", artwork.DefaultSize, "Maximum width and height of chapter artwork in pixels")
+	imageFormat := flags.String("image-format", artwork.DefaultFormat, "Encoding of embedded chapter artwork: jpeg or png")
+	imageQuality := flags.Int("image-quality", artwork.DefaultQuality, "JPEG quality of embedded chapter artwork (1-100)")
+	imageBudget := flags.Int64("image-budget", 1<<20, "Total size of embedded chapter artwork in bytes above which a warning is shown (0 for no limit)")
+	imageBudgetError := flags.Bool("image-budget-error", false, "Fail instead of warning when chapter artwork exceeds -image-budget")
+	titleCards := flags.Bool("title-cards", false, "Generate title card artwork for chapters without a chapter image (MP3 only)")
+	titleCardColor := flags.String("title-card-color", "#1E1E28", "Background color of generated title cards")
+	titleCardTemplate := flags.String("title-card-template", "", "Background image of generated title cards instead of a color")
+	presetName := flags.String("preset", "", "Compatibility preset for the target player: apple, spotify, generic, audiobook or a custom preset from the config file (MP3 only)")
+	configPath := flags.String("config", "", "Path to the config file (default: "+config.DefaultPath()+")")
+	sidecarFormat := flags.String("sidecar", "", "Write chapters to a sidecar file next to the input (json: episode.chapters.json, webvtt: episode.chapters.vtt) instead of tagging the audio")
+	sampleRate := flags.Int("sample-rate", 0, "Sample rate in Hz of markers exported in samples, if the CSV file does not state it (default: the sample rate of the input MP3)")
+	columnMap := flags.String("map", "", "Columns of a CSV file that does not follow the Audition layout, by header name ('name=Chapter,start=Begin,end=Finish') or by 1-based index for files without a header row ('name=2,start=1')")
+	maxRows := flags.Int("max-rows", 0, "Abort if the CSV file has more than this many rows after the header row, as a safeguard against malformed huge inputs (0 for no limit)")
+	lenient := flags.Bool("lenient", false, "Skip CSV rows that cannot be parsed and list them all in one warning instead of stopping at the first")
+	frameRate := flags.String("frame-rate", "", "Timecode frame rate of -csv files in formats that do not state one, such as EDL, e.g. 25fps or '29.97fps drop'")
+	tempo := flags.Float64("tempo", 0, "Tempo in beats per minute of bar positions in -csv files that use them, such as Logic marker lists")
+	timeSignature := flags.String("time-signature", "", "Time signature of bar positions in -csv files that use them, such as Logic marker lists, e.g. 6/8 (default 4/4)")
+	colors := flags.String("color", "", "Comma-separated marker colors to use from -csv files in formats that store one, such as EDL, e.g. blue,green (default: all markers)")
+	track := flags.String("track", "", "Use only the markers on this track of a multitrack session export, e.g. Chapters (default: all markers)")
+	onConflict := flags.String("on-conflict", pipeline.ConflictPreferCSV, "Which marker to keep when -csv files or existing chapters (-merge) have different titles at the same time: "+strings.Join(pipeline.ConflictStrategies, ", "))
+	conflictWindow := flags.Duration("conflict-window", time.Millisecond, "Largest start time difference of markers from different sources treated as a conflict")
+	alsoExport := flags.String("also-export", "", "Comma-separated export formats written next to the output from the same markers, e.g. webvtt,json,cue ("+strings.Join(exporter.Names(), ", ")+")")
+	publish := flags.String("publish", "", "Comma-separated publishers defined in [publish.name] sections of the config file, which receive the chapters after tagging")
+	episode := flags.String("episode", "", "Episode ID given to -publish publishers as {episode} (default: the output file name without extension)")
+	auditRecord := flags.Bool("audit", false, "Write a JSON audit record (input and output hashes, marker sources, transforms, frames written, tool version) next to the output as <output>.audit.json")
+	auditLog := flags.String("audit-log", "", "Append the audit record as one JSON line to this NDJSON log instead of or in addition to -audit")
+	checksums := flags.String("checksums", "", "Record the SHA-256 hashes of the output files (audio or sidecar, -also-export files and audit record) in this sha256sum manifest, e.g. manifest.sha256; entries of earlier runs are kept (check with the verify-checksums subcommand)")
+	otlpEndpoint := flags.String("otlp-endpoint", "", "OTLP/HTTP endpoint of an OpenTelemetry collector the trace spans of the run (parse, transform, copy, tag-save, verify, ...) are sent to, e.g. http://localhost:4318 (default: OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT)")
+	runReport := flags.String("report", "", "Write a JSON summary of the run to this file: status, exit code, error, files read and written, marker and chapter counts, warnings and the time spent in each phase, for publishing dashboards; written for failed runs as well")
+	verifyTolerance := flags.Duration("verify-tolerance", 0, "Largest start time difference between markers and written chapters accepted by the verification")
+	verifyMode := flags.String("verify-mode", verifyFull, "How the output is read back for verification: full (the whole tag and the audio length), or fast (only the tag header and the CHAP and CTOC frames, for large tags and long audio) (fast applies to MP3 only)")
+	timeFormat := flags.String("time-format", "clock", "Time format of the chapter table shown by the verification: "+strings.Join(listingTimeFormats, ", "))
+	tableLayout := flags.String("table", "text", "Layout of the chapter table shown by the verification: text, or tsv or csv for spreadsheets")
+	deterministic := flags.Bool("deterministic", false, "Write frames in a stable order with fixed padding and without PRIV and timestamp frames, so identical inputs give byte-identical files (MP3 only)")
+	padding := flags.Int("padding", 0, "Bytes of padding left after the tag so later small edits can be made in place (default 0, or 1024 with -deterministic) (MP3 only)")
+	trailingTags := flags.String("trailing-tags", id3tag.TrailingKeep, "Handling of trailing ID3v1 and APE tags: keep, strip, or upgrade (copy their fields into the ID3v2 tag where missing, then remove them) (MP3 only)")
+	rebuildTag := flags.Bool("rebuild-tag", false, "Replace an existing ID3v2 tag that cannot be parsed with a fresh one, keeping the audio from its first valid MPEG frame; the frames of the broken tag are lost (MP3 only)")
+	noTOC := flags.Bool("no-toc", false, "Write only CHAP frames without the table of contents (CTOC) frame (MP3 only)")
+	tocTopLevel := flags.Bool("toc-top-level", true, "Set the top-level flag of the CTOC frame; use -toc-top-level=false to clear it (MP3 only)")
+	tocOrdered := flags.Bool("toc-ordered", true, "Set the ordered flag of the CTOC frame; use -toc-ordered=false to clear it (MP3 only)")
+	tocID := flags.String("toc-id", id3tag.DefaultTOCID, "Element ID of the CTOC frame (MP3 only)")
+	tocTitle := flags.String("toc-title", id3tag.DefaultTOCTitle, "Title of the CTOC frame; an empty title writes no title subframe (MP3 only)")
+	partDelimiter := flags.String("part-delimiter", "", "Delimiter between part and chapter title in marker names, e.g. ' / ' for 'Hour 1 / News'; each part gets its own CTOC frame (MP3 only)")
+	merge := flags.Bool("merge", false, "Keep the existing chapters of the input and add the markers to them (MP3 only)")
+	chapterIDPrefix := flags.String("chapter-id-prefix", id3tag.DefaultChapterIDPrefix, "Prefix of chapter element IDs, followed by the marker index (MP3 only)")
+	renameIDs := flags.Bool("rename-duplicate-ids", false, "Make element IDs already used by existing chapters unique, e.g. chp0-2, instead of failing (MP3 only)")
+	strict := flags.Bool("strict", false, "Fail the run if any warning is reported (skipped rows, markers merged by -min-gap or as duplicates, dropped conflicts, rounding collisions, a rebuilt tag, artwork over budget, ...), before the output is written or published, for CI")
+	dryRun := flags.Bool("dry-run", false, "Show the new tag size, the change versus the existing tag and the resulting file size without writing (MP3 only)")
+	maxTagSize := flags.Int64("max-tag-size", 0, "Abort before writing if the new tag would be larger than this many bytes, e.g. because of chapter artwork (0 for no limit) (MP3 only)")
+	postHook := flags.String("post-hook", "", "Command to run after a successful run; placeholders {input}, {output}, {csv} and {chapters} are substituted")
 
 	// Customize help message
-	customizeHelpMessage()
+	c.customizeHelpMessage(flags)
 
-	flag.Parse()
+	if err := flags.Parse(args); err != nil {
+		return nil, &flagParseError{err}
+	}
 
 	// Create configuration
 	config := &Config{
-		CSVPath:   *csvPath,
-		InputMP3:  *inputMP3,
-		OutputMP3: *outputMP3,
+		CSVPath:    csvPaths.first(),
+		CSVPaths:   csvPaths,
+		InputMP3:   *inputMP3,
+		OutputMP3:  *outputMP3,
+		PostHook:   *postHook,
+		Webhook:    *webhookURL,
+		FFmpeg:     *ffmpegPath,
+		Snap:       *snap,
+		Align:      *align,
+		Round:      *round,
+		Preset:     *presetName,
+		ConfigPath: *configPath,
+		Sidecar:    *sidecarFormat,
+		AlsoExport: splitList(*alsoExport),
+		Publish:    splitList(*publish),
+		Episode:    *episode,
+		Audit:      *auditRecord,
+		AuditLog:   *auditLog,
+		Checksums:  *checksums,
+		Report:     *runReport,
+		SampleRate: *sampleRate,
+		Track:      *track,
+		ColumnMap:  *columnMap,
+		Lenient:    *lenient,
+		MaxRows:    *maxRows,
+		FrameRate:  *frameRate,
+		Colors:     splitList(*colors),
+		Tempo:      *tempo,
+		TimeSig:    *timeSignature,
+
+		OnConflict:     *onConflict,
+		ConflictWindow: *conflictWindow,
+
+		Number:      *number,
+		NumberStart: *numberStart,
+		NumberWidth: *numberWidth,
+
+		MinGap: *minGap,
+
+		OverDrive: *overDrive,
+
+		MaxSpan:   *maxSpan,
+		FailOnGap: *failOnGap,
+
+		VerifyTolerance: *verifyTolerance,
+		VerifyMode:      *verifyMode,
+		ListTimeFormat:  *timeFormat,
+		ListLayout:      *tableLayout,
+		DryRun:          *dryRun,
+		FollowSymlinks:  *followSymlinks,
+		Strict:          *strict,
+		MaxTagSize:      *maxTagSize,
+		Deterministic:   *deterministic,
+		Padding:         *padding,
+		TrailingTags:    *trailingTags,
+		RebuildTag:      *rebuildTag,
+		NoTOC:           *noTOC,
+		TOCTopLevel:     *tocTopLevel,
+		TOCOrdered:      *tocOrdered,
+		TOCID:           *tocID,
+		TOCTitle:        *tocTitle,
+		PartDelimiter:   *partDelimiter,
+		Merge:           *merge,
+		ChapterIDPrefix: *chapterIDPrefix,
+		RenameIDs:       *renameIDs,
+
+		TitleCommand: *titleCommand,
+		TitleWindow:  *titleWindow,
+
+		Transcript:         *transcriptPath,
+		TranscriptSync:     *transcriptSync,
+		TranscriptLanguage: *transcriptLanguage,
+
+		ChapterImages:    *chapterImages,
+		ChapterImageSize: *chapterImageSize,
+		ImageFormat:      *imageFormat,
+		ImageQuality:     *imageQuality,
+		ImageBudget:      *imageBudget,
+		ImageBudgetError: *imageBudgetError,
+
+		TitleCards:        *titleCards,
+		TitleCardColor:    *titleCardColor,
+		TitleCardTemplate: *titleCardTemplate,
+		OTLPEndpoint:      *otlpEndpoint,
 	}
 
 	// Validate required options
@@ -81,47 +813,601 @@ func parseAndValidateArgs() (*Config, error) {
 		return nil, fmt.Errorf("CSV file path and input MP3 path are required")
 	}
 
-	// Check file existence
-	if !fileExists(config.CSVPath) {
-		return nil, fmt.Errorf("CSV file '%s' not found", config.CSVPath)
+	// Copy files in remote storage, such as s3://bucket/episode.mp3, to local files before they are checked
+	if err := c.stageRemoteFiles(config); err != nil {
+		return nil, err
+	}
+
+	// Check file existence (remote CSV files are checked when downloaded)
+	for _, csvPath := range config.CSVPaths {
+		if !remote.IsURL(csvPath) && !fileExists(csvPath) {
+			return nil, fmt.Errorf("CSV file '%s' not found", csvPath)
+		}
 	}
 
 	if !fileExists(config.InputMP3) {
-		return nil, fmt.Errorf("Input MP3 file '%s' not found", config.InputMP3)
+		return nil, notFoundError("Input MP3 file", config.InputMP3)
+	}
+
+	if config.Transcript != "" && !fileExists(config.Transcript) {
+		return nil, fmt.Errorf("Transcript file '%s' not found", config.Transcript)
+	}
+
+	if config.ChapterImages != "" {
+		if info, err := os.Stat(config.ChapterImages); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("Chapter image directory '%s' not found", config.ChapterImages)
+		}
+	}
+
+	for _, name := range config.AlsoExport {
+		if _, err := exporter.Lookup(name); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := loadPublishers(config.Publish, config.ConfigPath); err != nil {
+		return nil, err
+	}
+
+	if config.ColumnMap != "" {
+		if _, err := csvparser.ParseColumnMap(config.ColumnMap); err != nil {
+			return nil, fmt.Errorf("Invalid -map: %w", err)
+		}
+	}
+	if _, err := importOptions(config.FrameRate, config.Colors, config.Tempo, config.TimeSig); err != nil {
+		return nil, err
+	}
+	if _, err := parseListingOptions(config.ListTimeFormat, config.ListLayout); err != nil {
+		return nil, err
+	}
+	if !slices.Contains(verifyModes, config.VerifyMode) {
+		return nil, fmt.Errorf("Invalid -verify-mode '%s': use %s", config.VerifyMode, strings.Join(verifyModes, ", "))
+	}
+	if config.MaxRows < 0 {
+		return nil, fmt.Errorf("Row limit must not be negative")
+	}
+	if !slices.Contains(pipeline.ConflictStrategies, config.OnConflict) {
+		return nil, fmt.Errorf("Invalid -on-conflict '%s': use %s", config.OnConflict, strings.Join(pipeline.ConflictStrategies, ", "))
+	}
+	if config.ConflictWindow < 0 {
+		return nil, fmt.Errorf("Conflict window must not be negative")
+	}
+	if config.Round < 0 {
+		return nil, fmt.Errorf("Rounding grid must not be negative")
+	}
+	if config.Round > 0 && config.Align {
+		return nil, fmt.Errorf("-round and -align cannot be used together: frame boundaries are not on the grid")
+	}
+	if config.MinGap < 0 {
+		return nil, fmt.Errorf("Minimum gap must not be negative")
+	}
+	if config.MaxSpan < 0 {
+		return nil, fmt.Errorf("Maximum span must not be negative")
 	}
+	if config.NumberStart < 0 {
+		return nil, fmt.Errorf("First chapter number must not be negative")
+	}
+	if config.NumberWidth < 0 || config.NumberWidth > 9 {
+		return nil, fmt.Errorf("Chapter number width must be between 0 and 9")
+	}
+	if config.SampleRate < 0 {
+		return nil, fmt.Errorf("Sample rate must not be negative")
+	}
+	if config.Padding < 0 {
+		return nil, fmt.Errorf("Padding must not be negative")
+	}
+	if err := id3tag.ValidateElementID(config.TOCID); err != nil {
+		return nil, fmt.Errorf("Invalid -toc-id: %w", err)
+	}
+	if err := id3tag.ValidateElementID(config.ChapterIDPrefix); err != nil {
+		return nil, fmt.Errorf("Invalid -chapter-id-prefix: %w", err)
+	}
+	if config.NoTOC && (!config.TOCTopLevel || !config.TOCOrdered || config.TOCID != id3tag.DefaultTOCID || config.TOCTitle != id3tag.DefaultTOCTitle) {
+		return nil, fmt.Errorf("-toc-top-level, -toc-ordered, -toc-id and -toc-title describe the CTOC frame and cannot be used with -no-toc")
+	}
+	mode, err := id3tag.ParseTrailingMode(config.TrailingTags)
+	if err != nil {
+		return nil, err
+	}
+	config.TrailingTags = mode
+
+	// Sidecar files only hold chapter titles and times
+	if config.Sidecar != "" {
+		if _, err := sidecar.Lookup(config.Sidecar); err != nil {
+			return nil, err
+		}
+		if config.OutputMP3 != "" {
+			return nil, fmt.Errorf("Output path cannot be used with -sidecar, which does not write an audio file")
+		}
+		if config.Transcript != "" || config.ChapterImages != "" || config.TitleCards || config.Preset != "" {
+			return nil, fmt.Errorf("Transcripts, chapter images and presets are stored in the tag and cannot be used with -sidecar")
+		}
+		if names := tagFlags(config); len(names) > 0 {
+			return nil, fmt.Errorf("%s cannot be used with -sidecar, which does not write a tag", strings.Join(names, ", "))
+		}
+	}
+
+	// Check the container of the input by its content, so odd extensions work and mislabeled files are caught
+	kind, err := container.Detect(config.InputMP3)
+	if err != nil {
+		return nil, err
+	}
+	if named := container.FromExtension(config.InputMP3); kind != container.Unknown && named != container.Unknown && named != kind {
+		return nil, fmt.Errorf("Input file '%s' has the extension of %s files but contains %s audio; rename it to match its content", config.InputMP3, named, kind)
+	}
+	outputKind := container.FromExtension(config.OutputMP3)
+
+	if kind == container.Ogg {
+		if outputKind != container.Unknown && outputKind != container.Ogg {
+			return nil, fmt.Errorf("Output file '%s' has the extension of %s files, but the input is Ogg audio", config.OutputMP3, outputKind)
+		}
+		if config.Snap > 0 || config.Align || config.Transcript != "" || config.TitleCommand != "" || config.ChapterImages != "" || config.TitleCards || config.Preset != "" {
+			return nil, fmt.Errorf("Snapping, frame alignment, transcripts, auto-titling, chapter images and presets are only supported for MP3 input")
+		}
+		if names := tagFlags(config); len(names) > 0 {
+			return nil, fmt.Errorf("%s cannot be used with Ogg input, which has no ID3v2 tag", strings.Join(names, ", "))
+		}
+	} else if config.FFmpeg != "" && kind != container.MP3 {
+		if config.OutputMP3 != "" && !strings.EqualFold(filepath.Ext(config.OutputMP3), filepath.Ext(config.InputMP3)) {
+			return nil, fmt.Errorf("Output file '%s' must have the extension of the input, as ffmpeg copies the streams", config.OutputMP3)
+		}
+		if config.Snap > 0 || config.Align || config.Transcript != "" || config.TitleCommand != "" || config.ChapterImages != "" || config.TitleCards || config.Preset != "" {
+			return nil, fmt.Errorf("Snapping, frame alignment, transcripts, auto-titling, chapter images and presets are only supported for MP3 input")
+		}
+		if names := tagFlags(config); len(names) > 0 {
+			return nil, fmt.Errorf("%s cannot be used with input written by ffmpeg, which has no ID3v2 tag", strings.Join(names, ", "))
+		}
+	} else {
+		switch kind {
+		case container.MP3:
+		case container.Unknown:
+			return nil, fmt.Errorf("Input file '%s' is not an MP3 or Ogg file: it starts with neither an ID3v2 tag, an MPEG audio frame nor an Ogg page", config.InputMP3)
+		default:
+			return nil, fmt.Errorf("Input file '%s' contains %s audio, which has no native chapter writer; set -ffmpeg to write chapters to it with ffmpeg", config.InputMP3, kind)
+		}
 
-	// Check file extensions
-	if !strings.EqualFold(filepath.Ext(config.InputMP3), ".mp3") {
-		return nil, fmt.Errorf("Input file '%s' is not an MP3 file", config.InputMP3)
+		if outputKind != container.Unknown && outputKind != container.MP3 {
+			return nil, fmt.Errorf("Output file '%s' has the extension of %s files, but the input is MP3 audio", config.OutputMP3, outputKind)
+		}
 	}
 
-	if config.OutputMP3 != "" && !strings.EqualFold(filepath.Ext(config.OutputMP3), ".mp3") {
-		return nil, fmt.Errorf("Output file '%s' does not have MP3 extension", config.OutputMP3)
+	// Catch in-place edits through symbolic links and unwritable outputs before any work is done
+	if config.Sidecar == "" {
+		input, output, err := resolveInPlacePaths(config.InputMP3, determineOutputPath(config.InputMP3, config.OutputMP3), config.FollowSymlinks)
+		if err != nil {
+			return nil, err
+		}
+		if !config.DryRun {
+			if err := safefile.CheckWritable(output); err != nil {
+				return nil, err
+			}
+		}
+		config.InputMP3, config.OutputMP3 = input, output
+	}
+	if config.Report != "" {
+		if err := safefile.CheckWritable(config.Report); err != nil {
+			return nil, err
+		}
 	}
 
+	// Trace the run if a collector is configured
+	tracer, err := tracing.NewFromEnv(config.OTLPEndpoint, "audition-marker")
+	if err != nil {
+		return nil, err
+	}
+	config.tracer = tracer
+
 	return config, nil
 }
 
+// keptChapters returns the chapters without the ones with the replaced element IDs
+func keptChapters(chapters []id3tag.Chapter, replaced []string) []id3tag.Chapter {
+	var kept []id3tag.Chapter
+	for _, chapter := range chapters {
+		if !slices.Contains(replaced, chapter.ElementID) {
+			kept = append(kept, chapter)
+		}
+	}
+	return kept
+}
+
+// mergeMarkers returns the existing chapters and the markers in playback order
+func mergeMarkers(chapters []id3tag.Chapter, markers []csvparser.MarkerEntry) []csvparser.MarkerEntry {
+	merged := make([]csvparser.MarkerEntry, 0, len(chapters)+len(markers))
+	for _, chapter := range chapters {
+		merged = append(merged, csvparser.MarkerEntry{Name: chapter.Title, StartTime: chapter.StartTime})
+	}
+	merged = append(merged, markers...)
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].StartTime < merged[j].StartTime })
+	return merged
+}
+
+// tagFlags returns the names of the options set in config that only apply to ID3v2 tags
+func tagFlags(config *Config) []string {
+	var names []string
+	add := func(set bool, name string) {
+		if set {
+			names = append(names, name)
+		}
+	}
+	add(config.DryRun, "-dry-run")
+	add(config.MaxTagSize > 0, "-max-tag-size")
+	add(config.Deterministic, "-deterministic")
+	add(config.Padding > 0, "-padding")
+	add(config.TrailingTags != id3tag.TrailingKeep, "-trailing-tags")
+	add(config.RebuildTag, "-rebuild-tag")
+	add(config.NoTOC, "-no-toc")
+	add(!config.TOCTopLevel, "-toc-top-level")
+	add(!config.TOCOrdered, "-toc-ordered")
+	add(config.TOCID != id3tag.DefaultTOCID, "-toc-id")
+	add(config.TOCTitle != id3tag.DefaultTOCTitle, "-toc-title")
+	add(config.PartDelimiter != "", "-part-delimiter")
+	add(config.Merge, "-merge")
+	add(config.OverDrive, "-overdrive")
+	add(config.ChapterIDPrefix != id3tag.DefaultChapterIDPrefix, "-chapter-id-prefix")
+	add(config.RenameIDs, "-rename-duplicate-ids")
+	return names
+}
+
+// listFlags collects the values of a repeated option
+type listFlags []string
+
+// String returns the values separated by commas
+func (l *listFlags) String() string {
+	return strings.Join(*l, ", ")
+}
+
+// Set adds a value
+func (l *listFlags) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// first returns the first value, or an empty string if there is none
+func (l listFlags) first() string {
+	if len(l) == 0 {
+		return ""
+	}
+	return l[0]
+}
+
+// parseMarkers parses markers from a local CSV file or an HTTP(S) URL
+func parseMarkers(csvPath string) ([]csvparser.MarkerEntry, error) {
+	return pipeline.ParseCSV(csvPath, csvparser.ParseOptions{})
+}
+
+// parseSessionMarkers parses the markers of the configured marker files, merging them if there are several
+func (c *cli) parseSessionMarkers(config *Config) ([]csvparser.MarkerEntry, error) {
+	options := c.pipelineOptions(config)
+
+	// Existing chapters take part in conflicts when merging
+	if config.Merge && isMP3File(config.InputMP3) {
+		chapters, err := readChapters(c.ffmpeg, config.InputMP3)
+		if err != nil {
+			return nil, fmt.Errorf("Error occurred while reading existing chapters: %w", err)
+		}
+		options.Existing = chapters
+	}
+	markers, replaced, err := pipeline.Read(config.CSVPaths, config.InputMP3, options)
+	if err != nil {
+		return nil, err
+	}
+	config.replacedChapters = replaced
+	return markers, nil
+}
+
+// pipelineOptions returns the settings of the marker pipeline from the configuration, reporting to the streams of the run
+func (c *cli) pipelineOptions(config *Config) pipeline.Options {
+	options := pipeline.Options{
+		CSV:            csvparser.ParseOptions{SampleRate: config.SampleRate, Lenient: config.Lenient, MaxRows: config.MaxRows},
+		Track:          config.Track,
+		OnConflict:     config.OnConflict,
+		ConflictWindow: config.ConflictWindow,
+		Choose:         c.askCandidate,
+		PartDelimiter:  config.PartDelimiter,
+		MinGap:         config.MinGap,
+		TitleCommand:   config.TitleCommand,
+		TitleWindow:    config.TitleWindow,
+		TitleStderr:    c.stderr,
+		Snap:           config.Snap,
+		Round:          config.Round,
+		Align:          config.Align,
+		Number:         config.Number,
+		NumberStart:    config.NumberStart,
+		NumberWidth:    config.NumberWidth,
+		Reporter:       cliReporter{c},
+	}
+
+	// Both were validated with the other options
+	options.Import, _ = importOptions(config.FrameRate, config.Colors, config.Tempo, config.TimeSig)
+	if config.ColumnMap != "" {
+		options.CSV.Columns, _ = csvparser.ParseColumnMap(config.ColumnMap)
+	}
+	return options
+}
+
+// askCandidate lists the candidates of a conflict and asks which one to keep
+func (c *cli) askCandidate(group []pipeline.Candidate) (int, error) {
+	fmt.Fprintf(c.stdout, "Conflict at %s:\n", id3tag.FormatDuration(group[0].Marker.StartTime))
+	for i, candidate := range group {
+		fmt.Fprintf(c.stdout, "  %d) %s '%s' (%s)\n", i+1, id3tag.FormatDuration(candidate.Marker.StartTime), candidate.Marker.Name, candidate.Source.Name)
+	}
+
+	for {
+		fmt.Fprintf(c.stdout, "Keep which one? (1-%d): ", len(group))
+		response, err := c.input.ReadString('\n')
+		if n, convErr := strconv.Atoi(strings.TrimSpace(response)); convErr == nil && n >= 1 && n <= len(group) {
+			return n - 1, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("Error reading input: %w", err)
+		}
+	}
+}
+
+// overDriveMarkers returns the OverDrive MediaMarkers XML of the chapters written, including the existing ones kept when merging
+func overDriveMarkers(config *Config, markers []csvparser.MarkerEntry) (string, error) {
+	if config.Merge {
+		chapters, err := readChapters(nil, config.InputMP3)
+		if err != nil {
+			return "", err
+		}
+		markers = mergeMarkers(keptChapters(chapters, config.replacedChapters), markers)
+	}
+	format, err := exporter.Lookup("overdrive")
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := format.Write(&buf, markers, exporter.Options{}); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// prompter returns the prompter asking for confirmations on the streams of the run
+func (c *cli) prompter() *prompt.Prompter {
+	return &prompt.Prompter{Input: c.input, Output: c.stdout}
+}
+
+// loadTagOptions builds the optional tag content from the configuration
+func (c *cli) loadTagOptions(config *Config, markers []csvparser.MarkerEntry) (id3tag.Options, error) {
+	options := id3tag.Options{
+		Prompt:             c.prompter(),
+		SyncTranscript:     config.TranscriptSync,
+		TranscriptLanguage: config.TranscriptLanguage,
+		Deterministic:      config.Deterministic,
+		Padding:            config.Padding,
+		TrailingTags:       config.TrailingTags,
+		RebuildTag:         config.RebuildTag,
+	}
+	if config.Preset != "" {
+		p, err := loadPreset(config.Preset, config.ConfigPath)
+		if err != nil {
+			return options, err
+		}
+		fmt.Fprintf(c.stdout, "Using preset '%s': %s\n", p.Name, p.Description)
+		p.Apply(&options)
+	}
+	options.OmitTOC = options.OmitTOC || config.NoTOC
+	options.TOCNotTopLevel = !config.TOCTopLevel
+	options.TOCUnordered = !config.TOCOrdered
+	options.TOCID = config.TOCID
+	options.TOCTitle = config.TOCTitle
+	options.OmitTOCTitle = config.TOCTitle == ""
+	options.MergeChapters = config.Merge
+	options.ReplacedChapters = config.replacedChapters
+	if config.OverDrive {
+		mediaMarkers, err := overDriveMarkers(config, markers)
+		if err != nil {
+			return options, err
+		}
+		options.MediaMarkers = mediaMarkers
+	}
+	options.ChapterIDPrefix = config.ChapterIDPrefix
+	options.RenameDuplicateIDs = config.RenameIDs
+	if config.Transcript != "" {
+		cues, err := transcript.ParseFile(config.Transcript)
+		if err != nil {
+			return options, err
+		}
+		fmt.Fprintf(c.stdout, "Loaded transcript with %d cues\n", len(cues))
+		options.Transcript = cues
+	}
+	if config.ChapterImages != "" || config.TitleCards {
+		images, err := c.loadChapterImages(config, markers)
+		if err != nil {
+			return options, err
+		}
+		options.ChapterImages = images
+	}
+	return options, nil
+}
+
+// loadPreset looks up a built-in preset or a custom preset from the config file
+func loadPreset(name, configPath string) (preset.Preset, error) {
+	file, err := config.Load(configPath)
+	if err != nil {
+		return preset.Preset{}, err
+	}
+	return preset.Lookup(name, file)
+}
+
+// loadChapterImages matches, scales and encodes the artwork for each marker, generating title cards for the rest if enabled
+func (c *cli) loadChapterImages(config *Config, markers []csvparser.MarkerEntry) ([]*artwork.Image, error) {
+	images := make([]*artwork.Image, len(markers))
+	imageOptions := artwork.Options{Size: config.ChapterImageSize, Format: config.ImageFormat, Quality: config.ImageQuality}
+
+	// Match images from the directory
+	if config.ChapterImages != "" {
+		titles := make([]string, len(markers))
+		for i, marker := range markers {
+			titles[i] = marker.Name
+		}
+		paths, err := artwork.MatchDirectory(config.ChapterImages, titles)
+		if err != nil {
+			return nil, err
+		}
+
+		matched := 0
+		for i, path := range paths {
+			if path == "" {
+				continue
+			}
+			if images[i], err = artwork.Load(path, imageOptions); err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(c.stdout, "Chapter image for '%s': %s (%d bytes)\n", markers[i].Name, filepath.Base(path), len(images[i].Data))
+			matched++
+		}
+		fmt.Fprintf(c.stdout, "Matched chapter images for %d of %d chapters\n", matched, len(markers))
+	}
+
+	// Generate title cards for chapters without an image
+	if config.TitleCards {
+		card, err := newTitleCard(config)
+		if err != nil {
+			return nil, err
+		}
+
+		generated := 0
+		for i, marker := range markers {
+			if images[i] != nil {
+				continue
+			}
+			if images[i], err = card.Image(i+1, marker.Name, imageOptions); err != nil {
+				return nil, err
+			}
+			generated++
+		}
+		fmt.Fprintf(c.stdout, "Generated title cards for %d chapters\n", generated)
+	}
+
+	// Check the total artwork size against the budget
+	if err := c.checkImageBudget(images, config.ImageBudget, config.ImageBudgetError); err != nil {
+		return nil, err
+	}
+
+	return images, nil
+}
+
+// checkExistingTag fails with a hint if the existing tag of the input is corrupt, or warns that it will be rebuilt
+func (c *cli) checkExistingTag(config *Config) error {
+	err := id3tag.CheckTag(config.InputMP3)
+	if !errors.Is(err, id3tag.ErrCorruptTag) {
+		return nil // Other errors are reported when the file is processed
+	}
+	if !config.RebuildTag {
+		return fmt.Errorf("%w (use -rebuild-tag to replace it with a fresh tag)", err)
+	}
+	c.warnf(c.stderr, "%v; rebuilding it, the frames of the broken tag are lost", err)
+	return nil
+}
+
+// previewTagSize displays the size of the tag about to be written and fails if it exceeds the configured maximum
+func (c *cli) previewTagSize(config *Config, markers []csvparser.MarkerEntry, options id3tag.Options) error {
+	preview, err := id3tag.PreviewTagSize(config.InputMP3, markers, config.OutputMP3, options)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "New tag size: %d bytes (%+d bytes versus the existing tag of %d bytes)\n", preview.NewTagSize, preview.Delta(), preview.OldTagSize)
+	fmt.Fprintf(c.stdout, "Resulting file size: %d bytes\n", preview.FileSize)
+	if preview.InPlace {
+		fmt.Fprintln(c.stdout, "The new tag fits into the existing tag and its padding; only the tag will be rewritten")
+	}
+
+	if config.MaxTagSize > 0 && preview.NewTagSize > config.MaxTagSize {
+		return fmt.Errorf("New tag (%d bytes) exceeds -max-tag-size of %d bytes; reduce chapter artwork with -chapter-image-size or -image-quality", preview.NewTagSize, config.MaxTagSize)
+	}
+	return nil
+}
+
+// checkImageBudget warns, or fails if strict is set, when the total size of images exceeds budget bytes
+func (c *cli) checkImageBudget(images []*artwork.Image, budget int64, strict bool) error {
+	var total int64
+	for _, image := range images {
+		if image != nil {
+			total += int64(len(image.Data))
+		}
+	}
+	fmt.Fprintf(c.stdout, "Chapter artwork adds %d bytes to the tag\n", total)
+
+	if budget <= 0 || total <= budget {
+		return nil
+	}
+	message := fmt.Sprintf("Chapter artwork (%d bytes) exceeds the budget of %d bytes; reduce -chapter-image-size or -image-quality", total, budget)
+	if strict {
+		return fmt.Errorf("%s", message)
+	}
+	c.warnf(c.stderr, "%s", message)
+	return nil
+}
+
+// newTitleCard creates the title card renderer from the configuration
+func newTitleCard(config *Config) (artwork.TitleCard, error) {
+	card := artwork.TitleCard{}
+	if config.TitleCardTemplate != "" {
+		template, err := artwork.LoadTemplate(config.TitleCardTemplate)
+		if err != nil {
+			return card, err
+		}
+		card.Template = template
+		return card, nil
+	}
+
+	background, err := artwork.ParseColor(config.TitleCardColor)
+	if err != nil {
+		return card, err
+	}
+	card.Background = background
+	return card, nil
+}
+
+// loadMarkers loads markers from a CSV file if csvPath is set, otherwise from the chapters of a tagged audio file
+func loadMarkers(csvPath string, audioPath string) ([]csvparser.MarkerEntry, error) {
+	if csvPath != "" {
+		return parseMarkers(csvPath)
+	}
+	return readMarkersFromMP3(audioPath)
+}
+
 // customizeHelpMessage customizes the help message
-func customizeHelpMessage() {
-	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s -csv <CSV file path> -input <input MP3 path> [-output <output MP3 path>]\n\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "Options:\n")
-		flag.PrintDefaults()
-		fmt.Fprintf(os.Stderr, "\nExamples:\n")
-		fmt.Fprintf(os.Stderr, "  Add chapters and save as podcast_with_chapters.mp3:\n")
-		fmt.Fprintf(os.Stderr, "  %s -csv \"marker.csv\" -input \"podcast.mp3\"\n\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "  Save with custom output filename:\n")
-		fmt.Fprintf(os.Stderr, "  %s -csv \"marker.csv\" -input \"podcast.mp3\" -output \"custom_filename.mp3\"\n", os.Args[0])
-	}
-}
-
-// showMarkerInfo displays marker information
-func showMarkerInfo(markers []csvparser.MarkerEntry) {
-	if len(markers) == 0 {
-		fmt.Println("Warning: No markers found in CSV file")
-	} else {
-		fmt.Printf("Loaded %d markers\n", len(markers))
+func (c *cli) customizeHelpMessage(flags *flag.FlagSet) {
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: %s -csv <CSV file path> -input <input MP3 path> [-output <output MP3 path>]\n", c.name)
+		fmt.Fprintf(c.stderr, "       %s serve [-addr <address>]\n", c.name)
+		fmt.Fprintf(c.stderr, "       %s feed -feed <feed.xml> -input <tagged MP3>\n", c.name)
+		fmt.Fprintf(c.stderr, "       %s export -format <format> -csv <CSV file> [-output <path>]\n", c.name)
+		fmt.Fprintf(c.stderr, "       %s join -output <joined MP3> <part1.mp3> <part2.mp3> ...\n", c.name)
+		fmt.Fprintf(c.stderr, "       %s detect -input <MP3 file> [-output <CSV file>]\n", c.name)
+		fmt.Fprintf(c.stderr, "       %s transcript -input <transcript.srt|.vtt> -prefix <list> [-output <CSV file>]\n", c.name)
+		fmt.Fprintf(c.stderr, "       %s extract-images -input <tagged MP3> [-dir <directory>]\n", c.name)
+		fmt.Fprintf(c.stderr, "       %s lint -input <MP3 file> [-format text|json] [-strict]\n", c.name)
+		fmt.Fprintf(c.stderr, "       %s repair -input <MP3 file> [-output <MP3 file>] [-dry-run]\n", c.name)
+		fmt.Fprintf(c.stderr, "       %s diff [-tolerance <duration>] <old CSV or MP3> <new CSV or MP3>\n", c.name)
+		fmt.Fprintf(c.stderr, "       %s stats <tagged MP3 file or directory> ...\n", c.name)
+		fmt.Fprintf(c.stderr, "       %s inspect -input <MP3 file> [-hex]\n", c.name)
+		fmt.Fprintf(c.stderr, "       %s read [-glob <pattern>] [-json] [<tagged MP3 file or URL> ...]\n", c.name)
+		fmt.Fprintf(c.stderr, "       %s apply-sidecar -input <MP3 file> [-sidecar <chapters.json|.vtt>] [-output <MP3 file>]\n", c.name)
+		fmt.Fprintf(c.stderr, "       %s convert [-from <format>] -to <format> [-output <path>] <input file>\n", c.name)
+		fmt.Fprintf(c.stderr, "       %s add -chapter <TIME=TITLE> [-chapter <TIME=TITLE> ...] <MP3 file>\n", c.name)
+		fmt.Fprintf(c.stderr, "       %s edit -chapter <number or ID> [-title <title>] [-start <time>] <MP3 file>\n", c.name)
+		fmt.Fprintf(c.stderr, "       %s remove (-chapter <number or ID> | -between <from> <to>) <MP3 file>\n", c.name)
+		fmt.Fprintf(c.stderr, "       %s set-image -chapter <number or ID> <image file> <MP3 file>\n", c.name)
+		fmt.Fprintf(c.stderr, "       %s set-url -chapter <number or ID> <URL> <MP3 file>\n", c.name)
+		fmt.Fprintf(c.stderr, "       %s auphonic -production <UUID> (-csv <CSV file> | -input <tagged MP3>)\n", c.name)
+		fmt.Fprintf(c.stderr, "       %s verify-checksums <manifest.sha256> ...\n", c.name)
+		fmt.Fprintf(c.stderr, "       %s doctor [-csv <marker file>] [-input <MP3 file>]\n", c.name)
+		fmt.Fprintf(c.stderr, "       %s init [-dir <directory>] [-force]\n\n", c.name)
+		fmt.Fprintf(c.stderr, "Errors, warnings and differences are colored on terminals; add -no-color to any command\n")
+		fmt.Fprintf(c.stderr, "or set NO_COLOR for plain output. Pipes and files always get plain output.\n\n")
+		fmt.Fprintf(c.stderr, "Options:\n")
+		flags.PrintDefaults()
+		fmt.Fprintf(c.stderr, "\nExamples:\n")
+		fmt.Fprintf(c.stderr, "  Add chapters and save as podcast_with_chapters.mp3:\n")
+		fmt.Fprintf(c.stderr, "  %s -csv \"marker.csv\" -input \"podcast.mp3\"\n\n", c.name)
+		fmt.Fprintf(c.stderr, "  Save with custom output filename:\n")
+		fmt.Fprintf(c.stderr, "  %s -csv \"marker.csv\" -input \"podcast.mp3\" -output \"custom_filename.mp3\"\n", c.name)
 	}
 }
 
@@ -138,46 +1424,109 @@ func determineOutputPath(inputPath, outputPath string) string {
 }
 
 // showSuccessMessage displays success message
-func showSuccessMessage(outputPath string) {
-	fmt.Printf("Done! MP3 file with chapter tags has been saved to '%s'\n", outputPath)
+func (c *cli) showSuccessMessage(outputPath string) {
+	fmt.Fprintf(c.stdout, "Done! MP3 file with chapter tags has been saved to '%s'\n", outputPath)
 }
 
-// verifyAndShowChapters reads and displays chapters from the output file
-func verifyAndShowChapters(filePath string) {
-	fmt.Println("\nVerifying chapters in output file:")
+// Values of -verify-mode
+const (
+	verifyFull = "full"
+	verifyFast = "fast"
+)
+
+// verifyModes lists the valid values of -verify-mode
+var verifyModes = []string{verifyFull, verifyFast}
+
+// verifyAndShowChapters reads and displays chapters from the output file and compares them with the markers.
+// If the writer recorded the chapters it wrote, only the CHAP and CTOC frames are read back and checked against that
+// model, which is then displayed and compared, with the end of the last chapter taken from the markers instead of the audio.
+func (c *cli) verifyAndShowChapters(filePath string, markers []csvparser.MarkerEntry, tolerance time.Duration, written *id3tag.WrittenChapters, listing listingOptions) error {
+	fmt.Fprintln(c.stdout, "\nVerifying chapters in output file:")
 
 	// Get chapter information
-	chapters, err := id3tag.ReadChapters(filePath)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: Could not read chapters from output file: %v\n", err)
-		return
+	var chapters []id3tag.Chapter
+	var tocInfo *id3tag.CTOCInfo
+	if written != nil {
+		if err := id3tag.VerifyWritten(filePath, written); err != nil {
+			return fmt.Errorf("Output file does not match the written chapters: %w", err)
+		}
+		chapters, tocInfo = written.Chapters, written.TOC
+	} else {
+		var err error
+		if chapters, err = readChapters(c.ffmpeg, filePath); err != nil {
+			return fmt.Errorf("Could not read chapters from output file: %w", err)
+		}
 	}
 
 	if len(chapters) == 0 {
-		fmt.Println("No chapters found in output file.")
+		fmt.Fprintln(c.stdout, "No chapters found in output file.")
+	} else {
+		// Read table of contents information
+		if written == nil {
+			tocInfo, _ = id3tag.ReadTOC(filePath)
+		}
+		if tocInfo != nil {
+			fmt.Fprintln(c.stdout, "Table of Contents information:")
+			fmt.Fprintf(c.stdout, "Title: %s\n", tocInfo.Title)
+			fmt.Fprintf(c.stdout, "Top level: %t\n", tocInfo.IsTopLevel)
+			fmt.Fprintf(c.stdout, "Ordered: %t\n", tocInfo.IsOrdered)
+			fmt.Fprintf(c.stdout, "Child elements: %d\n", len(tocInfo.ChildIDs))
+			fmt.Fprintln(c.stdout, "------------------------------------------------------------")
+		}
+
+		// Display chapter list
+		fmt.Fprintf(c.stdout, "Found %d chapters in output file:\n", len(chapters))
+		var duration time.Duration
+		if written != nil {
+			for _, marker := range markers {
+				duration = max(duration, marker.EndTime)
+			}
+		} else {
+			duration = audioDuration(filePath)
+		}
+		c.printChapterTable(chapters, duration, listing)
+	}
+
+	// Compare written chapters with the markers
+	actual := make([]csvparser.MarkerEntry, 0, len(chapters))
+	for _, chapter := range chapters {
+		actual = append(actual, csvparser.MarkerEntry{Name: chapter.Title, StartTime: chapter.StartTime})
+	}
+	changes := chapterdiff.Compare(markers, actual, tolerance)
+	if len(changes) == 0 {
+		fmt.Fprintf(c.stdout, "Verified: all %d markers were written\n", len(markers))
+		return nil
+	}
+	for _, change := range changes {
+		fmt.Fprintf(c.stderr, "Mismatch: %s\n", formatChange(change))
+	}
+	return fmt.Errorf("%d markers, %d chapters written, %d mismatches", len(markers), len(chapters), len(changes))
+}
+
+// notifyWebhook posts the processing result to the configured webhook
+func (c *cli) notifyWebhook(config *Config, outputPath string, chapterCount int, started time.Time, processErr error) {
+	if config.Webhook == "" {
 		return
 	}
 
-	// Read table of contents information
-	tocInfo, tocErr := id3tag.ReadTOC(filePath)
-	if tocErr == nil {
-		fmt.Println("Table of Contents information:")
-		fmt.Printf("Title: %s\n", tocInfo.Title)
-		fmt.Printf("Top level: %t\n", tocInfo.IsTopLevel)
-		fmt.Printf("Ordered: %t\n", tocInfo.IsOrdered)
-		fmt.Printf("Child elements: %d\n", len(tocInfo.ChildIDs))
-		fmt.Println("------------------------------------------------------------")
+	event := webhook.Event{
+		Status:     webhook.StatusDone,
+		Input:      config.InputMP3,
+		CSV:        config.CSVPath,
+		Output:     outputPath,
+		Chapters:   chapterCount,
+		DurationMS: time.Since(started).Milliseconds(),
+	}
+	if processErr != nil {
+		event.Status = webhook.StatusFailed
+		event.Error = processErr.Error()
+	} else if config.DryRun {
+		event.Status = webhook.StatusDryRun
 	}
 
-	// Display chapter list
-	fmt.Printf("Found %d chapters in output file:\n", len(chapters))
-	fmt.Println("------------------------------------------------------------")
-	fmt.Printf("%-4s | %-12s | %s\n", "No.", "Start Time", "Title")
-	fmt.Println("------------------------------------------------------------")
-	for i, chapter := range chapters {
-		fmt.Printf("%-4d | %-12s | %s\n", i+1, id3tag.FormatDuration(chapter.StartTime), chapter.Title)
+	if err := webhook.Notify(config.Webhook, event); err != nil {
+		c.warnf(c.stderr, "%v", err)
 	}
-	fmt.Println("------------------------------------------------------------")
 }
 
 // fileExists checks if a file exists
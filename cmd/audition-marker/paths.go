@@ -0,0 +1,51 @@
+package auditionmarker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/safefile"
+)
+
+// followSymlinksUsage is the help text of the -follow-symlinks option of commands that can edit files in place
+const followSymlinksUsage = "Allow editing the input in place through a symbolic link; the file the link points to is edited and the link kept"
+
+// notFoundError returns the error for a missing input file, naming the target of a broken symbolic link
+func notFoundError(what, path string) error {
+	if target, err := os.Readlink(path); err == nil {
+		return fmt.Errorf("%s '%s' is a symbolic link to '%s', which does not exist", what, path, target)
+	}
+	return fmt.Errorf("%s '%s' not found", what, path)
+}
+
+// resolveInPlacePaths returns the input and output paths to write with; paths naming the same file are made equal, so the file is edited in place.
+// Editing in place replaces the file with a new one, which would turn a symbolic link into a regular file and leave the linked file unchanged;
+// it is refused for links unless followSymlinks is set, which edits the linked file instead.
+func resolveInPlacePaths(inputPath, outputPath string, followSymlinks bool) (string, string, error) {
+	if !safefile.Same(inputPath, outputPath) {
+		return inputPath, outputPath, nil
+	}
+	if !isSymlink(inputPath) && !isSymlink(outputPath) {
+		return inputPath, inputPath, nil
+	}
+
+	target, err := filepath.EvalSymlinks(inputPath)
+	if err != nil {
+		return "", "", fmt.Errorf("Failed to resolve symbolic link '%s': %w", inputPath, err)
+	}
+	if !followSymlinks {
+		link := inputPath
+		if !isSymlink(link) {
+			link = outputPath
+		}
+		return "", "", fmt.Errorf("'%s' is a symbolic link to '%s'; editing it in place would replace the link with a copy. Set -follow-symlinks to edit '%s', or -output to write a new file", link, target, target)
+	}
+	return target, target, nil
+}
+
+// isSymlink reports whether path is a symbolic link
+func isSymlink(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode()&os.ModeSymlink != 0
+}
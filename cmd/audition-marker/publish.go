@@ -0,0 +1,59 @@
+package auditionmarker
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/config"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/mp3frame"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/publisher"
+)
+
+// loadPublishers builds the publishers named with -publish from the config file
+func loadPublishers(names []string, configPath string) ([]publisher.Publisher, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	file, err := config.Load(configPath)
+	if err != nil {
+		return nil, err
+	}
+	publishers := make([]publisher.Publisher, 0, len(names))
+	for _, name := range names {
+		p, err := publisher.Lookup(name, file)
+		if err != nil {
+			return nil, err
+		}
+		publishers = append(publishers, p)
+	}
+	return publishers, nil
+}
+
+// publishChapters pushes the chapters of the tagged file to each -publish publisher
+// The episode ID defaults to the file name of the tagged file without its extension
+func (c *cli) publishChapters(config *Config, audioPath string, markers []csvparser.MarkerEntry) error {
+	publishers, err := loadPublishers(config.Publish, config.ConfigPath)
+	if err != nil {
+		return err
+	}
+
+	episode := publisher.Episode{ID: config.Episode, Markers: markers, AudioFile: audioPath}
+	if episode.ID == "" {
+		episode.ID = strings.TrimSuffix(filepath.Base(audioPath), filepath.Ext(audioPath))
+	}
+	if isMP3File(audioPath) {
+		if info, err := mp3frame.ScanFile(audioPath); err == nil {
+			episode.Duration = info.Duration()
+		}
+	}
+
+	for i, p := range publishers {
+		if err := p.Publish(episode); err != nil {
+			return fmt.Errorf("Publisher '%s': %w", config.Publish[i], err)
+		}
+		fmt.Fprintf(c.stdout, "Published %d chapters with '%s' for episode '%s'\n", len(markers), config.Publish[i], episode.ID)
+	}
+	return nil
+}
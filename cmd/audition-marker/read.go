@@ -0,0 +1,215 @@
+package auditionmarker
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/remote"
+)
+
+// readReport is the consolidated JSON report of the read subcommand
+type readReport struct {
+	Files    int          `json:"files"`
+	Chapters int          `json:"chapters"`
+	Errors   int          `json:"errors"`
+	Results  []readResult `json:"results"`
+}
+
+// readResult is the entry of a single source in the JSON report
+type readResult struct {
+	Source             string        `json:"source"`
+	Chapters           []readChapter `json:"chapters"`
+	OrphanedChapters   []string      `json:"orphaned_chapters,omitempty"`
+	DanglingReferences []string      `json:"dangling_references,omitempty"`
+	TrailingTags       []string      `json:"trailing_tags,omitempty"`
+	Error              string        `json:"error,omitempty"`
+}
+
+// readChapter is a chapter in the JSON report
+type readChapter struct {
+	Title     string `json:"title"`
+	Start     string `json:"start"`
+	StartTime int64  `json:"start_ms"`
+	EndTime   int64  `json:"end_ms,omitempty"`      // Next chapter's start or the audio length (omitted if unknown)
+	Duration  int64  `json:"duration_ms,omitempty"` // Length of the chapter (omitted if the end is unknown)
+	ElementID string `json:"element_id,omitempty"`
+	Orphaned  bool   `json:"orphaned,omitempty"`
+}
+
+// executeRead lists the chapters of tagged audio files or remote MP3 URLs
+func (c *cli) executeRead(args []string) int {
+	// Define read options
+	flags := flag.NewFlagSet("read", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	pattern := flags.String("glob", "", "Glob pattern of files to read in addition to the arguments, e.g. 'archive/*.mp3'")
+	asJSON := flags.Bool("json", false, "Print one consolidated JSON report instead of chapter tables")
+	concurrency := flags.Int("concurrency", runtime.NumCPU(), "Number of files read at the same time")
+	timeFormat := flags.String("time-format", "clock", "Time format of the chapter tables: "+strings.Join(listingTimeFormats, ", "))
+	tableLayout := flags.String("table", "text", "Layout of the chapter tables: text, or tsv or csv for spreadsheets")
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: %s read [-glob <pattern>] [-json | -table text|tsv|csv] [-time-format <format>] [<tagged MP3/Ogg/M4A file or HTTP(S) URL> ...]\n\n", c.name)
+		fmt.Fprintf(c.stderr, "Remote MP3 files are read with HTTP range requests, downloading only the ID3 tag.\n")
+		fmt.Fprintf(c.stderr, "Exits with code 1 if any source cannot be read.\n\n")
+		fmt.Fprintf(c.stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return parseErrorCode(err)
+	}
+
+	listing, err := parseListingOptions(*timeFormat, *tableLayout)
+	if err != nil {
+		fmt.Fprintln(c.stderr, "Error:", err)
+		return 1
+	}
+
+	// Collect sources
+	sources := flags.Args()
+	if *pattern != "" {
+		matches, err := filepath.Glob(*pattern)
+		if err != nil {
+			fmt.Fprintf(c.stderr, "Error: Invalid glob pattern '%s': %v\n", *pattern, err)
+			return 1
+		}
+		sources = append(sources, matches...)
+	}
+	if len(sources) == 0 {
+		fmt.Fprintln(c.stderr, "Error: at least one file, URL or matching glob pattern is required")
+		flags.Usage()
+		return 1
+	}
+
+	// Read all sources
+	results := id3tag.ReadChaptersBatch(sources, *concurrency, c.readSourceChapters)
+	report := readReport{Files: len(results), Results: make([]readResult, 0, len(results))}
+	durations := make([]time.Duration, len(results))
+	for n, result := range results {
+		entry := readResult{Source: result.Source, Chapters: make([]readChapter, 0, len(result.Chapters))}
+		if result.Err != nil {
+			entry.Error = result.Err.Error()
+			report.Errors++
+		} else {
+			entry.TrailingTags = trailingTagNames(result.Source)
+			entry.DanglingReferences = danglingReferences(result.Source)
+			if !remote.IsURL(result.Source) {
+				durations[n] = audioDuration(result.Source)
+			}
+		}
+		for i, chapter := range result.Chapters {
+			item := readChapter{
+				Title:     chapter.Title,
+				Start:     id3tag.FormatDuration(chapter.StartTime),
+				StartTime: chapter.StartTime.Milliseconds(),
+				ElementID: chapter.ElementID,
+				Orphaned:  chapter.Orphaned,
+			}
+			if end := chapterEnd(result.Chapters, i, durations[n]); end > 0 {
+				item.EndTime, item.Duration = end.Milliseconds(), (end - chapter.StartTime).Milliseconds()
+			}
+			entry.Chapters = append(entry.Chapters, item)
+			if chapter.Orphaned {
+				entry.OrphanedChapters = append(entry.OrphanedChapters, chapter.ElementID)
+			}
+		}
+		report.Chapters += len(result.Chapters)
+		report.Results = append(report.Results, entry)
+	}
+
+	// Print report
+	switch {
+	case *asJSON:
+		encoder := json.NewEncoder(c.stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	case listing.Layout == "tsv" || listing.Layout == "csv":
+		// One table of all files with a source column, for spreadsheets; errors go to stderr only
+		var rows [][]string
+		for i, result := range results {
+			if result.Err != nil {
+				fmt.Fprintf(c.stderr, "Error occurred while reading chapters from '%s': %v\n", result.Source, result.Err)
+				continue
+			}
+			for _, row := range chapterRows(result.Chapters, durations[i], listing) {
+				rows = append(rows, append([]string{result.Source}, row...))
+			}
+		}
+		listing.writeSeparated(c.stdout, append([]string{"source"}, chapterTableHeader...), rows)
+	default:
+		for i, result := range results {
+			if result.Err != nil {
+				fmt.Fprintf(c.stderr, "Error occurred while reading chapters from '%s': %v\n", result.Source, result.Err)
+				continue
+			}
+			fmt.Fprintf(c.stdout, "%s: %d chapters\n", result.Source, len(result.Chapters))
+			if len(result.Chapters) > 0 {
+				c.printChapterTable(result.Chapters, durations[i], listing)
+			}
+			if ids := report.Results[i].OrphanedChapters; len(ids) > 0 {
+				fmt.Fprintf(c.stdout, "Warning: chapters not listed in any table of contents: %s\n", strings.Join(ids, ", "))
+			}
+			if ids := report.Results[i].DanglingReferences; len(ids) > 0 {
+				fmt.Fprintf(c.stdout, "Warning: table of contents entries without a chapter: %s\n", strings.Join(ids, ", "))
+			}
+			if names := report.Results[i].TrailingTags; len(names) > 0 {
+				fmt.Fprintf(c.stdout, "Trailing tags: %s\n", strings.Join(names, ", "))
+			}
+		}
+		if len(results) > 1 {
+			fmt.Fprintf(c.stdout, "Read %d chapters from %d files (%d errors)\n", report.Chapters, report.Files, report.Errors)
+		}
+	}
+
+	if report.Errors > 0 {
+		return 1
+	}
+	return 0
+}
+
+// trailingTagNames returns the names of the trailing ID3v1 and APE tags of a local MP3 file
+func trailingTagNames(source string) []string {
+	if remote.IsURL(source) || !isMP3File(source) {
+		return nil
+	}
+	trailing, err := id3tag.ReadTrailingTags(source)
+	if err != nil {
+		return nil
+	}
+	return trailing.Names()
+}
+
+// danglingReferences returns the table of contents entries without a chapter of a local MP3 file
+func danglingReferences(source string) []string {
+	if remote.IsURL(source) || !isMP3File(source) {
+		return nil
+	}
+	references, err := id3tag.ReadTOCReferences(source)
+	if err != nil {
+		return nil
+	}
+	return references.Dangling
+}
+
+// readSourceChapters reads the chapters of a local file, or of a remote MP3 file by downloading only its tag
+func (c *cli) readSourceChapters(source string) ([]id3tag.Chapter, error) {
+	if !remote.IsURL(source) {
+		if !fileExists(source) {
+			return nil, fmt.Errorf("File not found")
+		}
+		return readChapters(c.ffmpeg, source)
+	}
+
+	reader := remote.NewRangeReader(source, remote.DefaultChunkSize)
+	defer reader.Close()
+	chapters, err := id3tag.ReadChaptersFrom(reader)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(c.stderr, "Downloaded %d bytes in %d requests from '%s'\n", reader.Downloaded, reader.Requests, source)
+	return chapters, nil
+}
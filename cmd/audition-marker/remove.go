@@ -0,0 +1,96 @@
+package auditionmarker
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+)
+
+// executeRemove removes chapters of an MP3 file selected by number, element ID or start time range
+func (c *cli) executeRemove(args []string) int {
+	// Define remove options
+	flags := flag.NewFlagSet("remove", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	selectors := flags.String("chapter", "", "Comma-separated numbers in start time order (as listed by read) or element IDs of the chapters to remove")
+	between := flags.String("between", "", "Remove the chapters starting from this time up to the time given as the next argument, e.g. -between 10:00 20:00")
+	outputPath := flags.String("output", "", "Path for the edited MP3 (default: edit the input file in place)")
+	followSymlinks := flags.Bool("follow-symlinks", false, followSymlinksUsage)
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: %s remove (-chapter <number or ID>[,...] | -between <from> <to>) [-output <MP3 file>] [-follow-symlinks] <MP3 file>\n\n", c.name)
+		fmt.Fprintf(c.stderr, "Removes the CHAP frames and their table of contents entries; a preceding chapter\n")
+		fmt.Fprintf(c.stderr, "that ended where a removed chapter started is extended over it.\n\n")
+		fmt.Fprintf(c.stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return parseErrorCode(err)
+	}
+
+	// The end of the -between range is the first argument after the options
+	rest := flags.Args()
+	selection := id3tag.ChapterSelection{Chapters: splitList(*selectors)}
+	if *between != "" {
+		if len(rest) == 0 {
+			fmt.Fprintln(c.stderr, "Error: -between requires a start and an end time")
+			flags.Usage()
+			return 1
+		}
+		from, err := csvparser.ParseTime(*between)
+		if err == nil {
+			selection.From = from
+			selection.To, err = csvparser.ParseTime(rest[0])
+		}
+		if err != nil {
+			fmt.Fprintf(c.stderr, "Error: Invalid time range: %v\n", err)
+			return 1
+		}
+		if selection.To <= selection.From {
+			fmt.Fprintln(c.stderr, "Error: The end of the time range must be after its start")
+			return 1
+		}
+		// Options may follow the range
+		flags.Parse(rest[1:])
+		rest = flags.Args()
+	}
+
+	// Validate options
+	if (len(selection.Chapters) == 0 && *between == "") || len(rest) != 1 {
+		fmt.Fprintln(c.stderr, "Error: chapters or a time range and exactly one MP3 file are required")
+		flags.Usage()
+		return 1
+	}
+	inputPath := rest[0]
+	inputPath, target, err := validateChapterEditPaths(inputPath, *outputPath, *followSymlinks)
+	if err != nil {
+		fmt.Fprintln(c.stderr, "Error:", err)
+		return 1
+	}
+	*outputPath = target
+
+	// Remove chapters
+	changes, err := id3tag.RemoveChapters(inputPath, *outputPath, selection, c.prompter())
+	if err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while removing chapters: %v\n", err)
+		return 1
+	}
+	if len(changes) == 0 {
+		fmt.Fprintln(c.stdout, "No chapters matched; nothing to remove.")
+		return 0
+	}
+	for _, change := range changes {
+		fmt.Fprintln(c.stdout, change)
+	}
+
+	chapters, err := readChapters(nil, *outputPath)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while reading chapters: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(c.stdout, "Done! %d chapters left in '%s'\n", len(chapters), *outputPath)
+	if len(chapters) > 0 {
+		c.printChapterTable(chapters, audioDuration(*outputPath), listingOptions{})
+	}
+	return 0
+}
@@ -0,0 +1,75 @@
+package auditionmarker
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/safefile"
+)
+
+// executeRepair rebuilds malformed chapter structures of an MP3 file
+func (c *cli) executeRepair(args []string) int {
+	// Define repair options
+	flags := flag.NewFlagSet("repair", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	inputPath := flags.String("input", "", "Path to the MP3 file to repair (required)")
+	outputPath := flags.String("output", "", "Path for the repaired MP3 (default: filename_repaired.mp3; the input path repairs in place)")
+	dryRun := flags.Bool("dry-run", false, "Only list the changes without writing a file")
+	followSymlinks := flags.Bool("follow-symlinks", false, followSymlinksUsage+" (with -output set to the input)")
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: %s repair -input <MP3 file> [-output <MP3 file>] [-dry-run] [-follow-symlinks]\n\n", c.name)
+		fmt.Fprintf(c.stderr, "Sorts chapters, regenerates missing or duplicate element IDs, fixes end times and\n")
+		fmt.Fprintf(c.stderr, "title encodings, removes malformed frames and rebuilds a single table of contents.\n\n")
+		fmt.Fprintf(c.stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return parseErrorCode(err)
+	}
+
+	// Validate options
+	if *inputPath == "" {
+		fmt.Fprintln(c.stderr, "Error: input path is required")
+		flags.Usage()
+		return 1
+	}
+	if !fileExists(*inputPath) {
+		fmt.Fprintln(c.stderr, "Error:", notFoundError("Input MP3 file", *inputPath))
+		return 1
+	}
+	if *outputPath == "" {
+		ext := filepath.Ext(*inputPath)
+		*outputPath = (*inputPath)[:len(*inputPath)-len(ext)] + "_repaired" + ext
+	}
+	input, output, err := resolveInPlacePaths(*inputPath, *outputPath, *followSymlinks)
+	if err == nil && !*dryRun {
+		err = safefile.CheckWritable(output)
+	}
+	if err != nil {
+		fmt.Fprintln(c.stderr, "Error:", err)
+		return 1
+	}
+	*inputPath, *outputPath = input, output
+
+	// Repair chapters
+	changes, err := id3tag.Repair(*inputPath, *outputPath, *dryRun, c.prompter())
+	if err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while repairing chapters: %v\n", err)
+		return 1
+	}
+	for _, change := range changes {
+		fmt.Fprintln(c.stdout, change)
+	}
+
+	switch {
+	case len(changes) == 0:
+		fmt.Fprintf(c.stdout, "No repairs needed for '%s'\n", *inputPath)
+	case *dryRun:
+		fmt.Fprintf(c.stdout, "%d changes would be made (dry run)\n", len(changes))
+	default:
+		fmt.Fprintf(c.stdout, "Done! Repaired MP3 file has been saved to '%s'\n", *outputPath)
+	}
+	return 0
+}
@@ -0,0 +1,174 @@
+package auditionmarker
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+)
+
+// testMarkers is an Audition CSV file with two markers
+const testMarkers = "Name\tStart\tDuration\tTime Format\tType\tDescription\n" +
+	"Intro\t0:00.000\t0:00.000\tdecimal\tCue\t\n" +
+	"Talk\t0:10.000\t0:00.000\tdecimal\tCue\t\n"
+
+// writeTestFiles writes an untagged MP3 file of 26 seconds and a marker file to dir
+func writeTestFiles(t *testing.T, dir string) {
+	t.Helper()
+	frame := make([]byte, 417) // Silent MPEG-1 Layer III frame, 128 kbit/s, 44.1 kHz
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0x00})
+	if err := os.WriteFile(filepath.Join(dir, "episode.mp3"), bytes.Repeat(frame, 1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "markers.csv"), []byte(testMarkers), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string // "{dir}" is replaced with the directory of the test files
+		stdin    string
+		code     int
+		stdout   []string // Substrings expected in the standard output
+		stderr   []string // Substrings expected in the standard error
+		chapters int      // Chapters expected in episode.mp3 afterwards
+	}{
+		{
+			name:   "help",
+			args:   []string{"-h"},
+			code:   0,
+			stderr: []string{"Usage: audition-marker -csv"},
+		},
+		{
+			name:   "subcommand help",
+			args:   []string{"add", "-h"},
+			code:   0,
+			stderr: []string{"Usage: audition-marker add"},
+		},
+		{
+			name:   "unknown flag",
+			args:   []string{"-bogus"},
+			code:   2,
+			stderr: []string{"-bogus"},
+		},
+		{
+			name:   "missing input",
+			args:   []string{"-input", "{dir}/missing.mp3", "-csv", "{dir}/markers.csv"},
+			code:   1,
+			stderr: []string{"missing.mp3"},
+		},
+		{
+			name:   "new output",
+			args:   []string{"-input", "{dir}/episode.mp3", "-csv", "{dir}/markers.csv", "-output", "{dir}/out.mp3"},
+			code:   0,
+			stdout: []string{"Verified: all 2 markers were written"},
+		},
+		{
+			name:     "in place confirmed",
+			args:     []string{"-input", "{dir}/episode.mp3", "-csv", "{dir}/markers.csv", "-output", "{dir}/episode.mp3"},
+			stdin:    "y\n",
+			code:     0,
+			stdout:   []string{"This will modify the original file", "Verified: all 2 markers were written"},
+			chapters: 2,
+		},
+		{
+			name:     "in place declined",
+			args:     []string{"-input", "{dir}/episode.mp3", "-csv", "{dir}/markers.csv", "-output", "{dir}/episode.mp3"},
+			stdin:    "n\n",
+			code:     1,
+			stdout:   []string{"This will modify the original file"},
+			stderr:   []string{"Operation cancelled by user"},
+			chapters: 0,
+		},
+		{
+			name:     "add in place confirmed",
+			args:     []string{"add", "-chapter", "0=Intro", "-chapter", "5=Topic", "-output", "{dir}/episode.mp3", "{dir}/episode.mp3"},
+			stdin:    "yes\n",
+			code:     0,
+			stdout:   []string{"Topic"},
+			chapters: 2,
+		},
+		{
+			name:   "read untagged",
+			args:   []string{"read", "{dir}/episode.mp3"},
+			code:   0,
+			stdout: []string{"episode.mp3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestFiles(t, dir)
+			args := make([]string, len(tt.args))
+			for i, arg := range tt.args {
+				args[i] = strings.ReplaceAll(arg, "{dir}", dir)
+			}
+
+			var stdout, stderr bytes.Buffer
+			if code := Run(args, strings.NewReader(tt.stdin), &stdout, &stderr); code != tt.code {
+				t.Fatalf("Run(%q) = %d, want %d\nstdout:\n%s\nstderr:\n%s", tt.args, code, tt.code, stdout.String(), stderr.String())
+			}
+			for _, want := range tt.stdout {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("stdout does not contain %q:\n%s", want, stdout.String())
+				}
+			}
+			for _, want := range tt.stderr {
+				if !strings.Contains(stderr.String(), want) {
+					t.Errorf("stderr does not contain %q:\n%s", want, stderr.String())
+				}
+			}
+
+			chapters, err := id3tag.ReadChapters(filepath.Join(dir, "episode.mp3"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(chapters) != tt.chapters {
+				t.Errorf("episode.mp3 has %d chapters after the run, want %d", len(chapters), tt.chapters)
+			}
+		})
+	}
+}
+
+func TestRunFFmpegNotShared(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ffmpeg is a shell script")
+	}
+	dir := t.TempDir()
+	writeTestFiles(t, dir)
+	if err := os.WriteFile(filepath.Join(dir, "episode.wav"), []byte("RIFF"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fake ffmpeg and ffprobe that log their calls
+	calls := filepath.Join(dir, "calls.log")
+	for _, name := range []string{"ffmpeg", "ffprobe"} {
+		script := "#!/bin/sh\necho " + name + " \"$@\" >> " + calls + "\necho '" + name + " version fake'\n"
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"-ffmpeg", filepath.Join(dir, "ffmpeg"), "-input", filepath.Join(dir, "episode.mp3"), "-csv", filepath.Join(dir, "markers.csv"), "-output", filepath.Join(dir, "out.mp3")}
+	if code := Run(args, strings.NewReader(""), &stdout, &stderr); code != 0 {
+		t.Fatalf("Run with -ffmpeg = %d, want 0\nstderr:\n%s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Using ffmpeg version fake") {
+		t.Errorf("stdout does not report the ffmpeg version:\n%s", stdout.String())
+	}
+	before, _ := os.ReadFile(calls)
+
+	// A later run without -ffmpeg must not use the tool of the previous run
+	Run([]string{"read", filepath.Join(dir, "episode.wav")}, strings.NewReader(""), &stdout, &stderr)
+	if after, _ := os.ReadFile(calls); !bytes.Equal(after, before) {
+		t.Errorf("run without -ffmpeg called the fake ffmpeg:\n%s", after[len(before):])
+	}
+}
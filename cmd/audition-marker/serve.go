@@ -0,0 +1,107 @@
+package auditionmarker
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/queue"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/server"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/storage"
+)
+
+// executeServe runs the HTTP API server
+func (c *cli) executeServe(args []string) int {
+	// Define serve options
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	addr := flags.String("addr", ":8080", "Address to listen on")
+	workDir := flags.String("workdir", "", "Directory for uploaded and tagged files (default: a new temporary directory)")
+	maxUpload := flags.Int64("max-upload", 1<<30, "Maximum upload size in bytes")
+	maxCSV := flags.Int64("max-csv-size", 10<<20, "Maximum size of an uploaded marker CSV file in bytes")
+	maxMarkers := flags.Int("max-markers", 10000, "Maximum number of markers in an upload")
+	outputs := flags.String("storage", "", "Directory or storage URL where tagged uploads are kept, e.g. s3://bucket/prefix (default: the job directories in -workdir)")
+	tagConcurrency := flags.Int("concurrency", 0, "Number of files tagged at the same time, uploads and queued jobs together (default: number of CPUs)")
+	jobTTL := flags.Duration("job-ttl", 24*time.Hour, "How long finished jobs and their files are kept")
+	webhookURL := flags.String("webhook", "", "URL to POST a JSON notification to after each processed file")
+	queueURL := flags.String("queue", "", "Message queue to consume tagging jobs from, e.g. redis://localhost:6379/0")
+	jobQueue := flags.String("queue-jobs", "audition-marker:jobs", "Name of the queue job messages are read from")
+	resultQueue := flags.String("queue-results", "audition-marker:results", "Name of the queue results are published to (empty to not publish)")
+	concurrency := flags.Int("queue-concurrency", 2, "Number of queued jobs processed at the same time")
+	retries := flags.Int("queue-retries", 2, "Number of retries of a failed queued job")
+	backoff := flags.Duration("queue-backoff", time.Second, "Wait before the first retry of a queued job, doubled for each further retry")
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: %s serve [-addr <address>] [-workdir <directory>] [-queue <url>]\n\n", c.name)
+		fmt.Fprintf(c.stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return parseErrorCode(err)
+	}
+	if *concurrency < 1 || *retries < 0 || *backoff <= 0 {
+		fmt.Fprintln(c.stderr, "Error: -queue-concurrency must be at least 1, -queue-retries 0 or more and -queue-backoff positive")
+		return 1
+	}
+	if *tagConcurrency < 0 || *jobTTL <= 0 {
+		fmt.Fprintln(c.stderr, "Error: -concurrency must be 0 or more and -job-ttl positive")
+		return 1
+	}
+
+	// Resolve the storage of tagged uploads
+	var outputStorage storage.Storage
+	var outputPrefix string
+	if *outputs != "" {
+		var err error
+		if outputStorage, outputPrefix, err = storage.For(*outputs); err != nil {
+			fmt.Fprintln(c.stderr, "Error:", err)
+			return 1
+		}
+	}
+
+	// Create server
+	srv, err := server.New(server.Options{
+		WorkDir:       *workDir,
+		MaxUploadSize: *maxUpload,
+		MaxCSVSize:    *maxCSV,
+		MaxMarkers:    *maxMarkers,
+		WebhookURL:    *webhookURL,
+		Logger:        log.New(c.stderr, "", log.LstdFlags),
+		Concurrency:   *tagConcurrency,
+		JobTTL:        *jobTTL,
+		Outputs:       outputStorage,
+		OutputPrefix:  outputPrefix,
+	})
+	if err != nil {
+		fmt.Fprintln(c.stderr, "Error:", err)
+		return 1
+	}
+
+	// Connect to the job queue
+	errs := make(chan error, 2)
+	if *queueURL != "" {
+		q, err := queue.Open(*queueURL, queue.Options{Jobs: *jobQueue, Results: *resultQueue})
+		if err != nil {
+			fmt.Fprintln(c.stderr, "Error:", err)
+			return 1
+		}
+		defer q.Close()
+
+		fmt.Fprintf(c.stdout, "Consuming jobs from %s (%d at a time)...\n", *jobQueue, *concurrency)
+		go func() {
+			errs <- srv.Consume(q, server.QueueOptions{Concurrency: *concurrency, Retries: *retries, Backoff: *backoff})
+		}()
+	}
+
+	// Start listening
+	fmt.Fprintf(c.stdout, "Listening on %s...\n", *addr)
+	go func() {
+		errs <- http.ListenAndServe(*addr, srv.Handler())
+	}()
+	if err := <-errs; err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while serving: %v\n", err)
+		return 1
+	}
+	return 0
+}
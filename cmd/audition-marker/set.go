@@ -0,0 +1,144 @@
+package auditionmarker
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/artwork"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/safefile"
+)
+
+// executeSetImage replaces the artwork of a single chapter of an MP3 file
+func (c *cli) executeSetImage(args []string) int {
+	// Define set-image options
+	flags := flag.NewFlagSet("set-image", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	selector := flags.String("chapter", "", "Number of the chapter in start time order (as listed by read) or its element ID (required)")
+	outputPath := flags.String("output", "", "Path for the edited MP3 (default: edit the input file in place)")
+	followSymlinks := flags.Bool("follow-symlinks", false, followSymlinksUsage)
+	imageSize := flags.Int("chapter-image-size", artwork.DefaultSize, "Maximum width and height of the artwork in pixels")
+	imageFormat := flags.String("image-format", artwork.DefaultFormat, "Encoding of the embedded artwork: jpeg or png")
+	imageQuality := flags.Int("image-quality", artwork.DefaultQuality, "JPEG quality of the embedded artwork (1-100)")
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: %s set-image -chapter <number or ID> [-output <MP3 file>] [-follow-symlinks] <image file> <MP3 file>\n\n", c.name)
+		fmt.Fprintf(c.stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return parseErrorCode(err)
+	}
+
+	// Validate options
+	if *selector == "" || flags.NArg() != 2 {
+		fmt.Fprintln(c.stderr, "Error: chapter, image file and MP3 file are required")
+		flags.Usage()
+		return 1
+	}
+	imagePath, inputPath := flags.Arg(0), flags.Arg(1)
+	if !fileExists(imagePath) {
+		fmt.Fprintf(c.stderr, "Error: Image file '%s' not found\n", imagePath)
+		return 1
+	}
+	inputPath, target, err := validateChapterEditPaths(inputPath, *outputPath, *followSymlinks)
+	if err != nil {
+		fmt.Fprintln(c.stderr, "Error:", err)
+		return 1
+	}
+	*outputPath = target
+
+	// Load artwork
+	image, err := artwork.Load(imagePath, artwork.Options{Size: *imageSize, Format: *imageFormat, Quality: *imageQuality})
+	if err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while loading image: %v\n", err)
+		return 1
+	}
+
+	// Set artwork
+	changes, err := id3tag.SetChapterImage(inputPath, *outputPath, *selector, image, c.prompter())
+	if err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while setting chapter image: %v\n", err)
+		return 1
+	}
+	c.showChapterEdit(changes, *outputPath)
+	return 0
+}
+
+// executeSetURL replaces the link of a single chapter of an MP3 file
+func (c *cli) executeSetURL(args []string) int {
+	// Define set-url options
+	flags := flag.NewFlagSet("set-url", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	selector := flags.String("chapter", "", "Number of the chapter in start time order (as listed by read) or its element ID (required)")
+	outputPath := flags.String("output", "", "Path for the edited MP3 (default: edit the input file in place)")
+	followSymlinks := flags.Bool("follow-symlinks", false, followSymlinksUsage)
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: %s set-url -chapter <number or ID> [-output <MP3 file>] [-follow-symlinks] <URL> <MP3 file>\n\n", c.name)
+		fmt.Fprintf(c.stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return parseErrorCode(err)
+	}
+
+	// Validate options
+	if *selector == "" || flags.NArg() != 2 {
+		fmt.Fprintln(c.stderr, "Error: chapter, URL and MP3 file are required")
+		flags.Usage()
+		return 1
+	}
+	link, inputPath := flags.Arg(0), flags.Arg(1)
+	if parsed, err := url.Parse(link); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		fmt.Fprintf(c.stderr, "Error: Invalid URL '%s'\n", link)
+		return 1
+	}
+	inputPath, target, err := validateChapterEditPaths(inputPath, *outputPath, *followSymlinks)
+	if err != nil {
+		fmt.Fprintln(c.stderr, "Error:", err)
+		return 1
+	}
+	*outputPath = target
+
+	// Set link
+	changes, err := id3tag.SetChapterURL(inputPath, *outputPath, *selector, link, c.prompter())
+	if err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while setting chapter URL: %v\n", err)
+		return 1
+	}
+	c.showChapterEdit(changes, *outputPath)
+	return 0
+}
+
+// validateChapterEditPaths checks the MP3 file of a chapter edit and returns the input and output paths, the output defaulting to the input
+func validateChapterEditPaths(inputPath, outputPath string, followSymlinks bool) (string, string, error) {
+	if !fileExists(inputPath) {
+		return "", "", notFoundError("Input MP3 file", inputPath)
+	}
+	if !isMP3File(inputPath) {
+		return "", "", fmt.Errorf("Input file '%s' is not an MP3 file", inputPath)
+	}
+	if outputPath == "" {
+		outputPath = inputPath
+	}
+	inputPath, outputPath, err := resolveInPlacePaths(inputPath, outputPath, followSymlinks)
+	if err != nil {
+		return "", "", err
+	}
+	if err := safefile.CheckWritable(outputPath); err != nil {
+		return "", "", err
+	}
+	return inputPath, outputPath, nil
+}
+
+// showChapterEdit prints the changes of a chapter edit
+func (c *cli) showChapterEdit(changes []string, outputPath string) {
+	if len(changes) == 0 {
+		fmt.Fprintln(c.stdout, "Chapter already has the given value; nothing to change.")
+		return
+	}
+	for _, change := range changes {
+		fmt.Fprintln(c.stdout, change)
+	}
+	fmt.Fprintf(c.stdout, "Done! Edited chapter saved to '%s'\n", outputPath)
+}
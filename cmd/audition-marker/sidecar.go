@@ -0,0 +1,105 @@
+package auditionmarker
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/config"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/mp3frame"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/sidecar"
+)
+
+// executeApplySidecar embeds the chapters of a sidecar file into an MP3 file
+func (c *cli) executeApplySidecar(args []string) int {
+	// Define apply-sidecar options
+	flags := flag.NewFlagSet("apply-sidecar", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	inputPath := flags.String("input", "", "Path to the MP3 file to add chapters to (required)")
+	sidecarPath := flags.String("sidecar", "", "Path to the JSON or WebVTT sidecar file (default: the .chapters.json or .chapters.vtt file next to the input)")
+	outputPath := flags.String("output", "", "Path for output MP3 with chapters (default: filename_with_chapters.mp3)")
+	presetName := flags.String("preset", "", "Compatibility preset for the target player: apple, spotify, generic, audiobook or a custom preset from the config file")
+	configPath := flags.String("config", "", "Path to the config file (default: "+config.DefaultPath()+")")
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: %s apply-sidecar -input <MP3 file> [-sidecar <chapters.json|.vtt>] [-output <MP3 file>] [-preset <name>]\n\n", c.name)
+		fmt.Fprintf(c.stderr, "Embeds chapters written earlier with -sidecar into the MP3 file.\n\n")
+		fmt.Fprintf(c.stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return parseErrorCode(err)
+	}
+
+	// Validate options
+	if *inputPath == "" {
+		fmt.Fprintln(c.stderr, "Error: input path is required")
+		flags.Usage()
+		return 1
+	}
+	if !fileExists(*inputPath) {
+		fmt.Fprintf(c.stderr, "Error: Input MP3 file '%s' not found\n", *inputPath)
+		return 1
+	}
+	if !isMP3File(*inputPath) || (*outputPath != "" && !isMP3File(*outputPath)) {
+		fmt.Fprintln(c.stderr, "Error: Sidecar files can only be applied to MP3 files")
+		return 1
+	}
+	if *sidecarPath == "" {
+		path, ok := sidecar.Find(*inputPath)
+		if !ok {
+			fmt.Fprintf(c.stderr, "Error: No sidecar file found next to '%s'\n", *inputPath)
+			return 1
+		}
+		*sidecarPath = path
+	}
+
+	// Read sidecar
+	markers, err := sidecar.Read(*sidecarPath)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while reading sidecar file: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(c.stdout, "Loaded %d chapters from '%s'\n", len(markers), *sidecarPath)
+
+	// Apply preset if requested
+	options := id3tag.Options{Prompt: c.prompter()}
+	if *presetName != "" {
+		p, err := loadPreset(*presetName, *configPath)
+		if err != nil {
+			fmt.Fprintln(c.stderr, "Error:", err)
+			return 1
+		}
+		fmt.Fprintf(c.stdout, "Using preset '%s': %s\n", p.Name, p.Description)
+		p.Apply(&options)
+	}
+
+	// Add chapter tags
+	fmt.Fprintln(c.stdout, "Adding chapter tags to audio file...")
+	if err := id3tag.AddChaptersWithOptions(*inputPath, markers, *outputPath, options); err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while adding chapter tags: %v\n", err)
+		return 1
+	}
+
+	targetFile := determineOutputPath(*inputPath, *outputPath)
+	c.showSuccessMessage(targetFile)
+	if err := c.verifyAndShowChapters(targetFile, markers, 0, nil, listingOptions{}); err != nil {
+		fmt.Fprintf(c.stderr, "Error: Verification of the output file failed: %v\n", err)
+		return exitVerificationFailed
+	}
+	return 0
+}
+
+// writeSidecar writes markers to the sidecar file of an audio file, using the MP3 length as the end of the last chapter
+func writeSidecar(audioPath string, format string, markers []csvparser.MarkerEntry) (string, error) {
+	var duration time.Duration
+	if isMP3File(audioPath) {
+		info, err := mp3frame.ScanFile(audioPath)
+		if err != nil {
+			return "", err
+		}
+		duration = info.Duration()
+	}
+	return sidecar.Write(audioPath, format, markers, duration)
+}
@@ -0,0 +1,171 @@
+package auditionmarker
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/chapterstats"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/mp3frame"
+)
+
+// executeStats reports chapter statistics for files and directories of tagged audio files
+func (c *cli) executeStats(args []string) int {
+	// Define stats options
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: %s stats <tagged MP3/Ogg file or directory> ...\n\n", c.name)
+		fmt.Fprintf(c.stderr, "Directories are searched recursively for MP3 and Ogg files.\n")
+		fmt.Fprintf(c.stderr, "Lengths of the last chapter and uncovered audio are only known for MP3 files.\n")
+	}
+	if err := flags.Parse(args); err != nil {
+		return parseErrorCode(err)
+	}
+
+	if flags.NArg() == 0 {
+		fmt.Fprintln(c.stderr, "Error: at least one file or directory is required")
+		flags.Usage()
+		return 1
+	}
+
+	// Collect files
+	paths, err := collectAudioFiles(flags.Args())
+	if err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while collecting files: %v\n", err)
+		return 1
+	}
+	if len(paths) == 0 {
+		fmt.Fprintln(c.stderr, "Error: No MP3 or Ogg files found")
+		return 1
+	}
+
+	// Compute statistics per file
+	var total chapterstats.Stats
+	writer := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "File\tChapters\tMin\tAvg\tMax\tGaps\tUncovered")
+	for _, path := range paths {
+		chapters, duration, err := loadStatsChapters(path)
+		if err != nil {
+			fmt.Fprintf(c.stderr, "Warning: Skipping '%s': %v\n", path, err)
+			continue
+		}
+		stats := chapterstats.Compute(chapters, duration)
+		total.Merge(stats)
+		fmt.Fprintf(writer, "%s\t%d\t%s\t%s\t%s\t%d\t%s\n", path, stats.Chapters,
+			formatStatsDuration(stats.MinLength), formatStatsDuration(stats.AvgLength), formatStatsDuration(stats.MaxLength),
+			stats.Gaps, formatStatsDuration(stats.Uncovered))
+	}
+	writer.Flush()
+
+	c.printStatsSummary(total)
+	return 0
+}
+
+// collectAudioFiles expands directories into the MP3 and Ogg files they contain
+func collectAudioFiles(args []string) ([]string, error) {
+	var paths []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+
+		err = filepath.WalkDir(arg, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.IsDir() && (isMP3File(path) || isOggFile(path) || isMP4File(path)) {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
+// loadStatsChapters reads the chapters with end times and the audio length of a file
+func loadStatsChapters(path string) ([]chapterstats.Chapter, time.Duration, error) {
+	// Ogg and MP4 chapters are read without end times and the audio length is not known
+	if !isMP3File(path) {
+		oggChapters, err := readChapters(nil, path)
+		if err != nil {
+			return nil, 0, err
+		}
+		chapters := make([]chapterstats.Chapter, 0, len(oggChapters))
+		for _, chapter := range oggChapters {
+			chapters = append(chapters, chapterstats.Chapter{Title: chapter.Title, Start: chapter.StartTime})
+		}
+		return chapters, 0, nil
+	}
+
+	tag, err := id3tag.ReadRawTag(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := mp3frame.ScanFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var chapters []chapterstats.Chapter
+	if tag != nil {
+		for _, chapter := range tag.Chapters() {
+			chapters = append(chapters, chapterstats.Chapter{
+				Title: chapter.Title(),
+				Start: time.Duration(chapter.StartTime) * time.Millisecond,
+				End:   time.Duration(chapter.EndTime) * time.Millisecond,
+			})
+		}
+	}
+	return chapters, info.Duration(), nil
+}
+
+// printStatsSummary prints the statistics over all files
+func (c *cli) printStatsSummary(total chapterstats.Stats) {
+	fmt.Fprintln(c.stdout, "------------------------------------------------------------")
+	fmt.Fprintf(c.stdout, "Files:          %d\n", total.Files)
+	fmt.Fprintf(c.stdout, "Chapters:       %d\n", total.Chapters)
+	if total.Chapters == 0 {
+		return
+	}
+	fmt.Fprintf(c.stdout, "Chapter length: min %s, avg %s, max %s\n",
+		formatStatsDuration(total.MinLength), formatStatsDuration(total.AvgLength), formatStatsDuration(total.MaxLength))
+	fmt.Fprintf(c.stdout, "Gaps:           %d (%s in total)\n", total.Gaps, formatStatsDuration(total.GapTotal))
+	if total.Duration > 0 {
+		fmt.Fprintf(c.stdout, "Not covered:    %s of %s (%.1f%%)\n", formatStatsDuration(total.Uncovered), formatStatsDuration(total.Duration),
+			100*total.Uncovered.Seconds()/total.Duration.Seconds())
+	}
+	fmt.Fprintf(c.stdout, "Title length:   min %d, avg %d, max %d characters\n", total.MinTitle, total.AvgTitle, total.MaxTitle)
+
+	// Title length histogram
+	lower := 0
+	for i, count := range total.TitleHistogram {
+		label := fmt.Sprintf("%d+", lower)
+		if i < len(chapterstats.TitleBuckets) {
+			label = fmt.Sprintf("%d-%d", lower, chapterstats.TitleBuckets[i])
+			lower = chapterstats.TitleBuckets[i] + 1
+		}
+		fmt.Fprintf(c.stdout, "  %-7s %4d\n", label, count)
+	}
+}
+
+// formatStatsDuration formats a duration for the statistics, or "-" if it is not known
+func formatStatsDuration(d time.Duration) string {
+	if d <= 0 {
+		return "-"
+	}
+	return id3tag.FormatDuration(d)
+}
@@ -0,0 +1,168 @@
+package auditionmarker
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/remote"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/storage"
+)
+
+// stageRemoteFiles downloads the input and marker files of the main command given as storage URLs, such as s3://bucket/key,
+// to a temporary directory and points the configuration at the local copies. A remote output, or the default output of a
+// remote input, is written to the directory as well and uploaded by uploadOutputs. HTTP(S) marker files are downloaded
+// when they are parsed, as before.
+func (c *cli) stageRemoteFiles(config *Config) error {
+	// file:// URLs name local files
+	for _, location := range append([]*string{&config.InputMP3, &config.OutputMP3}, csvPathRefs(config)...) {
+		*location = localName(*location)
+	}
+	config.CSVPath = config.CSVPaths[0]
+
+	remoteInput := storage.IsRemote(config.InputMP3)
+	output := config.OutputMP3
+	if remoteInput && output == "" && config.Sidecar == "" {
+		output = determineOutputPath(config.InputMP3, "")
+	}
+	remoteOutput := config.Sidecar == "" && storage.IsRemote(output)
+	stageCSV := false
+	for _, csvPath := range config.CSVPaths {
+		stageCSV = stageCSV || storage.IsRemote(csvPath) && !remote.IsURL(csvPath)
+	}
+	if !remoteInput && !remoteOutput && !stageCSV {
+		return nil
+	}
+	if (remoteInput || remoteOutput) && config.Checksums != "" {
+		return fmt.Errorf("-checksums cannot be used with a remote input or output; record the checksums where the files are downloaded")
+	}
+
+	dir, err := c.stagingDirectory()
+	if err != nil {
+		return err
+	}
+
+	// Marker files keep their names, which tell their format, in a directory each
+	for i, csvPath := range config.CSVPaths {
+		if !storage.IsRemote(csvPath) || remote.IsURL(csvPath) {
+			continue
+		}
+		local, err := c.download(csvPath, filepath.Join(dir, "markers"+strconv.Itoa(i)), "CSV file")
+		if err != nil {
+			return err
+		}
+		config.CSVPaths[i] = local
+	}
+	config.CSVPath = config.CSVPaths[0]
+
+	// Files written next to the input in sidecar mode go next to the remote input
+	if remoteInput {
+		if config.Sidecar != "" {
+			config.remoteOutput = config.InputMP3
+		}
+		if config.InputMP3, err = c.download(config.InputMP3, filepath.Join(dir, "input"), "Input MP3 file"); err != nil {
+			return err
+		}
+	}
+	if remoteOutput {
+		config.remoteOutput = output
+		config.OutputMP3 = filepath.Join(dir, "output", filepath.Base(output))
+		if err := os.MkdirAll(filepath.Dir(config.OutputMP3), 0755); err != nil {
+			return fmt.Errorf("Cannot create a directory for remote files: %w", err)
+		}
+	}
+	return nil
+}
+
+// localName returns the path of a file:// URL, and any other location unchanged
+func localName(location string) string {
+	if s, name, err := storage.For(location); err == nil && s == (storage.Local{}) {
+		return name
+	}
+	return location
+}
+
+// stagingDirectory returns the temporary directory for local copies of remote files, creating it on first use
+func (c *cli) stagingDirectory() (string, error) {
+	if c.stagingDir == "" {
+		dir, err := os.MkdirTemp("", "audition-marker-*")
+		if err != nil {
+			return "", fmt.Errorf("Cannot create a directory for remote files: %w", err)
+		}
+		c.stagingDir = dir
+	}
+	return c.stagingDir, nil
+}
+
+// csvPathRefs returns pointers to the marker file paths of the configuration
+func csvPathRefs(config *Config) []*string {
+	refs := make([]*string, len(config.CSVPaths))
+	for i := range config.CSVPaths {
+		refs[i] = &config.CSVPaths[i]
+	}
+	return refs
+}
+
+// download copies a remote file into a new directory, keeping its base name, and returns the local path
+func (c *cli) download(location, dir, what string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("Cannot create a directory for remote files: %w", err)
+	}
+	local := filepath.Join(dir, filepath.Base(location))
+	fmt.Fprintf(c.stdout, "Downloading '%s'...\n", location)
+	if err := storage.Download(location, local); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("%s '%s' not found", what, location)
+		}
+		return "", err
+	}
+	return local, nil
+}
+
+// stagePart downloads a remote part of the join subcommand, and the marker file next to it if there is one, and returns the local path
+func (c *cli) stagePart(location string, i int) (string, error) {
+	dir, err := c.stagingDirectory()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "part"+strconv.Itoa(i+1))
+	local, err := c.download(location, dir, "Input MP3 file")
+	if err != nil {
+		return "", err
+	}
+
+	// The marker file keeps its name next to the part, where loadPartMarkers looks for it
+	csvBase := strings.TrimSuffix(filepath.Base(local), filepath.Ext(local)) + ".csv"
+	err = storage.Download(storage.Sibling(location, csvBase), filepath.Join(dir, csvBase))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	return local, nil
+}
+
+// uploadOutputs uploads the output and the files written next to it, such as exports and the audit record, next to the remote output
+func (c *cli) uploadOutputs(config *Config, outputs []string) error {
+	for _, path := range outputs {
+		location := storage.Sibling(config.remoteOutput, filepath.Base(path))
+		if path == config.OutputMP3 {
+			location = config.remoteOutput
+		}
+		if err := storage.Upload(path, location); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stdout, "Uploaded '%s'\n", location)
+	}
+	return nil
+}
+
+// removeStagingDir removes the local copies of remote files once the run is over
+func (c *cli) removeStagingDir() {
+	if c.stagingDir != "" {
+		os.RemoveAll(c.stagingDir)
+		c.stagingDir = ""
+	}
+}
@@ -0,0 +1,60 @@
+package auditionmarker
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// startTrace starts the root span of the main command if tracing is configured
+func (c *cli) startTrace(config *Config) {
+	c.trace = config.tracer.Start("add-chapters")
+	c.trace.SetAttribute("audition_marker.episode", traceEpisode(config))
+	c.trace.SetAttribute("audition_marker.input", config.InputMP3)
+	c.trace.SetAttribute("audition_marker.markers_files", strings.Join(config.CSVPaths, ","))
+}
+
+// traceEpisode returns the episode ID of a run as publishers get it: -episode, or the name of the audio file without extension
+func traceEpisode(config *Config) string {
+	if config.Episode != "" {
+		return config.Episode
+	}
+	audio := config.remoteOutput
+	switch {
+	case config.Sidecar != "":
+		audio = config.InputMP3
+	case audio == "":
+		audio = determineOutputPath(config.InputMP3, config.OutputMP3)
+	}
+	base := filepath.Base(audio)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// startPhase ends the span of the current phase of the main command and starts the span of the next one
+func (c *cli) startPhase(name string) {
+	c.phase.End()
+	c.phase = c.trace.Start(name)
+}
+
+// fail records the error ending the run, if any, in the run report and in the spans of the run and the current phase
+func (c *cli) fail(err error) {
+	c.report.Fail(err)
+	c.phase.RecordError(err)
+	c.trace.RecordError(err)
+}
+
+// endTrace ends the spans of the main command and sends them to the collector; a failure to send them is only a warning
+func (c *cli) endTrace(config *Config, code int) {
+	if c.trace == nil {
+		return
+	}
+	c.phase.End()
+	c.trace.SetAttribute("audition_marker.exit_code", code)
+	c.trace.SetAttribute("audition_marker.warnings", len(c.warnings))
+	c.trace.End()
+	if err := config.tracer.Flush(context.Background()); err != nil {
+		fmt.Fprintf(c.stderr, "Warning: %v\n", err)
+	}
+	c.trace, c.phase = nil, nil
+}
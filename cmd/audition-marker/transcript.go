@@ -0,0 +1,76 @@
+package auditionmarker
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/exporter"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/transcript"
+)
+
+// executeTranscript creates chapter markers from an SRT/WebVTT transcript and writes them as a marker CSV
+func (c *cli) executeTranscript(args []string) int {
+	// Define transcript options
+	flags := flag.NewFlagSet("transcript", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	inputPath := flags.String("input", "", "Path to an SRT or WebVTT transcript (required)")
+	outputPath := flags.String("output", "", "Path for the marker CSV (default: standard output)")
+	prefixes := flags.String("prefix", "", "Comma-separated prefixes; cues starting with one start a chapter (e.g. \"Chapter\")")
+	keywords := flags.String("keywords", "", "Comma-separated keywords; cues containing one start a chapter")
+	speakers := flags.Bool("speakers", false, "Start a chapter whenever the speaker changes")
+	minGap := flags.Duration("min-gap", 0, "Skip matches closer than this to the previous chapter, e.g. 30s")
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: %s transcript -input <transcript.srt|.vtt> [-prefix <list>] [-keywords <list>] [-speakers] [-output <CSV file>]\n\n", c.name)
+		fmt.Fprintf(c.stderr, "Speakers are taken from WebVTT <v Name> tags and \"[Name]\" or upper-case \"NAME:\" prefixes.\n\n")
+		fmt.Fprintf(c.stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return parseErrorCode(err)
+	}
+
+	// Validate options
+	rules := transcript.Rules{
+		Prefixes:       splitList(*prefixes),
+		Keywords:       splitList(*keywords),
+		SpeakerChanges: *speakers,
+		MinGap:         *minGap,
+	}
+	if *inputPath == "" || (len(rules.Prefixes) == 0 && len(rules.Keywords) == 0 && !rules.SpeakerChanges) {
+		fmt.Fprintln(c.stderr, "Error: input path and at least one of -prefix, -keywords or -speakers are required")
+		flags.Usage()
+		return 1
+	}
+
+	// Parse transcript and apply rules
+	cues, err := transcript.ParseFile(*inputPath)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while parsing transcript: %v\n", err)
+		return 1
+	}
+	markers := transcript.Chapters(cues, rules)
+
+	// Write markers as Audition CSV
+	format, _ := exporter.Lookup("audition")
+	if err := c.writeExport(format, *outputPath, markers, exporter.Options{}); err != nil {
+		fmt.Fprintf(c.stderr, "Error occurred while writing markers: %v\n", err)
+		return 1
+	}
+
+	if *outputPath != "" {
+		fmt.Fprintf(c.stdout, "Done! Created %d chapters from %d cues in '%s'\n", len(markers), len(cues), *outputPath)
+	}
+	return 0
+}
+
+// splitList splits a comma-separated option value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,150 @@
+package artwork
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	_ "image/gif" // Register GIF decoder
+)
+
+// Default encoding settings
+const (
+	DefaultSize    = 600    // Maximum width and height of embedded artwork in pixels
+	DefaultFormat  = "jpeg" // Output format of embedded artwork
+	DefaultQuality = 85     // JPEG quality of embedded artwork
+)
+
+// Options configures how artwork is scaled and encoded
+type Options struct {
+	Size    int    // Maximum width and height in pixels (0 keeps the original size)
+	Format  string // Output format: "jpeg" or "png"
+	Quality int    // JPEG quality from 1 to 100
+}
+
+// DefaultOptions returns the default encoding settings
+func DefaultOptions() Options {
+	return Options{Size: DefaultSize, Format: DefaultFormat, Quality: DefaultQuality}
+}
+
+// imageExtensions lists the file extensions recognized as artwork
+var imageExtensions = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".gif": true}
+
+// Image is encoded artwork ready to embed
+type Image struct {
+	MimeType string // MIME type of Data
+	Data     []byte // Encoded image
+}
+
+// MatchDirectory finds an image in dir for each chapter title.
+// Images match by 1-based chapter number ("01.png", "1.jpg") or by slugified title ("opening-talk.png");
+// the result has an empty path for chapters without an image.
+func MatchDirectory(dir string, titles []string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read image directory: %w", err)
+	}
+
+	// Index images by number and by slug
+	byNumber := make(map[int]string)
+	bySlug := make(map[string]string)
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || !imageExtensions[ext] {
+			continue
+		}
+		base := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		path := filepath.Join(dir, entry.Name())
+		if number, err := strconv.Atoi(base); err == nil {
+			byNumber[number] = path
+		} else {
+			bySlug[Slugify(base)] = path
+		}
+	}
+
+	// Numbered images take precedence over title matches
+	paths := make([]string, len(titles))
+	for i, title := range titles {
+		if path, ok := byNumber[i+1]; ok {
+			paths[i] = path
+		} else {
+			paths[i] = bySlug[Slugify(title)]
+		}
+	}
+	return paths, nil
+}
+
+// Slugify lower-cases s and replaces every run of characters other than letters and digits with a hyphen
+func Slugify(s string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+		} else {
+			hyphen = true
+		}
+	}
+	return b.String()
+}
+
+// Load reads an image file, scales it down to fit within the configured size and re-encodes it
+func Load(path string, options Options) (*Image, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read image: %w", err)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("Cannot decode image '%s': %w", path, err)
+	}
+
+	return Encode(Fit(img, options.Size), options)
+}
+
+// Encode encodes img in the configured format
+func Encode(img image.Image, options Options) (*Image, error) {
+	switch strings.ToLower(options.Format) {
+	case "png":
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, fmt.Errorf("Failed to encode PNG: %w", err)
+		}
+		return &Image{MimeType: "image/png", Data: buf.Bytes()}, nil
+	case "jpeg", "jpg", "":
+		return encodeJPEG(img, options.Quality)
+	default:
+		return nil, fmt.Errorf("Unsupported image format '%s' (available: jpeg, png)", options.Format)
+	}
+}
+
+// encodeJPEG encodes img as JPEG, flattening transparency onto white
+func encodeJPEG(img image.Image, quality int) (*Image, error) {
+	if quality <= 0 || quality > 100 {
+		quality = DefaultQuality
+	}
+
+	flat := image.NewRGBA(img.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("Failed to encode JPEG: %w", err)
+	}
+	return &Image{MimeType: "image/jpeg", Data: buf.Bytes()}, nil
+}
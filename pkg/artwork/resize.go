@@ -0,0 +1,70 @@
+package artwork
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Fit scales img down by area averaging so that neither side exceeds size pixels, keeping the aspect ratio.
+// Images that already fit are returned unchanged.
+func Fit(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if size <= 0 || (srcW <= size && srcH <= size) {
+		return img
+	}
+
+	// Compute target dimensions
+	dstW, dstH := size, srcH*size/srcW
+	if srcH > srcW {
+		dstW, dstH = srcW*size/srcH, size
+	}
+	dstW, dstH = max(dstW, 1), max(dstH, 1)
+
+	// Average all source pixels covered by each destination pixel
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0 := bounds.Min.Y + y*srcH/dstH
+		y1 := max(bounds.Min.Y+(y+1)*srcH/dstH, y0+1)
+		for x := 0; x < dstW; x++ {
+			x0 := bounds.Min.X + x*srcW/dstW
+			x1 := max(bounds.Min.X+(x+1)*srcW/dstW, x0+1)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+// cover crops img to a centered square and scales it to size x size pixels
+func cover(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	x0 := bounds.Min.X + (bounds.Dx()-side)/2
+	y0 := bounds.Min.Y + (bounds.Dy()-side)/2
+
+	// Crop
+	square := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(square, square.Bounds(), img, image.Point{X: x0, Y: y0}, draw.Src)
+	if side >= size {
+		return Fit(square, size)
+	}
+
+	// Enlarge small templates by repeating pixels
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dst.Set(x, y, square.At(x*side/size, y*side/size))
+		}
+	}
+	return dst
+}
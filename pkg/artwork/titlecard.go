@@ -0,0 +1,164 @@
+package artwork
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DefaultCardColor is the default background of generated title cards
+var DefaultCardColor = color.RGBA{R: 0x1E, G: 0x1E, B: 0x28, A: 0xFF}
+
+// TitleCard renders simple chapter artwork showing the chapter number and title
+type TitleCard struct {
+	Background color.Color // Background color used when Template is nil
+	Template   image.Image // Optional background image, cropped and scaled to cover the card
+}
+
+// Render draws the card for a chapter.
+// The built-in font only covers ASCII, so titles with other characters show the chapter number only.
+func (card TitleCard) Render(number int, title string, size int) image.Image {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+
+	// Draw background and choose a readable text color
+	textColor := color.Color(color.White)
+	if card.Template != nil {
+		draw.Draw(dst, dst.Bounds(), cover(card.Template, size), image.Point{}, draw.Src)
+		band := image.Rect(0, size/4, size, size*7/8)
+		draw.Draw(dst, band, image.NewUniform(color.RGBA{A: 0x99}), image.Point{}, draw.Over)
+	} else {
+		background := card.Background
+		if background == nil {
+			background = DefaultCardColor
+		}
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+		if luminance(background) > 0.5 {
+			textColor = color.Black
+		}
+	}
+
+	// Chapter label
+	margin := size / 10
+	labelScale := max(size/120, 1)
+	drawText(dst, margin, size*3/10, labelScale, fmt.Sprintf("CHAPTER %d", number), textColor)
+
+	// Title, as large as fits below the label
+	if !supported(title) {
+		return dst
+	}
+	top := size*3/10 + (glyphHeight+4)*labelScale
+	width, height := size-2*margin, size-margin-top
+	for scale := max(size/40, 1); scale >= 1; scale-- {
+		lines := wrap(title, width/((glyphWidth+1)*scale))
+		if len(lines)*(glyphHeight+3)*scale > height && scale > 1 {
+			continue
+		}
+		for i, line := range lines {
+			drawText(dst, margin, top+i*(glyphHeight+3)*scale, scale, line, textColor)
+		}
+		break
+	}
+	return dst
+}
+
+// Image renders the card for a chapter at the configured size and encodes it
+func (card TitleCard) Image(number int, title string, options Options) (*Image, error) {
+	return Encode(card.Render(number, title, options.Size), options)
+}
+
+// ParseColor parses a color in #RRGGBB notation
+func ParseColor(s string) (color.Color, error) {
+	hex := strings.TrimPrefix(s, "#")
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 6 || err != nil {
+		return nil, fmt.Errorf("Invalid color '%s' (expected #RRGGBB)", s)
+	}
+	return color.RGBA{R: uint8(value >> 16), G: uint8(value >> 8), B: uint8(value), A: 0xFF}, nil
+}
+
+// LoadTemplate reads a background image for title cards
+func LoadTemplate(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot open template image: %w", err)
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot decode template image '%s': %w", path, err)
+	}
+	return img, nil
+}
+
+// supported reports whether the built-in font can draw every character of text
+func supported(text string) bool {
+	for _, r := range text {
+		if _, ok := glyph(r); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// wrap splits text into lines of at most width characters, breaking at spaces where possible
+func wrap(text string, width int) []string {
+	width = max(width, 1)
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		// Break words longer than a line
+		for len(word) > width {
+			if line != "" {
+				lines = append(lines, line)
+				line = ""
+			}
+			lines = append(lines, word[:width])
+			word = word[width:]
+		}
+
+		switch {
+		case line == "":
+			line = word
+		case len(line)+1+len(word) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// drawText draws ASCII text with its top-left corner at (x, y), each font pixel scaled to scale x scale
+func drawText(dst draw.Image, x, y, scale int, text string, c color.Color) {
+	src := image.NewUniform(c)
+	for _, r := range text {
+		rows, _ := glyph(r)
+		for row, bits := range rows {
+			for col := 0; col < glyphWidth; col++ {
+				if bits&(1<<(glyphWidth-1-col)) != 0 {
+					pixel := image.Rect(x+col*scale, y+row*scale, x+(col+1)*scale, y+(row+1)*scale)
+					draw.Draw(dst, pixel, src, image.Point{}, draw.Src)
+				}
+			}
+		}
+		x += (glyphWidth + 1) * scale
+	}
+}
+
+// luminance returns the relative brightness of c between 0 and 1
+func luminance(c color.Color) float64 {
+	r, g, b, _ := c.RGBA()
+	return (0.2126*float64(r) + 0.7152*float64(g) + 0.0722*float64(b)) / 0xFFFF
+}
@@ -0,0 +1,103 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/checksum"
+)
+
+// Status values of records
+const (
+	StatusDone   = "done"   // Output was written and verified
+	StatusFailed = "failed" // Output was written but failed verification
+)
+
+// Record is the provenance record of one processed file
+type Record struct {
+	Time       time.Time      `json:"time"`                     // When processing finished (UTC)
+	Tool       string         `json:"tool"`                     // Module path of the tool
+	Version    string         `json:"version"`                  // Module version and VCS revision of the tool
+	Status     string         `json:"status"`                   // "done" or "failed"
+	Error      string         `json:"error,omitempty"`          // Error message if verification failed
+	Input      File           `json:"input"`                    // Input audio file, hashed before writing
+	Markers    []File         `json:"marker_sources"`           // Marker files the chapters were read from
+	Transforms []string       `json:"transforms"`               // Changes applied to the markers, in order
+	Chapters   int            `json:"chapters"`                 // Number of chapters written
+	Frames     map[string]int `json:"frames_written,omitempty"` // Frames of the output ID3v2 tag by frame ID (empty for other containers)
+	Output     File           `json:"output"`                   // Written audio or sidecar file
+}
+
+// File identifies a file by its path and content
+type File struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size,omitempty"`
+	SHA256 string `json:"sha256,omitempty"` // Hex digest (empty for remote sources)
+	Format string `json:"format,omitempty"` // Import format of marker files
+}
+
+// HashFile returns the size and SHA-256 digest of the file at path
+func HashFile(path string) (File, error) {
+	hash, size, err := checksum.SHA256File(path)
+	if err != nil {
+		return File{}, err
+	}
+	return File{Path: path, Size: size, SHA256: hash}, nil
+}
+
+// ToolVersion returns the module path and version of the running binary; development builds report the VCS revision
+func ToolVersion() (string, string) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "audition-marker", "unknown"
+	}
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Path, info.Main.Version
+	}
+	version, modified := "devel", false
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			version = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if modified {
+		version += "+dirty"
+	}
+	return info.Main.Path, version
+}
+
+// WriteFile writes a record as indented JSON to path, replacing an earlier record
+func WriteFile(path string, record Record) error {
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Failed to encode audit record: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("Failed to write audit record: %w", err)
+	}
+	return nil
+}
+
+// Append adds a record as one line to the NDJSON log at path, creating the log if needed
+func Append(path string, record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("Failed to encode audit record: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("Cannot open audit log: %w", err)
+	}
+	// A single write keeps lines of concurrent runs from interleaving
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("Failed to write audit log: %w", err)
+	}
+	return file.Close()
+}
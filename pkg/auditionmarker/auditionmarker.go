@@ -0,0 +1,167 @@
+package auditionmarker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/chapterdiff"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/config"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/pipeline"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/preset"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/prompt"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/tracing"
+)
+
+// ErrVerification is returned, wrapped, when the chapters read back from the output do not match the markers
+var ErrVerification = errors.New("chapters in the output file do not match the markers")
+
+// Options configures TagMP3WithCSV; the zero value writes chapters like the command line tool without options
+type Options struct {
+	OutputPath string   // Path for the output file (default: filename_with_chapters.mp3)
+	Overwrite  bool     // Whether to replace an existing output file; without it an existing file is an error
+	MergeCSV   []string // Further marker files merged with the one at csvPath, resolving conflicts by Pipeline.OnConflict
+	Preset     string   // Name of the compatibility preset (empty for the defaults)
+	ConfigPath string   // Path to the config file with custom presets (empty for the default location)
+
+	// Parsing, conflict resolution and transforms such as snapping, alignment and titles from a transcription command,
+	// the same steps as the command line tool runs; the preset may title untitled markers on top
+	Pipeline pipeline.Options
+
+	VerifyTolerance time.Duration // Largest start time difference accepted when verifying the output
+	FastVerify      bool          // Whether to check only the CHAP and CTOC frames of the output against the chapters as written, skipping large frames such as cover art
+
+	Tag id3tag.Options // Transcript, chapter artwork and writer settings; the preset is applied on top
+}
+
+// Result describes the tagged file
+type Result struct {
+	OutputPath string                  // Path of the written file
+	Markers    []csvparser.MarkerEntry // Markers as written, after snapping and alignment
+	Chapters   []id3tag.Chapter        // Chapters read back from the output file, or as written and checked with FastVerify
+}
+
+// TagMP3WithCSV parses the marker file at csvPath (a local path or an HTTP(S) URL), applies the transforms in opts
+// and writes the markers as chapters of the MP3 file at mp3Path, verifying the result.
+// ctx is checked between the steps; a step that has started runs to completion.
+// If ctx carries a span (tracing.ContextWithSpan), the parse, transform, write and verify steps are traced under it.
+func TagMP3WithCSV(ctx context.Context, csvPath, mp3Path string, opts Options) (*Result, error) {
+	outputPath := opts.OutputPath
+	if outputPath == "" {
+		ext := filepath.Ext(mp3Path)
+		outputPath = mp3Path[:len(mp3Path)-len(ext)] + "_with_chapters" + ext
+	}
+	if outputPath == mp3Path && !opts.Overwrite {
+		return nil, fmt.Errorf("Output path is the input file '%s'; set Overwrite to tag it in place", mp3Path)
+	}
+	if !opts.Overwrite && fileExists(outputPath) {
+		return nil, fmt.Errorf("Output file '%s' already exists", outputPath)
+	}
+
+	// Parse markers
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	trace := tracing.SpanFromContext(ctx)
+	span := trace.Start("parse")
+	markers, _, err := pipeline.Read(append([]string{csvPath}, opts.MergeCSV...), mp3Path, opts.Pipeline)
+	span.RecordError(err)
+	span.End()
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply the preset and the transforms of the pipeline
+	span = trace.Start("transform")
+	markers, tagOptions, err := transformMarkers(ctx, markers, mp3Path, opts)
+	span.RecordError(err)
+	span.End()
+	if err != nil {
+		return nil, err
+	}
+
+	// Write chapters
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	span = trace.Start("write")
+	tagOptions.Trace = span
+	if opts.FastVerify {
+		tagOptions.Written = &id3tag.WrittenChapters{}
+	}
+	err = id3tag.AddChaptersWithOptions(mp3Path, markers, outputPath, tagOptions)
+	span.RecordError(err)
+	span.End()
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify chapters
+	span = trace.Start("verify")
+	defer span.End()
+	var chapters []id3tag.Chapter
+	if written := tagOptions.Written; written != nil {
+		chapters = written.Chapters
+		if err := id3tag.VerifyWritten(outputPath, written); err != nil {
+			err = fmt.Errorf("%w: %w", ErrVerification, err)
+			span.RecordError(err)
+			return &Result{OutputPath: outputPath, Markers: markers, Chapters: chapters}, err
+		}
+	} else {
+		chapters, err = id3tag.ReadChapters(outputPath)
+		if err != nil {
+			err = fmt.Errorf("Could not read chapters from output file: %w", err)
+			span.RecordError(err)
+			return nil, err
+		}
+	}
+	result := &Result{OutputPath: outputPath, Markers: markers, Chapters: chapters}
+	written := make([]csvparser.MarkerEntry, 0, len(chapters))
+	for _, chapter := range chapters {
+		written = append(written, csvparser.MarkerEntry{Name: chapter.Title, StartTime: chapter.StartTime})
+	}
+	if changes := chapterdiff.Compare(markers, written, opts.VerifyTolerance); len(changes) > 0 {
+		err = fmt.Errorf("%w: %d markers, %d chapters written, %d mismatches", ErrVerification, len(markers), len(chapters), len(changes))
+		span.RecordError(err)
+		return result, err
+	}
+	return result, nil
+}
+
+// transformMarkers runs the transforms of the pipeline and returns the markers with the tag options of opts and the preset
+func transformMarkers(ctx context.Context, markers []csvparser.MarkerEntry, mp3Path string, opts Options) ([]csvparser.MarkerEntry, id3tag.Options, error) {
+	tagOptions := opts.Tag
+	tagOptions.Overwrite = opts.Overwrite
+	tagOptions.Prompt = &prompt.Prompter{Disabled: true} // A library never reads from the terminal
+
+	pipelineOptions := opts.Pipeline
+	if opts.Preset != "" {
+		file, err := config.Load(opts.ConfigPath)
+		if err != nil {
+			return nil, id3tag.Options{}, err
+		}
+		p, err := preset.Lookup(opts.Preset, file)
+		if err != nil {
+			return nil, id3tag.Options{}, err
+		}
+		p.Apply(&tagOptions)
+		pipelineOptions.ChapterTitles = pipelineOptions.ChapterTitles || p.ChapterTitles
+	}
+
+	markers, err := pipeline.Transform(ctx, markers, mp3Path, pipelineOptions)
+	if err != nil {
+		return nil, id3tag.Options{}, err
+	}
+	return markers, tagOptions, nil
+}
+
+// fileExists checks if a file exists
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,52 @@
+package auditionmarker
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/pipeline"
+)
+
+// writeFile writes data to name in dir and returns its path
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestTagMP3WithCSV(t *testing.T) {
+	dir := t.TempDir()
+	frame := make([]byte, 417) // Silent MPEG-1 Layer III frame, 128 kbit/s, 44.1 kHz
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0x00})
+	mp3Path := writeFile(t, dir, "episode.mp3", bytes.Repeat(frame, 1000))
+	header := "Name\tStart\tDuration\tTime Format\tType\tDescription\n"
+	csvPath := writeFile(t, dir, "markers.csv", []byte(header+
+		"Marker 01\t0:00.000\t0:00.000\tdecimal\tCue\t\n"+
+		"Talk\t0:10.000\t0:00.000\tdecimal\tCue\t\n"+
+		"Aside\t0:10.500\t0:00.000\tdecimal\tCue\t\n"))
+	morePath := writeFile(t, dir, "more.csv", []byte(header+"Outro\t0:20.000\t0:00.000\tdecimal\tCue\t\n"))
+
+	result, err := TagMP3WithCSV(context.Background(), csvPath, mp3Path, Options{
+		MergeCSV: []string{morePath},
+		Pipeline: pipeline.Options{MinGap: time.Second, ChapterTitles: true, Number: true, NumberStart: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"1. Chapter 1", "2. Talk", "3. Outro"}
+	if len(result.Chapters) != len(want) {
+		t.Fatalf("wrote %d chapters, want %d: %+v", len(result.Chapters), len(want), result.Chapters)
+	}
+	for i, chapter := range result.Chapters {
+		if chapter.Title != want[i] {
+			t.Errorf("chapter %d title = %q, want %q", i+1, chapter.Title, want[i])
+		}
+	}
+}
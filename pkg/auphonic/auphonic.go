@@ -0,0 +1,105 @@
+package auphonic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+// DefaultBaseURL is the base URL of the Auphonic API
+const DefaultBaseURL = "https://auphonic.com/api"
+
+// Chapter is a chapter of an Auphonic production
+type Chapter struct {
+	Start string `json:"start"`           // Start time as HH:MM:SS.mmm
+	Title string `json:"title"`           // Chapter title
+	URL   string `json:"url,omitempty"`   // Link shown with the chapter
+	Image string `json:"image,omitempty"` // HTTP(S) URL of the chapter image
+}
+
+// Client sends requests to the Auphonic API
+type Client struct {
+	BaseURL string       // Base URL of the API (DefaultBaseURL if empty)
+	Token   string       // API key or OAuth token sent as a bearer token
+	HTTP    *http.Client // HTTP client (a client with a 30 second timeout if nil)
+}
+
+// NewChapters converts markers to Auphonic chapters without links or images
+func NewChapters(markers []csvparser.MarkerEntry) []Chapter {
+	chapters := make([]Chapter, 0, len(markers))
+	for _, marker := range markers {
+		chapters = append(chapters, Chapter{Start: formatStart(marker.StartTime), Title: marker.Name})
+	}
+	return chapters
+}
+
+// SetChapters replaces the chapters of a production: the existing chapters are deleted, then the new ones are added
+func (c *Client) SetChapters(production string, chapters []Chapter) error {
+	path := "/production/" + url.PathEscape(production)
+	if err := c.do(http.MethodDelete, path+"/chapters.json", nil); err != nil {
+		return fmt.Errorf("Cannot delete the existing chapters: %w", err)
+	}
+
+	body, err := json.Marshal(struct {
+		Chapters []Chapter `json:"chapters"`
+	}{chapters})
+	if err != nil {
+		return fmt.Errorf("Failed to encode chapters: %w", err)
+	}
+	if err := c.do(http.MethodPost, path+".json", body); err != nil {
+		return fmt.Errorf("Cannot update the production: %w", err)
+	}
+	return nil
+}
+
+// do sends an API request and checks its status
+func (c *Client) do(method, path string, body []byte) error {
+	base := c.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	client := c.HTTP
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	req, err := http.NewRequest(method, base+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Auphonic reports errors as {"error_message": "..."}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var result struct {
+			ErrorMessage string `json:"error_message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if json.Unmarshal(data, &result) == nil && result.ErrorMessage != "" {
+			return fmt.Errorf("Auphonic returned status %s: %s", resp.Status, result.ErrorMessage)
+		}
+		return fmt.Errorf("Auphonic returned status %s", resp.Status)
+	}
+	return nil
+}
+
+// formatStart formats a chapter start as HH:MM:SS.mmm
+func formatStart(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
@@ -0,0 +1,98 @@
+package chapterdiff
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+// Kind classifies a difference between two chapter lists
+type Kind string
+
+const (
+	Added   Kind = "added"   // Chapter only exists in the new list
+	Removed Kind = "removed" // Chapter only exists in the old list
+	Retimed Kind = "retimed" // Chapter has the same title but a different start time
+	Renamed Kind = "renamed" // Chapter has the same start time but a different title
+)
+
+// Change is a single difference between two chapter lists
+type Change struct {
+	Kind Kind
+	Old  *csvparser.MarkerEntry // Chapter in the old list (nil for added chapters)
+	New  *csvparser.MarkerEntry // Chapter in the new list (nil for removed chapters)
+}
+
+// Compare returns the differences between the chapters before and after a change ordered by time.
+// Start times are compared at millisecond precision, as stored in ID3 tags; differences up to tolerance are ignored.
+func Compare(before, after []csvparser.MarkerEntry, tolerance time.Duration) []Change {
+	matched := make([]bool, len(after))
+	var unmatched []int
+	var changes []Change
+
+	// Pair chapters with the same title, preferring the closest start time
+	for i := range before {
+		best := -1
+		for j := range after {
+			if matched[j] || after[j].Name != before[i].Name {
+				continue
+			}
+			if best < 0 || distance(before[i], after[j]) < distance(before[i], after[best]) {
+				best = j
+			}
+		}
+		if best < 0 {
+			unmatched = append(unmatched, i)
+			continue
+		}
+		matched[best] = true
+		if distance(before[i], after[best]) > tolerance {
+			changes = append(changes, Change{Kind: Retimed, Old: &before[i], New: &after[best]})
+		}
+	}
+
+	// Pair the remaining chapters by start time
+	for _, i := range unmatched {
+		best := -1
+		for j := range after {
+			if matched[j] || distance(before[i], after[j]) > tolerance {
+				continue
+			}
+			if best < 0 || distance(before[i], after[j]) < distance(before[i], after[best]) {
+				best = j
+			}
+		}
+		if best < 0 {
+			changes = append(changes, Change{Kind: Removed, Old: &before[i]})
+			continue
+		}
+		matched[best] = true
+		changes = append(changes, Change{Kind: Renamed, Old: &before[i], New: &after[best]})
+	}
+	for j := range after {
+		if !matched[j] {
+			changes = append(changes, Change{Kind: Added, New: &after[j]})
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Time() < changes[j].Time() })
+	return changes
+}
+
+// Time returns the start time of the changed chapter, preferring the old one
+func (c Change) Time() time.Duration {
+	if c.Old != nil {
+		return c.Old.StartTime
+	}
+	return c.New.StartTime
+}
+
+// distance returns the absolute difference of the start times at millisecond precision
+func distance(a, b csvparser.MarkerEntry) time.Duration {
+	d := a.StartTime.Truncate(time.Millisecond) - b.StartTime.Truncate(time.Millisecond)
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,161 @@
+package chapterstats
+
+import (
+	"sort"
+	"time"
+	"unicode/utf8"
+)
+
+// Chapter is a chapter as read from a file
+type Chapter struct {
+	Title string        // Chapter title
+	Start time.Duration // Start time
+	End   time.Duration // End time, or 0 if the file does not store a usable one
+}
+
+// TitleBuckets are the upper bounds in runes of the title length histogram; longer titles fall into a last bucket
+var TitleBuckets = []int{10, 20, 40, 80}
+
+// Stats summarizes the chapters of one or more files
+type Stats struct {
+	Files     int           // Number of files
+	Chapters  int           // Number of chapters
+	Duration  time.Duration // Total audio length (0 if unknown)
+	MinLength time.Duration // Shortest chapter
+	AvgLength time.Duration // Average chapter length
+	MaxLength time.Duration // Longest chapter
+	Gaps      int           // Number of gaps between the end of a chapter and the start of the next
+	GapTotal  time.Duration // Total length of the gaps
+	Uncovered time.Duration // Audio not covered by any chapter, including gaps
+
+	MinTitle       int   // Shortest title in runes
+	AvgTitle       int   // Average title length in runes
+	MaxTitle       int   // Longest title in runes
+	TitleHistogram []int // Title counts per TitleBuckets entry plus one for longer titles
+
+	lengths      time.Duration // Sum of chapter lengths, for merging averages
+	measured     int           // Number of chapters with a known length
+	titleLengths int           // Sum of title lengths in runes
+	titles       int           // Number of recorded titles
+}
+
+// Compute computes the statistics of a single file; duration is the audio length, or 0 if unknown.
+// Chapters without a usable end time end where the next chapter starts, or at the end of the audio.
+func Compute(chapters []Chapter, duration time.Duration) Stats {
+	stats := Stats{Files: 1, Chapters: len(chapters), Duration: duration, TitleHistogram: make([]int, len(TitleBuckets)+1)}
+	if len(chapters) == 0 {
+		stats.Uncovered = duration
+		return stats
+	}
+
+	sorted := append([]Chapter(nil), chapters...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	// Chapter lengths and gaps; the clamped chapters do not overlap
+	var covered time.Duration
+	for i, chapter := range sorted {
+		end := chapter.End
+		next := duration
+		if i+1 < len(sorted) {
+			next = sorted[i+1].Start
+		}
+		if end <= chapter.Start || (next > 0 && end > next) {
+			end = next
+		}
+		if end <= chapter.Start {
+			continue // Last chapter of a file with unknown length, or a duplicate start time
+		}
+
+		stats.addLength(end - chapter.Start)
+		covered += end - chapter.Start
+		if i+1 < len(sorted) && next > end {
+			stats.Gaps++
+			stats.GapTotal += next - end
+		}
+	}
+	if duration > 0 {
+		stats.Uncovered = max(duration-covered, 0)
+	} else {
+		stats.Uncovered = sorted[0].Start + stats.GapTotal
+	}
+
+	// Title lengths
+	for _, chapter := range sorted {
+		stats.addTitle(utf8.RuneCountInString(chapter.Title))
+	}
+	stats.finish()
+	return stats
+}
+
+// Merge adds the statistics of another file
+func (s *Stats) Merge(other Stats) {
+	if s.TitleHistogram == nil {
+		s.TitleHistogram = make([]int, len(TitleBuckets)+1)
+	}
+	if other.measured > 0 {
+		if s.measured == 0 || other.MinLength < s.MinLength {
+			s.MinLength = other.MinLength
+		}
+		s.MaxLength = max(s.MaxLength, other.MaxLength)
+	}
+	if other.titles > 0 {
+		if s.titles == 0 || other.MinTitle < s.MinTitle {
+			s.MinTitle = other.MinTitle
+		}
+		s.MaxTitle = max(s.MaxTitle, other.MaxTitle)
+	}
+
+	s.Files += other.Files
+	s.Chapters += other.Chapters
+	s.Duration += other.Duration
+	s.Gaps += other.Gaps
+	s.GapTotal += other.GapTotal
+	s.Uncovered += other.Uncovered
+	s.lengths += other.lengths
+	s.measured += other.measured
+	s.titleLengths += other.titleLengths
+	s.titles += other.titles
+	for i, count := range other.TitleHistogram {
+		s.TitleHistogram[i] += count
+	}
+	s.finish()
+}
+
+// addLength records the length of a chapter
+func (s *Stats) addLength(length time.Duration) {
+	if s.measured == 0 || length < s.MinLength {
+		s.MinLength = length
+	}
+	s.MaxLength = max(s.MaxLength, length)
+	s.lengths += length
+	s.measured++
+}
+
+// addTitle records the length of a title in runes
+func (s *Stats) addTitle(runes int) {
+	if s.titles == 0 || runes < s.MinTitle {
+		s.MinTitle = runes
+	}
+	s.MaxTitle = max(s.MaxTitle, runes)
+	s.titleLengths += runes
+	s.titles++
+
+	bucket := len(TitleBuckets)
+	for i, limit := range TitleBuckets {
+		if runes <= limit {
+			bucket = i
+			break
+		}
+	}
+	s.TitleHistogram[bucket]++
+}
+
+// finish updates the averages
+func (s *Stats) finish() {
+	if s.measured > 0 {
+		s.AvgLength = s.lengths / time.Duration(s.measured)
+	}
+	if s.titles > 0 {
+		s.AvgTitle = (s.titleLengths + s.titles/2) / s.titles
+	}
+}
@@ -0,0 +1,77 @@
+package chapterstats
+
+import (
+	"sort"
+	"time"
+)
+
+// SpanKind is the kind of a coverage problem
+type SpanKind string
+
+// Kinds of coverage problems
+const (
+	SpanLeading  SpanKind = "leading"  // Audio before the first chapter
+	SpanGap      SpanKind = "gap"      // Audio between the end of a chapter and the start of the next
+	SpanLong     SpanKind = "long"     // Stretch longer than the limit without a chapter start
+	SpanTrailing SpanKind = "trailing" // Audio after the end of the last chapter
+)
+
+// Span is a stretch of audio reported by Coverage
+type Span struct {
+	Kind  SpanKind
+	Start time.Duration
+	End   time.Duration
+}
+
+// Length returns the length of the span
+func (s Span) Length() time.Duration {
+	return s.End - s.Start
+}
+
+// Coverage finds audio not covered by the chapters and stretches longer than maxSpan without a chapter start (0 to skip those).
+// duration is the audio length, or 0 if unknown, in which case nothing after the last chapter start is reported.
+// Chapters without a usable end time end where the next chapter starts, or at the end of the audio, as in Compute.
+func Coverage(chapters []Chapter, duration, maxSpan time.Duration) []Span {
+	if len(chapters) == 0 {
+		if duration > 0 {
+			return []Span{{Kind: SpanLeading, Start: 0, End: duration}}
+		}
+		return nil
+	}
+
+	sorted := append([]Chapter(nil), chapters...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	var spans []Span
+	if sorted[0].Start > 0 {
+		spans = append(spans, Span{Kind: SpanLeading, Start: 0, End: sorted[0].Start})
+	}
+	if maxSpan > 0 && sorted[0].Start > maxSpan {
+		spans = append(spans, Span{Kind: SpanLong, Start: 0, End: sorted[0].Start})
+	}
+	for i, chapter := range sorted {
+		last := i+1 == len(sorted)
+		next := duration
+		if !last {
+			next = sorted[i+1].Start
+		}
+		if next <= chapter.Start {
+			continue // Last chapter of a file with unknown length, or a duplicate start time
+		}
+
+		end := chapter.End
+		if end <= chapter.Start || end > next {
+			end = next
+		}
+		switch {
+		case end < next && last:
+			spans = append(spans, Span{Kind: SpanTrailing, Start: end, End: next})
+		case end < next:
+			spans = append(spans, Span{Kind: SpanGap, Start: end, End: next})
+		}
+		if maxSpan > 0 && next-chapter.Start > maxSpan {
+			spans = append(spans, Span{Kind: SpanLong, Start: chapter.Start, End: next})
+		}
+	}
+	return spans
+}
@@ -0,0 +1,165 @@
+package checksum
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/safefile"
+)
+
+// ErrMismatch is returned by Verify for a file whose content does not match its recorded hash
+var ErrMismatch = errors.New("checksum mismatch")
+
+// Entry is a line of a manifest
+type Entry struct {
+	SHA256 string // Hex digest
+	Path   string // Path as written in the manifest, relative to the manifest's directory unless absolute
+}
+
+// Result is the outcome of verifying an entry
+type Result struct {
+	Entry
+	Err error // nil if the file matches, ErrMismatch or the error reading the file
+}
+
+// SHA256File returns the hex SHA-256 digest and size of the file at path
+func SHA256File(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("Cannot open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", 0, fmt.Errorf("Failed to hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// ReadManifest reads a manifest in the format of sha256sum; a missing manifest has no entries
+func ReadManifest(manifestPath string) ([]Entry, error) {
+	file, err := os.Open(manifestPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Cannot open checksum manifest: %w", err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		// "<hash>  <path>" in text mode or "<hash> *<path>" in binary mode
+		hash, path, found := strings.Cut(text, " ")
+		if !found || len(hash) != sha256.Size*2 || len(path) < 2 || (path[0] != ' ' && path[0] != '*') {
+			return nil, fmt.Errorf("Invalid checksum manifest line %d: %s", line, text)
+		}
+		entries = append(entries, Entry{SHA256: strings.ToLower(hash), Path: path[1:]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to read checksum manifest: %w", err)
+	}
+	return entries, nil
+}
+
+// Update hashes files and records them in the manifest, replacing earlier entries of the same files and keeping the others,
+// so one manifest can collect the outputs of several runs
+func Update(manifestPath string, files []string) error {
+	entries, err := ReadManifest(manifestPath)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(manifestPath)
+	for _, file := range files {
+		hash, _, err := SHA256File(file)
+		if err != nil {
+			return err
+		}
+		entry := Entry{SHA256: hash, Path: manifestRelative(dir, file)}
+		replaced := false
+		for i := range entries {
+			if filepath.Clean(entries[i].Path) == filepath.Clean(entry.Path) {
+				entries[i], replaced = entry, true
+			}
+		}
+		if !replaced {
+			entries = append(entries, entry)
+		}
+	}
+
+	// Replace the manifest in one step, so a reader never sees a partial list
+	var b strings.Builder
+	for _, entry := range entries {
+		fmt.Fprintf(&b, "%s  %s\n", entry.SHA256, filepath.ToSlash(entry.Path))
+	}
+	temp, err := safefile.TempPath(manifestPath)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(temp, []byte(b.String()), 0644); err != nil {
+		os.Remove(temp)
+		return fmt.Errorf("Failed to write checksum manifest: %w", err)
+	}
+	if err := safefile.Replace(temp, manifestPath); err != nil {
+		os.Remove(temp)
+		return fmt.Errorf("Failed to write checksum manifest: %w", err)
+	}
+	return nil
+}
+
+// Verify hashes the files listed in the manifest and returns a result per entry
+func Verify(manifestPath string) ([]Result, error) {
+	entries, err := ReadManifest(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	if entries == nil {
+		if _, err := os.Stat(manifestPath); err != nil {
+			return nil, fmt.Errorf("Cannot open checksum manifest: %w", err)
+		}
+	}
+
+	dir := filepath.Dir(manifestPath)
+	results := make([]Result, 0, len(entries))
+	for _, entry := range entries {
+		path := filepath.FromSlash(entry.Path)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		hash, _, err := SHA256File(path)
+		if err == nil && hash != entry.SHA256 {
+			err = ErrMismatch
+		}
+		results = append(results, Result{Entry: entry, Err: err})
+	}
+	return results, nil
+}
+
+// manifestRelative returns the path of file relative to the manifest directory, or its absolute path if it is outside
+func manifestRelative(dir, file string) string {
+	absDir, err1 := filepath.Abs(dir)
+	absFile, err2 := filepath.Abs(file)
+	if err1 != nil || err2 != nil {
+		return file
+	}
+	rel, err := filepath.Rel(absDir, absFile)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return absFile
+	}
+	return rel
+}
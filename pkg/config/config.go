@@ -0,0 +1,119 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultFileName is the name of the configuration file looked up in the user configuration directory
+const DefaultFileName = "audition-marker.conf"
+
+// File is a parsed INI-style configuration file.
+// Lines are "key = value" pairs grouped under "[section]" headers; lines starting with '#' or ';' are comments.
+type File struct {
+	Path     string                       // Path the file was read from
+	sections map[string]map[string]string // Values by section and key
+	order    []string                     // Section names in file order
+}
+
+// DefaultPath returns the path of the configuration file in the user configuration directory
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "audition-marker", DefaultFileName)
+}
+
+// Load reads the configuration file at path.
+// If path is empty, the default path is used and a missing file yields an empty configuration.
+func Load(path string) (*File, error) {
+	empty := &File{sections: make(map[string]map[string]string)}
+	optional := path == ""
+	if optional {
+		if path = DefaultPath(); path == "" {
+			return empty, nil
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if optional && os.IsNotExist(err) {
+			return empty, nil
+		}
+		return nil, fmt.Errorf("Cannot open config file: %w", err)
+	}
+	defer file.Close()
+
+	config := &File{Path: path, sections: make(map[string]map[string]string)}
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if _, ok := config.sections[section]; !ok {
+				config.sections[section] = make(map[string]string)
+				config.order = append(config.order, section)
+			}
+		default:
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				return nil, fmt.Errorf("%s:%d: expected 'key = value'", path, lineNumber)
+			}
+			if _, exists := config.sections[section]; !exists {
+				config.sections[section] = make(map[string]string)
+				config.order = append(config.order, section)
+			}
+			config.sections[section][strings.TrimSpace(key)] = unquote(strings.TrimSpace(value))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to read config file: %w", err)
+	}
+
+	return config, nil
+}
+
+// Section returns the values of a section, or nil if the file has no such section
+func (f *File) Section(name string) map[string]string {
+	return f.sections[name]
+}
+
+// SectionsWithPrefix returns the names (without the prefix) of all sections starting with prefix, in file order
+func (f *File) SectionsWithPrefix(prefix string) []string {
+	var names []string
+	for _, name := range f.order {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, strings.TrimPrefix(name, prefix))
+		}
+	}
+	return names
+}
+
+// Bool parses a boolean configuration value
+func Bool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "yes", "on":
+		return true, nil
+	case "no", "off":
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// unquote removes matching double or single quotes around a value
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
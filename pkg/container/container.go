@@ -0,0 +1,115 @@
+package container
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/mp3frame"
+)
+
+// Kind is an audio container format
+type Kind string
+
+// Known containers
+const (
+	Unknown Kind = ""
+	MP3     Kind = "MP3"
+	Ogg     Kind = "Ogg"
+	MP4     Kind = "MP4"
+	WAV     Kind = "WAV"
+	FLAC    Kind = "FLAC"
+	AIFF    Kind = "AIFF"
+)
+
+// extensions maps file extensions to the container they name
+var extensions = map[string]Kind{
+	".mp3":  MP3,
+	".ogg":  Ogg,
+	".oga":  Ogg,
+	".opus": Ogg,
+	".m4a":  MP4,
+	".m4b":  MP4,
+	".mp4":  MP4,
+	".m4v":  MP4,
+	".mov":  MP4,
+	".wav":  WAV,
+	".flac": FLAC,
+	".aif":  AIFF,
+	".aiff": AIFF,
+}
+
+// sniffSize is the number of bytes read from the start of a file to detect its container
+const sniffSize = 16
+
+// junkLimit is the number of bytes searched for MPEG audio frames in a file without magic bytes
+const junkLimit = 64 * 1024
+
+// FromExtension returns the container named by the extension of path, or Unknown
+func FromExtension(path string) Kind {
+	return extensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// Sniff returns the container of a file starting with head by its magic bytes:
+// an ID3v2 tag or an MPEG audio frame header for MP3, "OggS" for Ogg and an "ftyp" box for MP4
+func Sniff(head []byte) Kind {
+	switch {
+	case bytes.HasPrefix(head, []byte("ID3")):
+		return MP3
+	case bytes.HasPrefix(head, []byte("OggS")):
+		return Ogg
+	case len(head) >= 8 && string(head[4:8]) == "ftyp":
+		return MP4
+	case len(head) >= 12 && string(head[:4]) == "RIFF" && string(head[8:12]) == "WAVE":
+		return WAV
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		return FLAC
+	case len(head) >= 12 && string(head[:4]) == "FORM" && (string(head[8:12]) == "AIFF" || string(head[8:12]) == "AIFC"):
+		return AIFF
+	}
+	// ADTS AAC shares the sync word, but its layer bits 00 are rejected as reserved
+	if _, ok := mp3frame.ParseHeader(head); ok {
+		return MP3
+	}
+	return Unknown
+}
+
+// Detect returns the container of the file at path by its content
+func Detect(path string) (Kind, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Unknown, fmt.Errorf("Cannot open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	head := make([]byte, sniffSize)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return Unknown, fmt.Errorf("Failed to read %s: %w", path, err)
+	}
+	if kind := Sniff(head[:n]); kind != Unknown {
+		return kind, nil
+	}
+
+	// MP3 files may start with junk before the first frame
+	info, err := file.Stat()
+	if err != nil {
+		return Unknown, fmt.Errorf("Failed to read %s: %w", path, err)
+	}
+	if _, err := mp3frame.FindFirstFrame(file, min(info.Size(), junkLimit), 0); err == nil {
+		return MP3, nil
+	}
+	return Unknown, nil
+}
+
+// Of returns the container of an existing file by its content, or by the extension of path if it cannot be read or is not recognized
+func Of(path string) Kind {
+	if kind, err := Detect(path); err == nil && kind != Unknown {
+		return kind
+	}
+	return FromExtension(path)
+}
@@ -0,0 +1,129 @@
+package csvparser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// mappableFields are the marker fields a column map can assign columns to
+var mappableFields = []string{"name", "start", "end", "part", "track", "format"}
+
+// ColumnMap assigns marker fields to the columns of a CSV file that does not follow the Audition layout.
+// Columns are given either all by header name or all by 1-based index, the latter for files without a header row.
+type ColumnMap struct {
+	Names   map[string]string // Header name of the column of each field, compared case-insensitively
+	Indices map[string]int    // 0-based index of the column of each field in a file without a header row
+}
+
+// ParseColumnMap parses a column map such as "name=Chapter,start=Begin,end=Finish" or "name=2,start=1".
+// Fields are name, start, end, part, track and format; name and start are required.
+func ParseColumnMap(spec string) (*ColumnMap, error) {
+	columnMap := &ColumnMap{Names: make(map[string]string), Indices: make(map[string]int)}
+	for _, pair := range strings.Split(spec, ",") {
+		field, column, found := strings.Cut(pair, "=")
+		field = strings.ToLower(strings.TrimSpace(field))
+		column = strings.TrimSpace(column)
+		if !found || column == "" {
+			return nil, fmt.Errorf("Invalid column mapping '%s': expected field=column", strings.TrimSpace(pair))
+		}
+		if !slices.Contains(mappableFields, field) {
+			return nil, fmt.Errorf("Unknown field '%s' in column mapping (fields: %s)", field, strings.Join(mappableFields, ", "))
+		}
+		if _, ok := columnMap.Names[field]; ok {
+			return nil, fmt.Errorf("Field '%s' is mapped more than once", field)
+		}
+		if _, ok := columnMap.Indices[field]; ok {
+			return nil, fmt.Errorf("Field '%s' is mapped more than once", field)
+		}
+		if index, err := strconv.Atoi(column); err == nil {
+			if index < 1 {
+				return nil, fmt.Errorf("Column index of field '%s' must be 1 or greater", field)
+			}
+			columnMap.Indices[field] = index - 1
+		} else {
+			columnMap.Names[field] = column
+		}
+	}
+
+	if len(columnMap.Names) > 0 && len(columnMap.Indices) > 0 {
+		return nil, fmt.Errorf("Column mapping must use either header names or column indices, not both")
+	}
+	for _, field := range []string{"name", "start"} {
+		if _, ok := columnMap.Names[field]; !ok {
+			if _, ok := columnMap.Indices[field]; !ok {
+				return nil, fmt.Errorf("Column mapping must include the '%s' field", field)
+			}
+		}
+	}
+	return columnMap, nil
+}
+
+// headerless reports whether the columns are given by index, so the file has no header row
+func (columnMap *ColumnMap) headerless() bool {
+	return len(columnMap.Indices) > 0
+}
+
+// indexColumns returns the column indices of a file without a header row
+func (columnMap *ColumnMap) indexColumns() markerColumns {
+	return columnMap.markerColumns(func(field string) int { return lookupColumn(columnMap.Indices, field) })
+}
+
+// headerColumns returns the column indices if the row has all mapped header names
+func (columnMap *ColumnMap) headerColumns(row []string) (markerColumns, bool) {
+	indices := make(map[string]int)
+	for j, cell := range row {
+		for field, name := range columnMap.Names {
+			if _, ok := indices[field]; !ok && strings.EqualFold(strings.TrimSpace(cell), name) {
+				indices[field] = j
+			}
+		}
+	}
+	if len(indices) < len(columnMap.Names) {
+		return markerColumns{}, false
+	}
+	return columnMap.markerColumns(func(field string) int { return lookupColumn(indices, field) }), true
+}
+
+// missingHeaderError returns the error for a file without a row with all mapped header names
+func (columnMap *ColumnMap) missingHeaderError() error {
+	names := make([]string, 0, len(columnMap.Names))
+	for _, field := range mappableFields {
+		if name, ok := columnMap.Names[field]; ok {
+			names = append(names, "'"+name+"'")
+		}
+	}
+	return fmt.Errorf("CSV format error: no header row with the columns %s", strings.Join(names, ", "))
+}
+
+// lookupColumn returns the column index of a field, or -1 if it is not mapped
+func lookupColumn(indices map[string]int, field string) int {
+	if index, ok := indices[field]; ok {
+		return index
+	}
+	return -1
+}
+
+// markerColumns returns the column indices of all fields from a lookup function
+func (columnMap *ColumnMap) markerColumns(lookup func(field string) int) markerColumns {
+	return markerColumns{
+		name:   lookup("name"),
+		start:  lookup("start"),
+		end:    lookup("end"),
+		part:   lookup("part"),
+		format: lookup("format"),
+		track:  lookup("track"),
+	}
+}
+
+// sniffComma returns the delimiter of a mapped CSV file: a comma if its first line has commas but no tabs, otherwise a tab
+func sniffComma(r *bufio.Reader) rune {
+	data, _ := r.Peek(64 * 1024)
+	if line, _, _ := bytes.Cut(data, []byte("\n")); !bytes.Contains(line, []byte("\t")) && bytes.Contains(line, []byte(",")) {
+		return ','
+	}
+	return '\t'
+}
@@ -0,0 +1,50 @@
+package csvparser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RowError is a problem with a row of a marker CSV file, located by line and column
+type RowError struct {
+	Source string   // Name of the CSV file (empty if unknown)
+	Line   int      // Line number of the row, starting at 1
+	Column string   // Header of the offending column, or "column N" in files without a header row
+	Row    []string // Cells of the row
+	Err    error    // Underlying problem
+}
+
+// Error returns the location, the problem and the row, e.g. "markers.csv:5: column 'Start': ... (row: Intro | 1:xx.000 | ...)"
+func (e *RowError) Error() string {
+	location := fmt.Sprintf("line %d", e.Line)
+	if e.Source != "" {
+		location = fmt.Sprintf("%s:%d", e.Source, e.Line)
+	}
+	return fmt.Sprintf("%s: column '%s': %v (row: %s)", location, e.Column, e.Err, strings.Join(e.Row, " | "))
+}
+
+// Unwrap returns the underlying problem
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// ParseErrors lists the rows skipped by a lenient parse
+type ParseErrors []*RowError
+
+// Error returns one line per skipped row
+func (errs ParseErrors) Error() string {
+	lines := []string{fmt.Sprintf("%d rows could not be parsed:", len(errs))}
+	for _, err := range errs {
+		lines = append(lines, "  "+err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Unwrap returns the row errors
+func (errs ParseErrors) Unwrap() []error {
+	unwrapped := make([]error, len(errs))
+	for i, err := range errs {
+		unwrapped[i] = err
+	}
+	return unwrapped
+}
@@ -0,0 +1,88 @@
+package csvparser
+
+import (
+	"fmt"
+	"io"
+	"slices"
+)
+
+// Layout describes how the rows of marker CSV data are read, for diagnostics
+type Layout struct {
+	Comma      rune           // Field delimiter
+	HeaderLine int            // 1-based line of the header row (0 if there is none)
+	Header     []string       // Cells of the header row (nil if there is none)
+	Columns    map[string]int // 0-based column index of each field found: name, start, end, part, format and track
+	TimeFormat TimeFormat     // Time format stated above the header row (decimal if none)
+	SampleRate int            // Sample rate stated above the header row or given in the options (0 if unknown)
+	Rows       int            // Rows after the header row
+	Starts     []string       // Start cells of the first rows, e.g. to guess their time format
+}
+
+// maxLayoutStarts is the number of start cells kept in Layout.Starts
+const maxLayoutStarts = 20
+
+// DescribeLayout reads marker CSV data as the parser does and returns its layout without parsing the markers.
+// Data without any rows has a layout without a header row; data whose header row cannot be found returns the parser's error.
+func DescribeLayout(r io.Reader, options ParseOptions) (*Layout, error) {
+	reader := newMarkerReader(r, options)
+	layout := &Layout{Comma: reader.Comma}
+
+	var columns *markerColumns
+	if options.Columns != nil && options.Columns.headerless() {
+		indexColumns := options.Columns.indexColumns()
+		columns = &indexColumns
+	}
+	var preamble [][]string
+	var preambleLines []int
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read CSV data: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		if columns == nil {
+			found, ok := findHeaderColumns(record, options.Columns)
+			if !ok {
+				if len(preamble) >= maxPreambleRows {
+					return nil, fmt.Errorf("CSV format error: no header row in the first %d lines", maxPreambleRows)
+				}
+				preamble = append(preamble, slices.Clone(record))
+				preambleLines = append(preambleLines, line)
+				continue
+			}
+			if layout.TimeFormat, err = parseMetadata(preamble, preambleLines, &options); err != nil {
+				return nil, err
+			}
+			columns = &found
+			layout.HeaderLine, layout.Header = line, slices.Clone(record)
+			continue
+		}
+
+		layout.Rows++
+		if cell := optionalCell(record, columns.start); cell != "" && len(layout.Starts) < maxLayoutStarts {
+			layout.Starts = append(layout.Starts, cell)
+		}
+	}
+
+	if columns == nil {
+		if len(preamble) == 0 {
+			return layout, nil
+		}
+		if options.Columns != nil {
+			return nil, options.Columns.missingHeaderError()
+		}
+		return nil, fmt.Errorf("CSV format error: 'Name' and 'Start' columns not found")
+	}
+	layout.SampleRate = options.SampleRate
+	layout.Columns = make(map[string]int)
+	for field, index := range map[string]int{"name": columns.name, "start": columns.start, "end": columns.end, "part": columns.part, "format": columns.format, "track": columns.track} {
+		if index >= 0 {
+			layout.Columns[field] = index
+		}
+	}
+	return layout, nil
+}
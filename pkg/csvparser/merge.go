@@ -0,0 +1,36 @@
+package csvparser
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// MergeMarkers combines the markers of several sources into one list sorted by start time.
+// Markers with the same title at the same millisecond as an earlier one are dropped; it returns how many were dropped.
+func MergeMarkers(sources ...[]MarkerEntry) ([]MarkerEntry, int) {
+	var merged []MarkerEntry
+	for _, markers := range sources {
+		merged = append(merged, markers...)
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].StartTime < merged[j].StartTime
+	})
+
+	// Duplicates are adjacent after sorting, apart from other markers at the same time
+	type key struct {
+		start time.Duration
+		name  string
+	}
+	seen := make(map[key]bool)
+	unique := merged[:0]
+	for _, marker := range merged {
+		k := key{marker.StartTime.Truncate(time.Millisecond), strings.ToLower(strings.TrimSpace(marker.Name))}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		unique = append(unique, marker)
+	}
+	return unique, len(merged) - len(unique)
+}
@@ -1,9 +1,14 @@
 package csvparser
 
 import (
+	"bufio"
 	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -13,10 +18,60 @@ import (
 type MarkerEntry struct {
 	Name      string        // Marker name (chapter title)
 	StartTime time.Duration // Start time of the marker
+	Part      string        // Part or section of a multi-part program the marker belongs to (empty for none)
+	Track     string        // Track of a multitrack session the marker is on (empty for none)
+	EndTime   time.Duration // End time of the marker if the CSV file has an end column (0 for none)
+}
+
+// ParseOptions holds the optional settings of marker CSV parsing
+type ParseOptions struct {
+	SampleRate int        // Sample rate of markers given in samples, unless the CSV file states one (0 for unknown)
+	Columns    *ColumnMap // Columns of a CSV file that does not follow the Audition layout (nil to detect Audition's columns)
+	Source     string     // Name of the CSV file in errors (set from the path by ParseAuditionCSVWithOptions)
+	Lenient    bool       // Whether to skip rows that cannot be parsed and report them all as ParseErrors along with the other markers
+	MaxRows    int        // Largest number of rows after the header row accepted as a safeguard against runaway files (0 for no limit)
+	MaxBytes   int64      // Largest size of the CSV data in bytes (0 for no limit)
+}
+
+// ErrTooLarge is returned, wrapped, when CSV data exceeds ParseOptions.MaxBytes
+var ErrTooLarge = errors.New("CSV data too large")
+
+// limitedReader fails with ErrTooLarge once more than limit bytes have been read
+type limitedReader struct {
+	r         io.Reader
+	limit     int64
+	remaining int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return 0, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, l.limit)
+	}
+	return n, err
+}
+
+// markerColumns holds the column indices of a marker CSV file (-1 for missing optional columns)
+type markerColumns struct {
+	name   int
+	start  int
+	end    int
+	part   int
+	format int
+	track  int
 }
 
 // ParseAuditionCSV parses Adobe Audition marker CSV file
 func ParseAuditionCSV(filepath string) ([]MarkerEntry, error) {
+	return ParseAuditionCSVWithOptions(filepath, ParseOptions{})
+}
+
+// ParseAuditionCSVWithOptions parses Adobe Audition marker CSV file with options
+func ParseAuditionCSVWithOptions(filepath string, options ParseOptions) ([]MarkerEntry, error) {
 	// Open CSV file
 	file, err := os.Open(filepath)
 	if err != nil {
@@ -24,174 +79,418 @@ func ParseAuditionCSV(filepath string) ([]MarkerEntry, error) {
 	}
 	defer file.Close()
 
-	// Read CSV data
-	reader := csv.NewReader(file)
-	reader.Comma = '\t'            // Process tab-delimited CSV file
-	reader.LazyQuotes = true       // Process quotes flexibly
-	reader.TrimLeadingSpace = true // Remove leading whitespace
-
-	// Read all records
-	records, err := reader.ReadAll()
-	if err != nil {
-		return nil, fmt.Errorf("Failed to read CSV data: %w", err)
+	if options.Source == "" {
+		options.Source = filepath
 	}
+	return ParseAuditionCSVReaderWithOptions(file, options)
+}
 
-	// Check if file is empty
-	if len(records) <= 1 {
-		return []MarkerEntry{}, nil
-	}
+// ParseAuditionCSVReader parses Adobe Audition marker CSV data from a reader
+func ParseAuditionCSVReader(r io.Reader) ([]MarkerEntry, error) {
+	return ParseAuditionCSVReaderWithOptions(r, ParseOptions{})
+}
 
-	// Find header row and determine column indices
-	nameIdx, startTimeIdx, err := findHeaderColumns(records)
-	if err != nil {
+// ParseAuditionCSVReaderWithOptions parses Adobe Audition marker CSV data from a reader with options
+func ParseAuditionCSVReaderWithOptions(r io.Reader, options ParseOptions) ([]MarkerEntry, error) {
+	markers := []MarkerEntry{}
+	err := ParseAuditionCSVStream(r, options, func(marker MarkerEntry) error {
+		markers = append(markers, marker)
+		return nil
+	})
+	var problems ParseErrors
+	if err != nil && !errors.As(err, &problems) {
 		return nil, err
 	}
+	return markers, err
+}
 
-	// Parse all markers
-	markers, err := parseMarkers(records, nameIdx, startTimeIdx)
-	if err != nil {
-		return nil, err
+// ParseAuditionCSVStream parses Adobe Audition marker CSV data row by row and calls fn with each marker,
+// so memory use does not grow with the size of the file. Parsing stops at the first error returned by fn.
+func ParseAuditionCSVStream(r io.Reader, options ParseOptions, fn func(MarkerEntry) error) error {
+	if options.MaxBytes > 0 {
+		r = &limitedReader{r: r, limit: options.MaxBytes, remaining: options.MaxBytes}
 	}
 
-	return markers, nil
-}
+	reader := newMarkerReader(r, options)
 
-// findHeaderColumns searches for the header row in CSV records and returns the required column indices
-func findHeaderColumns(records [][]string) (nameIdx int, startTimeIdx int, err error) {
-	nameIdx, startTimeIdx = -1, -1
+	// Files mapped by column index have no header row, so every row is data
+	var parser *rowParser
+	if options.Columns != nil && options.Columns.headerless() {
+		parser = &rowParser{columns: options.Columns.indexColumns(), options: options}
+	}
 
-	// Search for header row
-	for _, row := range records {
-		if len(row) > 0 {
-			for j, cell := range row {
-				cellLower := strings.ToLower(strings.TrimSpace(cell))
-				if strings.Contains(cellLower, "name") {
-					nameIdx = j
-				} else if strings.Contains(cellLower, "start") {
-					startTimeIdx = j
+	var preamble [][]string // Rows above the header row, which may be metadata lines
+	var preambleLines []int
+	var problems ParseErrors
+	rows := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to read CSV data: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		// Collect rows until the header row, then read the metadata lines among them
+		if parser == nil {
+			columns, ok := findHeaderColumns(record, options.Columns)
+			if !ok {
+				if len(preamble) >= maxPreambleRows {
+					return fmt.Errorf("CSV format error: no header row in the first %d lines", maxPreambleRows)
 				}
+				preamble = append(preamble, slices.Clone(record))
+				preambleLines = append(preambleLines, line)
+				continue
 			}
+			format, err := parseMetadata(preamble, preambleLines, &options)
+			if err != nil {
+				return err
+			}
+			parser = &rowParser{columns: columns, header: slices.Clone(record), format: format, options: options}
+			preamble, preambleLines = nil, nil
+			continue
+		}
 
-			// If header row is found, start parsing from the next row
-			if nameIdx >= 0 && startTimeIdx >= 0 {
-				return nameIdx, startTimeIdx, nil
+		// Stop at implausibly large inputs before they exhaust memory
+		rows++
+		if options.MaxRows > 0 && rows > options.MaxRows {
+			return fmt.Errorf("CSV data has more than %d rows after the header row", options.MaxRows)
+		}
+
+		marker, rowErr := parser.parseRow(record, line)
+		if rowErr != nil {
+			if !options.Lenient {
+				return rowErr
 			}
+			problems = append(problems, rowErr)
+			continue
 		}
+		if marker != nil {
+			if err := fn(*marker); err != nil {
+				return err
+			}
+		}
+	}
+
+	// A file with at most one row and no header row is empty
+	if parser == nil && len(preamble) > 1 {
+		if options.Columns != nil {
+			return options.Columns.missingHeaderError()
+		}
+		return fmt.Errorf("CSV format error: 'Name' and 'Start' columns not found")
+	}
+	if len(problems) > 0 {
+		return problems
 	}
+	return nil
+}
 
-	// If required columns are not found
-	return -1, -1, fmt.Errorf("CSV format error: 'Name' and 'Start' columns not found")
+// newMarkerReader returns a reader of the rows of marker CSV data, which are tab-separated; mapped files may also be comma-separated
+func newMarkerReader(r io.Reader, options ParseOptions) *csv.Reader {
+	comma := '\t'
+	if options.Columns != nil {
+		buffered := bufio.NewReader(r)
+		comma, r = sniffComma(buffered), buffered
+	}
+	reader := csv.NewReader(r)
+	reader.Comma = comma           // Process tab-delimited CSV file
+	reader.LazyQuotes = true       // Process quotes flexibly
+	reader.TrimLeadingSpace = true // Remove leading whitespace
+	reader.FieldsPerRecord = -1    // Allow metadata lines above the header row
+	reader.ReuseRecord = true      // Avoid an allocation per row; kept rows are copied
+	return reader
 }
 
-// parseMarkers extracts marker information from data after the header row
-func parseMarkers(records [][]string, nameIdx int, startTimeIdx int) ([]MarkerEntry, error) {
-	var markers []MarkerEntry
+// maxPreambleRows is the number of rows searched for the header row
+const maxPreambleRows = 1000
 
-	// Skip header row and process only data rows
-	dataStart := 0
-	for rowIdx, row := range records {
-		if len(row) > 0 {
-			for _, cell := range row {
-				cellLower := strings.ToLower(strings.TrimSpace(cell))
-				if strings.Contains(cellLower, "name") || strings.Contains(cellLower, "start") {
-					dataStart = rowIdx + 1
-					break
-				}
-			}
-			if dataStart > 0 {
-				break
-			}
+// findHeaderColumns returns the column indices if the row is the header row, which has all mapped columns,
+// or without a column map Audition's "Name" and "Start" columns
+func findHeaderColumns(row []string, columnMap *ColumnMap) (markerColumns, bool) {
+	if columnMap != nil {
+		return columnMap.headerColumns(row)
+	}
+
+	columns := markerColumns{name: -1, start: -1, end: -1, part: -1, format: -1, track: -1}
+	for j, cell := range row {
+		cellLower := strings.ToLower(strings.TrimSpace(cell))
+		switch {
+		case strings.Contains(cellLower, "name"):
+			columns.name = j
+		case strings.Contains(cellLower, "start"):
+			columns.start = j
+		case (cellLower == "part" || cellLower == "section") && columns.part < 0:
+			columns.part = j
+		case (cellLower == "time format" || cellLower == "format") && columns.format < 0:
+			columns.format = j
+		case cellLower == "track" && columns.track < 0:
+			columns.track = j
 		}
 	}
+	return columns, columns.name >= 0 && columns.start >= 0
+}
 
-	// Parse each marker
-	for _, row := range records[dataStart:] {
-		if len(row) <= max(nameIdx, startTimeIdx) {
-			continue // Skip rows with insufficient columns
+// parseMetadata reads "Time Format" and "Sample Rate" lines above the header row, given either as two cells or as "Key: value".
+// It returns the time format of rows without a format cell and sets the sample rate in options if the CSV file states one.
+func parseMetadata(rows [][]string, lines []int, options *ParseOptions) (TimeFormat, error) {
+	var format TimeFormat
+	for i, row := range rows {
+		if len(row) == 0 {
+			continue
+		}
+		key, value, found := strings.Cut(row[0], ":")
+		if !found && len(row) > 1 {
+			value = row[1]
+		}
+		value = strings.TrimSpace(value)
+
+		var err error
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "time format":
+			format, err = ParseTimeFormat(value)
+		case "sample rate":
+			rate, parseErr := strconv.Atoi(strings.TrimSuffix(strings.ToLower(value), " hz"))
+			if parseErr != nil || rate <= 0 {
+				err = fmt.Errorf("Invalid sample rate: '%s'", value)
+			}
+			options.SampleRate = rate
+		}
+		if err != nil {
+			return TimeFormat{}, &RowError{Source: options.Source, Line: lines[i], Column: strings.TrimSpace(key), Row: row, Err: err}
 		}
+	}
+	return format, nil
+}
+
+// rowParser converts the data rows after the header row to markers
+type rowParser struct {
+	columns markerColumns
+	header  []string   // Header row naming the columns in errors (nil for files without one)
+	format  TimeFormat // Time format of rows without a format cell
+	options ParseOptions
+	part    string // Part of the preceding marker
+}
+
+// parseRow converts a data row to a marker, or returns nil for rows without a marker.
+// Markers with an empty part column belong to the part of the preceding marker, and
+// markers with an empty format column use the time format of the metadata lines.
+func (p *rowParser) parseRow(row []string, line int) (*MarkerEntry, *RowError) {
+	columns := p.columns
+	if len(row) <= max(columns.name, columns.start) {
+		return nil, nil // Skip rows with insufficient columns
+	}
+	rowError := func(column int, err error) *RowError {
+		return &RowError{Source: p.options.Source, Line: line, Column: p.columnName(column), Row: slices.Clone(row), Err: err}
+	}
+
+	// Get marker name
+	name := strings.TrimSpace(row[columns.name])
+	if name == "" {
+		return nil, nil // Skip items without a name
+	}
 
-		// Get marker name
-		name := strings.TrimSpace(row[nameIdx])
-		if name == "" {
-			continue // Skip items without a name
+	// Get the time format of the row, if the CSV file has a format column
+	rowFormat := p.format
+	if cell := optionalCell(row, columns.format); cell != "" {
+		var err error
+		if rowFormat, err = ParseTimeFormat(cell); err != nil {
+			return nil, rowError(columns.format, err)
 		}
+	}
 
-		// Parse start time
-		startTimeStr := strings.TrimSpace(row[startTimeIdx])
-		startTime, err := parseTimeString(startTimeStr)
-		if err != nil {
-			return nil, fmt.Errorf("Failed to parse start time '%s': %w", startTimeStr, err)
+	// Parse start time
+	startTimeStr := strings.TrimSpace(row[columns.start])
+	startTime, err := rowFormat.Parse(startTimeStr, p.options.SampleRate)
+	if err != nil {
+		return nil, rowError(columns.start, fmt.Errorf("Failed to parse start time '%s': %w", startTimeStr, err))
+	}
+
+	// Parse end time, if the CSV file has an end column
+	var endTime time.Duration
+	if cell := optionalCell(row, columns.end); cell != "" {
+		if endTime, err = rowFormat.Parse(cell, p.options.SampleRate); err != nil {
+			return nil, rowError(columns.end, fmt.Errorf("Failed to parse end time '%s': %w", cell, err))
+		}
+		if endTime < startTime {
+			return nil, rowError(columns.end, fmt.Errorf("Marker '%s' ends at %s before it starts at %s", name, cell, startTimeStr))
 		}
+	}
 
-		// Add marker to the list
-		markers = append(markers, MarkerEntry{
-			Name:      name,
-			StartTime: startTime,
-		})
+	// Get part, if the CSV file has a part column
+	if cell := optionalCell(row, columns.part); cell != "" {
+		p.part = cell
 	}
 
-	return markers, nil
+	return &MarkerEntry{
+		Name:      name,
+		StartTime: startTime,
+		Part:      p.part,
+		Track:     optionalCell(row, columns.track),
+		EndTime:   endTime,
+	}, nil
 }
 
-// parseTimeString converts various time string formats to time.Duration
-func parseTimeString(timeStr string) (time.Duration, error) {
-	// Try to parse as decimal seconds
-	if seconds, err := strconv.ParseFloat(timeStr, 64); err == nil {
-		return time.Duration(seconds * float64(time.Second)), nil
+// columnName returns the header of a column, or "column N" in files without a header row
+func (p *rowParser) columnName(idx int) string {
+	if idx < len(p.header) && strings.TrimSpace(p.header[idx]) != "" {
+		return strings.TrimSpace(p.header[idx])
 	}
+	return fmt.Sprintf("column %d", idx+1)
+}
 
-	// Try to parse as MM:SS.mmm format
-	if strings.Contains(timeStr, ":") {
-		parts := strings.Split(timeStr, ":")
+// optionalCell returns the trimmed cell of an optional column, or an empty string if the column or cell is missing
+func optionalCell(row []string, idx int) string {
+	if idx < 0 || idx >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[idx])
+}
 
-		switch len(parts) {
-		case 2:
-			// MM:SS.mmm format
-			return parseMinutesSeconds(parts)
-		case 3:
-			// HH:MM:SS.mmm format
-			return parseHoursMinutesSeconds(parts)
+// Tracks returns the distinct tracks of the markers in order of appearance, leaving out markers without a track
+func Tracks(markers []MarkerEntry) []string {
+	var tracks []string
+	for _, marker := range markers {
+		if marker.Track != "" && !slices.ContainsFunc(tracks, func(track string) bool { return strings.EqualFold(track, marker.Track) }) {
+			tracks = append(tracks, marker.Track)
 		}
 	}
+	return tracks
+}
 
-	return 0, fmt.Errorf("Unsupported time format: %s", timeStr)
+// SelectTrack returns the markers on the track, compared case-insensitively
+func SelectTrack(markers []MarkerEntry, track string) ([]MarkerEntry, error) {
+	var selected []MarkerEntry
+	for _, marker := range markers {
+		if strings.EqualFold(marker.Track, track) {
+			selected = append(selected, marker)
+		}
+	}
+	if len(selected) == 0 {
+		tracks := Tracks(markers)
+		if len(tracks) == 0 {
+			return nil, fmt.Errorf("No markers on track '%s': the CSV file has no Track column", track)
+		}
+		return nil, fmt.Errorf("No markers on track '%s' (tracks: %s)", track, strings.Join(tracks, ", "))
+	}
+	return selected, nil
 }
 
-// parseMinutesSeconds parses time strings in MM:SS.mmm format
-func parseMinutesSeconds(parts []string) (time.Duration, error) {
-	minutes, err := strconv.ParseFloat(parts[0], 64)
-	if err != nil {
-		return 0, fmt.Errorf("Invalid minutes format: %v", err)
+// SplitParts moves the text before the first delimiter in marker names, e.g. "Hour 1 / News" with " / ", into the part.
+// Markers without the delimiter belong to the part of the preceding marker.
+func SplitParts(markers []MarkerEntry, delimiter string) []MarkerEntry {
+	split := make([]MarkerEntry, len(markers))
+	part := ""
+	for i, marker := range markers {
+		if before, after, found := strings.Cut(marker.Name, delimiter); found && strings.TrimSpace(before) != "" && strings.TrimSpace(after) != "" {
+			marker.Part = strings.TrimSpace(before)
+			marker.Name = strings.TrimSpace(after)
+		} else if marker.Part == "" {
+			marker.Part = part
+		}
+		part = marker.Part
+		split[i] = marker
 	}
+	return split
+}
 
-	seconds, err := strconv.ParseFloat(parts[1], 64)
+// ParseTime parses a marker time in one of the formats of Audition marker CSV files: decimal seconds, MM:SS.mmm or HH:MM:SS.mmm
+func ParseTime(text string) (time.Duration, error) {
+	d, err := parseTimeString(strings.TrimSpace(text))
+	if err == nil && d < 0 {
+		return 0, fmt.Errorf("Negative time: %s", text)
+	}
+	return d, err
+}
+
+// parseTimeString converts various time string formats to time.Duration.
+// It uses integer arithmetic throughout, so times are exact to the nanosecond however long the file is.
+func parseTimeString(timeStr string) (time.Duration, error) {
+	text, negative := strings.CutPrefix(timeStr, "-")
+	parts := strings.Split(text, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("Unsupported time format: %s", timeStr)
+	}
+
+	// Decimal seconds, MM:SS.mmm or HH:MM:SS.mmm
+	total, err := ParseSeconds(parts[len(parts)-1])
+	if errors.Is(err, errOutOfRange) {
+		return 0, fmt.Errorf("Time out of range: %s", timeStr)
+	}
 	if err != nil {
-		return 0, fmt.Errorf("Invalid seconds format: %v", err)
+		return 0, fmt.Errorf("Unsupported time format: %s", timeStr)
+	}
+	units := []time.Duration{time.Minute, time.Hour} // Of the fields before the seconds, from right to left
+	names := []string{"minutes", "hours"}
+	for i := range len(parts) - 1 {
+		field := parts[len(parts)-2-i]
+		value, err := strconv.ParseInt(field, 10, 64)
+		if err != nil || value < 0 {
+			return 0, fmt.Errorf("Invalid %s format: %s", names[i], field)
+		}
+		if value > int64((math.MaxInt64-total)/units[i]) {
+			return 0, fmt.Errorf("Time out of range: %s", timeStr)
+		}
+		total += time.Duration(value) * units[i]
 	}
 
-	totalSeconds := minutes*60 + seconds
-	return time.Duration(totalSeconds * float64(time.Second)), nil
+	if negative {
+		total = -total
+	}
+	return total, nil
 }
 
-// parseHoursMinutesSeconds parses time strings in HH:MM:SS.mmm format
-func parseHoursMinutesSeconds(parts []string) (time.Duration, error) {
-	hours, err := strconv.ParseFloat(parts[0], 64)
-	if err != nil {
-		return 0, fmt.Errorf("Invalid hours format: %v", err)
+// errOutOfRange is returned, wrapped, for times too large for a time.Duration
+var errOutOfRange = errors.New("Time out of range")
+
+// ParseSeconds parses decimal seconds such as "12.3456" exactly, rounding to the nearest nanosecond
+func ParseSeconds(text string) (time.Duration, error) {
+	whole, fraction, _ := strings.Cut(text, ".")
+	if whole == "" && fraction == "" {
+		return 0, fmt.Errorf("Invalid seconds format: '%s'", text)
+	}
+	for _, r := range whole + fraction {
+		if r < '0' || r > '9' {
+			return 0, fmt.Errorf("Invalid seconds format: '%s'", text)
+		}
 	}
 
-	minutes, err := strconv.ParseFloat(parts[1], 64)
-	if err != nil {
-		return 0, fmt.Errorf("Invalid minutes format: %v", err)
+	var seconds int64
+	if whole != "" {
+		var err error
+		if seconds, err = strconv.ParseInt(whole, 10, 64); err != nil || seconds > math.MaxInt64/int64(time.Second)-1 {
+			return 0, fmt.Errorf("%w: seconds '%s'", errOutOfRange, text)
+		}
 	}
 
-	seconds, err := strconv.ParseFloat(parts[2], 64)
-	if err != nil {
-		return 0, fmt.Errorf("Invalid seconds format: %v", err)
+	// Take nine fractional digits as nanoseconds and round on the tenth
+	var nanos int64
+	for i := range 9 {
+		nanos *= 10
+		if i < len(fraction) {
+			nanos += int64(fraction[i] - '0')
+		}
+	}
+	if len(fraction) > 9 && fraction[9] >= '5' {
+		nanos++
+	}
+	return time.Duration(seconds)*time.Second + time.Duration(nanos), nil
+}
+
+// samplesToDuration converts a sample position to a duration like SamplesToDuration, failing if it is out of range
+func samplesToDuration(samples int64, sampleRate int, text string) (time.Duration, error) {
+	if samples/int64(sampleRate) > math.MaxInt64/int64(time.Second)-1 {
+		return 0, fmt.Errorf("Time out of range: %s", text)
 	}
+	return SamplesToDuration(samples, sampleRate), nil
+}
 
-	totalSeconds := hours*3600 + minutes*60 + seconds
-	return time.Duration(totalSeconds * float64(time.Second)), nil
+// SamplesToDuration converts a sample position at the given sample rate to a duration, rounding to the nearest nanosecond
+func SamplesToDuration(samples int64, sampleRate int) time.Duration {
+	rate := int64(sampleRate)
+	whole, rest := samples/rate, samples%rate
+	return time.Duration(whole)*time.Second + time.Duration((rest*int64(time.Second)+rate/2)/rate)
 }
 
 // max returns the maximum value of the provided integers
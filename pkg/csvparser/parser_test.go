@@ -0,0 +1,140 @@
+package csvparser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testCSV = "Name\tStart\tDuration\tTime Format\tType\tDescription\n" +
+	"Intro\t0:00.000\t0:00.000\tdecimal\tCue\t\n" +
+	"Talk\t1:30.500\t0:00.000\tdecimal\tCue\t\n"
+
+func TestParseLimits(t *testing.T) {
+	if _, err := ParseAuditionCSVReaderWithOptions(strings.NewReader(testCSV), ParseOptions{MaxBytes: int64(len(testCSV))}); err != nil {
+		t.Errorf("parsing CSV data of exactly MaxBytes: %v", err)
+	}
+	if _, err := ParseAuditionCSVReaderWithOptions(strings.NewReader(testCSV), ParseOptions{MaxBytes: int64(len(testCSV)) - 1}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("parsing CSV data above MaxBytes: error %v, want ErrTooLarge", err)
+	}
+}
+
+func FuzzParseAuditionCSV(f *testing.F) {
+	f.Add([]byte(testCSV), int64(0), false)
+	f.Add([]byte(testCSV), int64(100), true)
+	f.Add([]byte("Sample Rate: 48000\nName\tStart\tDuration\tTime Format\tType\tDescription\nA\t96000\t0\t48000 Hz\tCue\t\n"), int64(0), false)
+	f.Add([]byte("Name,Start,Duration,Time Format,Type,Description\r\nA,00:01:02:03,0,30 fps drop,Cue,\r\n"), int64(0), true)
+	f.Add([]byte("\ufeffName;Start;End;Part\nA;1:00.000;2:00.000;Part 1\n"), int64(0), false)
+	f.Fuzz(func(t *testing.T, data []byte, maxBytes int64, lenient bool) {
+		options := ParseOptions{MaxBytes: maxBytes, Lenient: lenient, MaxRows: 1000}
+		markers, err := ParseAuditionCSVReaderWithOptions(bytes.NewReader(data), options)
+		var problems ParseErrors
+		if err != nil && !errors.As(err, &problems) {
+			return
+		}
+		if maxBytes > 0 && int64(len(data)) > maxBytes {
+			t.Fatalf("parsed %d bytes of CSV data with MaxBytes %d", len(data), maxBytes)
+		}
+		if len(markers) > options.MaxRows {
+			t.Fatalf("parsed %d markers with MaxRows %d", len(markers), options.MaxRows)
+		}
+		for _, marker := range markers {
+			if marker.StartTime < 0 {
+				t.Fatalf("marker %q has a negative start time %v", marker.Name, marker.StartTime)
+			}
+		}
+	})
+}
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		text string
+		want time.Duration
+		err  string // Substring of the expected error (empty for none)
+	}{
+		{"12.3456", 12*time.Second + 345600*time.Microsecond, ""},
+		{"0.3", 300 * time.Millisecond, ""},
+		{"1:30.500", 90*time.Second + 500*time.Millisecond, ""},
+		{"1:02:03.004", time.Hour + 2*time.Minute + 3*time.Second + 4*time.Millisecond, ""},
+		{"0.0000000015", 2, ""}, // Rounded to the nearest nanosecond
+		{"2562047:47:16.854775807", math.MaxInt64, ""},
+		{"2562047:47:16.854775808", 0, "out of range"},
+		{"2562048:00:00", 0, "out of range"},
+		{"9223372036854775807:00:00", 0, "out of range"},
+		{"9223372036", 0, "out of range"},
+		{"-1.5", 0, "Negative time"},
+		{"1:2:3:4", 0, "Unsupported time format"},
+		{"1:x:00", 0, "Invalid minutes format"},
+		{"1e3", 0, "Unsupported time format"},
+	}
+	for _, tt := range tests {
+		got, err := ParseTime(tt.text)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ParseTime(%q) = %v, %v, want an error containing %q", tt.text, got, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseTime(%q) = %v, %v, want %v", tt.text, got, err, tt.want)
+		}
+	}
+
+	// Decimal seconds add up exactly, unlike 0.1+0.2 in floating point
+	a, _ := ParseTime("0.1")
+	b, _ := ParseTime("0.2")
+	if c, _ := ParseTime("0.3"); a+b != c {
+		t.Errorf("ParseTime(\"0.1\") + ParseTime(\"0.2\") = %v, want %v", a+b, c)
+	}
+
+	// HH:MM:SS.mmm times round-trip exactly
+	for _, d := range []time.Duration{0, time.Millisecond, 59*time.Minute + 59*time.Second + 999*time.Millisecond, 1000*time.Hour + 1234*time.Millisecond} {
+		text := fmt.Sprintf("%d:%02d:%02d.%03d", int64(d/time.Hour), int64(d/time.Minute%60), int64(d/time.Second%60), int64(d/time.Millisecond%1000))
+		if got, err := ParseTime(text); err != nil || got != d {
+			t.Errorf("ParseTime(%q) = %v, %v, want %v", text, got, err, d)
+		}
+	}
+}
+
+func TestTimeFormatParse(t *testing.T) {
+	tests := []struct {
+		format     string
+		text       string
+		sampleRate int
+		want       time.Duration
+		err        string // Substring of the expected error (empty for none)
+	}{
+		{"samples", "48000", 48000, time.Second, ""},
+		{"samples", "1", 44100, 22676, ""}, // 1/44100 s rounded to the nearest nanosecond
+		{"samples", "172800000000", 48000, 1000 * time.Hour, ""},
+		{"samples", "9223372036854775807", 48000, 0, "out of range"},
+		{"samples", "96000", 0, 0, "sample rate unknown"},
+		{"30fps", "00:00:01:15", 0, 1500 * time.Millisecond, ""},
+		{"25 fps", "01:00:00:00", 0, time.Hour, ""},
+		{"29.97 fps", "00:00:01:00", 0, 1001 * time.Millisecond, ""},
+		{"29.97 fps drop", "00:01:00;02", 0, 1800 * 1001 * time.Second / 30000, ""},
+		{"29.97 fps drop", "00:10:00;00", 0, 17982 * 1001 * time.Second / 30000, ""},
+		{"30fps", "00:00:01:30", 0, 0, "Invalid timecode"},
+		{"30fps", "2147483647:00:00:00", 0, 0, "out of range"},
+	}
+	for _, tt := range tests {
+		format, err := ParseTimeFormat(tt.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := format.Parse(tt.text, tt.sampleRate)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s Parse(%q) = %v, %v, want an error containing %q", tt.format, tt.text, got, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s Parse(%q) = %v, %v, want %v", tt.format, tt.text, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package csvparser
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnknownSampleRate is returned, wrapped, when markers are given in samples but the sample rate is unknown
+var ErrUnknownSampleRate = errors.New("sample rate unknown")
+
+// TimeFormat is the format of marker times selected by Audition's time display setting
+type TimeFormat struct {
+	Samples   bool // Whether times are sample positions
+	FrameRate int  // Nominal frames per second of SMPTE timecode HH:MM:SS:FF (0 for decimal or sample times)
+	Pulldown  bool // Whether the timecode runs at 1000/1001 of the nominal frame rate, as 29.97 fps does
+	DropFrame bool // Whether the timecode skips frame numbers to match real time, as "29.97 fps drop" does
+}
+
+// frameRatePattern matches the frame rate in time formats such as "30fps" or "SMPTE Drop (29.97 fps)"
+var frameRatePattern = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*fps`)
+
+// ParseTimeFormat parses the value of a "Time Format" column or metadata line: "decimal", "samples" or a frame rate such as "30fps".
+// An empty value means decimal.
+func ParseTimeFormat(text string) (TimeFormat, error) {
+	value := strings.ToLower(strings.TrimSpace(text))
+	switch value {
+	case "", "decimal":
+		return TimeFormat{}, nil
+	case "samples":
+		return TimeFormat{Samples: true}, nil
+	}
+
+	match := frameRatePattern.FindStringSubmatch(value)
+	if match == nil {
+		return TimeFormat{}, fmt.Errorf("Unsupported time format: '%s'", text)
+	}
+	fps, err := strconv.ParseFloat(match[1], 64)
+	if err != nil || fps < 1 || fps > 1000 {
+		return TimeFormat{}, fmt.Errorf("Invalid frame rate in time format: '%s'", text)
+	}
+	format := TimeFormat{FrameRate: int(math.Round(fps))}
+	format.Pulldown = fps != float64(format.FrameRate)
+	format.DropFrame = strings.Contains(value, "drop")
+	if format.DropFrame && (!format.Pulldown || format.FrameRate%30 != 0) {
+		return TimeFormat{}, fmt.Errorf("Drop-frame timecode requires 29.97 or 59.94 fps: '%s'", text)
+	}
+	return format, nil
+}
+
+// Parse converts a marker time in the format to a duration; sampleRate is only used for sample positions
+func (format TimeFormat) Parse(text string, sampleRate int) (time.Duration, error) {
+	switch {
+	case format.Samples:
+		samples, err := strconv.ParseInt(text, 10, 64)
+		if err != nil || samples < 0 {
+			return 0, fmt.Errorf("Invalid sample position: %s", text)
+		}
+		if sampleRate <= 0 {
+			return 0, fmt.Errorf("%w: cannot convert sample position %s", ErrUnknownSampleRate, text)
+		}
+		return samplesToDuration(samples, sampleRate, text)
+	case format.FrameRate > 0:
+		return format.parseTimecode(text)
+	default:
+		return ParseTime(text)
+	}
+}
+
+// parseTimecode converts SMPTE timecode HH:MM:SS:FF, or MM:SS:FF, to a duration.
+// Drop-frame timecode may separate the frames with a semicolon.
+func (format TimeFormat) parseTimecode(text string) (time.Duration, error) {
+	fields := strings.FieldsFunc(text, func(r rune) bool { return r == ':' || r == ';' })
+	if len(fields) < 3 || len(fields) > 4 {
+		return 0, fmt.Errorf("Invalid timecode: %s", text)
+	}
+	if len(fields) == 3 {
+		fields = append([]string{"0"}, fields...)
+	}
+	var values [4]int64
+	for i, field := range fields {
+		value, err := strconv.ParseInt(field, 10, 64)
+		if err != nil || value < 0 || value > math.MaxInt32 {
+			return 0, fmt.Errorf("Invalid timecode: %s", text)
+		}
+		values[i] = value
+	}
+	hours, minutes, seconds, frames := values[0], values[1], values[2], values[3]
+	rate := int64(format.FrameRate)
+	if minutes > 59 || seconds > 59 || frames >= rate {
+		return 0, fmt.Errorf("Invalid timecode: %s", text)
+	}
+
+	// Count frames, leaving out the frame numbers drop-frame timecode skips at every minute but each tenth
+	count := ((hours*60+minutes)*60+seconds)*rate + frames
+	if format.DropFrame {
+		totalMinutes := hours*60 + minutes
+		count -= rate / 15 * (totalMinutes - totalMinutes/10)
+	}
+	if format.Pulldown {
+		return samplesToDuration(count*1001, format.FrameRate*1000, text)
+	}
+	return samplesToDuration(count, format.FrameRate, text)
+}
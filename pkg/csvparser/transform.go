@@ -0,0 +1,62 @@
+package csvparser
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// NumberMarkers prefixes marker names with their position in the list, e.g. "01. Intro",
+// counting from start and zero-padding the numbers to width digits. Untitled markers get the number alone.
+func NumberMarkers(markers []MarkerEntry, start, width int) []MarkerEntry {
+	numbered := make([]MarkerEntry, len(markers))
+	for i, marker := range markers {
+		number := fmt.Sprintf("%0*d", width, start+i)
+		if name := strings.TrimSpace(marker.Name); name != "" {
+			marker.Name = number + ". " + name
+		} else {
+			marker.Name = number
+		}
+		numbered[i] = marker
+	}
+	return numbered
+}
+
+// Absorbed records a marker merged into the preceding chapter by MergeCloseMarkers
+type Absorbed struct {
+	Marker MarkerEntry // Marker that was dropped
+	Into   MarkerEntry // Chapter it was merged into, as given
+}
+
+// MergeCloseMarkers merges each marker starting less than gap after the preceding kept marker into it,
+// so chapters are at least gap apart. The kept marker is extended to the end of the markers it absorbs.
+// Markers of another part are never absorbed, so parts keep their first chapter.
+func MergeCloseMarkers(markers []MarkerEntry, gap time.Duration) ([]MarkerEntry, []Absorbed) {
+	var kept []MarkerEntry
+	var absorbed []Absorbed
+	for _, marker := range markers {
+		if len(kept) > 0 {
+			previous := &kept[len(kept)-1]
+			if distance := marker.StartTime - previous.StartTime; distance >= 0 && distance < gap && marker.Part == previous.Part {
+				absorbed = append(absorbed, Absorbed{Marker: marker, Into: *previous})
+				if marker.EndTime > previous.EndTime {
+					previous.EndTime = marker.EndTime
+				}
+				continue
+			}
+		}
+		kept = append(kept, marker)
+	}
+	return kept, absorbed
+}
+
+// RoundMarkers rounds marker start and end times to the nearest multiple of grid, e.g. whole seconds
+func RoundMarkers(markers []MarkerEntry, grid time.Duration) []MarkerEntry {
+	rounded := make([]MarkerEntry, len(markers))
+	for i, marker := range markers {
+		marker.StartTime = marker.StartTime.Round(grid)
+		marker.EndTime = marker.EndTime.Round(grid)
+		rounded[i] = marker
+	}
+	return rounded
+}
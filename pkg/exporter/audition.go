@@ -0,0 +1,46 @@
+package exporter
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+func init() {
+	register(Format{
+		Name:        "audition",
+		Extension:   ".csv",
+		Description: "Adobe Audition marker CSV (tab-delimited, can be imported back into Audition)",
+		Write:       writeAudition,
+	})
+}
+
+// writeAudition writes markers as an Adobe Audition marker CSV file
+func writeAudition(w io.Writer, markers []csvparser.MarkerEntry, options Options) error {
+	writer := csv.NewWriter(w)
+	writer.Comma = '\t'
+
+	// Write header and one cue marker per row
+	writer.Write([]string{"Name", "Start", "Duration", "Time Format", "Type", "Description"})
+	for _, marker := range markers {
+		writer.Write([]string{marker.Name, formatAuditionTime(marker.StartTime), formatAuditionTime(0), "decimal", "Cue", ""})
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("Failed to write Audition CSV: %w", err)
+	}
+	return nil
+}
+
+// formatAuditionTime formats a duration as M:SS.mmm, or H:MM:SS.mmm from one hour on
+func formatAuditionTime(d time.Duration) string {
+	ms := d.Milliseconds()
+	if ms >= int64(time.Hour/time.Millisecond) {
+		return fmt.Sprintf("%d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+	}
+	return fmt.Sprintf("%d:%02d.%03d", ms/60000, ms/1000%60, ms%1000)
+}
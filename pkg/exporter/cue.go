@@ -0,0 +1,53 @@
+package exporter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+func init() {
+	register(Format{
+		Name:        "cue",
+		Extension:   ".cue",
+		Description: "CUE sheet with one track per chapter (for foobar2000, CD burning and splitting tools)",
+		Write:       writeCue,
+	})
+}
+
+// writeCue writes markers as a CUE sheet with one track per chapter
+func writeCue(w io.Writer, markers []csvparser.MarkerEntry, options Options) error {
+	audioFile := "audio.mp3"
+	if options.AudioFile != "" {
+		audioFile = filepath.Base(options.AudioFile)
+	}
+
+	writer := bufio.NewWriter(w)
+	fmt.Fprintf(writer, "FILE %s MP3\n", quoteCue(audioFile))
+	for i, marker := range markers {
+		fmt.Fprintf(writer, "  TRACK %02d AUDIO\n", i+1)
+		fmt.Fprintf(writer, "    TITLE %s\n", quoteCue(marker.Name))
+		fmt.Fprintf(writer, "    INDEX 01 %s\n", formatCueTime(marker.StartTime))
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("Failed to write CUE sheet: %w", err)
+	}
+	return nil
+}
+
+// quoteCue quotes a CUE sheet string; double quotes cannot be escaped and are replaced with single quotes
+func quoteCue(text string) string {
+	return `"` + strings.ReplaceAll(text, `"`, `'`) + `"`
+}
+
+// formatCueTime formats a duration as MM:SS:FF with 75 frames per second, rounding down to a frame
+func formatCueTime(d time.Duration) string {
+	frames := d * 75 / time.Second
+	return fmt.Sprintf("%02d:%02d:%02d", frames/75/60, frames/75%60, frames%75)
+}
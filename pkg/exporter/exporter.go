@@ -0,0 +1,62 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+// Options holds settings shared by all export formats
+type Options struct {
+	Duration  time.Duration // Total audio length, used as the end of the last chapter (0 if unknown)
+	Language  string        // Language code for formats that store one (default "und")
+	AudioFile string        // Path of the audio file for formats that reference one
+	Template  string        // Template text replacing the default layout of template-driven formats (empty for the default)
+}
+
+// Format describes a chapter export format
+type Format struct {
+	Name        string // Format name used on the command line
+	Extension   string // Default file extension including the dot
+	Description string // Short description for help messages
+	Write       func(w io.Writer, markers []csvparser.MarkerEntry, options Options) error
+}
+
+// formats holds all registered export formats by name
+var formats = make(map[string]Format)
+
+// register adds an export format to the registry
+func register(format Format) {
+	formats[format.Name] = format
+}
+
+// Lookup returns the export format with the given name
+func Lookup(name string) (Format, error) {
+	format, ok := formats[strings.ToLower(name)]
+	if !ok {
+		return Format{}, fmt.Errorf("Unknown export format '%s' (available: %s)", name, strings.Join(Names(), ", "))
+	}
+	return format, nil
+}
+
+// Names returns the names of all registered export formats in alphabetical order
+func Names() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// chapterEnd returns the end time of the marker at index i: the next marker's start, or the total duration for the last one
+func chapterEnd(markers []csvparser.MarkerEntry, i int, duration time.Duration) time.Duration {
+	if i+1 < len(markers) {
+		return markers[i+1].StartTime
+	}
+	return duration
+}
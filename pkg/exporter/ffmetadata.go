@@ -0,0 +1,38 @@
+package exporter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+func init() {
+	register(Format{
+		Name:        "ffmetadata",
+		Extension:   ".txt",
+		Description: "FFmpeg metadata file (FFMETADATA1), for ffmpeg -map_chapters",
+		Write:       writeFFMetadata,
+	})
+}
+
+// ffMetadataEscaper escapes the characters with a special meaning in FFmpeg metadata files
+var ffMetadataEscaper = strings.NewReplacer(`\`, `\\`, "=", `\=`, ";", `\;`, "#", `\#`, "\n", "\\\n")
+
+// writeFFMetadata writes markers as FFmpeg metadata chapters with millisecond times.
+// The last chapter ends at the audio length, or at its start if the length is unknown.
+func writeFFMetadata(w io.Writer, markers []csvparser.MarkerEntry, options Options) error {
+	writer := bufio.NewWriter(w)
+	fmt.Fprint(writer, ";FFMETADATA1\n")
+	for i, marker := range markers {
+		end := max(chapterEnd(markers, i, options.Duration), marker.StartTime)
+		fmt.Fprintf(writer, "\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n", marker.StartTime.Milliseconds(), end.Milliseconds(), ffMetadataEscaper.Replace(marker.Name))
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("Failed to write FFmpeg metadata: %w", err)
+	}
+	return nil
+}
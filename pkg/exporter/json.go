@@ -0,0 +1,59 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+func init() {
+	register(Format{
+		Name:        "json",
+		Extension:   ".json",
+		Description: "Podcasting 2.0 JSON chapters (podcast:chapters)",
+		Write:       writeJSON,
+	})
+}
+
+// JSONChapters is the root object of a Podcasting 2.0 JSON chapters file
+type JSONChapters struct {
+	Version  string        `json:"version"`
+	Chapters []JSONChapter `json:"chapters"`
+}
+
+// JSONChapter is a single chapter with times in seconds
+type JSONChapter struct {
+	StartTime float64  `json:"startTime"`
+	EndTime   *float64 `json:"endTime,omitempty"`
+	Title     string   `json:"title"`
+}
+
+// writeJSON writes markers as a Podcasting 2.0 JSON chapters file
+func writeJSON(w io.Writer, markers []csvparser.MarkerEntry, options Options) error {
+	doc := JSONChapters{Version: "1.2.0", Chapters: make([]JSONChapter, 0, len(markers))}
+	for i, marker := range markers {
+		chapter := JSONChapter{StartTime: jsonSeconds(marker.StartTime), Title: marker.Name}
+		if end := chapterEnd(markers, i, options.Duration); end > marker.StartTime {
+			seconds := jsonSeconds(end)
+			chapter.EndTime = &seconds
+		}
+		doc.Chapters = append(doc.Chapters, chapter)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("Failed to encode JSON chapters: %w", err)
+	}
+	return nil
+}
+
+// jsonSeconds converts a duration to seconds rounded to milliseconds
+func jsonSeconds(d time.Duration) float64 {
+	return math.Round(d.Seconds()*1000) / 1000
+}
@@ -0,0 +1,154 @@
+package exporter
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/artwork"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+func init() {
+	register(Format{
+		Name:        "markdown",
+		Extension:   ".md",
+		Description: "Markdown chapter list for show notes and READMEs (layout can be replaced with a template)",
+		Write:       writeMarkdown,
+	})
+	register(Format{
+		Name:        "html",
+		Extension:   ".html",
+		Description: "HTML snippet listing the chapters with anchors and data attributes (layout can be replaced with a template)",
+		Write:       writeHTML,
+	})
+}
+
+// markdownTemplate is the default layout of the markdown format, which the markdown importer reads back
+const markdownTemplate = `{{range .Chapters}}- [{{timestamp .Start}}] {{.Title}}
+{{end}}`
+
+// htmlTemplate is the default layout of the html format
+const htmlTemplate = `<ol class="chapters">
+{{- range .Chapters}}
+  <li id="{{.Anchor}}" data-start="{{seconds .Start}}"{{if .End}} data-end="{{seconds .End}}"{{end}}><a href="#t={{seconds .Start}}">{{timestamp .Start}}</a> {{.Title}}</li>
+{{- end}}
+</ol>
+`
+
+// ListingData is the data passed to chapter listing templates
+type ListingData struct {
+	Chapters  []ListingChapter
+	Duration  time.Duration // Total audio length (0 if unknown)
+	AudioFile string        // Base name of the audio file (empty if unknown)
+}
+
+// ListingChapter is a chapter of a chapter listing
+type ListingChapter struct {
+	Number int           // 1-based chapter number
+	Title  string        // Chapter title
+	Start  time.Duration // Start time
+	End    time.Duration // End time: the next chapter's start or the audio length (0 for the last chapter if the length is unknown)
+	Anchor string        // Unique HTML id, e.g. "chapter-2-interview-with-x"
+}
+
+// ListingFuncs are the helper functions available in chapter listing templates
+var ListingFuncs = map[string]any{
+	"timestamp":    formatTimestamp,
+	"seconds":      formatSeconds,
+	"clock":        formatWebVTTTime,
+	"milliseconds": func(d time.Duration) int64 { return d.Milliseconds() },
+	"json":         jsonString,
+	"upper":        strings.ToUpper,
+	"lower":        strings.ToLower,
+}
+
+// NewListingData returns the template data of markers
+func NewListingData(markers []csvparser.MarkerEntry, options Options) ListingData {
+	data := ListingData{Chapters: make([]ListingChapter, 0, len(markers)), Duration: options.Duration}
+	if options.AudioFile != "" {
+		data.AudioFile = filepath.Base(options.AudioFile)
+	}
+	for i, marker := range markers {
+		anchor := "chapter-" + strconv.Itoa(i+1)
+		if slug := artwork.Slugify(marker.Name); slug != "" {
+			anchor += "-" + slug
+		}
+		chapter := ListingChapter{Number: i + 1, Title: marker.Name, Start: marker.StartTime, Anchor: anchor}
+		if end := chapterEnd(markers, i, options.Duration); end > marker.StartTime {
+			chapter.End = end
+		}
+		data.Chapters = append(data.Chapters, chapter)
+	}
+	return data
+}
+
+// writeMarkdown writes markers as a Markdown list, or with the template of options
+func writeMarkdown(w io.Writer, markers []csvparser.MarkerEntry, options Options) error {
+	text := markdownTemplate
+	if options.Template != "" {
+		text = options.Template
+	}
+	tmpl, err := template.New("markdown").Funcs(ListingFuncs).Parse(text)
+	if err != nil {
+		return fmt.Errorf("Invalid Markdown template: %w", err)
+	}
+	return executeListing(w, tmpl, markers, options)
+}
+
+// writeHTML writes markers as an HTML ordered list, or with the template of options; titles are escaped
+func writeHTML(w io.Writer, markers []csvparser.MarkerEntry, options Options) error {
+	text := htmlTemplate
+	if options.Template != "" {
+		text = options.Template
+	}
+	tmpl, err := htmltemplate.New("html").Funcs(ListingFuncs).Parse(text)
+	if err != nil {
+		return fmt.Errorf("Invalid HTML template: %w", err)
+	}
+	return executeListing(w, tmpl, markers, options)
+}
+
+// listingTemplate is a parsed text or HTML template
+type listingTemplate interface {
+	Execute(w io.Writer, data any) error
+}
+
+// executeListing runs a text or HTML template over the listing data of markers
+func executeListing(w io.Writer, tmpl listingTemplate, markers []csvparser.MarkerEntry, options Options) error {
+	writer := bufio.NewWriter(w)
+	if err := tmpl.Execute(writer, NewListingData(markers, options)); err != nil {
+		return fmt.Errorf("Failed to render chapter listing: %w", err)
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("Failed to write chapter listing: %w", err)
+	}
+	return nil
+}
+
+// formatTimestamp formats a duration as MM:SS, or H:MM:SS from an hour on, as shown in show notes and video descriptions
+func formatTimestamp(d time.Duration) string {
+	s := int64(d / time.Second)
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%02d:%02d", s/60, s%60)
+}
+
+// jsonString quotes a string as a JSON string literal
+func jsonString(s string) (string, error) {
+	data, err := json.Marshal(s)
+	return string(data), err
+}
+
+// formatSeconds formats a duration as seconds with millisecond precision, e.g. "750.5"
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(jsonSeconds(d), 'f', -1, 64)
+}
@@ -0,0 +1,85 @@
+package exporter
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+func init() {
+	register(Format{
+		Name:        "matroska",
+		Extension:   ".xml",
+		Description: "Matroska chapter XML (for mkvmerge / mkvpropedit)",
+		Write:       writeMatroska,
+	})
+}
+
+// matroskaChapters is the root element of a Matroska chapter XML file
+type matroskaChapters struct {
+	XMLName xml.Name             `xml:"Chapters"`
+	Edition matroskaEditionEntry `xml:"EditionEntry"`
+}
+
+// matroskaEditionEntry groups the chapter atoms of one edition
+type matroskaEditionEntry struct {
+	Atoms []matroskaChapterAtom `xml:"ChapterAtom"`
+}
+
+// matroskaChapterAtom is a single chapter
+type matroskaChapterAtom struct {
+	TimeStart string                 `xml:"ChapterTimeStart"`
+	TimeEnd   string                 `xml:"ChapterTimeEnd,omitempty"`
+	Display   matroskaChapterDisplay `xml:"ChapterDisplay"`
+}
+
+// matroskaChapterDisplay holds the title of a chapter
+type matroskaChapterDisplay struct {
+	String   string `xml:"ChapterString"`
+	Language string `xml:"ChapterLanguage"`
+}
+
+// writeMatroska writes markers as Matroska chapter XML
+func writeMatroska(w io.Writer, markers []csvparser.MarkerEntry, options Options) error {
+	language := options.Language
+	if language == "" {
+		language = "und"
+	}
+
+	// Build chapter atoms
+	doc := matroskaChapters{}
+	for i, marker := range markers {
+		atom := matroskaChapterAtom{
+			TimeStart: formatMatroskaTime(marker.StartTime),
+			Display: matroskaChapterDisplay{
+				String:   marker.Name,
+				Language: language,
+			},
+		}
+		if end := chapterEnd(markers, i, options.Duration); end > marker.StartTime {
+			atom.TimeEnd = formatMatroskaTime(end)
+		}
+		doc.Edition.Atoms = append(doc.Edition.Atoms, atom)
+	}
+
+	// Write XML
+	if _, err := io.WriteString(w, xml.Header+"<!DOCTYPE Chapters SYSTEM \"matroskachapters.dtd\">\n"); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("Failed to encode Matroska chapters: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// formatMatroskaTime formats a duration as HH:MM:SS.nnnnnnnnn
+func formatMatroskaTime(d time.Duration) string {
+	ns := d.Nanoseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%09d", ns/int64(time.Hour), ns/int64(time.Minute)%60, ns/int64(time.Second)%60, ns%int64(time.Second))
+}
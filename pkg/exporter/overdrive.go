@@ -0,0 +1,54 @@
+package exporter
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+func init() {
+	register(Format{
+		Name:        "overdrive",
+		Extension:   ".xml",
+		Description: "OverDrive MediaMarkers XML (for library audiobook platforms)",
+		Write:       writeOverDrive,
+	})
+}
+
+// overDriveMarkers is the root element of OverDrive MediaMarkers XML
+type overDriveMarkers struct {
+	XMLName xml.Name          `xml:"Markers"`
+	Markers []overDriveMarker `xml:"Marker"`
+}
+
+// overDriveMarker is a single marker
+type overDriveMarker struct {
+	Name string `xml:"Name"`
+	Time string `xml:"Time"`
+}
+
+// writeOverDrive writes markers as OverDrive MediaMarkers XML on a single line, as stored in the TXXX frame
+func writeOverDrive(w io.Writer, markers []csvparser.MarkerEntry, options Options) error {
+	doc := overDriveMarkers{Markers: make([]overDriveMarker, 0, len(markers))}
+	for _, marker := range markers {
+		doc.Markers = append(doc.Markers, overDriveMarker{Name: marker.Name, Time: formatOverDriveTime(marker.StartTime)})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	if err := xml.NewEncoder(w).Encode(doc); err != nil {
+		return fmt.Errorf("Failed to encode OverDrive MediaMarkers: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// formatOverDriveTime formats a duration as OverDrive marker time M:SS.mmm, with minutes beyond 59 instead of hours
+func formatOverDriveTime(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%d:%02d.%03d", ms/60000, ms/1000%60, ms%1000)
+}
@@ -0,0 +1,30 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"text/template"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+func init() {
+	register(Format{
+		Name:        "template",
+		Extension:   ".txt",
+		Description: "Any text format, rendered by a user-supplied Go text/template over the chapter list",
+		Write:       writeTemplate,
+	})
+}
+
+// writeTemplate renders the template of options over the chapter list, with the same data and functions as the markdown and html formats
+func writeTemplate(w io.Writer, markers []csvparser.MarkerEntry, options Options) error {
+	if options.Template == "" {
+		return fmt.Errorf("The template format needs a template file")
+	}
+	tmpl, err := template.New("template").Funcs(ListingFuncs).Parse(options.Template)
+	if err != nil {
+		return fmt.Errorf("Invalid template: %w", err)
+	}
+	return executeListing(w, tmpl, markers, options)
+}
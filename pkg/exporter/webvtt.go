@@ -0,0 +1,48 @@
+package exporter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+func init() {
+	register(Format{
+		Name:        "webvtt",
+		Extension:   ".vtt",
+		Description: "WebVTT chapter track (for HTML5 <track kind=\"chapters\"> and web players)",
+		Write:       writeWebVTT,
+	})
+}
+
+// webVTTEscaper escapes the characters that would otherwise be read as cue markup
+var webVTTEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// writeWebVTT writes markers as a WebVTT chapter track with one cue per chapter
+func writeWebVTT(w io.Writer, markers []csvparser.MarkerEntry, options Options) error {
+	writer := bufio.NewWriter(w)
+	fmt.Fprint(writer, "WEBVTT\n")
+	for i, marker := range markers {
+		// Cues need an end time; the last chapter ends at its start if the audio length is unknown
+		end := chapterEnd(markers, i, options.Duration)
+		if end < marker.StartTime {
+			end = marker.StartTime
+		}
+		fmt.Fprintf(writer, "\n%d\n%s --> %s\n%s\n", i+1, formatWebVTTTime(marker.StartTime), formatWebVTTTime(end), webVTTEscaper.Replace(marker.Name))
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("Failed to write WebVTT chapters: %w", err)
+	}
+	return nil
+}
+
+// formatWebVTTTime formats a duration as HH:MM:SS.mmm
+func formatWebVTTTime(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
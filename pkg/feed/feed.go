@@ -0,0 +1,178 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+// Namespaces used for chapter elements
+const (
+	PSCNamespace     = "http://podlove.org/simple-chapters"
+	PodcastNamespace = "https://podcastindex.org/namespace/1.0"
+)
+
+// Options controls how chapters are written into the feed
+type Options struct {
+	Match        string // GUID or enclosure file name identifying the item
+	ChaptersURL  string // If set, a podcast:chapters link is written as well
+	ChaptersType string // MIME type of the podcast:chapters link
+	SkipPSC      bool   // Do not write inline psc:chapters
+}
+
+var (
+	itemPattern      = regexp.MustCompile(`(?s)<item[\s>].*?</item>`)
+	guidPattern      = regexp.MustCompile(`(?s)<guid[^>]*>(.*?)</guid>`)
+	enclosurePattern = regexp.MustCompile(`<enclosure[^>]*\surl\s*=\s*["']([^"']*)["']`)
+	pscPattern       = regexp.MustCompile(`(?s)\s*<psc:chapters[\s>].*?</psc:chapters>`)
+	podcastPattern   = regexp.MustCompile(`(?s)\s*<podcast:chapters[^>]*/>`)
+	rssPattern       = regexp.MustCompile(`<rss[\s>]`)
+	indentPattern    = regexp.MustCompile(`\n([ \t]*)</item>$`)
+)
+
+// InjectChapters writes chapters into the feed item matching options.Match and returns the patched feed
+func InjectChapters(data []byte, markers []csvparser.MarkerEntry, options Options) ([]byte, error) {
+	if options.Match == "" {
+		return nil, fmt.Errorf("No item to match was given")
+	}
+	if options.ChaptersType == "" {
+		options.ChaptersType = "application/json+chapters"
+	}
+
+	// Find the matching item
+	loc := findItem(data, options.Match)
+	if loc == nil {
+		return nil, fmt.Errorf("No item matching '%s' found in feed", options.Match)
+	}
+	item := data[loc[0]:loc[1]]
+
+	// Remove existing chapter elements
+	item = pscPattern.ReplaceAll(item, nil)
+	item = podcastPattern.ReplaceAll(item, nil)
+
+	// Build new chapter elements using the item's indentation
+	childIndent, closeIndent := itemIndentation(item)
+	var block strings.Builder
+	if !options.SkipPSC {
+		block.WriteString(buildPSC(markers, childIndent))
+	}
+	if options.ChaptersURL != "" {
+		fmt.Fprintf(&block, "\n%s<podcast:chapters url=\"%s\" type=\"%s\"/>", childIndent, escape(options.ChaptersURL), escape(options.ChaptersType))
+	}
+
+	// Insert before the closing tag
+	closing := bytes.LastIndex(item, []byte("</item>"))
+	body := bytes.TrimRight(item[:closing], " \t\r\n")
+	var patchedItem bytes.Buffer
+	patchedItem.Write(body)
+	patchedItem.WriteString(block.String())
+	patchedItem.WriteString("\n" + closeIndent + "</item>")
+
+	// Assemble the feed
+	var out bytes.Buffer
+	out.Write(data[:loc[0]])
+	out.Write(patchedItem.Bytes())
+	out.Write(data[loc[1]:])
+
+	// Declare namespaces used by the inserted elements
+	result := out.Bytes()
+	if !options.SkipPSC {
+		result = ensureNamespace(result, "psc", PSCNamespace)
+	}
+	if options.ChaptersURL != "" {
+		result = ensureNamespace(result, "podcast", PodcastNamespace)
+	}
+
+	return result, nil
+}
+
+// findItem returns the byte range of the first item whose GUID or enclosure file name matches
+func findItem(data []byte, match string) []int {
+	for _, loc := range itemPattern.FindAllIndex(data, -1) {
+		item := data[loc[0]:loc[1]]
+
+		// Compare GUID
+		if m := guidPattern.FindSubmatch(item); m != nil && strings.TrimSpace(unescape(string(m[1]))) == match {
+			return loc
+		}
+
+		// Compare enclosure URL and its file name
+		if m := enclosurePattern.FindSubmatch(item); m != nil {
+			url := unescape(string(m[1]))
+			if url == match {
+				return loc
+			}
+			if i := strings.IndexAny(url, "?#"); i >= 0 {
+				url = url[:i]
+			}
+			if path.Base(url) == match {
+				return loc
+			}
+		}
+	}
+	return nil
+}
+
+// buildPSC renders markers as a Podlove Simple Chapters block
+func buildPSC(markers []csvparser.MarkerEntry, indent string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n%s<psc:chapters version=\"1.2\">", indent)
+	for _, marker := range markers {
+		fmt.Fprintf(&b, "\n%s  <psc:chapter start=\"%s\" title=\"%s\"/>", indent, formatNormalPlayTime(marker.StartTime), escape(marker.Name))
+	}
+	fmt.Fprintf(&b, "\n%s</psc:chapters>", indent)
+	return b.String()
+}
+
+// itemIndentation guesses the indentation of the item's children and closing tag
+func itemIndentation(item []byte) (string, string) {
+	closeIndent := ""
+	if m := indentPattern.FindSubmatch(item); m != nil {
+		closeIndent = string(m[1])
+	}
+	return closeIndent + "  ", closeIndent
+}
+
+// ensureNamespace adds an xmlns declaration to the rss element if it is missing
+func ensureNamespace(data []byte, prefix string, uri string) []byte {
+	if bytes.Contains(data, []byte("xmlns:"+prefix+"=")) {
+		return data
+	}
+
+	loc := rssPattern.FindIndex(data)
+	if loc == nil {
+		return data
+	}
+
+	insertAt := loc[0] + len("<rss")
+	declaration := fmt.Sprintf(" xmlns:%s=\"%s\"", prefix, uri)
+	return append(data[:insertAt:insertAt], append([]byte(declaration), data[insertAt:]...)...)
+}
+
+// formatNormalPlayTime formats a duration as HH:MM:SS.mmm
+func formatNormalPlayTime(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// escape escapes text for use in an XML attribute
+func escape(text string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(text))
+	return b.String()
+}
+
+// unescape resolves XML entities in text
+func unescape(text string) string {
+	var s string
+	if err := xml.Unmarshal([]byte("<x>"+text+"</x>"), &s); err != nil {
+		return text
+	}
+	return s
+}
@@ -0,0 +1,160 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/exporter"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/lockfile"
+)
+
+// Tool is an ffmpeg installation used for containers without a native chapter writer, such as M4A and WAV
+type Tool struct {
+	FFmpeg  string // Path of the ffmpeg binary
+	FFprobe string // Path of the ffprobe binary
+	Version string // First line of "ffmpeg -version"
+}
+
+// Chapter is a chapter read by ffprobe
+type Chapter struct {
+	Title     string
+	StartTime time.Duration
+}
+
+// Detect checks that the ffmpeg binary at path (or found in PATH by name) runs, and finds ffprobe next to it or in PATH
+func Detect(path string) (*Tool, error) {
+	ffmpegPath, err := exec.LookPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg not found at '%s': %w", path, err)
+	}
+	out, err := exec.Command(ffmpegPath, "-hide_banner", "-version").Output()
+	if err != nil {
+		return nil, fmt.Errorf("'%s' does not run as ffmpeg: %w", ffmpegPath, err)
+	}
+	version, _, _ := strings.Cut(string(out), "\n")
+	if !strings.HasPrefix(version, "ffmpeg version") {
+		return nil, fmt.Errorf("'%s' is not ffmpeg", ffmpegPath)
+	}
+
+	// ffprobe is usually installed next to ffmpeg
+	ffprobePath, err := exec.LookPath(filepath.Join(filepath.Dir(ffmpegPath), "ffprobe"+filepath.Ext(ffmpegPath)))
+	if err != nil {
+		if ffprobePath, err = exec.LookPath("ffprobe"); err != nil {
+			return nil, fmt.Errorf("ffprobe not found next to '%s' or in PATH", ffmpegPath)
+		}
+	}
+	return &Tool{FFmpeg: ffmpegPath, FFprobe: ffprobePath, Version: strings.TrimSpace(version)}, nil
+}
+
+// AddChapters copies the streams and tags of inputPath to outputPath without re-encoding and replaces its chapters with markers
+func (tool *Tool) AddChapters(inputPath string, markers []csvparser.MarkerEntry, outputPath string) error {
+	duration, err := tool.Duration(inputPath)
+	if err != nil {
+		return err
+	}
+
+	// Write the chapters to a temporary FFMETADATA file
+	format, err := exporter.Lookup("ffmetadata")
+	if err != nil {
+		return err
+	}
+	metadata, err := os.CreateTemp("", "chapters-*.txt")
+	if err != nil {
+		return fmt.Errorf("Cannot create FFmpeg metadata file: %w", err)
+	}
+	defer os.Remove(metadata.Name())
+	if err := format.Write(metadata, markers, exporter.Options{Duration: duration}); err != nil {
+		metadata.Close()
+		return err
+	}
+	if err := metadata.Close(); err != nil {
+		return fmt.Errorf("Cannot write FFmpeg metadata file: %w", err)
+	}
+
+	// Keep other processes from writing the same output at the same time
+	lock, err := lockfile.Acquire(outputPath)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	cmd := exec.Command(tool.FFmpeg, "-hide_banner", "-loglevel", "error", "-y",
+		"-i", inputPath, "-f", "ffmetadata", "-i", metadata.Name(),
+		"-map", "0", "-map_metadata", "0", "-map_chapters", "1", "-codec", "copy", outputPath)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// ffprobeOutput is the part of "ffprobe -of json" output that is read
+type ffprobeOutput struct {
+	Chapters []struct {
+		StartTime string            `json:"start_time"`
+		Tags      map[string]string `json:"tags"`
+	} `json:"chapters"`
+	Format struct {
+		Duration string `json:"duration"`
+	} `json:"format"`
+}
+
+// ReadChapters reads the chapters of a file with ffprobe
+func (tool *Tool) ReadChapters(path string) ([]Chapter, error) {
+	output, err := tool.probe(path, "-show_chapters")
+	if err != nil {
+		return nil, err
+	}
+	chapters := make([]Chapter, 0, len(output.Chapters))
+	for _, chapter := range output.Chapters {
+		start, err := parseSeconds(chapter.StartTime)
+		if err != nil {
+			return nil, err
+		}
+		chapters = append(chapters, Chapter{Title: chapter.Tags["title"], StartTime: start})
+	}
+	return chapters, nil
+}
+
+// Duration returns the length of a file as reported by ffprobe (0 if unknown)
+func (tool *Tool) Duration(path string) (time.Duration, error) {
+	output, err := tool.probe(path, "-show_format")
+	if err != nil || output.Format.Duration == "" {
+		return 0, err
+	}
+	return parseSeconds(output.Format.Duration)
+}
+
+// probe runs ffprobe with a show option and decodes its JSON output
+func (tool *Tool) probe(path string, show string) (ffprobeOutput, error) {
+	var output ffprobeOutput
+	cmd := exec.Command(tool.FFprobe, "-v", "error", show, "-of", "json", path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return output, fmt.Errorf("ffprobe failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if err := json.Unmarshal(out, &output); err != nil {
+		return output, fmt.Errorf("Invalid ffprobe output: %w", err)
+	}
+	return output, nil
+}
+
+// parseSeconds converts ffprobe's decimal seconds to a duration rounded to milliseconds
+func parseSeconds(text string) (time.Duration, error) {
+	seconds, err := strconv.ParseFloat(text, 64)
+	if err != nil || seconds < 0 {
+		return 0, fmt.Errorf("Invalid time in ffprobe output: '%s'", text)
+	}
+	return time.Duration(seconds * float64(time.Second)).Round(time.Millisecond), nil
+}
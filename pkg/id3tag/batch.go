@@ -0,0 +1,45 @@
+package id3tag
+
+import (
+	"runtime"
+	"sync"
+)
+
+// BatchResult is the outcome of reading the chapters of one file in a batch
+type BatchResult struct {
+	Source   string    // Path or URL that was read
+	Chapters []Chapter // Chapters sorted by start time
+	Err      error     // Error reading the source, if any
+}
+
+// ReadChaptersBatch reads the chapters of many sources concurrently and returns the results in the order of sources.
+// read reads a single source and defaults to ReadChapters; concurrency defaults to the number of CPUs.
+func ReadChaptersBatch(sources []string, concurrency int, read func(source string) ([]Chapter, error)) []BatchResult {
+	if read == nil {
+		read = ReadChapters
+	}
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+
+	results := make([]BatchResult, len(sources))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range min(concurrency, len(sources)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				chapters, err := read(sources[i])
+				results[i] = BatchResult{Source: sources[i], Chapters: chapters, Err: err}
+			}
+		}()
+	}
+	for i := range sources {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
@@ -1,11 +1,31 @@
 package id3tag
 
 import (
+	"fmt"
 	"io"
+	"strings"
+	"time"
 
 	"github.com/bogem/id3v2/v2"
 )
 
+// Defaults of the table of contents written along with the chapters
+const (
+	DefaultTOCID    = "toc"               // Element ID of the CTOC frame
+	DefaultTOCTitle = "Table of Contents" // Title of the CTOC frame
+)
+
+// MaxTOCEntries is the largest number of child elements of a CTOC frame, whose entry count is a single byte
+const MaxTOCEntries = 255
+
+// checkTOCEntries returns an error if the table of contents lists more child elements than a CTOC frame can hold
+func checkTOCEntries(title string, childIDs []string) error {
+	if len(childIDs) > MaxTOCEntries {
+		return fmt.Errorf("Table of contents '%s' would list %d entries, but a CTOC frame holds at most %d; group the chapters into parts", title, len(childIDs), MaxTOCEntries)
+	}
+	return nil
+}
+
 // CTOCFrame implements the ID3v2 Table Of Contents frame (CTOC)
 // As defined in ID3v2 Chapter Frame Addendum (id3v2-chapters-1.0)
 type CTOCFrame struct {
@@ -37,15 +57,15 @@ func (cf CTOCFrame) Size() int {
 	// Add size of optional Title subframe if present
 	if cf.Title != nil {
 		// Frame ID (4 bytes) + Size (4 bytes) + Flags (2 bytes) + Frame content
-		size += 10 + cf.Title.Size()
+		size += 10 + len(encodeTextFrame(cf.Title))
 	}
 
 	return size
 }
 
-// UniqueIdentifier returns "CTOC"
+// UniqueIdentifier returns the element ID, so a tag can hold several CTOC frames
 func (cf CTOCFrame) UniqueIdentifier() string {
-	return "CTOC"
+	return cf.ElementID
 }
 
 // WriteTo writes the frame to a writer
@@ -101,7 +121,8 @@ func (cf CTOCFrame) WriteTo(w io.Writer) (int64, error) {
 		}
 
 		// Write frame size (4 bytes)
-		size := uint32(cf.Title.Size())
+		title := encodeTextFrame(cf.Title)
+		size := uint32(len(title))
 		written, err = w.Write([]byte{
 			byte(size >> 24),
 			byte(size >> 16),
@@ -121,8 +142,8 @@ func (cf CTOCFrame) WriteTo(w io.Writer) (int64, error) {
 		}
 
 		// Write frame content
-		writtenInt64, err := cf.Title.WriteTo(w)
-		n += writtenInt64
+		written, err = w.Write(title)
+		n += int64(written)
 		if err != nil {
 			return n, err
 		}
@@ -150,3 +171,65 @@ func createCTOCFrame(elementID string, isTopLevel, isOrdered bool, childIDs []st
 
 	return ctocFrame
 }
+
+// tocEntry is a chapter listed in the table of contents
+type tocEntry struct {
+	ElementID string        // Element ID of the CHAP frame
+	StartTime time.Duration // Start time of the chapter
+	Part      string        // Part of a multi-part program the chapter belongs to (empty for none)
+}
+
+// partTOCs groups consecutive chapters of the same part into CTOC frames with the part as title.
+// It returns the child IDs of the top-level table of contents, with chapters outside any part listed directly, and the frames of the parts.
+func partTOCs(entries []tocEntry, ids *elementIDs, isOrdered bool, encoding id3v2.Encoding) ([]string, []CTOCFrame, error) {
+	var childIDs []string
+	var frames []CTOCFrame
+	for i, entry := range entries {
+		switch {
+		case entry.Part == "":
+			childIDs = append(childIDs, entry.ElementID)
+		case i > 0 && entry.Part == entries[i-1].Part:
+			frames[len(frames)-1].ChildIDs = append(frames[len(frames)-1].ChildIDs, entry.ElementID)
+		default:
+			id, err := ids.claim(fmt.Sprintf("part%d", len(frames)))
+			if err != nil {
+				return nil, nil, fmt.Errorf("Cannot add part '%s': %w", entry.Part, err)
+			}
+			frame := createCTOCFrame(id, false, isOrdered, []string{entry.ElementID}, entry.Part)
+			frame.Title.Encoding = encoding
+			frames = append(frames, frame)
+			childIDs = append(childIDs, frame.ElementID)
+		}
+	}
+	return childIDs, frames, nil
+}
+
+// tocID returns the element ID of the CTOC frame from options
+func (options Options) tocID() string {
+	if options.TOCID == "" {
+		return DefaultTOCID
+	}
+	return options.TOCID
+}
+
+// tocTitle returns the title of the CTOC frame from options, or an empty string for none
+func (options Options) tocTitle() string {
+	switch {
+	case options.OmitTOCTitle:
+		return ""
+	case options.TOCTitle == "":
+		return DefaultTOCTitle
+	}
+	return options.TOCTitle
+}
+
+// ValidateElementID checks that id can be written as the element ID of a CHAP or CTOC frame
+func ValidateElementID(id string) error {
+	if id == "" {
+		return fmt.Errorf("Element ID must not be empty")
+	}
+	if strings.IndexFunc(id, func(r rune) bool { return r <= ' ' || r > '~' }) >= 0 {
+		return fmt.Errorf("Element ID '%s' must consist of printable ASCII characters without spaces", id)
+	}
+	return nil
+}
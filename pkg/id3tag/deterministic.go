@@ -0,0 +1,27 @@
+package id3tag
+
+import (
+	"slices"
+	"sort"
+)
+
+// DeterministicPadding is the number of padding bytes after the frames of a deterministic tag without explicit padding
+const DeterministicPadding = 1024
+
+// noiseFrameIDs are frames that differ between runs over the same input: private data and encoding/tagging times
+var noiseFrameIDs = []string{"PRIV", "TDEN", "TDTG"}
+
+// deterministicFrames returns the frames of tag without noise frames, sorted by frame ID.
+// Frames with the same ID, such as chapters, keep their order.
+func deterministicFrames(tag *RawTag) []RawFrame {
+	var frames []RawFrame
+	for _, frame := range tag.Frames {
+		if !slices.Contains(noiseFrameIDs, frame.ID) {
+			frames = append(frames, frame)
+		}
+	}
+	sort.SliceStable(frames, func(i, j int) bool {
+		return frames[i].ID < frames[j].ID
+	})
+	return frames
+}
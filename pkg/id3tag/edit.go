@@ -0,0 +1,213 @@
+package id3tag
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/artwork"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/lockfile"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/prompt"
+	"github.com/bogem/id3v2/v2"
+)
+
+// ChapterEdit holds the new values for a single chapter; nil fields are left unchanged
+type ChapterEdit struct {
+	Title *string        // New chapter title
+	Start *time.Duration // New start time
+}
+
+// chapterFrame is a parsed CHAP frame with its position in the frames of the tag
+type chapterFrame struct {
+	RawChapter
+	index int // Index of the frame in RawTag.Frames
+}
+
+// EditChapter rewrites a single CHAP frame of an MP3 file and writes the result to outputPath.
+// The chapter is selected by its element ID or by its 1-based number in start time order, as listed by ReadChapters.
+// All other frames are kept, except that the end time of the previous chapter follows a changed start
+// time if it ended where the edited chapter started.
+// It returns a description of each change.
+func EditChapter(mp3Path, outputPath, selector string, edit ChapterEdit, prompter *prompt.Prompter) ([]string, error) {
+	tag, chapters, position, err := readChapterFrame(mp3Path, selector)
+	if err != nil {
+		return nil, err
+	}
+	chapter := chapters[position]
+	frames := append([]RawFrame(nil), tag.Frames...)
+	var changes []string
+
+	// Replace or add the title
+	if edit.Title != nil && *edit.Title != chapter.Title() {
+		changes = append(changes, fmt.Sprintf("Changed title of chapter '%s' from %q to %q", chapter.ElementID, chapter.Title(), *edit.Title))
+		encoding := id3v2.EncodingUTF8
+		if tag.Version == 3 {
+			encoding = id3v2.EncodingUTF16
+		}
+		chapter.setSubframe(RawFrame{ID: "TIT2", Body: encodeTextFrame(&id3v2.TextFrame{Encoding: encoding, Text: *edit.Title})})
+	}
+
+	// Move the start time, keeping the chapter between its neighbors
+	if edit.Start != nil {
+		start := uint32(edit.Start.Milliseconds())
+		if position > 0 && start <= chapters[position-1].StartTime {
+			return nil, fmt.Errorf("New start time %s is not after the start of the previous chapter", formatMillis(start))
+		}
+		if position+1 < len(chapters) && start >= chapters[position+1].StartTime {
+			return nil, fmt.Errorf("New start time %s is not before the start of the next chapter", formatMillis(start))
+		}
+		if chapter.EndTime > chapter.StartTime && start >= chapter.EndTime {
+			return nil, fmt.Errorf("New start time %s is not before the end of the chapter at %s", formatMillis(start), formatMillis(chapter.EndTime))
+		}
+
+		if start != chapter.StartTime {
+			changes = append(changes, fmt.Sprintf("Changed start time of chapter '%s' from %s to %s", chapter.ElementID, formatMillis(chapter.StartTime), formatMillis(start)))
+			if position > 0 && chapters[position-1].EndTime == chapter.StartTime {
+				previous := chapters[position-1]
+				previous.EndTime = start
+				frames[previous.index] = RawFrame{ID: "CHAP", Body: previous.body(tag.Version)}
+				changes = append(changes, fmt.Sprintf("Changed end time of chapter '%s' to %s", previous.ElementID, formatMillis(start)))
+			}
+			chapter.StartTime = start
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	frames[chapter.index] = RawFrame{ID: "CHAP", Body: chapter.body(tag.Version)}
+	return changes, writeEditedTag(mp3Path, outputPath, tag, frames, prompter)
+}
+
+// readChapterFrame reads the tag of an MP3 file and returns its chapters in start time order with the position of the selected one
+func readChapterFrame(mp3Path, selector string) (*RawTag, []chapterFrame, int, error) {
+	tag, err := ReadRawTag(mp3Path)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if tag == nil {
+		return nil, nil, 0, fmt.Errorf("File has no ID3v2 tag")
+	}
+
+	chapters := tag.chapterFrames()
+	position := findChapter(chapters, selector)
+	if position < 0 {
+		return nil, nil, 0, fmt.Errorf("No chapter '%s' found (the file has %d chapters)", selector, len(chapters))
+	}
+	return tag, chapters, position, nil
+}
+
+// chapterFrames returns the parseable CHAP frames of the tag in start time order
+func (tag *RawTag) chapterFrames() []chapterFrame {
+	var chapters []chapterFrame
+	for i, frame := range tag.Frames {
+		if frame.ID != "CHAP" {
+			continue
+		}
+		if chapter, ok := parseRawChapter(frame.Body, tag.Version); ok {
+			chapters = append(chapters, chapterFrame{RawChapter: chapter, index: i})
+		}
+	}
+	sort.SliceStable(chapters, func(i, j int) bool { return chapters[i].StartTime < chapters[j].StartTime })
+	return chapters
+}
+
+// findChapter returns the position of the chapter selected by its 1-based number or element ID, or -1 if there is none
+func findChapter(chapters []chapterFrame, selector string) int {
+	if n, err := strconv.Atoi(selector); err == nil && n >= 1 && n <= len(chapters) {
+		return n - 1
+	}
+	for i, chapter := range chapters {
+		if chapter.ElementID == selector {
+			return i
+		}
+	}
+	return -1
+}
+
+// setSubframe replaces the first subframe with the same ID, or adds the subframe after the title if there is none
+func (chapter *RawChapter) setSubframe(subframe RawFrame) {
+	for i, existing := range chapter.Subframes {
+		if existing.ID == subframe.ID {
+			chapter.Subframes[i] = subframe
+			return
+		}
+	}
+	if subframe.ID == "TIT2" {
+		chapter.Subframes = append([]RawFrame{subframe}, chapter.Subframes...)
+		return
+	}
+	chapter.Subframes = append(chapter.Subframes, subframe)
+}
+
+// writeEditedTag writes the edited frames, confirming first if the original file is modified
+func writeEditedTag(mp3Path, outputPath string, tag *RawTag, frames []RawFrame, prompter *prompt.Prompter) error {
+	if err := tag.checkRewritable(); err != nil {
+		return err
+	}
+	lock, err := lockfile.Acquire(outputPath)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	if mp3Path == outputPath {
+		if err := prompter.Confirm(fmt.Sprintf("This will modify the original file '%s'. Continue? (y/n): ", mp3Path)); err != nil {
+			return err
+		}
+	}
+	return writeRawTag(mp3Path, outputPath, tag, frames, 0)
+}
+
+// SetChapterImage replaces or adds the artwork (APIC subframe) of a single chapter and writes the result to outputPath.
+// The chapter is selected as in EditChapter; all other frames are kept.
+func SetChapterImage(mp3Path, outputPath, selector string, image *artwork.Image, prompter *prompt.Prompter) ([]string, error) {
+	var buf bytes.Buffer
+	picture := id3v2.PictureFrame{
+		Encoding:    id3v2.EncodingISO,
+		MimeType:    image.MimeType,
+		PictureType: id3v2.PTOther,
+		Description: "chapter image",
+		Picture:     image.Data,
+	}
+	if _, err := picture.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return setChapterSubframe(mp3Path, outputPath, selector, RawFrame{ID: "APIC", Body: buf.Bytes()},
+		fmt.Sprintf("%s image, %d bytes", image.MimeType, len(image.Data)), prompter)
+}
+
+// SetChapterURL replaces or adds the link (WXXX subframe) of a single chapter and writes the result to outputPath.
+// The chapter is selected as in EditChapter; all other frames are kept.
+func SetChapterURL(mp3Path, outputPath, selector, url string, prompter *prompt.Prompter) ([]string, error) {
+	// Latin-1 encoding with an empty description, followed by the URL
+	body := append([]byte{0, 0}, url...)
+	return setChapterSubframe(mp3Path, outputPath, selector, RawFrame{ID: "WXXX", Body: body}, url, prompter)
+}
+
+// setChapterSubframe replaces or adds a subframe of a single chapter
+func setChapterSubframe(mp3Path, outputPath, selector string, subframe RawFrame, description string, prompter *prompt.Prompter) ([]string, error) {
+	tag, chapters, position, err := readChapterFrame(mp3Path, selector)
+	if err != nil {
+		return nil, err
+	}
+	chapter := chapters[position]
+
+	change := fmt.Sprintf("Added %s to chapter '%s': %s", subframe.ID, chapter.ElementID, description)
+	for _, existing := range chapter.Subframes {
+		if existing.ID == subframe.ID {
+			if bytes.Equal(existing.Body, subframe.Body) {
+				return nil, nil
+			}
+			change = fmt.Sprintf("Replaced %s of chapter '%s': %s", subframe.ID, chapter.ElementID, description)
+			break
+		}
+	}
+	chapter.setSubframe(subframe)
+
+	frames := append([]RawFrame(nil), tag.Frames...)
+	frames[chapter.index] = RawFrame{ID: "CHAP", Body: chapter.body(tag.Version)}
+	return []string{change}, writeEditedTag(mp3Path, outputPath, tag, frames, prompter)
+}
@@ -1,32 +1,101 @@
 package id3tag
 
 import (
-	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/artwork"
 	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/lockfile"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/mp3frame"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/prompt"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/safefile"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/tracing"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/transcript"
 	"github.com/bogem/id3v2/v2"
 )
 
+// Options holds optional content written along with the chapters
+type Options struct {
+	Transcript         []transcript.Cue // Transcript stored as an unsynchronized lyrics (USLT) frame; nil leaves lyrics untouched
+	SyncTranscript     bool             // Whether to store the transcript as a synchronized lyrics (SYLT) frame as well
+	TranscriptLanguage string           // ISO 639-2 language code of the transcript (default "und")
+	ChapterImages      []*artwork.Image // Artwork per marker in marker order; nil entries have no artwork
+	MediaMarkers       string           // OverDrive MediaMarkers XML stored in a TXXX frame along with the chapters (empty leaves it untouched)
+
+	Version        byte   // ID3v2 major version to write (3 or 4); 0 keeps the version of an existing tag
+	Encoding       string // Text encoding of titles: "utf8", "utf16" or "latin1" (default "utf8")
+	EndTimes       bool   // Whether to write end times (the next chapter's start, or the audio end for the last chapter)
+	OmitTOC        bool   // Whether to leave out the table of contents (CTOC) frame
+	TOCNotTopLevel bool   // Whether to clear the top-level flag of the CTOC frame
+	TOCUnordered   bool   // Whether to clear the ordered flag of the CTOC frame
+	TOCID          string // Element ID of the CTOC frame (default DefaultTOCID)
+	TOCTitle       string // Title of the CTOC frame (default DefaultTOCTitle)
+	OmitTOCTitle   bool   // Whether to write the CTOC frame without a title (TIT2) subframe
+
+	MergeChapters      bool     // Whether to keep the existing chapters and list them in the new table of contents along with the markers
+	ReplacedChapters   []string // Element IDs of existing chapters left out when merging, e.g. because a marker replaces them
+	ChapterIDPrefix    string   // Prefix of chapter element IDs, followed by the marker index (default DefaultChapterIDPrefix)
+	RenameDuplicateIDs bool     // Whether to make element IDs used by existing frames unique, e.g. "chp0-2", instead of failing
+
+	Deterministic bool // Whether to sort frames, use fixed padding and drop PRIV and timestamp frames for byte-identical output
+	Padding       int  // Zero bytes left after the frames for later in-place edits (0 for none, or DeterministicPadding if Deterministic)
+
+	TrailingTags string // Handling of trailing ID3v1 and APE tags: TrailingKeep (default), TrailingStrip or TrailingUpgrade
+	RebuildTag   bool   // Whether to replace a malformed existing tag instead of failing with ErrCorruptTag
+
+	Overwrite bool             // Whether to overwrite an existing output file or modify the input in place without asking
+	Prompt    *prompt.Prompter // Where to ask otherwise (nil for stdin and stdout); servers disable it to fail instead
+
+	Trace   *tracing.Span    // Span under which copying the audio and saving the tag are traced (nil for no tracing)
+	Written *WrittenChapters // Filled with the chapters and table of contents as encoded, for VerifyWritten (nil if not needed)
+}
+
+// ParseEncoding returns the ID3v2 text encoding with the given name
+func ParseEncoding(name string) (id3v2.Encoding, error) {
+	switch strings.ToLower(name) {
+	case "", "utf8", "utf-8":
+		return id3v2.EncodingUTF8, nil
+	case "utf16", "utf-16":
+		return id3v2.EncodingUTF16, nil
+	case "latin1", "iso-8859-1":
+		return id3v2.EncodingISO, nil
+	}
+	return id3v2.Encoding{}, fmt.Errorf("Unknown text encoding '%s' (available: utf8, utf16, latin1)", name)
+}
+
 // AddChapters adds chapter tags to an MP3 file
 func AddChapters(mp3Path string, markers []csvparser.MarkerEntry, outputPath string) error {
+	return AddChaptersWithOptions(mp3Path, markers, outputPath, Options{})
+}
+
+// AddChaptersWithOptions adds chapter tags and the optional content in options to an MP3 file
+func AddChaptersWithOptions(mp3Path string, markers []csvparser.MarkerEntry, outputPath string, options Options) error {
 	// If output path is not specified, create a new filename with "_with_chapters" suffix
 	if outputPath == "" {
 		outputPath = generateOutputPath(mp3Path)
 	}
 
+	// Keep other processes from writing the same output at the same time
+	lock, err := lockfile.Acquire(outputPath)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
 	// If input and output file paths are the same
 	if mp3Path == outputPath {
 		// Modify the file directly
-		return addChaptersInPlace(mp3Path, markers)
+		return addChaptersInPlace(mp3Path, markers, options)
 	} else {
 		// Copy to a new file and add tags
-		return addChaptersToNewFile(mp3Path, markers, outputPath)
+		return addChaptersToNewFile(mp3Path, markers, outputPath, options)
 	}
 }
 
@@ -38,47 +107,69 @@ func generateOutputPath(inputPath string) string {
 }
 
 // addChaptersInPlace adds chapter tags directly to an existing MP3 file
-func addChaptersInPlace(mp3Path string, markers []csvparser.MarkerEntry) error {
+func addChaptersInPlace(mp3Path string, markers []csvparser.MarkerEntry, options Options) error {
 	// Confirm before modifying the original file
-	if err := confirmOperation(fmt.Sprintf("This will modify the original file '%s'. Continue? (y/n): ", mp3Path)); err != nil {
+	if !options.Overwrite {
+		if err := options.Prompt.Confirm(fmt.Sprintf("This will modify the original file '%s'. Continue? (y/n): ", mp3Path)); err != nil {
+			return err
+		}
+	}
+
+	// Large existing tags are streamed into a new copy of the file
+	if streamed, err := streamChapters(mp3Path, markers, mp3Path, options); streamed || err != nil {
 		return err
 	}
 
 	// Open MP3 file
-	tag, err := id3v2.Open(mp3Path, id3v2.Options{Parse: true})
+	existing, err := existingChapters(mp3Path, options)
+	if err != nil {
+		return err
+	}
+	tag, err := openTag(mp3Path, options)
 	if err != nil {
 		return fmt.Errorf("Cannot open MP3 file: %w", err)
 	}
 	defer tag.Close()
 
 	// Add chapter tags
-	if err = addChapterFrames(tag, markers); err != nil {
+	if err = addChapterFrames(tag, scanFile(mp3Path), markers, existing, options); err != nil {
+		return err
+	}
+	addOptionalFrames(tag, options)
+	if err := addTrailingFrames(tag, readTrailingFile(mp3Path), options); err != nil {
 		return err
 	}
 
 	// Save changes
-	return tag.Save()
+	span := options.Trace.Start("tag-save")
+	err = saveTagInPlace(mp3Path, tag, options, span)
+	span.RecordError(err)
+	span.End()
+	return err
 }
 
-// confirmOperation asks for user confirmation before proceeding with an operation
-func confirmOperation(prompt string) error {
-	fmt.Print(prompt)
-	reader := bufio.NewReader(os.Stdin)
-	response, err := reader.ReadString('\n')
+// saveTagInPlace saves the tag of the MP3 file, rewriting only the tag region if the new tag fits into the existing tag and its padding
+func saveTagInPlace(mp3Path string, tag *id3v2.Tag, options Options, span *tracing.Span) error {
+	saved, err := saveInPlace(mp3Path, tag, options)
 	if err != nil {
-		return fmt.Errorf("Error reading input: %w", err)
+		return err
 	}
-
-	response = strings.TrimSpace(strings.ToLower(response))
-	if response != "y" && response != "yes" {
-		return fmt.Errorf("Operation cancelled by user")
+	span.SetAttribute("in_place", saved)
+	if !saved {
+		if err := tag.Save(); err != nil {
+			return err
+		}
+		if options.rewritesTag() {
+			if err := rewriteTag(mp3Path, options); err != nil {
+				return err
+			}
+		}
 	}
-
-	return nil
+	return removeTrailingTags(mp3Path, options)
 }
 
 // addChaptersToNewFile adds chapter tags to a new MP3 file
-func addChaptersToNewFile(mp3Path string, markers []csvparser.MarkerEntry, outputPath string) error {
+func addChaptersToNewFile(mp3Path string, markers []csvparser.MarkerEntry, outputPath string, options Options) error {
 	// Ensure output directory exists
 	outputDir := filepath.Dir(outputPath)
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
@@ -86,15 +177,20 @@ func addChaptersToNewFile(mp3Path string, markers []csvparser.MarkerEntry, outpu
 	}
 
 	// If output file already exists, ask for confirmation
-	if fileExists(outputPath) {
-		if err := confirmOperation(fmt.Sprintf("File '%s' already exists. Overwrite? (y/n): ", outputPath)); err != nil {
+	if fileExists(outputPath) && !options.Overwrite {
+		if err := options.Prompt.Confirm(fmt.Sprintf("File '%s' already exists. Overwrite? (y/n): ", outputPath)); err != nil {
 			return err
 		}
 	}
 
+	// Large existing tags are streamed into the output without loading their large frames
+	if streamed, err := streamChapters(mp3Path, markers, outputPath, options); streamed || err != nil {
+		return err
+	}
+
 	// Create a temporary file for processing
-	tempPath := outputPath + ".tmp"
-	if err := copyFile(mp3Path, tempPath); err != nil {
+	tempPath, err := safefile.TempPath(outputPath)
+	if err != nil {
 		return err
 	}
 
@@ -104,34 +200,223 @@ func addChaptersToNewFile(mp3Path string, markers []csvparser.MarkerEntry, outpu
 			os.Remove(tempPath)
 		}
 	}()
+	span := options.Trace.Start("copy")
+	err = copyFile(mp3Path, tempPath)
+	span.RecordError(err)
+	span.End()
+	if err != nil {
+		return err
+	}
 
 	// Add ID3 tags to the temporary file
-	tag, err := id3v2.Open(tempPath, id3v2.Options{Parse: true})
+	existing, err := existingChapters(tempPath, options)
+	if err != nil {
+		return err
+	}
+	tag, err := openTag(tempPath, options)
 	if err != nil {
 		return fmt.Errorf("Cannot open temporary file: %w", err)
 	}
 
 	// Add chapter tags
-	if err = addChapterFrames(tag, markers); err != nil {
+	if err = addChapterFrames(tag, scanFile(tempPath), markers, existing, options); err != nil {
+		tag.Close()
+		return err
+	}
+	addOptionalFrames(tag, options)
+	if err := addTrailingFrames(tag, readTrailingFile(tempPath), options); err != nil {
 		tag.Close()
 		return err
 	}
 
 	// Save and close the tags
-	err = tag.Save()
-	tag.Close()
+	span = options.Trace.Start("tag-save")
+	err = saveTag(tempPath, tag, options)
+	span.RecordError(err)
+	span.End()
 	if err != nil {
-		return fmt.Errorf("Failed to save tags: %w", err)
+		return err
 	}
 
 	// On success, move the temporary file to the final output file
-	if err := os.Rename(tempPath, outputPath); err != nil {
+	if err := safefile.Replace(tempPath, outputPath); err != nil {
 		return fmt.Errorf("Failed to create final file: %w", err)
 	}
 
 	return nil
 }
 
+// saveTag saves and closes the tag of the temporary copy of the MP3 file
+func saveTag(tempPath string, tag *id3v2.Tag, options Options) error {
+	err := tag.Save()
+	tag.Close()
+	if err != nil {
+		return fmt.Errorf("Failed to save tags: %w", err)
+	}
+	if options.rewritesTag() {
+		if err := rewriteTag(tempPath, options); err != nil {
+			return fmt.Errorf("Failed to save tags: %w", err)
+		}
+	}
+	return removeTrailingTags(tempPath, options)
+}
+
+// AddChaptersTo reads an MP3 stream from r and writes it to w with chapter tags and the optional content in options.
+// The whole stream is held in memory, so it works without a file system, e.g. in WebAssembly.
+func AddChaptersTo(w io.Writer, r io.Reader, markers []csvparser.MarkerEntry, options Options) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("Cannot read MP3 data: %w", err)
+	}
+	tag, audio, err := buildTag(data, markers, options)
+	if err != nil {
+		return err
+	}
+
+	// Write the new tag followed by the audio data
+	encoded, err := encodeTag(tag, options)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(encoded); err != nil {
+		return fmt.Errorf("Failed to write tags: %w", err)
+	}
+	if _, err := w.Write(audio); err != nil {
+		return fmt.Errorf("Failed to write audio data: %w", err)
+	}
+	return nil
+}
+
+// TagSizePreview describes the sizes resulting from adding chapters to an MP3 file
+type TagSizePreview struct {
+	OldTagSize int64 // Size of the existing ID3v2 tag in bytes (0 if none)
+	NewTagSize int64 // Size of the ID3v2 tag that would be written in bytes
+	FileSize   int64 // Size of the resulting file in bytes
+	InPlace    bool  // Whether the new tag fits into the existing tag and its padding, so the audio data is not rewritten
+}
+
+// Delta returns the growth of the tag in bytes (negative if it shrinks)
+func (preview *TagSizePreview) Delta() int64 {
+	return preview.NewTagSize - preview.OldTagSize
+}
+
+// PreviewTagSize computes the tag AddChaptersWithOptions would write for the same arguments without writing anything
+func PreviewTagSize(mp3Path string, markers []csvparser.MarkerEntry, outputPath string, options Options) (*TagSizePreview, error) {
+	if preview, err := previewStream(mp3Path, markers, options); preview != nil || err != nil {
+		return preview, err
+	}
+	data, err := os.ReadFile(mp3Path)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read MP3 file: %w", err)
+	}
+	if options.RebuildTag {
+		if data, err = rebuildTagData(data); err != nil {
+			return nil, err
+		}
+	}
+	oldSize := int64(0)
+	if len(data) >= HeaderSize {
+		if size, ok := TagSize(data[:HeaderSize]); ok {
+			oldSize = min(size, int64(len(data)))
+		}
+	}
+	tag, audio, err := buildTag(data, markers, options)
+	if err != nil {
+		return nil, err
+	}
+
+	// Editing in place only rewrites the tag region if the new tag fits, as in saveInPlace
+	if outputPath == "" {
+		outputPath = generateOutputPath(mp3Path)
+	}
+	if outputPath == mp3Path && !options.Deterministic && oldSize > 0 {
+		minimal, err := encodeTag(tag, Options{})
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(minimal)+options.padding()) <= oldSize {
+			return &TagSizePreview{OldTagSize: oldSize, NewTagSize: oldSize, FileSize: oldSize + int64(len(audio)), InPlace: true}, nil
+		}
+	}
+
+	encoded, err := encodeTag(tag, options)
+	if err != nil {
+		return nil, err
+	}
+	newSize := int64(len(encoded))
+	return &TagSizePreview{
+		OldTagSize: oldSize,
+		NewTagSize: newSize,
+		FileSize:   newSize + int64(len(audio)),
+	}, nil
+}
+
+// buildTag parses the tag of the MP3 data and adds the chapters and optional content, returning it with the audio data behind it
+func buildTag(data []byte, markers []csvparser.MarkerEntry, options Options) (*id3v2.Tag, []byte, error) {
+	if options.RebuildTag {
+		var err error
+		if data, err = rebuildTagData(data); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Remove chapter frames that id3v2 cannot parse, as prepareForID3v2 does for files
+	raw, err := ReadRawTagFrom(bytes.NewReader(data))
+	var existing []RawChapter
+	if err == nil && raw != nil && options.MergeChapters {
+		existing = options.keptChapters(raw.Chapters())
+	}
+	if err == nil && raw != nil && raw.hasExtendedChapters() {
+		var frames []RawFrame
+		for _, frame := range raw.Frames {
+			if frame.ID != "CHAP" && frame.ID != "CTOC" {
+				frames = append(frames, frame)
+			}
+		}
+		data = append(encodeRawTag(raw.Version, frames, 0), data[min(raw.Size, int64(len(data))):]...)
+	}
+
+	// Parse the existing tag and locate the audio data behind it
+	if err := checkTagFrom(bytes.NewReader(data), int64(len(data))); err != nil {
+		return nil, nil, err
+	}
+	tag, err := id3v2.ParseReader(bytes.NewReader(data), id3v2.Options{Parse: true})
+	if err != nil {
+		return nil, nil, fmt.Errorf("Cannot parse MP3 data: %w", err)
+	}
+	audio := data
+	if len(data) >= HeaderSize {
+		if size, ok := TagSize(data[:HeaderSize]); ok {
+			audio = data[min(size, int64(len(data))):]
+		}
+	}
+
+	// Add chapter tags
+	scan := func() (*mp3frame.Info, error) {
+		return mp3frame.Scan(bytes.NewReader(data), int64(len(data)))
+	}
+	if err := addChapterFrames(tag, scan, markers, existing, options); err != nil {
+		return nil, nil, err
+	}
+	addOptionalFrames(tag, options)
+
+	// Upgrade or drop trailing tags
+	trailing, err := ReadTrailingTagsFrom(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, err
+	}
+	read := func() (*TrailingTags, error) {
+		return trailing, nil
+	}
+	if err := addTrailingFrames(tag, read, options); err != nil {
+		return nil, nil, err
+	}
+	if options.trailingMode() != TrailingKeep {
+		audio = audio[:max(len(audio)-(len(data)-int(trailing.Start)), 0)]
+	}
+	return tag, audio, nil
+}
+
 // copyFile copies a file from src to dst
 func copyFile(src, dst string) error {
 	// Open input file
@@ -157,67 +442,252 @@ func copyFile(src, dst string) error {
 	return nil
 }
 
-// addChapterFrames adds chapter frames to ID3 tags
-func addChapterFrames(tag *id3v2.Tag, markers []csvparser.MarkerEntry) error {
+// scanFile returns a function scanning the MP3 file at path, which is only called when end times are needed
+func scanFile(path string) func() (*mp3frame.Info, error) {
+	return func() (*mp3frame.Info, error) {
+		return mp3frame.ScanFile(path)
+	}
+}
+
+// addChapterFrames adds chapter frames to ID3 tags, applying the writer settings and artwork in options.
+// The existing chapters are written back unchanged and listed in the table of contents along with the markers.
+func addChapterFrames(tag *id3v2.Tag, scan func() (*mp3frame.Info, error), markers []csvparser.MarkerEntry, existing []RawChapter, options Options) error {
+	written := options.Written
+	if written == nil {
+		written = &WrittenChapters{}
+	}
+	*written = WrittenChapters{}
+
 	// Delete existing chapter and CTOC frames (to avoid duplicates)
 	tag.DeleteFrames("CHAP")
 	tag.DeleteFrames("CTOC")
 
-	if len(markers) == 0 {
+	// Apply version and text encoding
+	if options.Version != 0 {
+		tag.SetVersion(options.Version)
+	}
+	encoding, err := ParseEncoding(options.Encoding)
+	if err != nil {
+		return err
+	}
+	if options.Padding < 0 {
+		return fmt.Errorf("Padding must not be negative")
+	}
+	if _, err := ParseTrailingMode(options.TrailingTags); err != nil {
+		return err
+	}
+	if err := ValidateElementID(options.tocID()); err != nil {
+		return fmt.Errorf("Invalid table of contents: %w", err)
+	}
+	if err := ValidateElementID(options.chapterIDPrefix()); err != nil {
+		return fmt.Errorf("Invalid chapter ID prefix: %w", err)
+	}
+
+	if len(markers) == 0 && len(existing) == 0 {
 		return nil // Do nothing if there are no markers
 	}
 
-	// Generate chapter frames and collect their element IDs
-	var chapterElementIDs []string
+	// Write back the existing chapters, reserving their element IDs
+	ids := newElementIDs(existing, options.RenameDuplicateIDs)
+	var entries []tocEntry
+	for _, chapter := range existing {
+		chapter.Subframes = repairTextSubframes(tag.Version(), chapter.ElementID, chapter.Subframes, func(string, ...any) {}) // Version may change
+		tag.AddFrame("CHAP", elementFrame{ElementID: chapter.ElementID, Body: chapter.body(tag.Version())})
+		entries = append(entries, tocEntry{ElementID: chapter.ElementID, StartTime: time.Duration(chapter.StartTime) * time.Millisecond})
+		written.Chapters = append(written.Chapters, Chapter{Title: chapter.Title(), StartTime: time.Duration(chapter.StartTime) * time.Millisecond, ElementID: chapter.ElementID})
+	}
 
+	// Collect markers with names, as markers with empty names are skipped
+	var indices []int
 	for i, marker := range markers {
-		// Skip markers with empty names
-		if strings.TrimSpace(marker.Name) == "" {
-			continue
+		if strings.TrimSpace(marker.Name) != "" {
+			indices = append(indices, i)
+		}
+	}
+
+	// Determine end times if requested
+	endTimes := make([]time.Duration, len(indices))
+	for n := range endTimes {
+		endTimes[n] = id3v2.IgnoredOffset // Ignore end time
+	}
+	if options.EndTimes && len(indices) > 0 {
+		info, err := scan()
+		if err != nil {
+			return fmt.Errorf("Failed to determine audio duration for end times: %w", err)
 		}
+		for n := range indices {
+			if n+1 < len(indices) {
+				endTimes[n] = markers[indices[n+1]].StartTime
+			} else {
+				endTimes[n] = info.Duration()
+			}
+
+			// Merged chapters end where an existing chapter starts
+			for _, chapter := range existing {
+				if start := time.Duration(chapter.StartTime) * time.Millisecond; start > markers[indices[n]].StartTime && start < endTimes[n] {
+					endTimes[n] = start
+				}
+			}
+		}
+	}
+
+	// Markers with an end time keep it; only CSV files with a mapped end column have them, so Audition markers are unaffected
+	for n, i := range indices {
+		if markers[i].EndTime > markers[i].StartTime {
+			endTimes[n] = markers[i].EndTime
+		}
+	}
+
+	// Generate chapter frames and collect their element IDs
+	for n, i := range indices {
+		marker := markers[i]
 
 		// Unique ID for chapter element
-		elementID := fmt.Sprintf("chp%d", i)
-		chapterElementIDs = append(chapterElementIDs, elementID)
+		elementID, err := ids.claim(fmt.Sprintf("%s%d", options.chapterIDPrefix(), i))
+		if err != nil {
+			return fmt.Errorf("Cannot add chapter '%s': %w", marker.Name, err)
+		}
+		entries = append(entries, tocEntry{ElementID: elementID, StartTime: marker.StartTime, Part: marker.Part})
+		written.Chapters = append(written.Chapters, Chapter{Title: marker.Name, StartTime: marker.StartTime.Truncate(time.Millisecond), ElementID: elementID})
 
-		// Create chapter frame
-		chapterFrame := createChapterFrame(elementID, marker.Name, marker.StartTime)
+		// Create chapter frame, with a picture subframe if the marker has artwork
+		chapterFrame := createChapterFrame(elementID, marker.Name, marker.StartTime, endTimes[n], encoding)
+		if i < len(options.ChapterImages) && options.ChapterImages[i] != nil {
+			tag.AddFrame("CHAP", createPictureChapterFrame(chapterFrame, options.ChapterImages[i], tag.Version()))
+			continue
+		}
+		if tag.Version() == 3 || encoding.Equals(id3v2.EncodingUTF16) {
+			// id3v2 always writes synchsafe subframe sizes, which ID3v2.3 does not use, and pads UTF-16 titles
+			tag.AddFrame("CHAP", createPictureChapterFrame(chapterFrame, nil, tag.Version()))
+			continue
+		}
 
 		// Add chapter frame to the tag
 		tag.AddFrame("CHAP", chapterFrame)
 	}
 
-	// Exit if there are no valid chapters
-	if len(chapterElementIDs) == 0 {
+	// Exit if there are no valid chapters or no table of contents is wanted
+	sort.SliceStable(written.Chapters, func(i, j int) bool { return written.Chapters[i].StartTime < written.Chapters[j].StartTime })
+	if len(entries) == 0 || options.OmitTOC {
 		return nil
 	}
 
-	// Create a table of contents frame referencing all chapters
-	tocFrameID := "toc"
-	tocTitle := "Table of Contents"
-	tocFrame := createCTOCFrame(tocFrameID, true, true, chapterElementIDs, tocTitle)
+	// Group the chapters of multi-part programs into a table of contents per part, merged chapters in playback order
+	if len(existing) > 0 {
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].StartTime < entries[j].StartTime })
+	}
+	childIDs, partFrames, err := partTOCs(entries, ids, !options.TOCUnordered, encoding)
+	if err != nil {
+		return err
+	}
+
+	// Create a table of contents frame referencing all chapters and parts
+	tocFrameID, err := ids.claim(options.tocID())
+	if err != nil {
+		return fmt.Errorf("Invalid table of contents: %w", err)
+	}
+	tocFrame := createCTOCFrame(tocFrameID, !options.TOCNotTopLevel, !options.TOCUnordered, childIDs, options.tocTitle())
+	if tocFrame.Title != nil {
+		tocFrame.Title.Encoding = encoding
+	}
+	if err := checkTOCEntries(tocFrameID, childIDs); err != nil {
+		return err
+	}
+	for _, frame := range partFrames {
+		if err := checkTOCEntries(frame.Title.Text, frame.ChildIDs); err != nil {
+			return err
+		}
+	}
 
-	// Add CTOC frame to the tag
+	// Add CTOC frames to the tag, the top-level one first
+	written.TOC = &CTOCInfo{Title: options.tocTitle(), IsTopLevel: tocFrame.IsTopLevel, IsOrdered: tocFrame.IsOrdered, ChildIDs: childIDs}
 	tag.AddFrame("CTOC", tocFrame)
+	for _, frame := range partFrames {
+		tag.AddFrame("CTOC", frame)
+	}
 
 	return nil
 }
 
+// addOptionalFrames adds the frames for the optional content in options
+func addOptionalFrames(tag *id3v2.Tag, options Options) {
+	encoding, _ := ParseEncoding(options.Encoding) // Validated by addChapterFrames
+	if options.Transcript != nil {
+		addTranscriptFrames(tag, options.Transcript, options.SyncTranscript, padLanguage(options.TranscriptLanguage), encoding)
+	}
+	if options.MediaMarkers != "" {
+		addMediaMarkersFrame(tag, options.MediaMarkers, encoding)
+	}
+}
+
+// addTranscriptFrames replaces the lyrics frames with the transcript
+func addTranscriptFrames(tag *id3v2.Tag, cues []transcript.Cue, sync bool, language string, encoding id3v2.Encoding) {
+	tag.DeleteFrames("USLT")
+	tag.DeleteFrames("SYLT")
+
+	// Build one line per cue, prefixed with the speaker if known
+	lines := make([]SyncedText, 0, len(cues))
+	texts := make([]string, 0, len(cues))
+	for _, cue := range cues {
+		text := cue.Text
+		if cue.Speaker != "" {
+			text = cue.Speaker + ": " + text
+		}
+		lines = append(lines, SyncedText{Text: text, Time: cue.Start})
+		texts = append(texts, text)
+	}
+
+	tag.AddUnsynchronisedLyricsFrame(id3v2.UnsynchronisedLyricsFrame{
+		Encoding: encoding,
+		Language: language,
+		Lyrics:   strings.Join(texts, "\n"),
+	})
+	if sync {
+		tag.AddFrame("SYLT", SYLTFrame{
+			Encoding:    encoding,
+			Language:    language,
+			ContentType: SYLTContentTranscription,
+			Lines:       lines,
+		})
+	}
+}
+
 // createChapterFrame creates a new chapter frame with the given parameters
-func createChapterFrame(elementID string, title string, startTime time.Duration) id3v2.ChapterFrame {
+func createChapterFrame(elementID string, title string, startTime, endTime time.Duration, encoding id3v2.Encoding) id3v2.ChapterFrame {
 	return id3v2.ChapterFrame{
 		ElementID:   elementID,
 		StartTime:   startTime,
-		EndTime:     id3v2.IgnoredOffset, // Ignore end time
+		EndTime:     endTime,
 		StartOffset: id3v2.IgnoredOffset, // Ignore start offset
 		EndOffset:   id3v2.IgnoredOffset, // Ignore end offset
 		Title: &id3v2.TextFrame{
-			Encoding: id3v2.EncodingUTF8,
+			Encoding: encoding,
 			Text:     title,
 		},
 	}
 }
 
+// createPictureChapterFrame creates a chapter frame carrying the title of chapterFrame and the given artwork (if not nil)
+func createPictureChapterFrame(chapterFrame id3v2.ChapterFrame, image *artwork.Image, version byte) PictureChapterFrame {
+	frame := PictureChapterFrame{
+		ElementID: chapterFrame.ElementID,
+		StartTime: chapterFrame.StartTime,
+		EndTime:   chapterFrame.EndTime,
+		Title:     chapterFrame.Title,
+		Version:   version,
+	}
+	if image != nil {
+		frame.Picture = &id3v2.PictureFrame{
+			Encoding:    chapterFrame.Title.Encoding,
+			MimeType:    image.MimeType,
+			PictureType: id3v2.PTOther,
+			Description: "chapter image",
+			Picture:     image.Data,
+		}
+	}
+	return frame
+}
+
 // fileExists checks if a file exists
 func fileExists(path string) bool {
 	_, err := os.Stat(path)
@@ -0,0 +1,55 @@
+package id3tag
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/bogem/id3v2/v2"
+)
+
+func TestAddChaptersEndTimes(t *testing.T) {
+	// End time stored for chapters without one, and the end of 400 frames of 1152 samples at 44.1 kHz
+	unset := uint32(time.Duration(id3v2.IgnoredOffset) / time.Millisecond)
+	audioEnd := uint32(400 * 1152 * 1000 / 44100)
+	plain := []csvparser.MarkerEntry{{Name: "Intro"}, {Name: "Talk", StartTime: 5 * time.Second}}
+	mapped := []csvparser.MarkerEntry{{Name: "Intro", EndTime: 3 * time.Second}, {Name: "Talk", StartTime: 5 * time.Second}}
+	tests := []struct {
+		name    string
+		markers []csvparser.MarkerEntry
+		options Options
+		want    []uint32
+	}{
+		{"markers without end times leave them unset", plain, Options{}, []uint32{unset, unset}},
+		{"EndTimes ends chapters at the next start", plain, Options{EndTimes: true}, []uint32{5000, audioEnd}},
+		{"end times of a mapped end column are kept", mapped, Options{}, []uint32{3000, unset}},
+		{"end times of a mapped end column win over EndTimes", mapped, Options{EndTimes: true}, []uint32{3000, audioEnd}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			input := filepath.Join(dir, "input.mp3")
+			if err := os.WriteFile(input, testAudio(400), 0644); err != nil {
+				t.Fatal(err)
+			}
+			output := filepath.Join(dir, "output.mp3")
+			if err := AddChaptersWithOptions(input, tt.markers, output, tt.options); err != nil {
+				t.Fatal(err)
+			}
+			tag, err := ReadRawTag(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ends []uint32
+			for _, chapter := range tag.Chapters() {
+				ends = append(ends, chapter.EndTime)
+			}
+			if !slices.Equal(ends, tt.want) {
+				t.Errorf("CHAP end times = %v, want %v", ends, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package id3tag
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// previewLength is the maximum number of runes of text shown in a frame preview
+const previewLength = 80
+
+// Subframes returns the embedded frames of a CHAP or CTOC frame, or nil for other frames
+func (frame RawFrame) Subframes(version byte) []RawFrame {
+	switch frame.ID {
+	case "CHAP":
+		chapter, _ := parseRawChapter(frame.Body, version)
+		return chapter.Subframes
+	case "CTOC":
+		toc, _ := parseRawTOC(frame.Body, version)
+		return toc.Subframes
+	}
+	return nil
+}
+
+// Preview returns a short human-readable summary of the frame content, or "" if the frame type is not decoded
+func (frame RawFrame) Preview(version byte) string {
+	body := frame.Body
+	switch {
+	case frame.ID == "TXXX" && len(body) > 0:
+		description, rest := decodeString(body[0], body[1:])
+		value, _ := decodeString(body[0], rest)
+		return fmt.Sprintf("%s %s = %s", encodingNames[body[0]], quotePreview(description), quotePreview(value))
+	case frame.ID[0] == 'T' && len(body) > 0:
+		return fmt.Sprintf("%s %s", encodingNames[body[0]], quotePreview(decodeTextFrame(body)))
+	case frame.ID == "WXXX" && len(body) > 0:
+		description, rest := decodeString(body[0], body[1:])
+		url, _ := decodeString(0, rest)
+		return fmt.Sprintf("%s = %s", quotePreview(description), url)
+	case frame.ID[0] == 'W':
+		url, _ := decodeString(0, body)
+		return url
+	case (frame.ID == "COMM" || frame.ID == "USLT") && len(body) >= 4:
+		description, rest := decodeString(body[0], body[4:])
+		text, _ := decodeString(body[0], rest)
+		return fmt.Sprintf("%s lang=%s %s: %s", encodingNames[body[0]], body[1:4], quotePreview(description), quotePreview(text))
+	case frame.ID == "SYLT" && len(body) >= 6:
+		return fmt.Sprintf("%s lang=%s timestamps=%d content=%d", encodingNames[body[0]], body[1:4], body[4], body[5])
+	case frame.ID == "APIC":
+		picture, ok := parsePicture(body)
+		if !ok {
+			return ""
+		}
+		return fmt.Sprintf("%s type=%d %s, %d bytes", picture.MimeType, picture.PictureType, quotePreview(picture.Description), len(picture.Data))
+	case frame.ID == "PRIV" || frame.ID == "UFID":
+		owner, rest := decodeString(0, body)
+		return fmt.Sprintf("owner=%s, %d bytes", owner, len(rest))
+	case frame.ID == "CHAP":
+		chapter, ok := parseRawChapter(body, version)
+		if !ok {
+			return ""
+		}
+		preview := fmt.Sprintf("%s %s-%s", chapter.ElementID, formatMillis(chapter.StartTime), formatMillis(chapter.EndTime))
+		if chapter.StartOffset != 0xFFFFFFFF || chapter.EndOffset != 0xFFFFFFFF {
+			preview += fmt.Sprintf(" bytes %d-%d", chapter.StartOffset, chapter.EndOffset)
+		}
+		return preview
+	case frame.ID == "CTOC":
+		toc, ok := parseRawTOC(body, version)
+		if !ok {
+			return ""
+		}
+		return fmt.Sprintf("%s top-level=%t ordered=%t entries=%d [%s]", toc.ElementID, toc.TopLevel, toc.Ordered, toc.EntryCount, strings.Join(toc.ChildIDs, " "))
+	case frame.ID == "PCNT" && len(body) >= 4:
+		return fmt.Sprintf("%d", binary.BigEndian.Uint32(body[len(body)-4:]))
+	}
+	return ""
+}
+
+// quotePreview quotes text for a preview, shortening long text
+func quotePreview(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > previewLength {
+		text = string(runes[:previewLength]) + "..."
+	}
+	return fmt.Sprintf("%q", text)
+}
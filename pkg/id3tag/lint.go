@@ -0,0 +1,256 @@
+package id3tag
+
+import (
+	"fmt"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/mp3frame"
+)
+
+// Severity classifies lint findings
+type Severity string
+
+const (
+	SeverityError   Severity = "error"   // Violates the ID3v2 Chapter Addendum; players may ignore or misread the chapters
+	SeverityWarning Severity = "warning" // Allowed by the specification but known to cause problems in players
+	SeverityInfo    Severity = "info"    // Noteworthy but harmless
+)
+
+// Finding is a single problem found by Lint
+type Finding struct {
+	Severity  Severity `json:"severity"`
+	Code      string   `json:"code"`                 // Stable identifier of the check, e.g. "dangling-child"
+	ElementID string   `json:"element_id,omitempty"` // Element ID of the affected CHAP or CTOC frame
+	Message   string   `json:"message"`
+}
+
+// Lint checks the chapter structure of an MP3 file against the ID3v2 Chapter Addendum
+func Lint(mp3Path string) ([]Finding, error) {
+	tag, err := ReadRawTag(mp3Path)
+	if err != nil {
+		return nil, err
+	}
+	if tag == nil {
+		return []Finding{{Severity: SeverityInfo, Code: "no-tag", Message: "File has no ID3v2 tag"}}, nil
+	}
+
+	// The audio length is only used for range checks, so files that cannot be scanned are still linted
+	var duration time.Duration
+	if result, err := mp3frame.ScanFile(mp3Path); err == nil {
+		duration = result.Duration()
+	}
+	return tag.Lint(duration), nil
+}
+
+// Lint checks the chapter frames of the tag; duration is the audio length, or 0 to skip range checks
+func (tag *RawTag) Lint(duration time.Duration) []Finding {
+	var findings []Finding
+	report := func(severity Severity, code, elementID, format string, args ...any) {
+		findings = append(findings, Finding{Severity: severity, Code: code, ElementID: elementID, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// Parse frames, reporting the ones that cannot be parsed
+	var chapters []RawChapter
+	var tocs []RawTOC
+	for _, frame := range tag.Frames {
+		switch frame.ID {
+		case "CHAP":
+			chapter, ok := parseRawChapter(frame.Body, tag.Version)
+			if !ok {
+				report(SeverityError, "malformed-chap", "", "CHAP frame is truncated or has no terminated element ID")
+				continue
+			}
+			chapters = append(chapters, chapter)
+		case "CTOC":
+			toc, ok := parseRawTOC(frame.Body, tag.Version)
+			if !ok {
+				report(SeverityError, "malformed-ctoc", toc.ElementID, "CTOC frame is truncated or holds fewer child elements than it declares (%d)", toc.EntryCount)
+				continue
+			}
+			tocs = append(tocs, toc)
+		}
+	}
+	if len(chapters) == 0 {
+		report(SeverityInfo, "no-chapters", "", "Tag has no CHAP frames")
+		return findings
+	}
+
+	// Element IDs must be unique across CHAP and CTOC frames
+	elements := make(map[string]string)
+	checkElementID := func(frameID, elementID string) {
+		switch {
+		case elementID == "":
+			report(SeverityError, "empty-element-id", "", "%s frame has an empty element ID", frameID)
+		case elements[elementID] != "":
+			report(SeverityError, "duplicate-element-id", elementID, "Element ID is used by more than one frame (%s and %s)", elements[elementID], frameID)
+		default:
+			elements[elementID] = frameID
+		}
+	}
+	for _, chapter := range chapters {
+		checkElementID("CHAP", chapter.ElementID)
+	}
+	for _, toc := range tocs {
+		checkElementID("CTOC", toc.ElementID)
+	}
+
+	// Check each chapter
+	for _, chapter := range chapters {
+		id := chapter.ElementID
+		if chapter.EndTime < chapter.StartTime {
+			report(SeverityError, "invalid-time-range", id, "End time %s is before start time %s", formatMillis(chapter.EndTime), formatMillis(chapter.StartTime))
+		} else if chapter.EndTime == chapter.StartTime {
+			report(SeverityWarning, "empty-chapter", id, "Chapter has no length (start and end time %s)", formatMillis(chapter.StartTime))
+		}
+		if duration > 0 && time.Duration(chapter.StartTime)*time.Millisecond >= duration {
+			report(SeverityError, "start-beyond-audio", id, "Start time %s is beyond the end of the audio (%s)", formatMillis(chapter.StartTime), FormatDuration(duration))
+		} else if duration > 0 && time.Duration(chapter.EndTime)*time.Millisecond > duration+time.Second {
+			report(SeverityWarning, "end-beyond-audio", id, "End time %s is beyond the end of the audio (%s)", formatMillis(chapter.EndTime), FormatDuration(duration))
+		}
+		hasTitle := false
+		for _, subframe := range chapter.Subframes {
+			if subframe.ID == "TIT2" || subframe.ID == "TIT3" {
+				hasTitle = hasTitle || subframe.ID == "TIT2"
+				findings = append(findings, lintText(tag.Version, id, subframe)...)
+			}
+		}
+		if !hasTitle {
+			report(SeverityWarning, "missing-title", id, "Chapter has no TIT2 subframe")
+		} else if chapter.Title() == "" {
+			report(SeverityWarning, "empty-title", id, "Chapter title is empty")
+		}
+	}
+
+	// Chapters sorted by start time must not overlap or share start times
+	sorted := append([]RawChapter(nil), chapters...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].StartTime < sorted[j].StartTime })
+	if sorted[0].StartTime != 0 {
+		report(SeverityInfo, "late-first-chapter", sorted[0].ElementID, "First chapter starts at %s instead of 0:00.000", formatMillis(sorted[0].StartTime))
+	}
+	for i := 1; i < len(sorted); i++ {
+		previous, current := sorted[i-1], sorted[i]
+		switch {
+		case current.StartTime == previous.StartTime:
+			report(SeverityWarning, "duplicate-start-time", current.ElementID, "Chapter starts at the same time as '%s' (%s)", previous.ElementID, formatMillis(current.StartTime))
+		case previous.EndTime > current.StartTime && previous.EndTime >= previous.StartTime:
+			report(SeverityWarning, "overlapping-chapters", previous.ElementID, "Chapter ends at %s after '%s' starts at %s", formatMillis(previous.EndTime), current.ElementID, formatMillis(current.StartTime))
+		}
+	}
+
+	// Check the table of contents
+	if len(tocs) == 0 {
+		report(SeverityWarning, "missing-toc", "", "Tag has CHAP frames but no CTOC frame; some players ignore the chapters")
+		return findings
+	}
+	topLevel := 0
+	for _, toc := range tocs {
+		if toc.TopLevel {
+			topLevel++
+		}
+		if toc.EntryCount == 0 {
+			report(SeverityWarning, "empty-toc", toc.ElementID, "CTOC frame has no child elements")
+		}
+
+		seen := make(map[string]bool)
+		var previousStart int64 = -1
+		for _, childID := range toc.ChildIDs {
+			if seen[childID] {
+				report(SeverityError, "duplicate-child", toc.ElementID, "Child element '%s' is listed more than once", childID)
+				continue
+			}
+			seen[childID] = true
+
+			if childID == toc.ElementID {
+				report(SeverityError, "self-reference", toc.ElementID, "CTOC frame lists itself as a child element")
+				continue
+			}
+			if elements[childID] == "" {
+				report(SeverityError, "dangling-child", toc.ElementID, "Child element '%s' does not exist", childID)
+				continue
+			}
+
+			// Ordered tables list chapters in playback order
+			if chapter, ok := findRawChapter(chapters, childID); ok && toc.Ordered {
+				if int64(chapter.StartTime) < previousStart {
+					report(SeverityWarning, "non-monotonic-times", toc.ElementID, "Ordered child element '%s' starts at %s, before the preceding entry", childID, formatMillis(chapter.StartTime))
+				}
+				previousStart = int64(chapter.StartTime)
+			}
+		}
+		for _, subframe := range toc.Subframes {
+			if subframe.ID == "TIT2" || subframe.ID == "TIT3" {
+				findings = append(findings, lintText(tag.Version, toc.ElementID, subframe)...)
+			}
+		}
+	}
+	switch {
+	case topLevel == 0:
+		report(SeverityError, "no-top-level-toc", "", "No CTOC frame has the top-level flag set")
+	case topLevel > 1:
+		report(SeverityError, "multiple-top-level-toc", "", "%d CTOC frames have the top-level flag set", topLevel)
+	}
+	for _, id := range tag.TOCReferences().Orphaned {
+		if id != "" {
+			report(SeverityWarning, "orphaned-chapter", id, "Chapter is not listed in any CTOC frame")
+		}
+	}
+
+	return findings
+}
+
+// lintText checks the encoding of a text subframe
+func lintText(version byte, elementID string, subframe RawFrame) []Finding {
+	finding := func(severity Severity, code, format string, args ...any) []Finding {
+		message := subframe.ID + " subframe: " + fmt.Sprintf(format, args...)
+		return []Finding{{Severity: severity, Code: code, ElementID: elementID, Message: message}}
+	}
+
+	if len(subframe.Body) == 0 {
+		return finding(SeverityError, "empty-text-frame", "Frame has no encoding byte")
+	}
+	encoding, text := subframe.Body[0], subframe.Body[1:]
+	switch encoding {
+	case 0:
+		// Every byte is valid ISO-8859-1
+	case 1:
+		if len(text) >= 2 && !(text[0] == 0xFF && text[1] == 0xFE || text[0] == 0xFE && text[1] == 0xFF) {
+			return finding(SeverityError, "missing-bom", "UTF-16 text has no byte order mark")
+		}
+		if len(text)%2 != 0 {
+			return finding(SeverityError, "invalid-utf16", "UTF-16 text has an odd number of bytes")
+		}
+	case 2, 3:
+		if version == 3 {
+			return finding(SeverityError, "encoding-version", "Encoding %d (%s) is not allowed in ID3v2.3", encoding, encodingNames[encoding])
+		}
+		if encoding == 2 && len(text)%2 != 0 {
+			return finding(SeverityError, "invalid-utf16", "UTF-16BE text has an odd number of bytes")
+		}
+		if encoding == 3 && !utf8.Valid(text) {
+			return finding(SeverityError, "invalid-utf8", "Text is not valid UTF-8")
+		}
+	default:
+		return finding(SeverityError, "invalid-encoding", "Unknown text encoding %d", encoding)
+	}
+	return nil
+}
+
+// encodingNames names the ID3v2 text encodings
+var encodingNames = map[byte]string{0: "ISO-8859-1", 1: "UTF-16", 2: "UTF-16BE", 3: "UTF-8"}
+
+// findRawChapter returns the chapter with the given element ID
+func findRawChapter(chapters []RawChapter, elementID string) (RawChapter, bool) {
+	for _, chapter := range chapters {
+		if chapter.ElementID == elementID {
+			return chapter, true
+		}
+	}
+	return RawChapter{}, false
+}
+
+// formatMillis formats a CHAP time in milliseconds
+func formatMillis(ms uint32) string {
+	return FormatDuration(time.Duration(ms) * time.Millisecond)
+}
@@ -0,0 +1,41 @@
+package id3tag
+
+import (
+	"github.com/bogem/id3v2/v2"
+)
+
+// MediaMarkersDescription is the description of the TXXX frame holding OverDrive MediaMarkers XML
+const MediaMarkersDescription = "OverDrive MediaMarkers"
+
+// UserText returns the value of the TXXX frame with the given description
+func (tag *RawTag) UserText(description string) (string, bool) {
+	for _, frame := range tag.FramesByID("TXXX") {
+		if len(frame.Body) == 0 {
+			continue
+		}
+		name, rest := decodeString(frame.Body[0], frame.Body[1:])
+		if name == description {
+			value, _ := decodeString(frame.Body[0], rest)
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// ReadMediaMarkers returns the OverDrive MediaMarkers XML of an MP3 file, or "" if it has none
+func ReadMediaMarkers(mp3Path string) (string, error) {
+	tag, err := ReadRawTag(mp3Path)
+	if err != nil || tag == nil {
+		return "", err
+	}
+	value, _ := tag.UserText(MediaMarkersDescription)
+	return value, nil
+}
+
+// addMediaMarkersFrame replaces the OverDrive MediaMarkers TXXX frame.
+// The frame is encoded here, as the id3v2 package misplaces the BOM of UTF-16 values.
+func addMediaMarkersFrame(tag *id3v2.Tag, value string, encoding id3v2.Encoding) {
+	body := append([]byte{encoding.Key}, encodeString(encoding, MediaMarkersDescription)...)
+	body = append(body, encodeString(encoding, value)...)
+	tag.AddFrame("TXXX", elementFrame{ElementID: MediaMarkersDescription, Body: body})
+}
@@ -0,0 +1,96 @@
+package id3tag
+
+import (
+	"fmt"
+	"io"
+	"slices"
+)
+
+// DefaultChapterIDPrefix is the prefix of the element IDs of written chapters, followed by the marker index
+const DefaultChapterIDPrefix = "chp"
+
+// chapterIDPrefix returns the prefix of chapter element IDs from options
+func (options Options) chapterIDPrefix() string {
+	if options.ChapterIDPrefix == "" {
+		return DefaultChapterIDPrefix
+	}
+	return options.ChapterIDPrefix
+}
+
+// existingChapters returns the chapters of the MP3 file that are kept when merging is requested in options
+func existingChapters(mp3Path string, options Options) ([]RawChapter, error) {
+	if !options.MergeChapters {
+		return nil, nil
+	}
+	tag, err := ReadRawTag(mp3Path)
+	if err != nil || tag == nil {
+		return nil, err
+	}
+	return options.keptChapters(tag.Chapters()), nil
+}
+
+// keptChapters returns the existing chapters without the ones replaced according to options
+func (options Options) keptChapters(chapters []RawChapter) []RawChapter {
+	var kept []RawChapter
+	for _, chapter := range chapters {
+		if !slices.Contains(options.ReplacedChapters, chapter.ElementID) {
+			kept = append(kept, chapter)
+		}
+	}
+	return kept
+}
+
+// elementIDs tracks the element IDs of the CHAP and CTOC frames of a tag
+type elementIDs struct {
+	used   map[string]bool
+	rename bool // Whether to make duplicates unique instead of failing
+}
+
+// newElementIDs returns the element IDs of the chapters kept in the tag
+func newElementIDs(existing []RawChapter, rename bool) *elementIDs {
+	ids := &elementIDs{used: make(map[string]bool), rename: rename}
+	for _, chapter := range existing {
+		ids.used[chapter.ElementID] = true
+	}
+	return ids
+}
+
+// claim reserves id, or an unused variant such as "chp0-2" if renaming duplicates, and returns it.
+// Without renaming, a duplicate would make references from CTOC frames ambiguous, so it fails.
+func (ids *elementIDs) claim(id string) (string, error) {
+	if !ids.used[id] {
+		ids.used[id] = true
+		return id, nil
+	}
+	if !ids.rename {
+		return "", fmt.Errorf("Element ID '%s' is already used by another frame in the tag", id)
+	}
+	for n := 2; ; n++ {
+		if candidate := fmt.Sprintf("%s-%d", id, n); !ids.used[candidate] {
+			ids.used[candidate] = true
+			return candidate, nil
+		}
+	}
+}
+
+// elementFrame is a CHAP or CTOC frame written from an already encoded body, identified by its element ID
+type elementFrame struct {
+	ElementID string
+	Body      []byte
+}
+
+// Size returns the size of the body
+func (frame elementFrame) Size() int {
+	return len(frame.Body)
+}
+
+// UniqueIdentifier returns the element ID
+func (frame elementFrame) UniqueIdentifier() string {
+	return frame.ElementID
+}
+
+// WriteTo writes the body to a writer
+func (frame elementFrame) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(frame.Body)
+	return int64(n), err
+}
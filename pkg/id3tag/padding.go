@@ -0,0 +1,78 @@
+package id3tag
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/bogem/id3v2/v2"
+)
+
+// padding returns the number of padding bytes written after the frames
+func (options Options) padding() int {
+	if options.Padding == 0 && options.Deterministic {
+		return DeterministicPadding
+	}
+	return options.Padding
+}
+
+// rewritesTag reports whether the tag written by id3v2, which has neither padding nor a stable frame order, must be rewritten
+func (options Options) rewritesTag() bool {
+	return options.Deterministic || options.Padding > 0
+}
+
+// finalFrames returns the frames of a tag written by id3v2 as they are stored for the options
+func (options Options) finalFrames(tag *RawTag) []RawFrame {
+	if options.Deterministic {
+		return deterministicFrames(tag)
+	}
+	return tag.Frames
+}
+
+// rewriteTag rewrites the tag of the MP3 file written by id3v2 with the padding and frame order in options
+func rewriteTag(mp3Path string, options Options) error {
+	tag, err := ReadRawTag(mp3Path)
+	if err != nil || tag == nil {
+		return err
+	}
+	return writeRawTag(mp3Path, mp3Path, tag, options.finalFrames(tag), options.padding())
+}
+
+// encodeTag serializes tag with the padding and frame order in options
+func encodeTag(tag *id3v2.Tag, options Options) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := tag.WriteTo(&buf); err != nil {
+		return nil, fmt.Errorf("Failed to write tags: %w", err)
+	}
+	if !options.rewritesTag() || buf.Len() == 0 {
+		return buf.Bytes(), nil
+	}
+
+	raw, err := ReadRawTagFrom(bytes.NewReader(buf.Bytes()))
+	if err != nil || raw == nil {
+		return nil, fmt.Errorf("Failed to reread tags: %v", err)
+	}
+	return encodeRawTag(raw.Version, options.finalFrames(raw), options.padding()), nil
+}
+
+// saveInPlace writes tag over the existing tag of the MP3 file without moving the audio data if it fits with the padding in options.
+// Deterministic tags are not written this way, as the leftover space would change their padding.
+// It reports whether the tag was saved.
+func saveInPlace(mp3Path string, tag *id3v2.Tag, options Options) (bool, error) {
+	if options.Deterministic {
+		return false, nil
+	}
+	existing, err := ReadRawTag(mp3Path)
+	if err != nil || existing == nil {
+		return false, nil // Leave files without a readable tag to id3v2
+	}
+
+	encoded, err := encodeTag(tag, Options{})
+	if err != nil {
+		return false, err
+	}
+	raw, err := ReadRawTagFrom(bytes.NewReader(encoded))
+	if err != nil || raw == nil {
+		return false, nil
+	}
+	return overwriteTag(mp3Path, raw.Version, existing.Size, raw.Frames, options.padding())
+}
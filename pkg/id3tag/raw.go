@@ -0,0 +1,605 @@
+package id3tag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"unicode/utf16"
+
+	"github.com/bogem/id3v2/v2"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/safefile"
+)
+
+// RawFrame is an undecoded ID3v2 frame
+type RawFrame struct {
+	ID    string // Four-character frame ID
+	Flags uint16 // Frame status and format flags
+	Body  []byte // Frame content without the header
+}
+
+// RawTag is an ID3v2 tag split into undecoded frames
+// It is used where id3v2 drops information, such as subframes of CHAP frames other than TIT2 and TIT3
+type RawTag struct {
+	Version byte           // Major version (3 or 4)
+	Size    int64          // Total size of the tag in the file including the header
+	Frames  []RawFrame     // Frames in file order
+	Skipped []SkippedFrame // Large frames not loaded, as the tag exceeds MaxReadTagSize
+}
+
+// SkippedFrame is a frame of a tag above MaxReadTagSize whose body was skipped instead of loaded
+type SkippedFrame struct {
+	ID   string // Four-character frame ID
+	Size int64  // Size of the body
+}
+
+// HeaderSize is the size of the ID3v2 tag header
+const HeaderSize = 10
+
+// Limits when reading tags, which may come from untrusted uploads or URLs
+const (
+	MaxReadTagSize  = 64 << 20 // Largest tag read in bytes; the format allows 256 MiB
+	MaxSubframeSize = 16 << 20 // Largest subframe of a CHAP or CTOC frame in bytes, e.g. chapter artwork
+)
+
+// skipFrameSize is the smallest body of a frame other than CHAP and CTOC skipped when reading a tag above MaxReadTagSize
+const skipFrameSize = 256 << 10
+
+// ReadRawTag reads the ID3v2 tag at the start of an MP3 file; it returns nil if the file has no tag
+func ReadRawTag(mp3Path string) (*RawTag, error) {
+	file, err := os.Open(mp3Path)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot open MP3 file: %w", err)
+	}
+	defer file.Close()
+
+	return ReadRawTagFrom(file)
+}
+
+// ReadRawTagFrom reads the ID3v2 tag at the start of r; it returns nil if there is no tag.
+// Nothing after the declared tag size is read, so r may be a partial download or the body of an HTTP range request.
+// In a tag above MaxReadTagSize, large frames other than CHAP and CTOC, such as huge cover art, are skipped
+// (with Seek if r supports it) and listed in Skipped; it fails only if the loaded frames still exceed the limit.
+func ReadRawTagFrom(r io.Reader) (*RawTag, error) {
+	// Read tag header
+	header := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(r, header); err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, nil // Too short for a tag
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to read ID3v2 tag: %w", err)
+	}
+	size, ok := TagSize(header)
+	if !ok {
+		return nil, nil
+	}
+	version, flags := header[3], header[5]
+	if version < 3 || version > 4 {
+		return nil, fmt.Errorf("Unsupported ID3v2 version 2.%d", version)
+	}
+	if flags&0x80 != 0 {
+		return nil, fmt.Errorf("Unsynchronised ID3v2 tags are not supported")
+	}
+	if size > MaxReadTagSize {
+		return readLargeRawTag(r, version, flags, size)
+	}
+
+	// Read frame data
+	data := make([]byte, synchsafe(header[6:10])) // Without header and footer
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("Failed to read ID3v2 tag: %w", err)
+	}
+
+	// Skip extended header
+	if flags&0x40 != 0 && len(data) >= 4 {
+		if version == 4 {
+			data = data[min(int(synchsafe(data[:4])), len(data)):]
+		} else {
+			data = data[min(4+int(binary.BigEndian.Uint32(data[:4])), len(data)):]
+		}
+	}
+
+	return &RawTag{Version: version, Size: size, Frames: parseRawFrames(data, version)}, nil
+}
+
+// readLargeRawTag reads the frames of a tag above MaxReadTagSize one by one after its header, skipping large frames
+// other than CHAP and CTOC
+func readLargeRawTag(r io.Reader, version, flags byte, size int64) (*RawTag, error) {
+	tag := &RawTag{Version: version, Size: size}
+	remaining := size - HeaderSize
+	if flags&0x10 != 0 {
+		remaining -= 10 // Footer
+	}
+
+	// Skip extended header
+	header := make([]byte, 10)
+	if flags&0x40 != 0 {
+		if _, err := io.ReadFull(r, header[:4]); err != nil {
+			return nil, fmt.Errorf("Failed to read ID3v2 tag: %w", err)
+		}
+		extended := 4 + int64(binary.BigEndian.Uint32(header[:4]))
+		if version == 4 {
+			extended = synchsafe(header[:4])
+		}
+		extended = min(max(extended, 4), remaining)
+		if err := skipBytes(r, extended-4); err != nil {
+			return nil, err
+		}
+		remaining -= extended
+	}
+
+	var loaded int64
+	for remaining >= 10 {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, fmt.Errorf("Failed to read ID3v2 tag: %w", err)
+		}
+		if header[0] == 0 {
+			break // Padding
+		}
+		var frameSize int64
+		if version == 4 {
+			frameSize = synchsafe(header[4:8])
+		} else {
+			frameSize = int64(binary.BigEndian.Uint32(header[4:8]))
+		}
+		if frameSize > remaining-10 {
+			break // Malformed, as parseRawFrames stops
+		}
+		remaining -= 10 + frameSize
+
+		id := string(header[:4])
+		if frameSize >= skipFrameSize && id != "CHAP" && id != "CTOC" {
+			if err := skipBytes(r, frameSize); err != nil {
+				return nil, err
+			}
+			tag.Skipped = append(tag.Skipped, SkippedFrame{ID: id, Size: frameSize})
+			continue
+		}
+		if loaded += frameSize; loaded > MaxReadTagSize {
+			return nil, fmt.Errorf("ID3v2 tag of %d bytes exceeds the limit of %d bytes", size, MaxReadTagSize)
+		}
+		body := make([]byte, frameSize)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return nil, fmt.Errorf("Failed to read ID3v2 tag: %w", err)
+		}
+		tag.Frames = append(tag.Frames, RawFrame{ID: id, Flags: binary.BigEndian.Uint16(header[8:10]), Body: body})
+	}
+	return tag, nil
+}
+
+// skipBytes skips n bytes of r, seeking if r is an io.Seeker
+func skipBytes(r io.Reader, n int64) error {
+	if seeker, ok := r.(io.Seeker); ok {
+		if _, err := seeker.Seek(n, io.SeekCurrent); err != nil {
+			return fmt.Errorf("Failed to read ID3v2 tag: %w", err)
+		}
+		return nil
+	}
+	if _, err := io.CopyN(io.Discard, r, n); err != nil {
+		return fmt.Errorf("Failed to read ID3v2 tag: %w", err)
+	}
+	return nil
+}
+
+// TagSize returns the total size of the ID3v2 tag including header and footer from the first HeaderSize bytes of a file.
+// It reports false if the bytes do not start an ID3v2 tag.
+func TagSize(header []byte) (int64, bool) {
+	if len(header) < HeaderSize || string(header[:3]) != "ID3" {
+		return 0, false
+	}
+	size := HeaderSize + synchsafe(header[6:10])
+	if header[5]&0x10 != 0 {
+		size += 10 // Footer
+	}
+	return size, true
+}
+
+// FramesByID returns all frames with the given ID
+func (tag *RawTag) FramesByID(id string) []RawFrame {
+	var frames []RawFrame
+	for _, frame := range tag.Frames {
+		if frame.ID == id {
+			frames = append(frames, frame)
+		}
+	}
+	return frames
+}
+
+// parseRawFrames splits frame data into frames, stopping at padding or malformed data
+func parseRawFrames(data []byte, version byte) []RawFrame {
+	var frames []RawFrame
+	for len(data) >= 10 && data[0] != 0 {
+		var size int64
+		if version == 4 {
+			size = synchsafe(data[4:8])
+		} else {
+			size = int64(binary.BigEndian.Uint32(data[4:8]))
+		}
+		if size > int64(len(data)-10) {
+			break
+		}
+
+		frames = append(frames, RawFrame{ID: string(data[:4]), Flags: binary.BigEndian.Uint16(data[8:10]), Body: data[10 : 10+size]})
+		data = data[10+size:]
+	}
+	return frames
+}
+
+// synchsafe decodes a 4-byte synchsafe integer
+func synchsafe(b []byte) int64 {
+	return int64(b[0]&0x7F)<<21 | int64(b[1]&0x7F)<<14 | int64(b[2]&0x7F)<<7 | int64(b[3]&0x7F)
+}
+
+// RawChapter is a CHAP frame with its subframes
+type RawChapter struct {
+	ElementID   string     // Unique identifier referenced from CTOC frames
+	StartTime   uint32     // Start time in milliseconds
+	EndTime     uint32     // End time in milliseconds
+	StartOffset uint32     // Byte offset of the start in the file, 0xFFFFFFFF if unused
+	EndOffset   uint32     // Byte offset of the end in the file, 0xFFFFFFFF if unused
+	Subframes   []RawFrame // Embedded frames such as TIT2 and APIC
+}
+
+// parseRawChapter decodes the fixed part of a CHAP frame body and splits its subframes
+func parseRawChapter(body []byte, version byte) (RawChapter, bool) {
+	idEnd := bytes.IndexByte(body, 0)
+	if idEnd < 0 || len(body) < idEnd+1+16 {
+		return RawChapter{}, false
+	}
+
+	fixed := body[idEnd+1:]
+	subframes, ok := parseRawSubframes(fixed[16:], version)
+	return RawChapter{
+		ElementID:   string(body[:idEnd]),
+		StartTime:   binary.BigEndian.Uint32(fixed[0:4]),
+		EndTime:     binary.BigEndian.Uint32(fixed[4:8]),
+		StartOffset: binary.BigEndian.Uint32(fixed[8:12]),
+		EndOffset:   binary.BigEndian.Uint32(fixed[12:16]),
+		Subframes:   subframes,
+	}, ok
+}
+
+// parseRawSubframes splits the subframes of a CHAP or CTOC frame, reporting false if one exceeds MaxSubframeSize
+func parseRawSubframes(data []byte, version byte) ([]RawFrame, bool) {
+	subframes := parseRawFrames(data, version)
+	oversized := slices.ContainsFunc(subframes, func(subframe RawFrame) bool { return len(subframe.Body) > MaxSubframeSize })
+	return subframes, !oversized
+}
+
+// Chapters returns the CHAP frames of the tag
+func (tag *RawTag) Chapters() []RawChapter {
+	var chapters []RawChapter
+	for _, frame := range tag.FramesByID("CHAP") {
+		if chapter, ok := parseRawChapter(frame.Body, tag.Version); ok {
+			chapters = append(chapters, chapter)
+		}
+	}
+	return chapters
+}
+
+// Title returns the text of the chapter's TIT2 subframe
+func (chapter RawChapter) Title() string {
+	for _, subframe := range chapter.Subframes {
+		if subframe.ID == "TIT2" {
+			return decodeTextFrame(subframe.Body)
+		}
+	}
+	return ""
+}
+
+// decodeTextFrame decodes the body of a text frame (encoding byte followed by text)
+func decodeTextFrame(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	text, _ := decodeString(body[0], body[1:])
+	return text
+}
+
+// decodeString decodes null-terminated text in an ID3v2 encoding and returns it with the remaining bytes
+func decodeString(encoding byte, data []byte) (string, []byte) {
+	switch encoding {
+	case 1, 2: // UTF-16 with BOM, UTF-16BE
+		end := len(data) &^ 1
+		for i := 0; i+1 < len(data); i += 2 {
+			if data[i] == 0 && data[i+1] == 0 {
+				end = i
+				break
+			}
+		}
+		rest := data[min(end+2, len(data)):]
+
+		text := data[:end]
+		bigEndian := encoding == 2
+		if len(text) >= 2 && (text[0] == 0xFE && text[1] == 0xFF || text[0] == 0xFF && text[1] == 0xFE) {
+			bigEndian = text[0] == 0xFE
+			text = text[2:]
+		}
+		units := make([]uint16, len(text)/2)
+		for i := range units {
+			if bigEndian {
+				units[i] = binary.BigEndian.Uint16(text[2*i:])
+			} else {
+				units[i] = binary.LittleEndian.Uint16(text[2*i:])
+			}
+		}
+		return string(utf16.Decode(units)), rest
+	case 0: // ISO-8859-1
+		end := bytes.IndexByte(data, 0)
+		if end < 0 {
+			end = len(data)
+		}
+		runes := make([]rune, end)
+		for i, b := range data[:end] {
+			runes[i] = rune(b)
+		}
+		return string(runes), data[min(end+1, len(data)):]
+	default: // UTF-8
+		end := bytes.IndexByte(data, 0)
+		if end < 0 {
+			end = len(data)
+		}
+		return string(data[:end]), data[min(end+1, len(data)):]
+	}
+}
+
+// hasExtendedChapters reports whether a CHAP frame carries subframes other than TIT2 and TIT3.
+// id3v2 stops parsing the tag at such frames, so they have to be removed before it opens the file.
+func (tag *RawTag) hasExtendedChapters() bool {
+	for _, chapter := range tag.Chapters() {
+		for _, subframe := range chapter.Subframes {
+			if subframe.ID != "TIT2" && subframe.ID != "TIT3" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// removeFrames rewrites the MP3 file with the tag minus all frames with the given IDs
+func removeFrames(mp3Path string, tag *RawTag, ids ...string) error {
+	var frames []RawFrame
+	for _, frame := range tag.Frames {
+		if !slices.Contains(ids, frame.ID) {
+			frames = append(frames, frame)
+		}
+	}
+	return writeRawTag(mp3Path, mp3Path, tag, frames, 0)
+}
+
+// writeRawTag writes the audio data of inputPath to outputPath with a new tag holding frames and padding zero bytes in place of tag
+func writeRawTag(inputPath, outputPath string, tag *RawTag, frames []RawFrame, padding int) error {
+	if err := tag.checkRewritable(); err != nil {
+		return err
+	}
+
+	// Overwrite only the tag region if the frames fit into the existing tag and its padding
+	if inputPath == outputPath {
+		if done, err := overwriteTag(outputPath, tag.Version, tag.Size, frames, padding); done || err != nil {
+			return err
+		}
+	}
+
+	input, err := os.Open(inputPath)
+	if err != nil {
+		return fmt.Errorf("Cannot open MP3 file: %w", err)
+	}
+	defer input.Close()
+
+	// Write new tag followed by the audio data into a temporary file
+	tempPath, err := safefile.TempPath(outputPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempPath)
+	output, err := os.Create(tempPath)
+	if err != nil {
+		return fmt.Errorf("Failed to create temporary file: %w", err)
+	}
+
+	_, err = output.Write(encodeRawTag(tag.Version, frames, padding))
+	if err == nil {
+		_, err = io.Copy(output, io.NewSectionReader(input, tag.Size, 1<<62))
+	}
+	if closeErr := output.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to rewrite ID3v2 tag: %w", err)
+	}
+
+	input.Close()
+	return safefile.Replace(tempPath, outputPath)
+}
+
+// checkRewritable returns an error if frames of the tag were skipped when reading it, as writing its frames back would drop them
+func (tag *RawTag) checkRewritable() error {
+	if len(tag.Skipped) > 0 {
+		return fmt.Errorf("Cannot rewrite the ID3v2 tag of %d bytes: %d large frames above the read limit of %d bytes were not loaded", tag.Size, len(tag.Skipped), MaxReadTagSize)
+	}
+	return nil
+}
+
+// overwriteTag writes a tag holding frames over the existing tag of size bytes at the start of the MP3 file,
+// filling the rest of the space with padding, if the frames and at least minPadding bytes of padding fit.
+// The audio data is not moved, so this is much faster than rewriting the file. It reports whether the frames fitted.
+func overwriteTag(mp3Path string, version byte, size int64, frames []RawFrame, minPadding int) (bool, error) {
+	data := encodeRawTag(version, frames, 0)
+	if size == 0 || int64(len(data)+minPadding) > size {
+		return false, nil
+	}
+	data = encodeRawTag(version, frames, int(size)-len(data))
+
+	file, err := os.OpenFile(mp3Path, os.O_WRONLY, 0)
+	if err != nil {
+		return false, fmt.Errorf("Cannot open MP3 file: %w", err)
+	}
+	_, err = file.WriteAt(data, 0)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return false, fmt.Errorf("Failed to rewrite ID3v2 tag: %w", err)
+	}
+	return true, nil
+}
+
+// encodeRawTag serializes frames followed by padding zero bytes into a tag of the given version, including the tag header
+func encodeRawTag(version byte, frames []RawFrame, padding int) []byte {
+	var buf bytes.Buffer
+	buf.Write(make([]byte, HeaderSize))
+	for _, frame := range frames {
+		writeSubframeHeader(&buf, frame.ID, len(frame.Body), version)
+		buf.Write(frame.Body)
+	}
+	buf.Write(make([]byte, padding))
+
+	data := buf.Bytes()
+	size := len(data) - HeaderSize
+	copy(data, []byte{'I', 'D', '3', version, 0, 0, byte(size>>21) & 0x7F, byte(size>>14) & 0x7F, byte(size>>7) & 0x7F, byte(size) & 0x7F})
+	return data
+}
+
+// prepareForID3v2 removes chapter frames that id3v2 cannot parse from the MP3 file.
+// They are replaced by the caller anyway, and other frames following them would otherwise be lost.
+func prepareForID3v2(mp3Path string) error {
+	tag, err := ReadRawTag(mp3Path)
+	if err != nil || tag == nil || len(tag.Skipped) > 0 || !tag.hasExtendedChapters() {
+		return nil // Leave files that cannot be handled here to id3v2
+	}
+	return removeFrames(mp3Path, tag, "CHAP", "CTOC")
+}
+
+// Picture is an attached picture (APIC) frame
+type Picture struct {
+	MimeType    string // MIME type of Data, e.g. "image/jpeg"
+	PictureType byte   // Picture type, e.g. 3 for the front cover
+	Description string // Picture description
+	Data        []byte // Encoded image
+}
+
+// parsePicture decodes the body of an APIC frame
+func parsePicture(body []byte) (Picture, bool) {
+	if len(body) < 2 {
+		return Picture{}, false
+	}
+	encoding := body[0]
+
+	// MIME type is always ISO-8859-1
+	mimeEnd := bytes.IndexByte(body[1:], 0)
+	if mimeEnd < 0 || len(body) < 1+mimeEnd+2 {
+		return Picture{}, false
+	}
+	picture := Picture{MimeType: string(body[1 : 1+mimeEnd]), PictureType: body[1+mimeEnd+1]}
+	picture.Description, picture.Data = decodeString(encoding, body[1+mimeEnd+2:])
+	return picture, true
+}
+
+// Pictures returns the attached pictures of the tag itself, such as the cover art
+func (tag *RawTag) Pictures() []Picture {
+	var pictures []Picture
+	for _, frame := range tag.FramesByID("APIC") {
+		if picture, ok := parsePicture(frame.Body); ok {
+			pictures = append(pictures, picture)
+		}
+	}
+	return pictures
+}
+
+// Picture returns the first attached picture subframe of the chapter, or nil if it has none
+func (chapter RawChapter) Picture() *Picture {
+	for _, subframe := range chapter.Subframes {
+		if subframe.ID != "APIC" {
+			continue
+		}
+		if picture, ok := parsePicture(subframe.Body); ok {
+			return &picture
+		}
+	}
+	return nil
+}
+
+// encodeTextFrame encodes the body of a text frame
+// id3v2 appends a stray byte to UTF-16 text, leaving an odd number of bytes, so subframes are encoded here
+func encodeTextFrame(frame *id3v2.TextFrame) []byte {
+	return append([]byte{frame.Encoding.Key}, encodeString(frame.Encoding, frame.Text)...)
+}
+
+// encodeString encodes text in an ID3v2 encoding including the terminator
+func encodeString(encoding id3v2.Encoding, text string) []byte {
+	switch encoding.Key {
+	case 1: // UTF-16 with BOM, written little-endian
+		buf := []byte{0xFF, 0xFE}
+		for _, unit := range utf16.Encode([]rune(text)) {
+			buf = binary.LittleEndian.AppendUint16(buf, unit)
+		}
+		return append(buf, 0, 0)
+	case 2: // UTF-16BE
+		var buf []byte
+		for _, unit := range utf16.Encode([]rune(text)) {
+			buf = binary.BigEndian.AppendUint16(buf, unit)
+		}
+		return append(buf, 0, 0)
+	case 0: // ISO-8859-1, with unrepresentable characters replaced
+		buf := make([]byte, 0, len(text)+1)
+		for _, r := range text {
+			if r > 0xFF {
+				r = '?'
+			}
+			buf = append(buf, byte(r))
+		}
+		return append(buf, 0)
+	default: // UTF-8
+		return append([]byte(text), 0)
+	}
+}
+
+// RawTOC is a CTOC frame with its subframes
+type RawTOC struct {
+	ElementID  string     // Unique identifier of the table of contents
+	TopLevel   bool       // Whether this is the root table of contents
+	Ordered    bool       // Whether the child elements are ordered
+	EntryCount int        // Number of child elements declared in the frame
+	ChildIDs   []string   // Element IDs of the child CHAP or CTOC frames
+	Subframes  []RawFrame // Embedded frames such as TIT2
+}
+
+// parseRawTOC decodes the fixed part of a CTOC frame body and splits its subframes
+func parseRawTOC(body []byte, version byte) (RawTOC, bool) {
+	idEnd := bytes.IndexByte(body, 0)
+	if idEnd < 0 || len(body) < idEnd+3 {
+		return RawTOC{}, false
+	}
+
+	toc := RawTOC{
+		ElementID:  string(body[:idEnd]),
+		TopLevel:   body[idEnd+1]&1 != 0,
+		Ordered:    body[idEnd+1]&2 != 0,
+		EntryCount: int(body[idEnd+2]),
+	}
+	rest := body[idEnd+3:]
+	for range toc.EntryCount {
+		end := bytes.IndexByte(rest, 0)
+		if end < 0 {
+			return toc, false
+		}
+		toc.ChildIDs = append(toc.ChildIDs, string(rest[:end]))
+		rest = rest[end+1:]
+	}
+	var ok bool
+	toc.Subframes, ok = parseRawSubframes(rest, version)
+	return toc, ok
+}
+
+// TOCs returns the CTOC frames of the tag
+func (tag *RawTag) TOCs() []RawTOC {
+	var tocs []RawTOC
+	for _, frame := range tag.FramesByID("CTOC") {
+		if toc, ok := parseRawTOC(frame.Body, tag.Version); ok {
+			tocs = append(tocs, toc)
+		}
+	}
+	return tocs
+}
@@ -0,0 +1,150 @@
+package id3tag
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+// testFrame encodes an ID3v2.4 frame
+func testFrame(id string, body []byte) []byte {
+	var buf bytes.Buffer
+	writeSubframeHeader(&buf, id, len(body), 4)
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+// testTag encodes an ID3v2.4 tag holding frames
+func testTag(frames ...[]byte) []byte {
+	var body []byte
+	for _, frame := range frames {
+		body = append(body, frame...)
+	}
+	size := len(body)
+	header := []byte{'I', 'D', '3', 4, 0, 0, byte(size>>21) & 0x7F, byte(size>>14) & 0x7F, byte(size>>7) & 0x7F, byte(size) & 0x7F}
+	return append(header, body...)
+}
+
+// testAudio returns n silent MPEG-1 Layer III frames (128 kbit/s, 44.1 kHz) of 26 ms each
+func testAudio(n int) []byte {
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0x00})
+	return bytes.Repeat(frame, n)
+}
+
+// testChapter encodes the body of a CHAP frame with a title subframe
+func testChapter(elementID, title string, start time.Duration) []byte {
+	body := append([]byte(elementID), 0)
+	ms := uint32(start / time.Millisecond)
+	body = append(body, byte(ms>>24), byte(ms>>16), byte(ms>>8), byte(ms))
+	body = append(body, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF)
+	return append(body, testFrame("TIT2", append([]byte{3}, title...))...)
+}
+
+// largeFrameFile writes an MP3 file whose tag holds a chapter and cover art larger than MaxReadTagSize
+func largeFrameFile(t *testing.T) string {
+	t.Helper()
+	picture := append([]byte("\x00image/jpeg\x00\x03\x00"), make([]byte, MaxReadTagSize+(6<<20))...)
+	data := testTag(
+		testFrame("TIT2", []byte("\x03Episode")),
+		testFrame("APIC", picture),
+		testFrame("CHAP", testChapter("chp0", "Intro", 0)),
+	)
+	path := filepath.Join(t.TempDir(), "large.mp3")
+	if err := os.WriteFile(path, append(data, testAudio(400)...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadRawTagSkipsLargeFrames(t *testing.T) {
+	path := largeFrameFile(t)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readers := map[string]func() (*RawTag, error){
+		"file":     func() (*RawTag, error) { return ReadRawTag(path) },
+		"stream":   func() (*RawTag, error) { return ReadRawTagFrom(io.MultiReader(bytes.NewReader(data))) },
+		"seekable": func() (*RawTag, error) { return ReadRawTagFrom(bytes.NewReader(data)) },
+	}
+	for name, read := range readers {
+		t.Run(name, func(t *testing.T) {
+			tag, err := read()
+			if err != nil {
+				t.Fatalf("reading a tag with a frame above the read limit failed: %v", err)
+			}
+			if len(tag.Frames) != 2 || tag.Frames[0].ID != "TIT2" || tag.Frames[1].ID != "CHAP" {
+				t.Errorf("loaded frames = %v, want TIT2 and CHAP", tag.Frames)
+			}
+			if len(tag.Skipped) != 1 || tag.Skipped[0].ID != "APIC" || tag.Skipped[0].Size <= MaxReadTagSize {
+				t.Errorf("skipped frames = %v, want the APIC frame", tag.Skipped)
+			}
+		})
+	}
+}
+
+func TestAddChaptersWithFrameAboveReadLimit(t *testing.T) {
+	input := largeFrameFile(t)
+	output := filepath.Join(t.TempDir(), "out.mp3")
+	markers := []csvparser.MarkerEntry{
+		{Name: "Intro", StartTime: 0},
+		{Name: "Topic", StartTime: 5 * time.Second},
+	}
+	if err := AddChaptersWithOptions(input, markers, output, Options{}); err != nil {
+		t.Fatalf("AddChaptersWithOptions() error = %v", err)
+	}
+
+	chapters, err := ReadChapters(output)
+	if err != nil {
+		t.Fatalf("ReadChapters() of the written file error = %v", err)
+	}
+	if len(chapters) != len(markers) {
+		t.Fatalf("read %d chapters, want %d", len(chapters), len(markers))
+	}
+	for i, chapter := range chapters {
+		if chapter.Title != markers[i].Name || chapter.StartTime != markers[i].StartTime {
+			t.Errorf("chapter %d = %q at %v, want %q at %v", i, chapter.Title, chapter.StartTime, markers[i].Name, markers[i].StartTime)
+		}
+	}
+
+	// Rewriting the loaded frames would drop the skipped ones, so it is refused
+	if _, err := RemoveChapters(output, filepath.Join(t.TempDir(), "removed.mp3"), ChapterSelection{Chapters: []string{"1"}}, nil); err == nil {
+		t.Error("RemoveChapters() of a tag with skipped frames succeeded, want an error")
+	}
+	tag, err := ReadRawTag(output)
+	if err != nil || len(tag.Skipped) != 1 {
+		t.Errorf("cover art of the written file: tag %v, error %v", tag, err)
+	}
+}
+
+func FuzzReadRawTagFrom(f *testing.F) {
+	f.Add(testTag(testFrame("TIT2", []byte("\x03Episode")), testFrame("CHAP", testChapter("chp0", "Intro", 0))))
+	f.Add(testTag(testFrame("CTOC", append([]byte("toc\x00\x03\x01chp0\x00"), testFrame("TIT2", []byte("\x03Contents"))...))))
+	f.Add(testTag(testFrame("APIC", []byte("\x00image/png\x00\x03\x00\x89PNG"))))
+	f.Add(append([]byte{'I', 'D', '3', 3, 0, 0x40, 0, 0, 0, 20, 0, 0, 0, 6, 0, 0, 0, 0, 0, 0}, make([]byte, 10)...))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		tag, err := ReadRawTagFrom(bytes.NewReader(data))
+		if err != nil || tag == nil {
+			return
+		}
+		for _, frame := range tag.Frames {
+			if int64(len(frame.Body)) > tag.Size {
+				t.Fatalf("%s frame of %d bytes in a tag of %d bytes", frame.ID, len(frame.Body), tag.Size)
+			}
+			frame.Preview(tag.Version)
+			frame.Subframes(tag.Version)
+		}
+		chaptersFromTag(tag)
+		tocFromTag(tag)
+		tag.Pictures()
+		tag.Lint(time.Minute)
+		tag.repairChapters(time.Minute)
+	})
+}
@@ -2,6 +2,9 @@ package id3tag
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"slices"
 	"sort"
 	"time"
 
@@ -12,6 +15,14 @@ import (
 type Chapter struct {
 	Title     string        // Chapter title
 	StartTime time.Duration // Start time of the chapter
+	ElementID string        // Element ID of the CHAP frame (empty for chapters not read from ID3v2 tags)
+	Orphaned  bool          // Whether the tag has CTOC frames but none of them lists the chapter
+}
+
+// TOCReferences lists the mismatches between CHAP frames and the child elements of CTOC frames, which confuse players
+type TOCReferences struct {
+	Orphaned []string // Element IDs of chapters listed in no CTOC frame (none if the tag has no CTOC frame at all)
+	Dangling []string // Child element IDs of CTOC frames that match no CHAP or CTOC frame
 }
 
 // CTOCInfo represents the Table of Contents information contained in the ID3 tags of an MP3 file
@@ -24,32 +35,43 @@ type CTOCInfo struct {
 
 // ReadChapters reads chapter information from an MP3 file
 func ReadChapters(mp3Path string) ([]Chapter, error) {
-	// Open MP3 file
-	tag, err := id3v2.Open(mp3Path, id3v2.Options{Parse: true})
+	// Read raw tag, as id3v2 cannot parse chapters with picture subframes
+	tag, err := ReadRawTag(mp3Path)
 	if err != nil {
-		return nil, fmt.Errorf("Cannot open MP3 file: %w", err)
+		return nil, err
 	}
-	defer tag.Close()
+	return chaptersFromTag(tag), nil
+}
 
-	var chapters []Chapter
+// ReadChaptersFrom reads chapter information from the ID3v2 tag at the start of r without reading the audio data
+func ReadChaptersFrom(r io.Reader) ([]Chapter, error) {
+	tag, err := ReadRawTagFrom(r)
+	if err != nil {
+		return nil, err
+	}
+	return chaptersFromTag(tag), nil
+}
 
-	// Get all chapter frames
-	for _, frame := range tag.GetFrames("CHAP") {
-		chapterFrame, ok := frame.(id3v2.ChapterFrame)
-		if !ok {
-			continue // Skip non-chapter frames
-		}
+// chaptersFromTag returns the chapters of a raw tag sorted by start time
+func chaptersFromTag(tag *RawTag) []Chapter {
+	if tag == nil {
+		return nil
+	}
 
-		// Extract title from chapter frame
-		var title string
-		if chapterFrame.Title != nil {
-			title = chapterFrame.Title.Text
-		}
+	var chapters []Chapter
+	orphaned := make(map[string]bool)
+	for _, id := range tag.TOCReferences().Orphaned {
+		orphaned[id] = true
+	}
 
+	// Get all chapter frames
+	for _, chapterFrame := range tag.Chapters() {
 		// Add to chapter list
 		chapters = append(chapters, Chapter{
-			Title:     title,
-			StartTime: chapterFrame.StartTime,
+			Title:     chapterFrame.Title(),
+			StartTime: time.Duration(chapterFrame.StartTime) * time.Millisecond,
+			ElementID: chapterFrame.ElementID,
+			Orphaned:  orphaned[chapterFrame.ElementID],
 		})
 	}
 
@@ -58,26 +80,192 @@ func ReadChapters(mp3Path string) ([]Chapter, error) {
 		return chapters[i].StartTime < chapters[j].StartTime
 	})
 
-	return chapters, nil
+	return chapters
+}
+
+// ReadTOCReferences cross-checks the CHAP frames of an MP3 file against the child elements of its CTOC frames
+func ReadTOCReferences(mp3Path string) (*TOCReferences, error) {
+	tag, err := ReadRawTag(mp3Path)
+	if err != nil || tag == nil {
+		return &TOCReferences{}, err
+	}
+	references := tag.TOCReferences()
+	return &references, nil
+}
+
+// TOCReferences cross-checks the CHAP frames of the tag against the child elements of its CTOC frames
+func (tag *RawTag) TOCReferences() TOCReferences {
+	var references TOCReferences
+	tocs := tag.TOCs()
+	if len(tocs) == 0 {
+		return references // Missing tables of contents are not a per-chapter problem
+	}
+
+	elements := make(map[string]bool)
+	for _, chapter := range tag.Chapters() {
+		elements[chapter.ElementID] = true
+	}
+	listed := make(map[string]bool)
+	for _, toc := range tocs {
+		elements[toc.ElementID] = true
+	}
+	for _, toc := range tocs {
+		for _, childID := range toc.ChildIDs {
+			if !elements[childID] && !slices.Contains(references.Dangling, childID) {
+				references.Dangling = append(references.Dangling, childID)
+			}
+			listed[childID] = true
+		}
+	}
+	for _, chapter := range tag.Chapters() {
+		if !listed[chapter.ElementID] {
+			references.Orphaned = append(references.Orphaned, chapter.ElementID)
+		}
+	}
+	return references
 }
 
 // ReadTOC reads table of contents information from an MP3 file
 func ReadTOC(mp3Path string) (*CTOCInfo, error) {
-	// Open MP3 file
-	tag, err := id3v2.Open(mp3Path, id3v2.Options{Parse: true})
+	// Read raw tag
+	tag, err := ReadRawTag(mp3Path)
 	if err != nil {
-		return nil, fmt.Errorf("Cannot open MP3 file: %w", err)
+		return nil, err
 	}
-	defer tag.Close()
+	return tocFromTag(tag)
+}
+
+// ReadTOCFrom reads table of contents information from the ID3v2 tag at the start of r without reading the audio data
+func ReadTOCFrom(r io.Reader) (*CTOCInfo, error) {
+	tag, err := ReadRawTagFrom(r)
+	if err != nil {
+		return nil, err
+	}
+	return tocFromTag(tag)
+}
 
+// tocFromTag returns the top-level table of contents of a raw tag
+func tocFromTag(tag *RawTag) (*CTOCInfo, error) {
 	// Get all CTOC frames
-	ctocFrames := tag.GetFrames("CTOC")
-	if len(ctocFrames) == 0 {
+	if tag == nil || len(tag.FramesByID("CTOC")) == 0 {
 		return nil, fmt.Errorf("No CTOC frame found")
 	}
 
-	// Process the first CTOC frame
-	return extractCTOCInfo(ctocFrames[0])
+	// Process the top-level CTOC frame, or the first one if none is marked as top-level
+	frames := tag.FramesByID("CTOC")
+	for _, frame := range frames {
+		if toc, ok := parseRawTOC(frame.Body, tag.Version); ok && toc.TopLevel {
+			return extractCTOCInfo(id3v2.UnknownFrame{Body: frame.Body})
+		}
+	}
+	return extractCTOCInfo(id3v2.UnknownFrame{Body: frames[0].Body})
+}
+
+// ReadChaptersAndTOC reads the chapters and the top-level table of contents (nil if there is none) of an MP3 file,
+// loading only the tag header and the CHAP and CTOC frames. Other frames, such as large cover art, are skipped by their
+// headers, so it is much faster than ReadChapters for big tags and reads tags above MaxReadTagSize.
+func ReadChaptersAndTOC(mp3Path string) ([]Chapter, *CTOCInfo, error) {
+	file, err := os.Open(mp3Path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Cannot open MP3 file: %w", err)
+	}
+	defer file.Close()
+
+	tag, err := readChapterFrames(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	toc, err := tocFromTag(tag)
+	if err != nil {
+		toc = nil // No table of contents
+	}
+	return chaptersFromTag(tag), toc, nil
+}
+
+// WrittenChapters is the chapter model of a tag as AddChaptersWithOptions encoded it, recorded through Options.Written
+type WrittenChapters struct {
+	Chapters []Chapter // Chapters in start time order, with start times in whole milliseconds as stored in CHAP frames
+	TOC      *CTOCInfo // Top-level table of contents (nil if none was written)
+}
+
+// VerifyWritten checks that the CHAP and CTOC frames of an MP3 file match the chapters that were written to it.
+// Only the tag header and those frames are read back, as in ReadChaptersAndTOC.
+func VerifyWritten(mp3Path string, written *WrittenChapters) error {
+	chapters, toc, err := ReadChaptersAndTOC(mp3Path)
+	if err != nil {
+		return err
+	}
+	if len(chapters) != len(written.Chapters) {
+		return fmt.Errorf("%d chapters were written, but %d are read back", len(written.Chapters), len(chapters))
+	}
+
+	read := make(map[string]Chapter, len(chapters))
+	for _, chapter := range chapters {
+		read[chapter.ElementID] = chapter
+	}
+	for _, expected := range written.Chapters {
+		actual, ok := read[expected.ElementID]
+		if !ok {
+			return fmt.Errorf("Chapter '%s' was written, but is not read back", expected.ElementID)
+		}
+		if actual.Title != expected.Title || actual.StartTime != expected.StartTime {
+			return fmt.Errorf("Chapter '%s' was written as %q at %s, but reads back as %q at %s",
+				expected.ElementID, expected.Title, FormatDuration(expected.StartTime), actual.Title, FormatDuration(actual.StartTime))
+		}
+	}
+
+	expected := written.TOC
+	switch {
+	case expected == nil && toc != nil:
+		return fmt.Errorf("No table of contents was written, but one is read back")
+	case expected != nil && toc == nil:
+		return fmt.Errorf("The table of contents was written, but is not read back")
+	case expected != nil && (toc.Title != expected.Title || toc.IsTopLevel != expected.IsTopLevel ||
+		toc.IsOrdered != expected.IsOrdered || !slices.Equal(toc.ChildIDs, expected.ChildIDs)):
+		return fmt.Errorf("The table of contents does not read back as written")
+	}
+	return nil
+}
+
+// readChapterFrames reads the ID3v2 tag at the start of r like ReadRawTagFrom, but with only its CHAP and CTOC frames
+func readChapterFrames(r io.ReaderAt) (*RawTag, error) {
+	header := make([]byte, HeaderSize)
+	if _, err := r.ReadAt(header, 0); err == io.EOF {
+		return nil, nil // Too short for a tag
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to read ID3v2 tag: %w", err)
+	}
+	size, ok := TagSize(header)
+	if !ok {
+		return nil, nil
+	}
+	version, flags := header[3], header[5]
+	if version < 3 || version > 4 {
+		return nil, fmt.Errorf("Unsupported ID3v2 version 2.%d", version)
+	}
+	if flags&0x80 != 0 {
+		return nil, fmt.Errorf("Unsynchronised ID3v2 tags are not supported")
+	}
+
+	headers, _, err := scanFrameHeaders(r, version, flags, HeaderSize+synchsafe(header[6:10]))
+	if err != nil {
+		return nil, err
+	}
+	tag := &RawTag{Version: version, Size: size}
+	for _, frame := range headers {
+		if frame.ID != "CHAP" && frame.ID != "CTOC" {
+			continue
+		}
+		if frame.Size > MaxReadTagSize {
+			return nil, fmt.Errorf("%s frame of %d bytes exceeds the limit of %d bytes", frame.ID, frame.Size, MaxReadTagSize)
+		}
+		body := make([]byte, frame.Size)
+		if _, err := r.ReadAt(body, frame.Offset+10); err != nil {
+			return nil, fmt.Errorf("Failed to read ID3v2 tag: %w", err)
+		}
+		tag.Frames = append(tag.Frames, RawFrame{ID: frame.ID, Flags: frame.Flags, Body: body})
+	}
+	return tag, nil
 }
 
 // extractCTOCInfo extracts CTOC information from an ID3 frame
@@ -172,25 +360,8 @@ func extractTitleFromCTOC(data []byte, startPos int) string {
 			}
 
 			// Text frame starts with encoding byte
-			encoding := data[textPos]
-			textPos++
-
-			// Extract text based on encoding
-			if encoding == 0 || encoding == 3 { // ISO-8859-1 (0) or UTF-8 (3)
-				// Read until end or null
-				endPos := textPos
-				for endPos < len(data) && data[endPos] != 0 {
-					endPos++
-				}
-				return string(data[textPos:endPos])
-			} else {
-				// Other encoding, get readable content
-				endPos := textPos
-				for endPos < len(data) && endPos < textPos+50 && data[endPos] != 0 {
-					endPos++
-				}
-				return string(data[textPos:endPos])
-			}
+			text, _ := decodeString(data[textPos], data[textPos+1:])
+			return text
 		}
 	}
 
@@ -0,0 +1,76 @@
+package id3tag
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+// writeChapteredFile writes an MP3 file with cover art of artSize bytes and n chapters, returning its path and the written model
+func writeChapteredFile(tb testing.TB, artSize, n int) (string, *WrittenChapters) {
+	tb.Helper()
+	picture := append([]byte("\x00image/jpeg\x00\x03\x00"), make([]byte, artSize)...)
+	input := filepath.Join(tb.TempDir(), "input.mp3")
+	if err := os.WriteFile(input, append(testTag(testFrame("APIC", picture)), testAudio(400)...), 0644); err != nil {
+		tb.Fatal(err)
+	}
+
+	markers := make([]csvparser.MarkerEntry, n)
+	for i := range markers {
+		markers[i] = csvparser.MarkerEntry{Name: fmt.Sprintf("Chapter %d", i+1), StartTime: time.Duration(i)*50*time.Millisecond + 3*time.Microsecond}
+	}
+	output := filepath.Join(tb.TempDir(), "output.mp3")
+	written := &WrittenChapters{}
+	if err := AddChaptersWithOptions(input, markers, output, Options{Written: written}); err != nil {
+		tb.Fatal(err)
+	}
+	return output, written
+}
+
+func TestVerifyWritten(t *testing.T) {
+	path, written := writeChapteredFile(t, 1<<10, 3)
+	if len(written.Chapters) != 3 || written.Chapters[1].StartTime != 50*time.Millisecond || written.TOC == nil || len(written.TOC.ChildIDs) != 3 {
+		t.Fatalf("written model = %+v, want 3 chapters in whole milliseconds and a table of contents", written)
+	}
+	if err := VerifyWritten(path, written); err != nil {
+		t.Fatalf("VerifyWritten() of the written file error = %v", err)
+	}
+
+	changed := *written
+	changed.Chapters = append([]Chapter(nil), written.Chapters...)
+	changed.Chapters[2].Title = "Other"
+	if err := VerifyWritten(path, &changed); err == nil {
+		t.Error("VerifyWritten() with a different title succeeded, want an error")
+	}
+	changed = WrittenChapters{Chapters: written.Chapters}
+	if err := VerifyWritten(path, &changed); err == nil {
+		t.Error("VerifyWritten() without the written table of contents succeeded, want an error")
+	}
+}
+
+// BenchmarkVerify compares reading the whole tag back (-verify-mode full) with checking only the CHAP and CTOC
+// frames against the written model (-verify-mode fast) for a file with large cover art
+func BenchmarkVerify(b *testing.B) {
+	path, written := writeChapteredFile(b, 32<<20, 200)
+	b.Run("full", func(b *testing.B) {
+		for range b.N {
+			if _, err := ReadChapters(path); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := ReadTOC(path); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("fast", func(b *testing.B) {
+		for range b.N {
+			if err := VerifyWritten(path, written); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
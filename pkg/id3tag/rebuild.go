@@ -0,0 +1,162 @@
+package id3tag
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/mp3frame"
+	"github.com/bogem/id3v2/v2"
+)
+
+// ErrCorruptTag is returned, wrapped, when the existing ID3v2 tag is malformed
+var ErrCorruptTag = errors.New("existing ID3v2 tag is corrupt")
+
+// CheckTag returns an error wrapping ErrCorruptTag if the ID3v2 tag of the MP3 file is malformed.
+// The id3v2 library silently accepts many such tags and then loses frames or audio data when saving them.
+func CheckTag(mp3Path string) error {
+	file, err := os.Open(mp3Path)
+	if err != nil {
+		return fmt.Errorf("Cannot open MP3 file: %w", err)
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("Cannot stat MP3 file: %w", err)
+	}
+	return checkTagFrom(file, stat.Size())
+}
+
+// checkTagFrom returns an error wrapping ErrCorruptTag if the ID3v2 tag at the start of an MP3 stream of the given size is malformed
+func checkTagFrom(r io.ReaderAt, size int64) error {
+	header := make([]byte, HeaderSize)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil // Too short for a tag
+	}
+	tagSize, ok := TagSize(header)
+	if !ok {
+		return nil
+	}
+	if tagSize > size {
+		return fmt.Errorf("%w: declared size of %d bytes exceeds the file size of %d bytes", ErrCorruptTag, tagSize, size)
+	}
+	version, flags := header[3], header[5]
+	if (version != 3 && version != 4) || flags&0x80 != 0 {
+		return nil // Unsupported versions and unsynchronised tags are reported when they are parsed
+	}
+	// Walk the frame headers, as id3v2 stops parsing at the first malformed one without an error
+	end := HeaderSize + synchsafe(header[6:10])
+	_, paddingStart, err := scanFrameHeaders(r, version, flags, end)
+	if err != nil {
+		return err
+	}
+
+	// Check the padding in chunks, so large tags are not loaded at once
+	chunk := make([]byte, 64<<10)
+	for offset := paddingStart; offset < end; offset += int64(len(chunk)) {
+		data := chunk[:min(int64(len(chunk)), end-offset)]
+		if _, err := r.ReadAt(data, offset); err != nil {
+			return fmt.Errorf("Failed to read ID3v2 tag: %w", err)
+		}
+		if len(bytes.Trim(data, "\x00")) > 0 {
+			return fmt.Errorf("%w: unexpected data in the padding at offset %d", ErrCorruptTag, paddingStart)
+		}
+	}
+	return nil
+}
+
+// validFrameID reports whether id consists of four upper-case letters or digits
+func validFrameID(id []byte) bool {
+	for _, c := range id {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// openTag opens the tag of the MP3 file for editing, rebuilding a corrupt tag first if requested in options
+func openTag(mp3Path string, options Options) (*id3v2.Tag, error) {
+	if options.RebuildTag {
+		if err := rebuildTag(mp3Path); err != nil {
+			return nil, err
+		}
+	} else if err := CheckTag(mp3Path); err != nil {
+		return nil, err
+	}
+	if err := prepareForID3v2(mp3Path); err != nil {
+		return nil, err
+	}
+	return id3v2.Open(mp3Path, id3v2.Options{Parse: true})
+}
+
+// rebuildTag replaces a corrupt tag at the start of the MP3 file with an empty tag spanning up to the first audio frame.
+// Files whose tag can be parsed are left untouched.
+func rebuildTag(mp3Path string) error {
+	if err := CheckTag(mp3Path); !errors.Is(err, ErrCorruptTag) {
+		return nil
+	}
+
+	file, err := os.Open(mp3Path)
+	if err != nil {
+		return fmt.Errorf("Cannot open MP3 file: %w", err)
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("Cannot stat MP3 file: %w", err)
+	}
+	header := make([]byte, HeaderSize)
+	file.ReadAt(header, 0)
+	offset, err := findAudioStart(file, stat.Size(), header)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("Cannot rebuild the corrupt tag: %w", err)
+	}
+
+	// Fill the space of the broken tag with padding, or drop it if not even a tag header fits
+	tag := &RawTag{Version: rebuildVersion(header), Size: offset}
+	if done, err := overwriteTag(mp3Path, tag.Version, offset, nil, 0); done || err != nil {
+		return err
+	}
+	return writeRawTag(mp3Path, mp3Path, tag, nil, 0)
+}
+
+// rebuildTagData returns the MP3 data with a corrupt tag replaced as rebuildTag does for files
+func rebuildTagData(data []byte) ([]byte, error) {
+	if err := checkTagFrom(bytes.NewReader(data), int64(len(data))); !errors.Is(err, ErrCorruptTag) {
+		return data, nil
+	}
+
+	header := make([]byte, HeaderSize)
+	copy(header, data)
+	offset, err := findAudioStart(bytes.NewReader(data), int64(len(data)), header)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot rebuild the corrupt tag: %w", err)
+	}
+	if offset < HeaderSize {
+		return data[offset:], nil
+	}
+	return append(encodeRawTag(rebuildVersion(header), nil, int(offset)-HeaderSize), data[offset:]...), nil
+}
+
+// findAudioStart locates the first audio frame, preferring the end of the tag declared in header
+func findAudioStart(r io.ReaderAt, size int64, header []byte) (int64, error) {
+	if tagSize, ok := TagSize(header); ok && tagSize < size {
+		if offset, err := mp3frame.FindFirstFrame(r, size, tagSize); err == nil && offset == tagSize {
+			return offset, nil
+		}
+	}
+	return mp3frame.FindFirstFrame(r, size, 0)
+}
+
+// rebuildVersion returns the version of the rebuilt tag: that of the broken tag if supported, otherwise 4
+func rebuildVersion(header []byte) byte {
+	if string(header[:3]) == "ID3" && (header[3] == 3 || header[3] == 4) {
+		return header[3]
+	}
+	return 4
+}
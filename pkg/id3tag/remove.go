@@ -0,0 +1,102 @@
+package id3tag
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/prompt"
+)
+
+// ChapterSelection selects the chapters removed by RemoveChapters
+type ChapterSelection struct {
+	Chapters []string      // 1-based numbers in start time order or element IDs
+	From     time.Duration // Start of the range of start times whose chapters are removed
+	To       time.Duration // End of the range (exclusive); 0 selects no range
+}
+
+// RemoveChapters removes the selected CHAP frames of an MP3 file and their entries in CTOC frames,
+// and writes the result to outputPath.
+// A chapter that ended where a removed chapter started is extended to cover the removed chapter.
+// It returns a description of each change.
+func RemoveChapters(mp3Path, outputPath string, selection ChapterSelection, prompter *prompt.Prompter) ([]string, error) {
+	tag, err := ReadRawTag(mp3Path)
+	if err != nil {
+		return nil, err
+	}
+	if tag == nil {
+		return nil, fmt.Errorf("File has no ID3v2 tag")
+	}
+
+	// Select chapters
+	chapters := tag.chapterFrames()
+	removed := make([]bool, len(chapters))
+	for _, selector := range selection.Chapters {
+		position := findChapter(chapters, selector)
+		if position < 0 {
+			return nil, fmt.Errorf("No chapter '%s' found (the file has %d chapters)", selector, len(chapters))
+		}
+		removed[position] = true
+	}
+	if selection.To > 0 {
+		from, to := uint32(selection.From.Milliseconds()), uint32(selection.To.Milliseconds())
+		for i, chapter := range chapters {
+			if chapter.StartTime >= from && chapter.StartTime < to {
+				removed[i] = true
+			}
+		}
+	}
+
+	// Drop chapters, extending the preceding kept chapter over contiguous removed ones
+	var changes []string
+	frames := append([]RawFrame(nil), tag.Frames...)
+	drop := make(map[int]bool)
+	removedIDs := make(map[string]bool)
+	previous := -1
+	for i, chapter := range chapters {
+		if !removed[i] {
+			previous = i
+			continue
+		}
+		drop[chapter.index] = true
+		removedIDs[chapter.ElementID] = true
+		changes = append(changes, fmt.Sprintf("Removed chapter '%s' (%s) at %s", chapter.ElementID, chapter.Title(), formatMillis(chapter.StartTime)))
+
+		if previous >= 0 && chapters[previous].EndTime == chapter.StartTime && chapter.EndTime > chapter.StartTime {
+			chapters[previous].EndTime = chapter.EndTime
+			frames[chapters[previous].index] = RawFrame{ID: "CHAP", Body: chapters[previous].body(tag.Version)}
+			changes = append(changes, fmt.Sprintf("Changed end time of chapter '%s' to %s", chapters[previous].ElementID, formatMillis(chapter.EndTime)))
+		}
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	// Remove the entries of the chapters from the tables of contents, and tables of contents left empty
+	kept := frames[:0]
+	for i, frame := range frames {
+		if drop[i] {
+			continue
+		}
+		if frame.ID == "CTOC" {
+			if toc, ok := parseRawTOC(frame.Body, tag.Version); ok {
+				children := toc.ChildIDs[:0]
+				for _, childID := range toc.ChildIDs {
+					if !removedIDs[childID] {
+						children = append(children, childID)
+					}
+				}
+				if len(children) != len(toc.ChildIDs) {
+					if len(children) == 0 {
+						changes = append(changes, fmt.Sprintf("Removed table of contents '%s' without chapters", toc.ElementID))
+						continue
+					}
+					toc.ChildIDs = children
+					frame = RawFrame{ID: "CTOC", Body: toc.body(tag.Version)}
+				}
+			}
+		}
+		kept = append(kept, frame)
+	}
+
+	return changes, writeEditedTag(mp3Path, outputPath, tag, kept, prompter)
+}
@@ -0,0 +1,238 @@
+package id3tag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/mp3frame"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/prompt"
+	"github.com/bogem/id3v2/v2"
+)
+
+// Repair rebuilds the chapter structure of an MP3 file and writes the result to outputPath.
+// It returns a description of each change; nothing is written if dryRun is set or nothing needs repairing.
+func Repair(mp3Path, outputPath string, dryRun bool, prompter *prompt.Prompter) ([]string, error) {
+	tag, err := ReadRawTag(mp3Path)
+	if err != nil {
+		return nil, err
+	}
+	if tag == nil {
+		return nil, fmt.Errorf("File has no ID3v2 tag")
+	}
+
+	// The audio length is only used to drop and clamp chapters, so files that cannot be scanned are still repaired
+	var duration time.Duration
+	if info, err := mp3frame.ScanFile(mp3Path); err == nil {
+		duration = info.Duration()
+	}
+
+	frames, changes := tag.repairChapters(duration)
+
+	// The rebuilt table of contents lists every chapter, which must fit in a single CTOC frame
+	chapterCount := 0
+	for _, frame := range frames {
+		if frame.ID == "CHAP" {
+			chapterCount++
+		}
+	}
+	if chapterCount > MaxTOCEntries {
+		return nil, fmt.Errorf("Cannot rebuild the table of contents: %d chapters exceed the %d entries of a CTOC frame", chapterCount, MaxTOCEntries)
+	}
+	if len(changes) == 0 || dryRun {
+		return changes, nil
+	}
+
+	return changes, writeEditedTag(mp3Path, outputPath, tag, frames, prompter)
+}
+
+// repairChapters returns the frames of the tag with consistent CHAP frames and a single rebuilt CTOC frame
+func (tag *RawTag) repairChapters(duration time.Duration) ([]RawFrame, []string) {
+	var changes []string
+	change := func(format string, args ...any) {
+		changes = append(changes, fmt.Sprintf(format, args...))
+	}
+
+	// Split chapter frames from the rest, dropping the ones that cannot be parsed
+	var frames []RawFrame
+	var chapters []RawChapter
+	var tocFrames []RawFrame
+	for _, frame := range tag.Frames {
+		switch frame.ID {
+		case "CHAP":
+			chapter, ok := parseRawChapter(frame.Body, tag.Version)
+			if !ok {
+				change("Removed a malformed CHAP frame")
+				continue
+			}
+			chapters = append(chapters, chapter)
+		case "CTOC":
+			tocFrames = append(tocFrames, frame)
+		default:
+			frames = append(frames, frame)
+		}
+	}
+
+	// Drop chapters that start after the audio ends
+	if duration > 0 {
+		kept := chapters[:0]
+		for _, chapter := range chapters {
+			if time.Duration(chapter.StartTime)*time.Millisecond >= duration {
+				change("Removed chapter '%s' starting at %s, beyond the end of the audio", chapter.ElementID, formatMillis(chapter.StartTime))
+				continue
+			}
+			kept = append(kept, chapter)
+		}
+		chapters = kept
+	}
+	if len(chapters) == 0 {
+		if len(tocFrames) > 0 {
+			change("Removed %d CTOC frames without chapters", len(tocFrames))
+		}
+		return frames, changes
+	}
+
+	// Order chapters by start time
+	if !sort.SliceIsSorted(chapters, func(i, j int) bool { return chapters[i].StartTime < chapters[j].StartTime }) {
+		sort.SliceStable(chapters, func(i, j int) bool { return chapters[i].StartTime < chapters[j].StartTime })
+		change("Sorted chapters by start time")
+	}
+
+	// Keep the element ID and titles of the first top-level table of contents
+	toc := RawTOC{ElementID: "toc", TopLevel: true, Ordered: true}
+	for _, frame := range tocFrames {
+		if original, ok := parseRawTOC(frame.Body, tag.Version); ok && original.TopLevel && original.ElementID != "" {
+			toc.ElementID = original.ElementID
+			for _, subframe := range original.Subframes {
+				if subframe.ID == "TIT2" || subframe.ID == "TIT3" {
+					toc.Subframes = append(toc.Subframes, subframe)
+				}
+			}
+			break
+		}
+	}
+
+	// Regenerate empty and duplicate element IDs
+	used := map[string]bool{toc.ElementID: true}
+	for i := range chapters {
+		chapter := &chapters[i]
+		if chapter.ElementID != "" && !used[chapter.ElementID] {
+			used[chapter.ElementID] = true
+			continue
+		}
+		elementID := chapter.ElementID
+		for n := i; ; n++ {
+			chapter.ElementID = fmt.Sprintf("chp%d", n)
+			if !used[chapter.ElementID] {
+				break
+			}
+		}
+		used[chapter.ElementID] = true
+		if elementID == "" {
+			change("Assigned element ID '%s' to the chapter at %s", chapter.ElementID, formatMillis(chapter.StartTime))
+		} else {
+			change("Renamed duplicate element ID '%s' of the chapter at %s to '%s'", elementID, formatMillis(chapter.StartTime), chapter.ElementID)
+		}
+	}
+
+	// End each chapter no later than the next one starts and after it starts itself
+	for i := range chapters {
+		chapter := &chapters[i]
+		end := chapter.EndTime
+		if i+1 < len(chapters) {
+			next := chapters[i+1].StartTime
+			if end <= chapter.StartTime || end > next {
+				end = next
+			}
+		} else if limit := uint32(duration.Milliseconds()); duration > 0 && (end <= chapter.StartTime || end > limit) {
+			end = limit
+		}
+		if end != chapter.EndTime && end > chapter.StartTime {
+			change("Changed end time of chapter '%s' from %s to %s", chapter.ElementID, formatMillis(chapter.EndTime), formatMillis(end))
+			chapter.EndTime = end
+		}
+	}
+
+	// Re-encode titles in encodings the tag version does not allow
+	for i := range chapters {
+		chapters[i].Subframes = repairTextSubframes(tag.Version, chapters[i].ElementID, chapters[i].Subframes, change)
+	}
+	toc.Subframes = repairTextSubframes(tag.Version, toc.ElementID, toc.Subframes, change)
+
+	// Rebuild the table of contents
+	for _, chapter := range chapters {
+		toc.ChildIDs = append(toc.ChildIDs, chapter.ElementID)
+		frames = append(frames, RawFrame{ID: "CHAP", Body: chapter.body(tag.Version)})
+	}
+	tocFrame := RawFrame{ID: "CTOC", Body: toc.body(tag.Version)}
+	switch {
+	case len(tocFrames) == 0:
+		change("Added a table of contents with %d chapters", len(chapters))
+	case len(tocFrames) > 1:
+		change("Replaced %d CTOC frames with a single table of contents with %d chapters", len(tocFrames), len(chapters))
+	case !bytes.Equal(tocFrames[0].Body, tocFrame.Body):
+		change("Rebuilt the table of contents with %d chapters", len(chapters))
+	}
+	frames = append(frames, tocFrame)
+
+	return frames, changes
+}
+
+// repairTextSubframes re-encodes TIT2 and TIT3 subframes whose encoding is invalid for the tag version
+func repairTextSubframes(version byte, elementID string, subframes []RawFrame, change func(string, ...any)) []RawFrame {
+	encoding := id3v2.EncodingUTF8
+	if version == 3 {
+		encoding = id3v2.EncodingUTF16
+	}
+
+	repaired := make([]RawFrame, 0, len(subframes))
+	for _, subframe := range subframes {
+		if (subframe.ID == "TIT2" || subframe.ID == "TIT3") && lintText(version, elementID, subframe) != nil {
+			text := decodeTextFrame(subframe.Body)
+			subframe = RawFrame{ID: subframe.ID, Body: encodeTextFrame(&id3v2.TextFrame{Encoding: encoding, Text: text})}
+			change("Re-encoded %s of '%s' as %s", subframe.ID, elementID, encodingNames[encoding.Key])
+		}
+		repaired = append(repaired, subframe)
+	}
+	return repaired
+}
+
+// body encodes the chapter as a CHAP frame body
+func (chapter RawChapter) body(version byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(chapter.ElementID)
+	buf.WriteByte(0)
+	binary.Write(&buf, binary.BigEndian, [4]uint32{chapter.StartTime, chapter.EndTime, chapter.StartOffset, chapter.EndOffset})
+	for _, subframe := range chapter.Subframes {
+		writeSubframeHeader(&buf, subframe.ID, len(subframe.Body), version)
+		buf.Write(subframe.Body)
+	}
+	return buf.Bytes()
+}
+
+// body encodes the table of contents as a CTOC frame body
+func (toc RawTOC) body(version byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(toc.ElementID)
+	buf.WriteByte(0)
+
+	var flags byte
+	if toc.TopLevel {
+		flags |= 1
+	}
+	if toc.Ordered {
+		flags |= 2
+	}
+	buf.Write([]byte{flags, byte(len(toc.ChildIDs))})
+	for _, childID := range toc.ChildIDs {
+		buf.WriteString(childID)
+		buf.WriteByte(0)
+	}
+	for _, subframe := range toc.Subframes {
+		writeSubframeHeader(&buf, subframe.ID, len(subframe.Body), version)
+		buf.Write(subframe.Body)
+	}
+	return buf.Bytes()
+}
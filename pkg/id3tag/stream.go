@@ -0,0 +1,319 @@
+package id3tag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/safefile"
+	"github.com/bogem/id3v2/v2"
+)
+
+// StreamTagSize is the size of an existing tag from which its large untouched frames, such as cover art,
+// are copied from the input to the output while writing instead of being loaded into memory
+const StreamTagSize = 8 << 20
+
+// streamFrameSize is the smallest frame body copied from the input; smaller frames are loaded and parsed as usual
+const streamFrameSize = 256 << 10
+
+// rewrittenFrameIDs are frames the writer replaces or edits, which are always loaded
+var rewrittenFrameIDs = []string{"CHAP", "CTOC", "USLT", "SYLT", "TXXX"}
+
+// maxTagDataSize is the largest size of the frames and padding of a tag, as the synchsafe size field holds 28 bits
+const maxTagDataSize = 1<<28 - 1
+
+// frameHeader is the header of a frame of a tag in a file
+type frameHeader struct {
+	ID     string
+	Flags  uint16
+	Offset int64 // Offset of the frame header in the file
+	Size   int64 // Size of the body
+}
+
+// scanFrameHeaders reads the frame headers of an ID3v2 tag with the given version and header flags, whose frame data ends at end,
+// without reading the frame bodies. It returns the headers and the offset of the padding after the last frame;
+// a malformed frame header is reported as an error wrapping ErrCorruptTag.
+func scanFrameHeaders(r io.ReaderAt, version, flags byte, end int64) ([]frameHeader, int64, error) {
+	offset := int64(HeaderSize)
+
+	// Skip extended header
+	if flags&0x40 != 0 && end-offset >= 4 {
+		size := make([]byte, 4)
+		if _, err := r.ReadAt(size, offset); err != nil {
+			return nil, 0, fmt.Errorf("Failed to read ID3v2 tag: %w", err)
+		}
+		if version == 4 {
+			offset += min(synchsafe(size), end-offset)
+		} else {
+			offset += min(4+int64(binary.BigEndian.Uint32(size)), end-offset)
+		}
+	}
+
+	var headers []frameHeader
+	header := make([]byte, 10)
+	for end-offset >= 10 {
+		if _, err := r.ReadAt(header, offset); err != nil {
+			return nil, 0, fmt.Errorf("Failed to read ID3v2 tag: %w", err)
+		}
+		if header[0] == 0 {
+			break // Padding
+		}
+		var size int64
+		if version == 4 {
+			size = synchsafe(header[4:8])
+		} else {
+			size = int64(binary.BigEndian.Uint32(header[4:8]))
+		}
+		if !validFrameID(header[:4]) || size > end-offset-10 {
+			return nil, 0, fmt.Errorf("%w: malformed frame at offset %d", ErrCorruptTag, offset)
+		}
+		headers = append(headers, frameHeader{ID: string(header[:4]), Flags: binary.BigEndian.Uint16(header[8:10]), Offset: offset, Size: size})
+		offset += 10 + size
+	}
+	return headers, offset, nil
+}
+
+// streamedFrame is a frame of a tag being written: loaded into Body, or copied from the input at Offset
+type streamedFrame struct {
+	ID     string
+	Body   []byte // Loaded body (nil for a frame copied from the input)
+	Offset int64  // Offset of the body in the input
+	Size   int64  // Size of the body
+}
+
+// streamPlan is the tag written over a large existing tag, whose large untouched frames stay in the input until they are copied
+type streamPlan struct {
+	version byte
+	oldSize int64 // Size of the existing tag including the header
+	frames  []streamedFrame
+	padding int
+}
+
+// planStream plans the tag with chapters and the optional content in options for the MP3 file in input,
+// loading only the small frames of the existing tag and the frames rewritten anyway.
+// It returns nil if the existing tag is smaller than StreamTagSize, has no large untouched frames or cannot be streamed,
+// e.g. because it is corrupt, unsynchronised or converted to another version; such tags are written as usual.
+func planStream(input *os.File, mp3Path string, markers []csvparser.MarkerEntry, options Options) (*streamPlan, error) {
+	header := make([]byte, HeaderSize)
+	if _, err := input.ReadAt(header, 0); err != nil {
+		return nil, nil
+	}
+	tagSize, ok := TagSize(header)
+	version, flags := header[3], header[5]
+	if !ok || tagSize < StreamTagSize || (version != 3 && version != 4) || flags&0x80 != 0 {
+		return nil, nil
+	}
+	if options.RebuildTag || (options.Version != 0 && options.Version != version) {
+		return nil, nil
+	}
+	stat, err := input.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("Cannot stat MP3 file: %w", err)
+	}
+	if checkTagFrom(input, stat.Size()) != nil {
+		return nil, nil // Reported or rebuilt when the tag is opened
+	}
+	headers, _, err := scanFrameHeaders(input, version, flags, HeaderSize+synchsafe(header[6:10]))
+	if err != nil {
+		return nil, err
+	}
+
+	// Load the frames the tag is built from and leave the large untouched ones in the input
+	var loaded []RawFrame
+	var copied []streamedFrame
+	for _, frame := range headers {
+		if frame.Flags&0xFF != 0 {
+			return nil, nil // Compressed, encrypted or unsynchronised frames are left to id3v2
+		}
+		passThrough := frame.Size >= streamFrameSize && !slices.Contains(rewrittenFrameIDs, frame.ID) &&
+			!(options.Deterministic && slices.Contains(noiseFrameIDs, frame.ID))
+		if passThrough {
+			copied = append(copied, streamedFrame{ID: frame.ID, Offset: frame.Offset + 10, Size: frame.Size})
+			continue
+		}
+		body := make([]byte, frame.Size)
+		if _, err := input.ReadAt(body, frame.Offset+10); err != nil {
+			return nil, fmt.Errorf("Failed to read ID3v2 tag: %w", err)
+		}
+		loaded = append(loaded, RawFrame{ID: frame.ID, Flags: frame.Flags, Body: body})
+	}
+	if len(copied) == 0 {
+		return nil, nil
+	}
+
+	// Build the rest of the tag with id3v2 from the loaded frames, as for smaller tags
+	raw := &RawTag{Version: version, Frames: loaded}
+	var existing []RawChapter
+	if options.MergeChapters {
+		existing = options.keptChapters(raw.Chapters())
+	}
+	if raw.hasExtendedChapters() {
+		loaded = slices.DeleteFunc(loaded, func(frame RawFrame) bool { return frame.ID == "CHAP" || frame.ID == "CTOC" })
+	}
+	tag, err := id3v2.ParseReader(bytes.NewReader(encodeRawTag(version, loaded, 0)), id3v2.Options{Parse: true})
+	if err != nil {
+		return nil, fmt.Errorf("Cannot parse MP3 data: %w", err)
+	}
+	if err := addChapterFrames(tag, scanFile(mp3Path), markers, existing, options); err != nil {
+		return nil, err
+	}
+	addOptionalFrames(tag, options)
+	if err := addTrailingFrames(tag, readTrailingFile(mp3Path), options); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if _, err := tag.WriteTo(&buf); err != nil {
+		return nil, fmt.Errorf("Failed to write tags: %w", err)
+	}
+	built, err := ReadRawTagFrom(&buf)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to reread tags: %v", err)
+	}
+
+	plan := &streamPlan{version: version, oldSize: tagSize, padding: options.padding()}
+	if built != nil {
+		for _, frame := range options.finalFrames(built) {
+			plan.frames = append(plan.frames, streamedFrame{ID: frame.ID, Body: frame.Body, Size: int64(len(frame.Body))})
+		}
+	}
+	plan.frames = append(plan.frames, copied...)
+	if options.Deterministic {
+		sort.SliceStable(plan.frames, func(i, j int) bool { return plan.frames[i].ID < plan.frames[j].ID })
+	}
+	if size := plan.size() - HeaderSize; size > maxTagDataSize {
+		return nil, fmt.Errorf("New ID3v2 tag of %d bytes exceeds the largest possible tag of %d bytes", size+HeaderSize, maxTagDataSize+HeaderSize)
+	}
+	return plan, nil
+}
+
+// size returns the size of the planned tag including the header
+func (plan *streamPlan) size() int64 {
+	size := int64(HeaderSize + plan.padding)
+	for _, frame := range plan.frames {
+		size += 10 + frame.Size
+	}
+	return size
+}
+
+// copiedFrames returns the number of frames copied from the input
+func (plan *streamPlan) copiedFrames() int {
+	n := 0
+	for _, frame := range plan.frames {
+		if frame.Body == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// writeTo writes the planned tag followed by the audio data of input, copying the untouched frames from input
+func (plan *streamPlan) writeTo(w io.Writer, input io.ReaderAt, inputSize int64) error {
+	dataSize := plan.size() - HeaderSize
+	var buf bytes.Buffer
+	buf.Write([]byte{'I', 'D', '3', plan.version, 0, 0, byte(dataSize>>21) & 0x7F, byte(dataSize>>14) & 0x7F, byte(dataSize>>7) & 0x7F, byte(dataSize) & 0x7F})
+	for _, frame := range plan.frames {
+		writeSubframeHeader(&buf, frame.ID, int(frame.Size), plan.version)
+		if frame.Body != nil {
+			buf.Write(frame.Body)
+			continue
+		}
+		if _, err := buf.WriteTo(w); err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, io.NewSectionReader(input, frame.Offset, frame.Size)); err != nil {
+			return err
+		}
+	}
+	buf.Write(make([]byte, plan.padding))
+	if _, err := buf.WriteTo(w); err != nil {
+		return err
+	}
+	_, err := io.Copy(w, io.NewSectionReader(input, plan.oldSize, inputSize-plan.oldSize))
+	return err
+}
+
+// streamChapters writes the MP3 file with chapters to outputPath, which may be the MP3 file itself, if its existing tag is large
+// enough to stream, copying the large untouched frames without loading them. It reports whether it wrote the file.
+func streamChapters(mp3Path string, markers []csvparser.MarkerEntry, outputPath string, options Options) (bool, error) {
+	input, err := os.Open(mp3Path)
+	if err != nil {
+		return false, fmt.Errorf("Cannot open input file: %w", err)
+	}
+	defer input.Close()
+	plan, err := planStream(input, mp3Path, markers, options)
+	if err != nil || plan == nil {
+		return false, err
+	}
+	stat, err := input.Stat()
+	if err != nil {
+		return false, fmt.Errorf("Cannot stat MP3 file: %w", err)
+	}
+
+	span := options.Trace.Start("tag-save")
+	defer span.End()
+	span.SetAttribute("copied_frames", plan.copiedFrames())
+	err = writeStreamed(plan, input, stat.Size(), outputPath, options)
+	span.RecordError(err)
+	return true, err
+}
+
+// writeStreamed writes the planned tag and the audio data of input to a temporary file that replaces outputPath
+func writeStreamed(plan *streamPlan, input *os.File, inputSize int64, outputPath string, options Options) error {
+	tempPath, err := safefile.TempPath(outputPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempPath)
+	output, err := os.Create(tempPath)
+	if err != nil {
+		return fmt.Errorf("Failed to create temporary file: %w", err)
+	}
+	err = plan.writeTo(output, input, inputSize)
+	if closeErr := output.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to save tags: %w", err)
+	}
+	if err := removeTrailingTags(tempPath, options); err != nil {
+		return err
+	}
+
+	input.Close() // The input may be replaced
+	if err := safefile.Replace(tempPath, outputPath); err != nil {
+		return fmt.Errorf("Failed to create final file: %w", err)
+	}
+	return nil
+}
+
+// previewStream returns the tag size preview of an MP3 file whose existing tag is streamed when written, or nil if it is not
+func previewStream(mp3Path string, markers []csvparser.MarkerEntry, options Options) (*TagSizePreview, error) {
+	input, err := os.Open(mp3Path)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read MP3 file: %w", err)
+	}
+	defer input.Close()
+	plan, err := planStream(input, mp3Path, markers, options)
+	if err != nil || plan == nil {
+		return nil, err
+	}
+	stat, err := input.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("Cannot stat MP3 file: %w", err)
+	}
+
+	audioSize := stat.Size() - plan.oldSize
+	if options.trailingMode() != TrailingKeep {
+		trailing, err := ReadTrailingTagsFrom(input, stat.Size())
+		if err != nil {
+			return nil, err
+		}
+		audioSize -= stat.Size() - trailing.Start
+	}
+	return &TagSizePreview{OldTagSize: plan.oldSize, NewTagSize: plan.size(), FileSize: plan.size() + audioSize}, nil
+}
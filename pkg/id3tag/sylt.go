@@ -0,0 +1,86 @@
+package id3tag
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/bogem/id3v2/v2"
+)
+
+// SyncedText is one line of synchronized text
+type SyncedText struct {
+	Text string        // Text shown from Time on
+	Time time.Duration // Time at which the text starts
+}
+
+// SYLTFrame implements the ID3v2 Synchronised lyrics/text frame (SYLT)
+// Timestamps are written in milliseconds
+type SYLTFrame struct {
+	Encoding          id3v2.Encoding // Text encoding (UTF-8 if unset)
+	Language          string         // ISO 639-2 language code (3 characters)
+	ContentType       byte           // Content type (1 = lyrics, 2 = text transcription)
+	ContentDescriptor string         // Optional description of the content
+	Lines             []SyncedText   // Lines in time order
+}
+
+// SYLT content types used by this package
+const (
+	SYLTContentLyrics        = 1
+	SYLTContentTranscription = 2
+)
+
+// Size returns the size of the frame
+func (sf SYLTFrame) Size() int {
+	// Encoding, language, timestamp format and content type
+	size := 1 + 3 + 1 + 1
+	size += len(encodeString(sf.encoding(), sf.ContentDescriptor)) // Terminated descriptor
+
+	// Each line is terminated text followed by a 4-byte timestamp
+	for _, line := range sf.Lines {
+		size += len(encodeString(sf.encoding(), line.Text)) + 4
+	}
+
+	return size
+}
+
+// UniqueIdentifier returns the language and content descriptor
+func (sf SYLTFrame) UniqueIdentifier() string {
+	return sf.Language + sf.ContentDescriptor
+}
+
+// WriteTo writes the frame to a writer
+func (sf SYLTFrame) WriteTo(w io.Writer) (int64, error) {
+	buf := make([]byte, 0, sf.Size())
+
+	// Header: encoding, language, absolute milliseconds, content type, descriptor
+	buf = append(buf, sf.encoding().Key)
+	buf = append(buf, padLanguage(sf.Language)...)
+	buf = append(buf, 2, sf.ContentType)
+	buf = append(buf, encodeString(sf.encoding(), sf.ContentDescriptor)...)
+
+	// Synchronized lines
+	for _, line := range sf.Lines {
+		buf = append(buf, encodeString(sf.encoding(), line.Text)...)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(line.Time.Milliseconds()))
+	}
+
+	written, err := w.Write(buf)
+	return int64(written), err
+}
+
+// encoding returns the text encoding, defaulting to UTF-8
+func (sf SYLTFrame) encoding() id3v2.Encoding {
+	if sf.Encoding.TerminationBytes == nil {
+		return id3v2.EncodingUTF8
+	}
+	return sf.Encoding
+}
+
+// padLanguage returns a 3-byte language code, defaulting to "und"
+func padLanguage(language string) string {
+	if len(language) != 3 {
+		return "und"
+	}
+	return language
+}
@@ -0,0 +1,348 @@
+package id3tag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/bogem/id3v2/v2"
+)
+
+// Handling of trailing ID3v1 and APEv2 tags in Options.TrailingTags
+const (
+	TrailingKeep    = "keep"    // Leave trailing tags in place (default)
+	TrailingStrip   = "strip"   // Remove trailing tags
+	TrailingUpgrade = "upgrade" // Copy their fields into the ID3v2 tag where missing, then remove them
+)
+
+// ID3v1Tag is a trailing ID3v1 or ID3v1.1 tag
+type ID3v1Tag struct {
+	Title   string // Song title
+	Artist  string // Artist
+	Album   string // Album
+	Year    string // Year
+	Comment string // Comment
+	Track   int    // Track number (ID3v1.1 only, 0 if none)
+	Genre   int    // Genre index (255 if none)
+	Offset  int64  // Offset of the tag in the file
+}
+
+// APEItem is an item of an APEv2 tag
+type APEItem struct {
+	Key    string // Item key, e.g. "Title"
+	Value  string // UTF-8 value; empty for binary items
+	Binary bool   // Whether the item holds binary data or an external reference
+}
+
+// APETag is a trailing APEv1 or APEv2 tag
+type APETag struct {
+	Version int       // 1000 for APEv1, 2000 for APEv2
+	Offset  int64     // Offset of the tag in the file
+	Size    int64     // Size in bytes including header and footer
+	Items   []APEItem // Items in tag order
+}
+
+// TrailingTags are the tags stored after the audio data of an MP3 file
+type TrailingTags struct {
+	ID3v1 *ID3v1Tag // ID3v1 tag (nil if none)
+	APE   *APETag   // APE tag (nil if none)
+	Start int64     // Offset where the trailing tags start (the file size if there are none)
+}
+
+// Empty reports whether there are no trailing tags
+func (tags *TrailingTags) Empty() bool {
+	return tags.ID3v1 == nil && tags.APE == nil
+}
+
+// Names returns the names of the trailing tags, e.g. "ID3v1.1" and "APEv2"
+func (tags *TrailingTags) Names() []string {
+	var names []string
+	if tags.APE != nil {
+		names = append(names, fmt.Sprintf("APEv%d", tags.APE.Version/1000))
+	}
+	if tags.ID3v1 != nil {
+		if tags.ID3v1.Track > 0 {
+			names = append(names, "ID3v1.1")
+		} else {
+			names = append(names, "ID3v1")
+		}
+	}
+	return names
+}
+
+// ParseTrailingMode validates the name of a trailing tag handling mode
+func ParseTrailingMode(name string) (string, error) {
+	switch strings.ToLower(name) {
+	case "", TrailingKeep:
+		return TrailingKeep, nil
+	case TrailingStrip, TrailingUpgrade:
+		return strings.ToLower(name), nil
+	}
+	return "", fmt.Errorf("Unknown trailing tag mode '%s' (available: keep, strip, upgrade)", name)
+}
+
+// ReadTrailingTags reads the ID3v1 and APE tags at the end of an MP3 file
+func ReadTrailingTags(mp3Path string) (*TrailingTags, error) {
+	file, err := os.Open(mp3Path)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot open MP3 file: %w", err)
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("Cannot stat MP3 file: %w", err)
+	}
+	return ReadTrailingTagsFrom(file, stat.Size())
+}
+
+// ReadTrailingTagsFrom reads the ID3v1 and APE tags at the end of an MP3 stream of the given size
+func ReadTrailingTagsFrom(r io.ReaderAt, size int64) (*TrailingTags, error) {
+	tags := &TrailingTags{Start: size}
+
+	// An APE tag may follow the ID3v1 tag, but usually precedes it
+	if err := tags.readAPE(r); err != nil {
+		return nil, err
+	}
+	if size := tags.Start; size >= 128 {
+		block := make([]byte, 128)
+		if _, err := r.ReadAt(block, size-128); err != nil {
+			return nil, fmt.Errorf("Failed to read ID3v1 tag: %w", err)
+		}
+		if string(block[:3]) == "TAG" {
+			tags.ID3v1 = parseID3v1(block)
+			tags.ID3v1.Offset = size - 128
+			tags.Start -= 128
+		}
+	}
+	if tags.APE == nil {
+		if err := tags.readAPE(r); err != nil {
+			return nil, err
+		}
+	}
+	return tags, nil
+}
+
+// readAPE reads an APE tag ending at tags.Start, if there is one
+func (tags *TrailingTags) readAPE(r io.ReaderAt) error {
+	if tags.Start < 32 {
+		return nil
+	}
+	footer := make([]byte, 32)
+	if _, err := r.ReadAt(footer, tags.Start-32); err != nil {
+		return fmt.Errorf("Failed to read APE tag: %w", err)
+	}
+	if !bytes.HasPrefix(footer, []byte("APETAGEX")) {
+		return nil
+	}
+
+	version := int(binary.LittleEndian.Uint32(footer[8:12]))
+	itemsSize := int64(binary.LittleEndian.Uint32(footer[12:16])) // Items and footer
+	count := int(binary.LittleEndian.Uint32(footer[16:20]))
+	flags := binary.LittleEndian.Uint32(footer[20:24])
+	size := itemsSize
+	if flags&0x80000000 != 0 {
+		size += 32 // Header present
+	}
+	if itemsSize < 32 || size > tags.Start {
+		return nil // Malformed, treat as audio data
+	}
+
+	items := make([]byte, itemsSize-32)
+	if _, err := r.ReadAt(items, tags.Start-itemsSize); err != nil {
+		return fmt.Errorf("Failed to read APE tag: %w", err)
+	}
+	tags.APE = &APETag{Version: version, Offset: tags.Start - size, Size: size, Items: parseAPEItems(items, count)}
+	tags.Start -= size
+	return nil
+}
+
+// parseAPEItems decodes the items of an APE tag, stopping at malformed data
+func parseAPEItems(data []byte, count int) []APEItem {
+	var items []APEItem
+	for i := 0; i < count && len(data) >= 9; i++ {
+		valueSize := int(binary.LittleEndian.Uint32(data[:4]))
+		flags := binary.LittleEndian.Uint32(data[4:8])
+		keyEnd := bytes.IndexByte(data[8:], 0)
+		if keyEnd < 0 || 8+keyEnd+1+valueSize > len(data) || valueSize < 0 {
+			break
+		}
+		item := APEItem{Key: string(data[8 : 8+keyEnd]), Binary: flags&0x06 != 0}
+		value := data[8+keyEnd+1 : 8+keyEnd+1+valueSize]
+		if !item.Binary {
+			item.Value = string(value)
+		}
+		items = append(items, item)
+		data = data[8+keyEnd+1+valueSize:]
+	}
+	return items
+}
+
+// Value returns the text value of the item with the given key, compared case-insensitively
+func (tag *APETag) Value(key string) string {
+	for _, item := range tag.Items {
+		if strings.EqualFold(item.Key, key) && !item.Binary {
+			return item.Value
+		}
+	}
+	return ""
+}
+
+// parseID3v1 decodes a 128-byte ID3v1 tag
+func parseID3v1(block []byte) *ID3v1Tag {
+	tag := &ID3v1Tag{
+		Title:   latin1Field(block[3:33]),
+		Artist:  latin1Field(block[33:63]),
+		Album:   latin1Field(block[63:93]),
+		Year:    latin1Field(block[93:97]),
+		Comment: latin1Field(block[97:127]),
+		Genre:   int(block[127]),
+	}
+
+	// ID3v1.1 stores the track number in the last byte of the comment after a zero byte
+	if block[125] == 0 && block[126] != 0 {
+		tag.Comment = latin1Field(block[97:125])
+		tag.Track = int(block[126])
+	}
+	return tag
+}
+
+// latin1Field decodes a zero- or space-padded ISO-8859-1 field
+func latin1Field(field []byte) string {
+	if end := bytes.IndexByte(field, 0); end >= 0 {
+		field = field[:end]
+	}
+	runes := make([]rune, len(field))
+	for i, b := range field {
+		runes[i] = rune(b)
+	}
+	return strings.TrimRight(string(runes), " ")
+}
+
+// trailingMode returns the trailing tag handling mode of the options, validated by addChapterFrames
+func (options Options) trailingMode() string {
+	mode, _ := ParseTrailingMode(options.TrailingTags)
+	return mode
+}
+
+// readTrailingFile returns a function reading the trailing tags of the MP3 file at path, called only when upgrading
+func readTrailingFile(path string) func() (*TrailingTags, error) {
+	return func() (*TrailingTags, error) {
+		return ReadTrailingTags(path)
+	}
+}
+
+// addTrailingFrames copies the fields of the trailing tags returned by read into tag if upgrading is requested in options
+func addTrailingFrames(tag *id3v2.Tag, read func() (*TrailingTags, error), options Options) error {
+	if options.trailingMode() != TrailingUpgrade {
+		return nil
+	}
+	trailing, err := read()
+	if err != nil {
+		return err
+	}
+	encoding, _ := ParseEncoding(options.Encoding) // Validated by addChapterFrames
+	upgradeTrailingTags(tag, trailing, encoding)
+	return nil
+}
+
+// removeTrailingTags removes the trailing tags from the end of the MP3 file unless they are kept according to options
+func removeTrailingTags(mp3Path string, options Options) error {
+	if options.trailingMode() == TrailingKeep {
+		return nil
+	}
+	tags, err := ReadTrailingTags(mp3Path)
+	if err != nil || tags.Empty() {
+		return err
+	}
+	if err := os.Truncate(mp3Path, tags.Start); err != nil {
+		return fmt.Errorf("Failed to remove trailing tags: %w", err)
+	}
+	return nil
+}
+
+// upgradeTrailingTags copies the fields of the trailing tags into tag where it has no such frame yet.
+// APE values take precedence over ID3v1 values, which are limited to 30 characters.
+func upgradeTrailingTags(tag *id3v2.Tag, trailing *TrailingTags, encoding id3v2.Encoding) {
+	fields := map[string]string{}
+	var order []string
+	set := func(id, value string) {
+		value = strings.TrimSpace(value)
+		if _, ok := fields[id]; !ok && value != "" {
+			fields[id] = value
+			order = append(order, id)
+		}
+	}
+
+	// Collect values by ID3v2 frame ID
+	yearID := "TDRC"
+	if tag.Version() == 3 {
+		yearID = "TYER"
+	}
+	if ape := trailing.APE; ape != nil {
+		set("TIT2", ape.Value("Title"))
+		set("TPE1", ape.Value("Artist"))
+		set("TALB", ape.Value("Album"))
+		set("TPE2", ape.Value("Album Artist"))
+		set("TCOM", ape.Value("Composer"))
+		set(yearID, ape.Value("Year"))
+		set("TRCK", ape.Value("Track"))
+		set("TCON", ape.Value("Genre"))
+		set("COMM", ape.Value("Comment"))
+	}
+	if v1 := trailing.ID3v1; v1 != nil {
+		set("TIT2", v1.Title)
+		set("TPE1", v1.Artist)
+		set("TALB", v1.Album)
+		set(yearID, v1.Year)
+		set("COMM", v1.Comment)
+		if v1.Track > 0 {
+			set("TRCK", strconv.Itoa(v1.Track))
+		}
+		if v1.Genre != 255 {
+			if tag.Version() == 3 {
+				set("TCON", fmt.Sprintf("(%d)", v1.Genre))
+			} else {
+				set("TCON", strconv.Itoa(v1.Genre))
+			}
+		}
+	}
+
+	// Add frames missing from the ID3v2 tag
+	for _, id := range order {
+		if len(tag.GetFrames(id)) > 0 {
+			continue
+		}
+		if id == "COMM" {
+			body := append([]byte{encoding.Key}, "eng"...)
+			body = append(body, encodeString(encoding, "")...)
+			body = append(body, encodeString(encoding, fields[id])...)
+			tag.AddFrame(id, bodyFrame(body))
+			continue
+		}
+		tag.AddFrame(id, bodyFrame(encodeTextFrame(&id3v2.TextFrame{Encoding: encoding, Text: fields[id]})))
+	}
+}
+
+// bodyFrame is a frame written from an already encoded body
+type bodyFrame []byte
+
+// Size returns the size of the body
+func (frame bodyFrame) Size() int {
+	return len(frame)
+}
+
+// UniqueIdentifier returns an empty string, as the frame IDs used here occur once per tag
+func (frame bodyFrame) UniqueIdentifier() string {
+	return ""
+}
+
+// WriteTo writes the body to a writer
+func (frame bodyFrame) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(frame)
+	return int64(n), err
+}
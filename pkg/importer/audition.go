@@ -0,0 +1,18 @@
+package importer
+
+import (
+	"io"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+func init() {
+	register(Format{
+		Name:        "audition",
+		Extensions:  []string{".csv", ".txt"},
+		Description: "Adobe Audition marker CSV (tab-delimited)",
+		Read: func(r io.Reader, _ Options) ([]csvparser.MarkerEntry, error) {
+			return csvparser.ParseAuditionCSVReader(r)
+		},
+	})
+}
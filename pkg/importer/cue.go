@@ -0,0 +1,77 @@
+package importer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+func init() {
+	register(Format{
+		Name:        "cue",
+		Extensions:  []string{".cue"},
+		Description: "CUE sheet, one chapter per track",
+		Read:        readCue,
+	})
+}
+
+// readCue reads the tracks of a CUE sheet as chapters, using the INDEX 01 position of each track
+func readCue(r io.Reader, _ Options) ([]csvparser.MarkerEntry, error) {
+	var markers []csvparser.MarkerEntry
+	var title string
+	inTrack := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		keyword, rest, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(keyword) {
+		case "TRACK":
+			inTrack, title = true, ""
+		case "TITLE":
+			if inTrack {
+				title = strings.Trim(strings.TrimSpace(rest), `"`)
+			}
+		case "INDEX":
+			fields := strings.Fields(rest)
+			if !inTrack || len(fields) != 2 || fields[0] != "01" {
+				continue
+			}
+			start, err := parseCueTime(fields[1])
+			if err != nil {
+				return nil, err
+			}
+			markers = append(markers, csvparser.MarkerEntry{Name: title, StartTime: start})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to read CUE sheet: %w", err)
+	}
+
+	return markers, nil
+}
+
+// parseCueTime parses a CUE sheet position MM:SS:FF with 75 frames per second
+func parseCueTime(text string) (time.Duration, error) {
+	fields := strings.Split(text, ":")
+	if len(fields) != 3 {
+		return 0, fmt.Errorf("Invalid CUE position '%s'", text)
+	}
+
+	var values [3]int
+	for i, field := range fields {
+		value, err := strconv.Atoi(field)
+		if err != nil || value < 0 {
+			return 0, fmt.Errorf("Invalid CUE position '%s'", text)
+		}
+		values[i] = value
+	}
+	frames := (values[0]*60+values[1])*75 + values[2]
+	return (time.Duration(frames) * time.Second / 75).Round(time.Millisecond), nil
+}
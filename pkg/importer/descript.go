@@ -0,0 +1,72 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+func init() {
+	register(Format{
+		Name:        "descript",
+		Extensions:  []string{".txt"},
+		Description: "Descript composition markers copied or exported as text, one \"[HH:]MM:SS Title\" line per marker",
+		Read:        readDescript,
+		Match:       matchDescript,
+	})
+}
+
+// timestampLine matches a line starting with a timestamp and followed by a title, e.g. "00:12:30 Interview", "[12:30] - Interview" or "1:02:03.5: Outro"
+var timestampLine = regexp.MustCompile(`^\[?((?:\d+:)?\d{1,2}:\d{2}(?:[.,]\d+)?)\]?(?:\s*[-–—|:]\s*|\s+)(.*)$`)
+
+// matchDescript reports whether a file has a line starting with a timestamp and no tab-separated header row
+func matchDescript(head []byte) bool {
+	lines := bytes.Split(bytes.TrimPrefix(head, []byte("\ufeff")), []byte("\n"))
+	if bytes.Contains(lines[0], []byte("\t")) {
+		return false
+	}
+	for _, line := range lines {
+		if timestampLine.Match(bytes.TrimSpace(line)) {
+			return true
+		}
+	}
+	return false
+}
+
+// readDescript reads the markers of a Descript composition as chapters.
+// Lines without a leading timestamp, such as the composition name, are ignored.
+func readDescript(r io.Reader, _ Options) ([]csvparser.MarkerEntry, error) {
+	markers := []csvparser.MarkerEntry{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		start, title, ok := parseTimestampLine(line)
+		if !ok {
+			continue
+		}
+		markers = append(markers, csvparser.MarkerEntry{Name: title, StartTime: start})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to read Descript markers: %w", err)
+	}
+	return markers, nil
+}
+
+// parseTimestampLine returns the time and title of a line matching timestampLine
+func parseTimestampLine(line string) (time.Duration, string, bool) {
+	match := timestampLine.FindStringSubmatch(line)
+	if match == nil {
+		return 0, "", false
+	}
+	start, err := csvparser.ParseTime(strings.Replace(match[1], ",", ".", 1))
+	if err != nil {
+		return 0, "", false
+	}
+	return start, strings.TrimSpace(match[2]), true
+}
@@ -0,0 +1,115 @@
+package importer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+func init() {
+	register(Format{
+		Name:        "edl",
+		Extensions:  []string{".edl"},
+		Description: "CMX 3600 EDL, including DaVinci Resolve timeline marker exports, one chapter per event",
+		Read:        readEDL,
+	})
+}
+
+// edlEvent is an EDL event read as a chapter, with the marker color and name or clip name of its comment lines
+type edlEvent struct {
+	start time.Duration
+	color string
+	name  string
+	clip  string
+}
+
+// readEDL reads the events of an EDL as chapters, using the record in timecode of each event.
+// Resolve marker exports give the marker color and name in a "|C:ResolveColorBlue |M:Name" line; plain EDLs are titled with the clip name.
+// Times are relative to the whole hour the first event is in, as timelines start at 01:00:00:00 by default.
+// The frame rate is not stored in EDLs and is taken from options; "FCM: DROP FRAME" switches 30 and 60 fps to drop-frame timecode.
+func readEDL(r io.Reader, options Options) ([]csvparser.MarkerEntry, error) {
+	format := options.TimeFormat
+	var events []edlEvent
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		fields := strings.Fields(line)
+
+		switch {
+		case strings.HasPrefix(strings.ToUpper(line), "FCM:"):
+			if strings.Contains(strings.ToUpper(line), "NON-DROP") || format.FrameRate%30 != 0 {
+				continue
+			}
+			format.Pulldown, format.DropFrame = true, true
+		case strings.HasPrefix(line, "|"):
+			if len(events) == 0 {
+				continue
+			}
+			event := &events[len(events)-1]
+			for _, item := range strings.Split(line, "|") {
+				key, value, _ := strings.Cut(strings.TrimSpace(item), ":")
+				switch strings.ToUpper(key) {
+				case "C":
+					event.color = edlColor(value)
+				case "M":
+					event.name = strings.TrimSpace(value)
+				}
+			}
+		case strings.HasPrefix(line, "*"):
+			comment := strings.TrimSpace(strings.TrimPrefix(line, "*"))
+			if key, value, found := strings.Cut(comment, ":"); found && strings.EqualFold(key, "FROM CLIP NAME") && len(events) > 0 {
+				events[len(events)-1].clip = strings.TrimSpace(value)
+			}
+		case len(fields) >= 8 && isEDLEventNumber(fields[0]):
+			if format.FrameRate == 0 {
+				return nil, fmt.Errorf("The frame rate of the EDL timecode is unknown; set it, e.g. to 25fps")
+			}
+			start, err := format.Parse(fields[len(fields)-2], 0)
+			if err != nil {
+				return nil, fmt.Errorf("Event %s: %w", fields[0], err)
+			}
+			// The same edit on several tracks repeats the record in time
+			if len(events) > 0 && events[len(events)-1].start == start {
+				continue
+			}
+			events = append(events, edlEvent{start: start})
+		}
+		// Other lines (TITLE, transitions and other comments) are ignored
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to read EDL: %w", err)
+	}
+
+	var origin time.Duration
+	if len(events) > 0 {
+		origin = events[0].start.Truncate(time.Hour)
+	}
+	markers := []csvparser.MarkerEntry{}
+	for _, event := range events {
+		if len(options.Colors) > 0 && !slices.ContainsFunc(options.Colors, func(color string) bool { return strings.EqualFold(color, event.color) }) {
+			continue
+		}
+		name := event.name
+		if name == "" {
+			name = event.clip
+		}
+		markers = append(markers, csvparser.MarkerEntry{Name: name, StartTime: event.start - origin})
+	}
+	return markers, nil
+}
+
+// isEDLEventNumber reports whether the first field of a line is an event number
+func isEDLEventNumber(field string) bool {
+	return strings.Trim(field, "0123456789") == ""
+}
+
+// edlColor returns the plain color name of a marker color, e.g. "blue" for "ResolveColorBlue"
+func edlColor(value string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(value), "ResolveColor"))
+}
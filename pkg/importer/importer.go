@@ -0,0 +1,145 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+// Options holds settings shared by all import formats
+type Options struct {
+	TimeFormat csvparser.TimeFormat // Timecode frame rate of formats that do not state one, such as EDL
+	Colors     []string             // Marker colors imported by formats that store one (empty for all markers)
+	Tempo      float64              // Tempo in quarter notes per minute of bar positions, such as Logic's (0 if unknown)
+	Meter      [2]int               // Time signature of bar positions, e.g. {6, 8} (zero for 4/4)
+}
+
+// Format describes a chapter import format
+type Format struct {
+	Name        string   // Format name used on the command line
+	Extensions  []string // File extensions including the dot, used to detect the format
+	Description string   // Short description for help messages
+	Read        func(r io.Reader, options Options) ([]csvparser.MarkerEntry, error)
+	Match       func(head []byte) bool // Reports whether the start of a file is in the format, to tell formats sharing an extension apart (nil for any)
+}
+
+// Limits when reading marker files, which may be untrusted uploads
+const (
+	MaxFileSize = 16 << 20 // Largest marker file read in bytes
+	MaxMarkers  = 100000   // Largest number of markers read from a file
+)
+
+// ErrTooLarge is returned, wrapped, when a marker file exceeds MaxFileSize or MaxMarkers
+var ErrTooLarge = errors.New("Marker file too large")
+
+// limitedReader fails with ErrTooLarge once more than MaxFileSize bytes have been read
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return 0, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, MaxFileSize)
+	}
+	return n, err
+}
+
+// formats holds all registered import formats by name
+var formats = make(map[string]Format)
+
+// register adds an import format to the registry, with its reader limited to MaxFileSize and MaxMarkers
+func register(format Format) {
+	read := format.Read
+	format.Read = func(r io.Reader, options Options) ([]csvparser.MarkerEntry, error) {
+		markers, err := read(&limitedReader{r: r, remaining: MaxFileSize}, options)
+		if len(markers) > MaxMarkers {
+			return nil, fmt.Errorf("%w: %d markers, more than %d", ErrTooLarge, len(markers), MaxMarkers)
+		}
+		return markers, err
+	}
+	formats[format.Name] = format
+}
+
+// Lookup returns the import format with the given name
+func Lookup(name string) (Format, error) {
+	format, ok := formats[strings.ToLower(name)]
+	if !ok {
+		return Format{}, fmt.Errorf("Unknown import format '%s' (available: %s)", name, strings.Join(Names(), ", "))
+	}
+	return format, nil
+}
+
+// Names returns the names of all registered import formats in alphabetical order
+func Names() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Detect returns the import format matching the extension of path.
+// If several formats share the extension, the start of the file decides between them.
+func Detect(path string) (Format, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	var candidates []Format
+	for _, name := range Names() {
+		if slices.Contains(formats[name].Extensions, ext) {
+			candidates = append(candidates, formats[name])
+		}
+	}
+	if len(candidates) > 1 {
+		if head, err := readHead(path); err == nil {
+			for _, format := range candidates {
+				if format.Match != nil && format.Match(head) {
+					return format, nil
+				}
+			}
+		}
+	}
+	if len(candidates) > 0 {
+		return candidates[0], nil
+	}
+	return Format{}, fmt.Errorf("Cannot detect the format of '%s' from its extension (available: %s)", path, strings.Join(Names(), ", "))
+}
+
+// readHead returns the first bytes of a file for Match
+func readHead(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	head := make([]byte, 4096)
+	n, err := io.ReadFull(file, head)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		err = nil
+	}
+	return head[:n], err
+}
+
+// ReadFile reads the markers of a file in the given format
+func ReadFile(path string, format Format, options Options) ([]csvparser.MarkerEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot open %s file: %w", format.Name, err)
+	}
+	defer file.Close()
+
+	return format.Read(file, options)
+}
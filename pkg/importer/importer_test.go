@@ -0,0 +1,81 @@
+package importer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+// seeds holds a small valid file of each import format
+var seeds = map[string]string{
+	"audition":  "Name\tStart\tDuration\tTime Format\tType\tDescription\nIntro\t0:00.000\t0:00.000\tdecimal\tCue\t\n",
+	"cue":       "FILE \"a.mp3\" MP3\n  TRACK 01 AUDIO\n    TITLE \"Intro\"\n    INDEX 01 00:00:00\n  TRACK 02 AUDIO\n    TITLE \"Talk\"\n    INDEX 01 01:30:37\n",
+	"descript":  "Episode 12\n00:00 Intro\n[01:30] - Talk\n1:02:03.5: Outro\n",
+	"edl":       "TITLE: Timeline 1\nFCM: NON-DROP FRAME\n\n001  001      V     C        01:00:00:00 01:00:00:01 01:00:00:00 01:00:00:01\n |C:ResolveColorBlue |M:Intro |D:1\n",
+	"json":      `{"version":"1.2.0","chapters":[{"startTime":0,"title":"Intro"},{"startTime":90.5,"title":"Talk"}]}`,
+	"logic":     "Position\tMarker Name\tLength\n1 1 1 1\tIntro\t4 0 0 0\n5 1 1 1\tTalk\t4 0 0 0\n",
+	"markdown":  "# Episode\n\n## [00:00] Intro\n- [01:30](https://example.com/ep#t=90) Talk\n",
+	"matroska":  `<?xml version="1.0"?><Chapters><EditionEntry><ChapterAtom><ChapterTimeStart>00:00:00.000000000</ChapterTimeStart><ChapterDisplay><ChapterString>Intro</ChapterString></ChapterDisplay></ChapterAtom></EditionEntry></Chapters>`,
+	"overdrive": `<Markers><Marker><Name>Intro</Name><Time>0:00.000</Time></Marker><Marker><Name>Talk</Name><Time>1:30.500</Time></Marker></Markers>`,
+	"protools":  "SESSION NAME:\tEpisode\nSAMPLE RATE:\t48000.000000\nTIMECODE FORMAT:\t25 Frame\nSESSION START TIMECODE:\t00:00:00:00\n\nM A R K E R S  L I S T I N G\n#   \tLOCATION     \tTIME REFERENCE    \tUNITS    \tNAME                             \tCOMMENTS\n1   \t0:00.000     \t0                 \tSamples  \tIntro                            \t\n",
+	"webvtt":    "WEBVTT\n\n00:00.000 --> 01:30.500\nIntro\n\n01:30.500 --> 02:00.000\nTalk\n",
+}
+
+// fuzzOptions sets the frame rate and tempo that some formats need to be read at all
+var fuzzOptions = Options{TimeFormat: csvparser.TimeFormat{FrameRate: 25}, Tempo: 120}
+
+func TestSeeds(t *testing.T) {
+	for _, name := range Names() {
+		seed, ok := seeds[name]
+		if !ok {
+			t.Errorf("no seed for format %s", name)
+			continue
+		}
+		format, _ := Lookup(name)
+		if markers, err := format.Read(strings.NewReader(seed), fuzzOptions); err != nil || len(markers) == 0 {
+			t.Errorf("%s seed: %d markers, error %v", name, len(markers), err)
+		}
+	}
+}
+
+func TestReadLimits(t *testing.T) {
+	format, _ := Lookup("descript")
+
+	large := io.MultiReader(strings.NewReader("00:00 Intro\n"), bytes.NewReader(bytes.Repeat([]byte("\n"), MaxFileSize)))
+	if _, err := format.Read(large, Options{}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("reading more than MaxFileSize bytes: error %v, want ErrTooLarge", err)
+	}
+
+	many := strings.Repeat("00:00 Marker\n", MaxMarkers+1)
+	if _, err := format.Read(strings.NewReader(many), Options{}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("reading more than MaxMarkers markers: error %v, want ErrTooLarge", err)
+	}
+}
+
+func FuzzRead(f *testing.F) {
+	for _, name := range Names() {
+		f.Add(name, []byte(seeds[name]))
+	}
+	f.Fuzz(func(t *testing.T, name string, data []byte) {
+		format, err := Lookup(name)
+		if err != nil {
+			return
+		}
+		markers, err := format.Read(bytes.NewReader(data), fuzzOptions)
+		if err != nil {
+			return
+		}
+		if len(markers) > MaxMarkers {
+			t.Fatalf("%s: read %d markers, more than MaxMarkers", name, len(markers))
+		}
+		for _, marker := range markers {
+			if marker.StartTime < 0 {
+				t.Fatalf("%s: marker %q has a negative start time %v", name, marker.Name, marker.StartTime)
+			}
+		}
+	})
+}
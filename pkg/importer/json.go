@@ -0,0 +1,44 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/exporter"
+)
+
+func init() {
+	register(Format{
+		Name:        "json",
+		Extensions:  []string{".json"},
+		Description: "Podcasting 2.0 JSON chapters (podcast:chapters)",
+		Read:        readJSON,
+	})
+}
+
+// readJSON reads the chapters of a Podcasting 2.0 JSON chapters file
+func readJSON(r io.Reader, _ Options) ([]csvparser.MarkerEntry, error) {
+	var doc exporter.JSONChapters
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("Invalid JSON chapters file: %w", err)
+	}
+
+	markers := make([]csvparser.MarkerEntry, 0, len(doc.Chapters))
+	for _, chapter := range doc.Chapters {
+		if chapter.StartTime < 0 {
+			return nil, fmt.Errorf("Chapter '%s' has a negative start time", chapter.Title)
+		}
+		if chapter.StartTime >= math.MaxInt64/float64(time.Second) {
+			return nil, fmt.Errorf("Start time of chapter '%s' is out of range", chapter.Title)
+		}
+		markers = append(markers, csvparser.MarkerEntry{
+			Name:      chapter.Title,
+			StartTime: time.Duration(chapter.StartTime * float64(time.Second)).Round(time.Millisecond),
+		})
+	}
+	return markers, nil
+}
@@ -0,0 +1,146 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+func init() {
+	register(Format{
+		Name:        "logic",
+		Extensions:  []string{".txt"},
+		Description: "Logic Pro or GarageBand marker list text, one chapter per marker",
+		Read:        readLogic,
+		Match: func(head []byte) bool {
+			return bytes.HasPrefix(bytes.ToLower(bytes.TrimPrefix(head, []byte("\ufeff"))), []byte("position\t"))
+		},
+	})
+}
+
+// logicTicksPerDivision is the number of ticks in a division, the third field of a bar position
+const logicTicksPerDivision = 240
+
+// logicPosition holds what is needed to convert marker positions
+type logicPosition struct {
+	format   csvparser.TimeFormat // Frame rate of SMPTE positions
+	beat     time.Duration        // Length of a beat of bar positions (0 if the tempo is unknown)
+	perBar   int                  // Beats in a bar
+	perBeat  int                  // Divisions in a beat, with the default 1/16 division
+	timecode bool                 // Whether any position was SMPTE timecode, which is relative to the hour the first marker is in
+}
+
+// readLogic reads the markers of a Logic Pro or GarageBand marker list copied as text: a "Position" column and a "Marker Name" or "Name" column, separated by tabs.
+// Positions are bar positions ("5 1 1 1", which need the tempo), SMPTE timecode ("01:00:12:05.00", which needs the frame rate) or min:sec time.
+func readLogic(r io.Reader, options Options) ([]csvparser.MarkerEntry, error) {
+	position := logicPosition{format: options.TimeFormat, perBar: 4, perBeat: 4}
+	if options.Meter[0] > 0 && options.Meter[1] > 0 {
+		position.perBar = options.Meter[0]
+		position.perBeat = max(16/options.Meter[1], 1)
+	}
+	if options.Tempo > 0 {
+		unit := 4
+		if options.Meter[1] > 0 {
+			unit = options.Meter[1]
+		}
+		position.beat = time.Duration(float64(time.Minute) / options.Tempo * 4 / float64(unit))
+	}
+
+	scanner := bufio.NewScanner(r)
+	columns := map[string]int{}
+	markers := []csvparser.MarkerEntry{}
+	for scanner.Scan() {
+		line := strings.TrimPrefix(scanner.Text(), "\ufeff")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		cells := strings.Split(line, "\t")
+		for i := range cells {
+			cells[i] = strings.TrimSpace(cells[i])
+		}
+		if len(columns) == 0 {
+			for i, cell := range cells {
+				columns[strings.ToLower(cell)] = i
+			}
+			if _, ok := columns["position"]; !ok {
+				return nil, fmt.Errorf("Logic marker list has no Position column")
+			}
+			continue
+		}
+
+		cell := func(names ...string) string {
+			for _, name := range names {
+				if i, ok := columns[name]; ok && i < len(cells) {
+					return cells[i]
+				}
+			}
+			return ""
+		}
+		name := cell("marker name", "name")
+		start, err := position.parse(cell("position"))
+		if err != nil {
+			return nil, fmt.Errorf("Marker '%s': %w", name, err)
+		}
+		markers = append(markers, csvparser.MarkerEntry{Name: name, StartTime: start})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to read Logic marker list: %w", err)
+	}
+
+	if position.timecode && len(markers) > 0 {
+		origin := markers[0].StartTime.Truncate(time.Hour)
+		for i := range markers {
+			if markers[i].StartTime < origin {
+				return nil, fmt.Errorf("Marker '%s' is before the project start", markers[i].Name)
+			}
+			markers[i].StartTime -= origin
+		}
+	}
+	return markers, nil
+}
+
+// parse converts a marker position to its time from the project start
+func (position *logicPosition) parse(text string) (time.Duration, error) {
+	if fields := strings.Fields(text); len(fields) > 1 {
+		return position.parseBars(fields)
+	}
+
+	// Subframes after the frames are dropped
+	if strings.Count(text, ":") == 3 {
+		if position.format.FrameRate == 0 {
+			return 0, fmt.Errorf("The frame rate of SMPTE position %s is unknown; set it, e.g. to 25fps", text)
+		}
+		timecode, _, _ := strings.Cut(text, ".")
+		position.timecode = true
+		return position.format.Parse(timecode, 0)
+	}
+	return csvparser.ParseTime(text)
+}
+
+// parseBars converts a bar position "bar beat division tick", where missing trailing fields are 1
+func (position *logicPosition) parseBars(fields []string) (time.Duration, error) {
+	if len(fields) > 4 {
+		return 0, fmt.Errorf("Invalid bar position '%s'", strings.Join(fields, " "))
+	}
+	if position.beat == 0 {
+		return 0, fmt.Errorf("Bar position '%s' needs the project tempo", strings.Join(fields, " "))
+	}
+	values := [4]int{1, 1, 1, 1}
+	for i, field := range fields {
+		value, err := strconv.Atoi(field)
+		if err != nil || value < 1 {
+			return 0, fmt.Errorf("Invalid bar position '%s'", strings.Join(fields, " "))
+		}
+		values[i] = value
+	}
+
+	beats := float64((values[0]-1)*position.perBar+values[1]-1) +
+		(float64(values[2]-1)+float64(values[3]-1)/logicTicksPerDivision)/float64(position.perBeat)
+	return time.Duration(beats * float64(position.beat)).Round(time.Millisecond), nil
+}
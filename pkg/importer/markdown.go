@@ -0,0 +1,61 @@
+package importer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+func init() {
+	register(Format{
+		Name:        "markdown",
+		Extensions:  []string{".md", ".markdown"},
+		Description: "Markdown show notes, one chapter per heading or list item starting with a timestamp",
+		Read:        readMarkdown,
+	})
+}
+
+// markdownBlockPrefix matches the heading or list item marker at the start of a Markdown line
+var markdownBlockPrefix = regexp.MustCompile(`^(?:#{1,6}|[-*+]|\d+[.)])\s+`)
+
+// markdownLink matches an inline link [text](url); the text is kept
+var markdownLink = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+
+// markdownEmphasis removes bold, italic and code markers around timestamps and titles
+var markdownEmphasis = strings.NewReplacer("**", "", "__", "", "`", "")
+
+// readMarkdown reads show notes in Markdown as chapters: headings and list items starting with a timestamp,
+// e.g. "## [00:12:30] Interview with X" or "- [12:30](https://example.com/ep#t=750) Interview".
+// Other lines and fenced code blocks are ignored.
+func readMarkdown(r io.Reader, _ Options) ([]csvparser.MarkerEntry, error) {
+	markers := []csvparser.MarkerEntry{}
+	inCode := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~") {
+			inCode = !inCode
+			continue
+		}
+		prefix := markdownBlockPrefix.FindString(line)
+		if inCode || prefix == "" {
+			continue
+		}
+
+		text := markdownEmphasis.Replace(markdownLink.ReplaceAllString(line[len(prefix):], "$1"))
+		start, title, ok := parseTimestampLine(strings.TrimSpace(text))
+		if !ok {
+			continue
+		}
+		markers = append(markers, csvparser.MarkerEntry{Name: title, StartTime: start})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to read Markdown show notes: %w", err)
+	}
+	return markers, nil
+}
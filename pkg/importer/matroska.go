@@ -0,0 +1,75 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+func init() {
+	register(Format{
+		Name:        "matroska",
+		Extensions:  []string{".xml"},
+		Description: "Matroska chapter XML, chapters of the first edition",
+		Read:        readMatroska,
+		Match:       func(head []byte) bool { return bytes.Contains(head, []byte("<Chapters")) },
+	})
+}
+
+// matroskaChapters is the part of a Matroska chapter XML file that is read
+type matroskaChapters struct {
+	Editions []struct {
+		Atoms []struct {
+			TimeStart string   `xml:"ChapterTimeStart"`
+			Strings   []string `xml:"ChapterDisplay>ChapterString"`
+		} `xml:"ChapterAtom"`
+	} `xml:"EditionEntry"`
+}
+
+// readMatroska reads the chapter atoms of the first edition of a Matroska chapter XML file
+func readMatroska(r io.Reader, _ Options) ([]csvparser.MarkerEntry, error) {
+	var doc matroskaChapters
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("Invalid Matroska chapter XML: %w", err)
+	}
+	if len(doc.Editions) == 0 {
+		return []csvparser.MarkerEntry{}, nil
+	}
+
+	markers := make([]csvparser.MarkerEntry, 0, len(doc.Editions[0].Atoms))
+	for _, atom := range doc.Editions[0].Atoms {
+		start, err := parseMatroskaTime(strings.TrimSpace(atom.TimeStart))
+		if err != nil {
+			return nil, err
+		}
+		marker := csvparser.MarkerEntry{StartTime: start}
+		if len(atom.Strings) > 0 {
+			marker.Name = atom.Strings[0]
+		}
+		markers = append(markers, marker)
+	}
+	return markers, nil
+}
+
+// parseMatroskaTime parses a Matroska timestamp HH:MM:SS.nnnnnnnnn
+func parseMatroskaTime(text string) (time.Duration, error) {
+	fields := strings.Split(text, ":")
+	if len(fields) != 3 {
+		return 0, fmt.Errorf("Invalid Matroska timestamp '%s'", text)
+	}
+
+	hours, err1 := strconv.Atoi(fields[0])
+	minutes, err2 := strconv.Atoi(fields[1])
+	seconds, err3 := csvparser.ParseSeconds(fields[2])
+	if err1 != nil || err2 != nil || err3 != nil {
+		return 0, fmt.Errorf("Invalid Matroska timestamp '%s'", text)
+	}
+	total := time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + seconds
+	return total.Round(time.Millisecond), nil
+}
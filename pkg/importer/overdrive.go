@@ -0,0 +1,49 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+func init() {
+	register(Format{
+		Name:        "overdrive",
+		Extensions:  []string{".xml"},
+		Description: "OverDrive MediaMarkers XML, as stored in a TXXX frame by library audiobook platforms",
+		Read: func(r io.Reader, _ Options) ([]csvparser.MarkerEntry, error) {
+			return ReadOverDrive(r)
+		},
+		Match: func(head []byte) bool { return bytes.Contains(head, []byte("<Markers")) },
+	})
+}
+
+// overDriveMarkers is the part of OverDrive MediaMarkers XML that is read
+type overDriveMarkers struct {
+	Markers []struct {
+		Name string `xml:"Name"`
+		Time string `xml:"Time"`
+	} `xml:"Marker"`
+}
+
+// ReadOverDrive reads the markers of OverDrive MediaMarkers XML; times are M:SS.mmm or H:MM:SS.mmm
+func ReadOverDrive(r io.Reader) ([]csvparser.MarkerEntry, error) {
+	var doc overDriveMarkers
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("Invalid OverDrive MediaMarkers XML: %w", err)
+	}
+
+	markers := make([]csvparser.MarkerEntry, 0, len(doc.Markers))
+	for _, marker := range doc.Markers {
+		start, err := csvparser.ParseTime(marker.Time)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid time of marker '%s': %w", strings.TrimSpace(marker.Name), err)
+		}
+		markers = append(markers, csvparser.MarkerEntry{Name: strings.TrimSpace(marker.Name), StartTime: start})
+	}
+	return markers, nil
+}
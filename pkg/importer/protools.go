@@ -0,0 +1,160 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+func init() {
+	register(Format{
+		Name:        "protools",
+		Extensions:  []string{".txt"},
+		Description: "Pro Tools session info text export, one chapter per memory location",
+		Read:        readProTools,
+		Match:       func(head []byte) bool { return bytes.Contains(head, []byte("SESSION NAME:")) },
+	})
+}
+
+// proToolsMarkersHeading starts the memory location listing of a Pro Tools session text export
+const proToolsMarkersHeading = "M A R K E R S  L I S T I N G"
+
+// proToolsFrameRate matches the frame rate and drop-frame mode of a TIMECODE FORMAT line, e.g. "29.97 Drop Frame"
+var proToolsFrameRate = regexp.MustCompile(`(?i)^(\d+(?:\.\d+)?)\s*(drop)?`)
+
+// proToolsSession holds the session settings needed to convert memory location times
+type proToolsSession struct {
+	sampleRate int                  // Session sample rate (0 if not stated)
+	format     csvparser.TimeFormat // Timecode format of locations given as timecode
+	start      time.Duration        // Session start timecode, which locations given as timecode are relative to
+}
+
+// readProTools reads the memory locations of a Pro Tools "Export Session Info as Text" file as chapters.
+// Locations are converted from their sample position if the listing has one, otherwise from the timecode or min:sec location.
+func readProTools(r io.Reader, _ Options) ([]csvparser.MarkerEntry, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	settings := make(map[string]string)
+	for scanner.Scan() {
+		line := strings.TrimPrefix(scanner.Text(), "\ufeff")
+		if strings.HasPrefix(strings.TrimSpace(line), proToolsMarkersHeading) {
+			break
+		}
+		if key, value, found := strings.Cut(line, ":\t"); found {
+			settings[strings.ToUpper(strings.TrimSpace(key))] = strings.TrimSpace(value)
+		}
+	}
+	session, err := parseProToolsSession(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	// The listing has a header row and ends at an empty line
+	var columns map[string]int
+	markers := []csvparser.MarkerEntry{}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			if columns != nil {
+				break
+			}
+			continue
+		}
+		cells := strings.Split(line, "\t")
+		for i := range cells {
+			cells[i] = strings.TrimSpace(cells[i])
+		}
+		if columns == nil {
+			columns = make(map[string]int)
+			for i, cell := range cells {
+				columns[strings.ToUpper(cell)] = i
+			}
+			if _, ok := columns["NAME"]; !ok {
+				return nil, fmt.Errorf("Pro Tools marker listing has no NAME column")
+			}
+			continue
+		}
+
+		cell := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(cells) {
+				return cells[i]
+			}
+			return ""
+		}
+		start, err := session.locationTime(cell("LOCATION"), cell("TIME REFERENCE"), cell("UNITS"))
+		if err != nil {
+			return nil, fmt.Errorf("Memory location '%s': %w", cell("NAME"), err)
+		}
+		markers = append(markers, csvparser.MarkerEntry{Name: cell("NAME"), StartTime: start})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Cannot read Pro Tools session text: %w", err)
+	}
+	if columns == nil {
+		return nil, fmt.Errorf("No memory locations found: the file has no '%s' section", proToolsMarkersHeading)
+	}
+	return markers, nil
+}
+
+// parseProToolsSession reads the sample rate, timecode format and session start from the settings at the top of the file
+func parseProToolsSession(settings map[string]string) (proToolsSession, error) {
+	var session proToolsSession
+	if text, ok := settings["SAMPLE RATE"]; ok {
+		rate, err := strconv.ParseFloat(text, 64)
+		if err != nil || rate < 1 || rate > 1e6 {
+			return session, fmt.Errorf("Invalid Pro Tools sample rate: '%s'", text)
+		}
+		session.sampleRate = int(math.Round(rate))
+	}
+	if text, ok := settings["TIMECODE FORMAT"]; ok {
+		match := proToolsFrameRate.FindStringSubmatch(text)
+		if match == nil {
+			return session, fmt.Errorf("Unsupported Pro Tools timecode format: '%s'", text)
+		}
+		format, err := csvparser.ParseTimeFormat(match[1] + "fps " + match[2])
+		if err != nil {
+			return session, err
+		}
+		session.format = format
+	}
+	if text, ok := settings["SESSION START TIMECODE"]; ok && session.format.FrameRate > 0 {
+		start, err := session.format.Parse(text, 0)
+		if err != nil {
+			return session, fmt.Errorf("Invalid Pro Tools session start: %w", err)
+		}
+		session.start = start
+	}
+	return session, nil
+}
+
+// locationTime converts the time of a memory location relative to the session start
+func (session proToolsSession) locationTime(location, reference, units string) (time.Duration, error) {
+	if strings.EqualFold(units, "Samples") && session.sampleRate > 0 {
+		samples, err := strconv.ParseInt(reference, 10, 64)
+		if err != nil || samples < 0 {
+			return 0, fmt.Errorf("Invalid sample position: '%s'", reference)
+		}
+		return csvparser.SamplesToDuration(samples, session.sampleRate), nil
+	}
+
+	// Without a sample position the location is in the main counter format: timecode or min:sec
+	if session.format.FrameRate > 0 && strings.Count(location, ":")+strings.Count(location, ";") == 3 {
+		start, err := session.format.Parse(location, 0)
+		if err != nil {
+			return 0, err
+		}
+		if start < session.start {
+			return 0, fmt.Errorf("Location %s is before the session start", location)
+		}
+		return start - session.start, nil
+	}
+	return csvparser.ParseTime(location)
+}
@@ -0,0 +1,87 @@
+package importer
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+func init() {
+	register(Format{
+		Name:        "webvtt",
+		Extensions:  []string{".vtt"},
+		Description: "WebVTT chapter track, one chapter per cue",
+		Read:        readWebVTT,
+	})
+}
+
+// readWebVTT reads the cues of a WebVTT chapter track as chapters
+// Cue text is taken literally, so titles starting with "[...]" or "NAME:" are not read as speaker names
+func readWebVTT(r io.Reader, _ Options) ([]csvparser.MarkerEntry, error) {
+	var markers []csvparser.MarkerEntry
+	var lines []string
+	inCue := false
+
+	// finish completes the current chapter with the collected title lines
+	finish := func() {
+		if inCue {
+			markers[len(markers)-1].Name = html.UnescapeString(strings.Join(lines, " "))
+		}
+		inCue = false
+		lines = nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+
+		switch {
+		case line == "":
+			finish()
+		case strings.Contains(line, "-->"):
+			finish()
+			start, err := parseWebVTTTime(strings.TrimSpace(strings.SplitN(line, "-->", 2)[0]))
+			if err != nil {
+				return nil, err
+			}
+			markers = append(markers, csvparser.MarkerEntry{StartTime: start})
+			inCue = true
+		case inCue:
+			lines = append(lines, line)
+		}
+		// Other lines (header, cue identifiers and NOTE blocks) are ignored
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to read WebVTT file: %w", err)
+	}
+	finish()
+
+	return markers, nil
+}
+
+// parseWebVTTTime parses a WebVTT timestamp, HH:MM:SS.mmm or MM:SS.mmm
+func parseWebVTTTime(text string) (time.Duration, error) {
+	fields := strings.Split(text, ":")
+	if len(fields) < 2 || len(fields) > 3 {
+		return 0, fmt.Errorf("Invalid WebVTT timestamp '%s'", text)
+	}
+
+	total, err := csvparser.ParseSeconds(fields[len(fields)-1])
+	if err != nil {
+		return 0, fmt.Errorf("Invalid WebVTT timestamp '%s'", text)
+	}
+	for i, unit := range []time.Duration{time.Minute, time.Hour}[:len(fields)-1] {
+		value, err := strconv.Atoi(fields[len(fields)-2-i])
+		if err != nil {
+			return 0, fmt.Errorf("Invalid WebVTT timestamp '%s'", text)
+		}
+		total += time.Duration(value) * unit
+	}
+	return total.Round(time.Millisecond), nil
+}
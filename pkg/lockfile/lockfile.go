@@ -0,0 +1,103 @@
+package lockfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Suffix is appended to the path of the locked file to name its lock file
+const Suffix = ".lock"
+
+// ErrLocked is returned by Acquire when another process holds the lock
+var ErrLocked = errors.New("File is locked")
+
+// Lock is an advisory lock on a file, held by the existence of a lock file next to it
+type Lock struct {
+	path string
+}
+
+// Acquire locks path for writing by creating path.lock exclusively. The lock file records the process ID, host and time,
+// so a lock left behind by a process that no longer runs on this host is taken over.
+func Acquire(path string) (*Lock, error) {
+	lockPath := path + Suffix
+	// The lock is taken before the output directory is created by the writer
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0755); err != nil {
+		return nil, fmt.Errorf("Cannot create lock file %s: %w", lockPath, err)
+	}
+	for attempt := 0; ; attempt++ {
+		file, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			host, _ := os.Hostname()
+			_, err = fmt.Fprintf(file, "%d %s %s\n", os.Getpid(), host, time.Now().UTC().Format(time.RFC3339))
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(lockPath)
+				return nil, fmt.Errorf("Cannot write lock file %s: %w", lockPath, err)
+			}
+			return &Lock{path: lockPath}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("Cannot create lock file %s: %w", lockPath, err)
+		}
+
+		// Take over a stale lock once; a second failure means another process was faster
+		data, holder, stale := readHolder(lockPath)
+		if stale && attempt == 0 && takeOver(lockPath, data) {
+			continue
+		}
+		if !detectsStale {
+			return nil, fmt.Errorf("%w: '%s' is being written by %s; locks of crashed runs do not expire on this system, so delete %s if no other run is writing it", ErrLocked, path, holder, lockPath)
+		}
+		return nil, fmt.Errorf("%w: '%s' is being written by %s; remove %s if no other run is writing it", ErrLocked, path, holder, lockPath)
+	}
+}
+
+// takeOver removes the stale lock file whose content is data. The file is first renamed to a unique name, so a fresh lock
+// written by another process taking over at the same time is never removed; such a lock is put back and false is returned.
+func takeOver(lockPath string, data []byte) bool {
+	stalePath := fmt.Sprintf("%s.%d.%d.stale", lockPath, os.Getpid(), time.Now().UnixNano())
+	if err := os.Rename(lockPath, stalePath); err != nil {
+		return false
+	}
+	defer os.Remove(stalePath)
+	if renamed, err := os.ReadFile(stalePath); err != nil || !bytes.Equal(renamed, data) {
+		os.Link(stalePath, lockPath) // Fails if yet another process holds the lock by now
+		return false
+	}
+	return true
+}
+
+// Release removes the lock file
+func (lock *Lock) Release() error {
+	if err := os.Remove(lock.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("Cannot remove lock file %s: %w", lock.path, err)
+	}
+	return nil
+}
+
+// readHolder returns the content of a lock file and a description of the process holding it, and reports whether the lock is stale
+func readHolder(lockPath string) ([]byte, string, bool) {
+	data, err := os.ReadFile(lockPath)
+	if err != nil {
+		return nil, "another process", false
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return data, "another process", false
+	}
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return data, "another process", false
+	}
+	holder := fmt.Sprintf("process %d on %s since %s", pid, fields[1], fields[2])
+	host, _ := os.Hostname()
+	return data, holder, detectsStale && fields[1] == host && !processRunning(pid)
+}
@@ -0,0 +1,67 @@
+package lockfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAcquire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out", "episode.mp3")
+	lock, err := Acquire(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Acquire(path); !errors.Is(err, ErrLocked) || !strings.Contains(err.Error(), path+Suffix) {
+		t.Errorf("second Acquire() error = %v, want ErrLocked naming the lock file", err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatal(err)
+	}
+	lock, err = Acquire(path)
+	if err != nil {
+		t.Fatalf("Acquire() after Release() error = %v", err)
+	}
+	lock.Release()
+}
+
+func TestAcquireStale(t *testing.T) {
+	if !detectsStale {
+		t.Skip("stale locks are not detected on this system")
+	}
+	path := filepath.Join(t.TempDir(), "episode.mp3")
+	host, _ := os.Hostname()
+	if err := os.WriteFile(path+Suffix, []byte("999999999 "+host+" 2026-01-01T00:00:00Z\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lock, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("Acquire() of a stale lock error = %v", err)
+	}
+	defer lock.Release()
+	if data, _ := os.ReadFile(path + Suffix); strings.HasPrefix(string(data), "999999999 ") {
+		t.Errorf("lock file still holds the stale lock: %s", data)
+	}
+	if matches, _ := filepath.Glob(path + Suffix + ".*"); len(matches) > 0 {
+		t.Errorf("renamed stale lock files left behind: %q", matches)
+	}
+}
+
+func TestTakeOverKeepsFreshLock(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "episode.mp3"+Suffix)
+	stale := []byte("999999999 host 2026-01-01T00:00:00Z\n")
+	fresh := []byte("1234 host 2026-01-02T00:00:00Z\n")
+
+	// Another process took over the stale lock after it was read
+	if err := os.WriteFile(lockPath, fresh, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if takeOver(lockPath, stale) {
+		t.Error("takeOver() removed a lock that is no longer the stale one")
+	}
+	if data, err := os.ReadFile(lockPath); err != nil || string(data) != string(fresh) {
+		t.Errorf("lock file after takeOver() = %q, %v, want the fresh lock", data, err)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package lockfile
+
+import (
+	"errors"
+	"syscall"
+)
+
+// detectsStale reports whether locks left behind by processes that no longer run are detected
+const detectsStale = true
+
+// processRunning reports whether a process with the given ID exists
+func processRunning(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,74 @@
+package mp3frame
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Concat writes the audio frames of all parts to w one after another and returns the start time of each part in the output.
+// Tags and Xing headers of the parts are dropped; if keepFirstTag is set, the ID3v2 tag of the first part is written first.
+func Concat(w io.Writer, parts []string, keepFirstTag bool) ([]time.Duration, error) {
+	var starts []time.Duration
+	var first *Header
+	var samples int64
+	var sampleRate int
+
+	for i, path := range parts {
+		// Scan part
+		info, err := ScanFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to scan '%s': %w", path, err)
+		}
+
+		// All parts must share the same stream format
+		h := info.Frames[0].Header
+		if first == nil {
+			first = &h
+			sampleRate = info.SampleRate
+		} else if !h.compatible(*first) {
+			return nil, fmt.Errorf("Part '%s' has a different MPEG version, layer or sample rate than the first part", path)
+		}
+
+		starts = append(starts, time.Duration(samples)*time.Second/time.Duration(sampleRate))
+
+		// Copy tag and frames
+		if err := copyPart(w, path, info, keepFirstTag && i == 0); err != nil {
+			return nil, fmt.Errorf("Failed to copy '%s': %w", path, err)
+		}
+		samples += info.Samples
+	}
+
+	return starts, nil
+}
+
+// copyPart copies the frames of one scanned part (and optionally its ID3v2 tag) to w
+func copyPart(w io.Writer, path string, info *Info, withTag bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if withTag && info.TagSize > 0 {
+		if _, err := io.Copy(w, io.NewSectionReader(file, 0, info.TagSize)); err != nil {
+			return err
+		}
+	}
+
+	// Copy contiguous runs of frames at once
+	runStart := info.Frames[0].Offset
+	runEnd := runStart
+	for _, frame := range info.Frames {
+		if frame.Offset != runEnd {
+			if _, err := io.Copy(w, io.NewSectionReader(file, runStart, runEnd-runStart)); err != nil {
+				return err
+			}
+			runStart = frame.Offset
+		}
+		runEnd = frame.Offset + int64(frame.Header.Size)
+	}
+	_, err = io.Copy(w, io.NewSectionReader(file, runStart, runEnd-runStart))
+	return err
+}
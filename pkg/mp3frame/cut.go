@@ -0,0 +1,45 @@
+package mp3frame
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Cut writes the audio frames between from and to of the MP3 file at path to w, without tags.
+// The range is widened to whole frames; a to beyond the end of the audio is clamped.
+func Cut(w io.Writer, path string, from, to time.Duration) error {
+	info, err := ScanFile(path)
+	if err != nil {
+		return err
+	}
+
+	// Convert the time range to frame indices
+	samplesPerFrame := int64(info.Frames[0].Header.Samples)
+	first := int(int64(from) * int64(info.SampleRate) / int64(time.Second) / samplesPerFrame)
+	last := int((int64(to)*int64(info.SampleRate)/int64(time.Second) + samplesPerFrame - 1) / samplesPerFrame)
+	if first < 0 {
+		first = 0
+	}
+	if last > len(info.Frames) {
+		last = len(info.Frames)
+	}
+	if first >= last {
+		return fmt.Errorf("Range %s-%s is outside the audio", from, to)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("Cannot open MP3 file: %w", err)
+	}
+	defer file.Close()
+
+	// Write the selected frames
+	for _, frame := range info.Frames[first:last] {
+		if _, err := io.Copy(w, io.NewSectionReader(file, frame.Offset, int64(frame.Header.Size))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package mp3frame
+
+import "time"
+
+// MPEG audio versions
+const (
+	MPEG1  = 1
+	MPEG2  = 2
+	MPEG25 = 25 // Unofficial MPEG 2.5 extension
+)
+
+// Channel modes
+const (
+	Stereo      = 0
+	JointStereo = 1
+	DualChannel = 2
+	Mono        = 3
+)
+
+// Header is a decoded MPEG audio frame header
+type Header struct {
+	Version     int  // MPEG1, MPEG2 or MPEG25
+	Layer       int  // 1, 2 or 3
+	Protected   bool // Whether a CRC follows the header
+	Bitrate     int  // Bitrate in bits per second
+	SampleRate  int  // Sample rate in Hz
+	Padding     bool // Whether the frame has a padding slot
+	ChannelMode int  // Stereo, JointStereo, DualChannel or Mono
+	Size        int  // Total frame length in bytes including the header
+	Samples     int  // Number of samples per channel in the frame
+}
+
+// bitrates in kbit/s indexed by [version/layer row][bitrate index]
+var bitrates = [5][16]int{
+	{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, 0}, // MPEG1 Layer 1
+	{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 0},    // MPEG1 Layer 2
+	{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},     // MPEG1 Layer 3
+	{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, 0},    // MPEG2/2.5 Layer 1
+	{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},         // MPEG2/2.5 Layer 2 and 3
+}
+
+// sampleRates in Hz indexed by [version][sample rate index]
+var sampleRates = map[int][3]int{
+	MPEG1:  {44100, 48000, 32000},
+	MPEG2:  {22050, 24000, 16000},
+	MPEG25: {11025, 12000, 8000},
+}
+
+// ParseHeader decodes a 4-byte frame header; ok is false if b does not start with a valid header
+func ParseHeader(b []byte) (h Header, ok bool) {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return Header{}, false
+	}
+
+	// Version
+	switch (b[1] >> 3) & 0x03 {
+	case 0:
+		h.Version = MPEG25
+	case 2:
+		h.Version = MPEG2
+	case 3:
+		h.Version = MPEG1
+	default:
+		return Header{}, false // Reserved
+	}
+
+	// Layer
+	switch (b[1] >> 1) & 0x03 {
+	case 1:
+		h.Layer = 3
+	case 2:
+		h.Layer = 2
+	case 3:
+		h.Layer = 1
+	default:
+		return Header{}, false // Reserved
+	}
+	h.Protected = b[1]&0x01 == 0
+
+	// Bitrate (free format and bad index are not supported)
+	row := h.Layer - 1
+	if h.Version != MPEG1 {
+		row = 4
+		if h.Layer == 1 {
+			row = 3
+		}
+	}
+	h.Bitrate = bitrates[row][b[2]>>4] * 1000
+	if h.Bitrate == 0 {
+		return Header{}, false
+	}
+
+	// Sample rate
+	rateIndex := (b[2] >> 2) & 0x03
+	if rateIndex == 3 {
+		return Header{}, false // Reserved
+	}
+	h.SampleRate = sampleRates[h.Version][rateIndex]
+
+	h.Padding = (b[2]>>1)&0x01 == 1
+	h.ChannelMode = int(b[3] >> 6)
+
+	// Samples per frame and frame size
+	padding := 0
+	if h.Padding {
+		padding = 1
+	}
+	switch {
+	case h.Layer == 1:
+		h.Samples = 384
+		h.Size = (12*h.Bitrate/h.SampleRate + padding) * 4
+	case h.Layer == 3 && h.Version != MPEG1:
+		h.Samples = 576
+		h.Size = 72*h.Bitrate/h.SampleRate + padding
+	default:
+		h.Samples = 1152
+		h.Size = 144*h.Bitrate/h.SampleRate + padding
+	}
+
+	return h, true
+}
+
+// Duration returns the playback duration of the frame
+func (h Header) Duration() time.Duration {
+	return time.Duration(h.Samples) * time.Second / time.Duration(h.SampleRate)
+}
+
+// SideInfoSize returns the size of the Layer III side information following the header (and CRC)
+func (h Header) SideInfoSize() int {
+	if h.Version == MPEG1 {
+		if h.ChannelMode == Mono {
+			return 17
+		}
+		return 32
+	}
+	if h.ChannelMode == Mono {
+		return 9
+	}
+	return 17
+}
+
+// compatible reports whether two headers belong to the same stream
+func (h Header) compatible(other Header) bool {
+	return h.Version == other.Version && h.Layer == other.Layer && h.SampleRate == other.SampleRate
+}
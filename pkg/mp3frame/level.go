@@ -0,0 +1,83 @@
+package mp3frame
+
+import "math"
+
+// Silent is the level reported for frames whose spectral data is empty
+var Silent = math.Inf(-1)
+
+// bitReader reads big-endian bit fields from a byte slice
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+// read returns the next n bits (n <= 32); missing bits read as zero
+func (br *bitReader) read(n int) uint32 {
+	var v uint32
+	for i := 0; i < n; i++ {
+		v <<= 1
+		if byteIdx := br.pos / 8; byteIdx < len(br.data) {
+			v |= uint32(br.data[byteIdx]>>(7-br.pos%8)) & 1
+		}
+		br.pos++
+	}
+	return v
+}
+
+// Level estimates the peak level of a Layer III frame in dB relative to full scale without decoding it.
+// The estimate is taken from the side information: granules without Huffman data are silent, and otherwise
+// the global gain (1.5 dB per step, 210 being unity) of the loudest granule is used.
+// Frames of other layers, or too short to hold side information, report 0 dB.
+func (h Header) Level(frame []byte) float64 {
+	if h.Layer != 3 {
+		return 0
+	}
+
+	offset := 4
+	if h.Protected {
+		offset += 2
+	}
+	if len(frame) < offset+h.SideInfoSize() {
+		return 0
+	}
+
+	// Skip the fields preceding the granule information
+	channels := 2
+	if h.ChannelMode == Mono {
+		channels = 1
+	}
+	br := &bitReader{data: frame[offset : offset+h.SideInfoSize()]}
+	granules := 1
+	if h.Version == MPEG1 {
+		granules = 2
+		br.read(9)              // main_data_begin
+		br.read(7 - 2*channels) // private_bits (5 for mono, 3 otherwise)
+		br.read(4 * channels)   // scfsi
+	} else {
+		br.read(8)        // main_data_begin
+		br.read(channels) // private_bits
+	}
+
+	level := Silent
+	for gr := 0; gr < granules; gr++ {
+		for ch := 0; ch < channels; ch++ {
+			part23Length := br.read(12)
+			br.read(9) // big_values
+			globalGain := br.read(8)
+			if h.Version == MPEG1 {
+				br.read(4 + 1 + 22 + 3) // scalefac_compress, window switching fields, preflag/scalefac_scale/count1table_select
+			} else {
+				br.read(9 + 1 + 22 + 2) // scalefac_compress, window switching fields, scalefac_scale/count1table_select
+			}
+
+			if part23Length == 0 {
+				continue // No spectral data
+			}
+			if granuleLevel := 1.5 * (float64(globalGain) - 210); granuleLevel > level {
+				level = granuleLevel
+			}
+		}
+	}
+
+	return level
+}
@@ -0,0 +1,260 @@
+package mp3frame
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+)
+
+// Frame is an audio frame located in a file
+type Frame struct {
+	Offset int64  // Byte offset of the frame header
+	Header Header // Decoded frame header
+}
+
+// Info summarizes the audio stream of an MP3 file
+type Info struct {
+	TagSize    int64   // Size of the leading ID3v2 tag in bytes (0 if none)
+	AudioEnd   int64   // Offset where trailing ID3v1/APE tags start (file size if none)
+	XingFrame  *Frame  // Xing/Info/VBRI header frame, which carries no audio (nil if none)
+	Frames     []Frame // Audio frames in file order
+	Samples    int64   // Total number of samples per channel
+	SampleRate int     // Sample rate of the first frame in Hz
+}
+
+// Duration returns the total playback duration of the audio frames
+func (info *Info) Duration() time.Duration {
+	if info.SampleRate == 0 {
+		return 0
+	}
+	return time.Duration(info.Samples) * time.Second / time.Duration(info.SampleRate)
+}
+
+// ScanFile scans the MP3 file at path
+func ScanFile(path string) (*Info, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot open MP3 file: %w", err)
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("Cannot stat MP3 file: %w", err)
+	}
+
+	return Scan(file, stat.Size())
+}
+
+// Scan locates all audio frames in an MP3 stream of the given size
+func Scan(r io.ReaderAt, size int64) (*Info, error) {
+	info := &Info{}
+
+	// Determine the audio region between leading and trailing tags
+	tagSize, err := id3v2Size(r, size)
+	if err != nil {
+		return nil, err
+	}
+	info.TagSize = tagSize
+	info.AudioEnd = trailingTagsStart(r, size)
+
+	// Walk frames sequentially
+	reader := bufio.NewReaderSize(io.NewSectionReader(r, tagSize, info.AudioEnd-tagSize), 64*1024)
+	offset := tagSize
+	var first *Header
+	for offset+4 <= info.AudioEnd {
+		buf, err := reader.Peek(4)
+		if err != nil {
+			break
+		}
+
+		h, ok := ParseHeader(buf)
+		if ok && first != nil && !h.compatible(*first) {
+			ok = false
+		}
+		if ok && offset+int64(h.Size) > info.AudioEnd {
+			break // Truncated last frame
+		}
+
+		// Until the stream is locked, require the following frame to be valid as well
+		if ok && first == nil && offset+int64(h.Size)+4 <= info.AudioEnd {
+			next, err := reader.Peek(h.Size + 4)
+			if err != nil {
+				break
+			}
+			nextHeader, nextOK := ParseHeader(next[h.Size:])
+			ok = nextOK && nextHeader.compatible(h)
+		}
+
+		if !ok {
+			// Resynchronize byte by byte
+			reader.Discard(1)
+			offset++
+			continue
+		}
+
+		frame := Frame{Offset: offset, Header: h}
+		if first == nil {
+			first = &h
+			info.SampleRate = h.SampleRate
+
+			// The first frame may be a Xing/Info/VBRI header without audio
+			if body, err := reader.Peek(h.Size); err == nil && isXingFrame(body, h) {
+				info.XingFrame = &frame
+				reader.Discard(h.Size)
+				offset += int64(h.Size)
+				continue
+			}
+		}
+
+		info.Frames = append(info.Frames, frame)
+		info.Samples += int64(h.Samples)
+		reader.Discard(h.Size)
+		offset += int64(h.Size)
+	}
+
+	if len(info.Frames) == 0 {
+		return nil, fmt.Errorf("No MPEG audio frames found")
+	}
+
+	return info, nil
+}
+
+// FindFirstFrame returns the offset of the first MPEG audio frame at or after from that is followed by compatible frames.
+// It ignores any tag, so it locates the audio of a file whose ID3v2 tag is corrupt.
+func FindFirstFrame(r io.ReaderAt, size int64, from int64) (int64, error) {
+	const chain = 3 // Consecutive frames required, as tag data may contain frame syncs by chance
+
+	reader := bufio.NewReaderSize(io.NewSectionReader(r, from, size-from), 64*1024)
+	for offset := from; offset+4 <= size; offset++ {
+		if valid, err := frameChain(reader, size-offset, chain); err != nil {
+			break
+		} else if valid {
+			return offset, nil
+		}
+		reader.Discard(1)
+	}
+	return 0, fmt.Errorf("No MPEG audio frames found")
+}
+
+// frameChain reports whether the buffered data starts with count compatible frames, or with fewer frames up to the end
+func frameChain(reader *bufio.Reader, remaining int64, count int) (bool, error) {
+	var first *Header
+	pos := 0
+	for n := 0; n < count; n++ {
+		if int64(pos) == remaining && first != nil {
+			return true, nil // Stream ends with whole frames
+		}
+		buf, err := reader.Peek(pos + 4)
+		if err != nil {
+			if n == 0 {
+				return false, err
+			}
+			return false, nil
+		}
+		h, ok := ParseHeader(buf[pos:])
+		if !ok || (first != nil && !h.compatible(*first)) || int64(pos+h.Size) > remaining {
+			return false, nil
+		}
+		if first == nil {
+			first = &h
+		}
+		pos += h.Size
+	}
+	return true, nil
+}
+
+// id3v2Size returns the size of a leading ID3v2 tag including its header and footer
+func id3v2Size(r io.ReaderAt, size int64) (int64, error) {
+	header := make([]byte, 10)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		if err == io.EOF {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("Cannot read file header: %w", err)
+	}
+	if string(header[:3]) != "ID3" {
+		return 0, nil
+	}
+
+	tagSize := 10 + (int64(header[6]&0x7F)<<21 | int64(header[7]&0x7F)<<14 | int64(header[8]&0x7F)<<7 | int64(header[9]&0x7F))
+	if header[5]&0x10 != 0 {
+		tagSize += 10 // Footer present
+	}
+	if tagSize > size {
+		return 0, fmt.Errorf("ID3v2 tag size exceeds file size")
+	}
+	return tagSize, nil
+}
+
+// trailingTagsStart returns the offset where trailing ID3v1 and APEv2 tags begin
+func trailingTagsStart(r io.ReaderAt, size int64) int64 {
+	end := size
+
+	// ID3v1 tag occupies the last 128 bytes
+	if end >= 128 {
+		buf := make([]byte, 3)
+		if _, err := r.ReadAt(buf, end-128); err == nil && string(buf) == "TAG" {
+			end -= 128
+		}
+	}
+
+	// APEv2 tag footer is 32 bytes
+	if end >= 32 {
+		footer := make([]byte, 32)
+		if _, err := r.ReadAt(footer, end-32); err == nil && bytes.HasPrefix(footer, []byte("APETAGEX")) {
+			tagSize := int64(binary.LittleEndian.Uint32(footer[12:16]))
+			flags := binary.LittleEndian.Uint32(footer[20:24])
+			if flags&0x80000000 != 0 {
+				tagSize += 32 // Header present
+			}
+			if tagSize <= end {
+				end -= tagSize
+			}
+		}
+	}
+
+	return end
+}
+
+// isXingFrame reports whether a Layer III frame carries a Xing, Info or VBRI header
+func isXingFrame(frame []byte, h Header) bool {
+	if h.Layer != 3 {
+		return false
+	}
+
+	offset := 4 + h.SideInfoSize()
+	if h.Protected {
+		offset += 2
+	}
+	if len(frame) >= offset+4 {
+		tag := string(frame[offset : offset+4])
+		if tag == "Xing" || tag == "Info" {
+			return true
+		}
+	}
+	return len(frame) >= 40 && string(frame[36:40]) == "VBRI"
+}
+
+// NearestFrame returns the audio frame whose start is closest to t, along with that start time.
+// All frames of a stream have the same number of samples, so the index follows directly from t.
+func (info *Info) NearestFrame(t time.Duration) (Frame, time.Duration) {
+	samplesPerFrame := int64(info.Frames[0].Header.Samples)
+	frameDuration := float64(samplesPerFrame) / float64(info.SampleRate)
+
+	index := int(math.Round(t.Seconds() / frameDuration))
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(info.Frames) {
+		index = len(info.Frames) - 1
+	}
+
+	start := time.Duration(int64(index)*samplesPerFrame) * time.Second / time.Duration(info.SampleRate)
+	return info.Frames[index], start
+}
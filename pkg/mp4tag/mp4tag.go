@@ -0,0 +1,409 @@
+package mp4tag
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Chapter is a chapter read from an MP4 file
+type Chapter struct {
+	Title     string        // Chapter title
+	StartTime time.Duration // Start time of the chapter
+}
+
+// IsMP4File reports whether path has an MP4 audio or video file extension
+func IsMP4File(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".m4a", ".m4b", ".mp4", ".m4v", ".mov":
+		return true
+	}
+	return false
+}
+
+// box is an ISO base media file format box: its type and the position and size of its content
+type box struct {
+	kind   string
+	offset int64
+	size   int64
+}
+
+// ReadChapters reads the chapters of an MP4 file: the QuickTime chapter track Apple players use, or else the Nero chapter list (chpl)
+func ReadChapters(path string) ([]Chapter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot open MP4 file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("Cannot open MP4 file: %w", err)
+	}
+	return ReadChaptersFrom(file, info.Size())
+}
+
+// ReadChaptersFrom reads the chapters of an MP4 file of the given size from r
+func ReadChaptersFrom(r io.ReaderAt, size int64) ([]Chapter, error) {
+	moov, err := findBox(r, box{size: size}, "moov")
+	if err != nil {
+		return nil, err
+	}
+	if moov == nil {
+		return nil, fmt.Errorf("Not an MP4 file: no movie (moov) box")
+	}
+
+	chapters, err := readChapterTrack(r, *moov)
+	if err != nil || chapters != nil {
+		return chapters, err
+	}
+	if udta, err := findBox(r, *moov, "udta"); err != nil {
+		return nil, err
+	} else if udta != nil {
+		if chpl, err := findBox(r, *udta, "chpl"); err != nil {
+			return nil, err
+		} else if chpl != nil {
+			return readChpl(r, *chpl)
+		}
+	}
+	return []Chapter{}, nil
+}
+
+// children returns the boxes directly inside parent
+func children(r io.ReaderAt, parent box) ([]box, error) {
+	var boxes []box
+	header := make([]byte, 16)
+	for offset, end := parent.offset, parent.offset+parent.size; offset+8 <= end; {
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return nil, fmt.Errorf("Failed to read MP4 box: %w", err)
+		}
+		size := int64(binary.BigEndian.Uint32(header))
+		kind := string(header[4:8])
+		headerSize := int64(8)
+		switch size {
+		case 0:
+			size = end - offset // Box extends to the end of its parent
+		case 1:
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return nil, fmt.Errorf("Failed to read MP4 box: %w", err)
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+		if size < headerSize || offset+size > end {
+			return nil, fmt.Errorf("Invalid size of MP4 box '%s' at offset %d", kind, offset)
+		}
+		boxes = append(boxes, box{kind: kind, offset: offset + headerSize, size: size - headerSize})
+		offset += size
+	}
+	return boxes, nil
+}
+
+// findBox returns the first box of a type directly inside parent, or nil
+func findBox(r io.ReaderAt, parent box, kind string) (*box, error) {
+	boxes, err := children(r, parent)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range boxes {
+		if b.kind == kind {
+			return &b, nil
+		}
+	}
+	return nil, nil
+}
+
+// findPath returns the box at a path of box types below parent, or nil
+func findPath(r io.ReaderAt, parent box, path ...string) (*box, error) {
+	current := &parent
+	for _, kind := range path {
+		next, err := findBox(r, *current, kind)
+		if err != nil || next == nil {
+			return nil, err
+		}
+		current = next
+	}
+	return current, nil
+}
+
+// readContent reads the content of a box
+func readContent(r io.ReaderAt, b box) ([]byte, error) {
+	if b.size > 64<<20 {
+		return nil, fmt.Errorf("MP4 box '%s' is too large (%d bytes)", b.kind, b.size)
+	}
+	data := make([]byte, b.size)
+	if _, err := r.ReadAt(data, b.offset); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("Failed to read MP4 box '%s': %w", b.kind, err)
+	}
+	return data, nil
+}
+
+// readChpl reads a Nero chapter list: start times in 100 ns units, each followed by a length-prefixed UTF-8 title
+func readChpl(r io.ReaderAt, chpl box) ([]Chapter, error) {
+	data, err := readContent(r, chpl)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 5 {
+		return nil, fmt.Errorf("Invalid Nero chapter list")
+	}
+	pos := 4 // Version and flags
+	if data[0] == 1 {
+		pos += 4 // Reserved
+	}
+	if pos >= len(data) {
+		return nil, fmt.Errorf("Invalid Nero chapter list")
+	}
+	count := int(data[pos])
+	pos++
+
+	chapters := make([]Chapter, 0, count)
+	for i := 0; i < count; i++ {
+		if pos+9 > len(data) {
+			return nil, fmt.Errorf("Nero chapter list is truncated")
+		}
+		start := binary.BigEndian.Uint64(data[pos:])
+		length := int(data[pos+8])
+		pos += 9
+		if pos+length > len(data) {
+			return nil, fmt.Errorf("Nero chapter list is truncated")
+		}
+		chapters = append(chapters, Chapter{Title: string(data[pos : pos+length]), StartTime: time.Duration(start) * 100})
+		pos += length
+	}
+	return chapters, nil
+}
+
+// readChapterTrack reads the text samples of the track referenced as chapter track (tref/chap) by another track; nil if there is none
+func readChapterTrack(r io.ReaderAt, moov box) ([]Chapter, error) {
+	boxes, err := children(r, moov)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect tracks by ID and the IDs of referenced chapter tracks
+	tracks := map[uint32]box{}
+	var chapterIDs []uint32
+	for _, trak := range boxes {
+		if trak.kind != "trak" {
+			continue
+		}
+		tkhd, err := findBox(r, trak, "tkhd")
+		if err != nil || tkhd == nil {
+			continue
+		}
+		data, err := readContent(r, *tkhd)
+		if err != nil {
+			return nil, err
+		}
+		idOffset := 12
+		if len(data) > 0 && data[0] == 1 {
+			idOffset = 20
+		}
+		if len(data) < idOffset+4 {
+			continue
+		}
+		tracks[binary.BigEndian.Uint32(data[idOffset:])] = trak
+
+		if chap, err := findPath(r, trak, "tref", "chap"); err != nil {
+			return nil, err
+		} else if chap != nil {
+			ids, err := readContent(r, *chap)
+			if err != nil {
+				return nil, err
+			}
+			for i := 0; i+4 <= len(ids); i += 4 {
+				chapterIDs = append(chapterIDs, binary.BigEndian.Uint32(ids[i:]))
+			}
+		}
+	}
+
+	for _, id := range chapterIDs {
+		if trak, ok := tracks[id]; ok {
+			return readTextTrack(r, trak)
+		}
+	}
+	return nil, nil
+}
+
+// sampleTable holds what is needed to locate and time the samples of a track
+type sampleTable struct {
+	timescale uint32
+	durations []uint32 // Duration of each sample in timescale units
+	sizes     []uint32 // Size of each sample
+	offsets   []int64  // File offset of each sample
+}
+
+// readTextTrack reads the samples of a QuickTime text track as chapters: a 16-bit length followed by the title
+func readTextTrack(r io.ReaderAt, trak box) ([]Chapter, error) {
+	table, err := readSampleTable(r, trak)
+	if err != nil {
+		return nil, err
+	}
+
+	chapters := make([]Chapter, 0, len(table.sizes))
+	var start uint64
+	for i, size := range table.sizes {
+		title := ""
+		if size >= 2 {
+			data := make([]byte, min(size, 1<<16+2))
+			if _, err := r.ReadAt(data, table.offsets[i]); err != nil && !errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("Failed to read chapter title: %w", err)
+			}
+			length := int(binary.BigEndian.Uint16(data))
+			title = decodeText(data[2:min(2+length, len(data))])
+		}
+		chapters = append(chapters, Chapter{
+			Title:     title,
+			StartTime: time.Duration(start * uint64(time.Second) / uint64(table.timescale)),
+		})
+		if i < len(table.durations) {
+			start += uint64(table.durations[i])
+		}
+	}
+	return chapters, nil
+}
+
+// decodeText decodes a text sample, which is UTF-8 unless it starts with a UTF-16 byte order mark
+func decodeText(data []byte) string {
+	if len(data) >= 2 && data[0] == 0xFE && data[1] == 0xFF {
+		units := make([]uint16, 0, len(data)/2)
+		for i := 2; i+1 < len(data); i += 2 {
+			units = append(units, binary.BigEndian.Uint16(data[i:]))
+		}
+		return string(utf16.Decode(units))
+	}
+	return string(data)
+}
+
+// readSampleTable reads the timescale and the sample durations, sizes and offsets of a track
+func readSampleTable(r io.ReaderAt, trak box) (*sampleTable, error) {
+	mdhd, err := findPath(r, trak, "mdia", "mdhd")
+	if err != nil {
+		return nil, err
+	}
+	stbl, err := findPath(r, trak, "mdia", "minf", "stbl")
+	if err != nil {
+		return nil, err
+	}
+	if mdhd == nil || stbl == nil {
+		return nil, fmt.Errorf("Chapter track has no media header or sample table")
+	}
+
+	table := &sampleTable{}
+	data, err := readContent(r, *mdhd)
+	if err != nil {
+		return nil, err
+	}
+	timescaleOffset := 12
+	if len(data) > 0 && data[0] == 1 {
+		timescaleOffset = 20
+	}
+	if len(data) < timescaleOffset+4 {
+		return nil, fmt.Errorf("Invalid media header")
+	}
+	table.timescale = binary.BigEndian.Uint32(data[timescaleOffset:])
+	if table.timescale == 0 {
+		return nil, fmt.Errorf("Chapter track has a timescale of 0")
+	}
+
+	read := func(kind string) ([]byte, error) {
+		b, err := findBox(r, *stbl, kind)
+		if err != nil || b == nil {
+			return nil, err
+		}
+		return readContent(r, *b)
+	}
+
+	// Sample durations (stts): runs of count and duration
+	stts, err := read("stts")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range tableEntries(stts, 8) {
+		for n := binary.BigEndian.Uint32(entry); n > 0 && len(table.durations) < 1<<16; n-- {
+			table.durations = append(table.durations, binary.BigEndian.Uint32(entry[4:]))
+		}
+	}
+
+	// Sample sizes (stsz): a fixed size or a size per sample
+	stsz, err := read("stsz")
+	if err != nil {
+		return nil, err
+	}
+	if len(stsz) < 12 {
+		return nil, fmt.Errorf("Chapter track has no sample sizes")
+	}
+	fixed, count := binary.BigEndian.Uint32(stsz[4:]), int(binary.BigEndian.Uint32(stsz[8:]))
+	count = min(count, 1<<16)
+	for i := 0; i < count; i++ {
+		if fixed != 0 {
+			table.sizes = append(table.sizes, fixed)
+		} else if 12+4*i+4 <= len(stsz) {
+			table.sizes = append(table.sizes, binary.BigEndian.Uint32(stsz[12+4*i:]))
+		}
+	}
+
+	// Chunk offsets (stco or co64)
+	var chunks []int64
+	if stco, err := read("stco"); err != nil {
+		return nil, err
+	} else if stco != nil {
+		for _, entry := range tableEntries(stco, 4) {
+			chunks = append(chunks, int64(binary.BigEndian.Uint32(entry)))
+		}
+	} else if co64, err := read("co64"); err != nil {
+		return nil, err
+	} else {
+		for _, entry := range tableEntries(co64, 8) {
+			chunks = append(chunks, int64(binary.BigEndian.Uint64(entry)))
+		}
+	}
+
+	// Samples per chunk (stsc): runs starting at a 1-based chunk number
+	stsc, err := read("stsc")
+	if err != nil {
+		return nil, err
+	}
+	runs := tableEntries(stsc, 12)
+	sort.SliceStable(runs, func(i, j int) bool {
+		return binary.BigEndian.Uint32(runs[i]) < binary.BigEndian.Uint32(runs[j])
+	})
+	sample := 0
+	for chunk := range chunks {
+		perChunk := uint32(1)
+		for _, run := range runs {
+			if int(binary.BigEndian.Uint32(run))-1 <= chunk {
+				perChunk = binary.BigEndian.Uint32(run[4:])
+			}
+		}
+		offset := chunks[chunk]
+		for n := uint32(0); n < perChunk && sample < len(table.sizes); n++ {
+			table.offsets = append(table.offsets, offset)
+			offset += int64(table.sizes[sample])
+			sample++
+		}
+	}
+	table.sizes = table.sizes[:len(table.offsets)]
+	return table, nil
+}
+
+// tableEntries splits the entries of a full box holding a 32-bit entry count followed by entries of a fixed size
+func tableEntries(data []byte, size int) [][]byte {
+	if len(data) < 8 {
+		return nil
+	}
+	count := int(binary.BigEndian.Uint32(data[4:]))
+	var entries [][]byte
+	for i := 0; i < count && 8+(i+1)*size <= len(data); i++ {
+		entries = append(entries, data[8+i*size:8+(i+1)*size])
+	}
+	return entries
+}
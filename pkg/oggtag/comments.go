@@ -0,0 +1,102 @@
+package oggtag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// codec describes how a codec stores its header packets
+type codec struct {
+	name          string // Codec name for messages
+	headerCount   int    // Number of header packets
+	commentPrefix string // Magic at the start of the comment packet
+	framingBit    bool   // Whether the comment packet ends with a framing bit
+}
+
+var (
+	opusCodec   = codec{name: "Opus", headerCount: 2, commentPrefix: "OpusTags", framingBit: false}
+	vorbisCodec = codec{name: "Vorbis", headerCount: 3, commentPrefix: "\x03vorbis", framingBit: true}
+)
+
+// detectCodec determines the codec from the identification header packet
+func detectCodec(packet []byte) (codec, error) {
+	switch {
+	case bytes.HasPrefix(packet, []byte("OpusHead")):
+		return opusCodec, nil
+	case bytes.HasPrefix(packet, []byte("\x01vorbis")):
+		return vorbisCodec, nil
+	}
+	return codec{}, fmt.Errorf("Unsupported Ogg codec (only Opus and Vorbis are supported)")
+}
+
+// commentBlock holds the contents of a Vorbis comment packet
+type commentBlock struct {
+	vendor   string   // Encoder vendor string
+	comments []string // Comments in KEY=value form
+}
+
+// parseComments decodes a comment header packet
+func parseComments(packet []byte, c codec) (*commentBlock, error) {
+	if !bytes.HasPrefix(packet, []byte(c.commentPrefix)) {
+		return nil, fmt.Errorf("%s comment header not found", c.name)
+	}
+	data := packet[len(c.commentPrefix):]
+
+	// Read a length-prefixed string
+	readString := func() (string, error) {
+		if len(data) < 4 {
+			return "", fmt.Errorf("%s comment header is truncated", c.name)
+		}
+		length := binary.LittleEndian.Uint32(data)
+		data = data[4:]
+		if uint64(length) > uint64(len(data)) {
+			return "", fmt.Errorf("%s comment header is truncated", c.name)
+		}
+		value := string(data[:length])
+		data = data[length:]
+		return value, nil
+	}
+
+	vendor, err := readString()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < 4 {
+		return nil, fmt.Errorf("%s comment header is truncated", c.name)
+	}
+	count := binary.LittleEndian.Uint32(data)
+	data = data[4:]
+
+	block := &commentBlock{vendor: vendor}
+	for i := uint32(0); i < count; i++ {
+		comment, err := readString()
+		if err != nil {
+			return nil, err
+		}
+		block.comments = append(block.comments, comment)
+	}
+
+	return block, nil
+}
+
+// encode serializes the comment block as a comment header packet
+func (b *commentBlock) encode(c codec) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(c.commentPrefix)
+
+	binary.Write(&buf, binary.LittleEndian, uint32(len(b.vendor)))
+	buf.WriteString(b.vendor)
+
+	binary.Write(&buf, binary.LittleEndian, uint32(len(b.comments)))
+	for _, comment := range b.comments {
+		binary.Write(&buf, binary.LittleEndian, uint32(len(comment)))
+		buf.WriteString(comment)
+	}
+
+	if c.framingBit {
+		buf.WriteByte(1)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,154 @@
+package oggtag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Ogg page header type flags
+const (
+	flagContinued = 0x01 // Page starts with a continued packet
+	flagBOS       = 0x02 // First page of a logical stream
+	flagEOS       = 0x04 // Last page of a logical stream
+)
+
+// maxSegments is the maximum number of lacing values in a single page
+const maxSegments = 255
+
+// page represents a single Ogg page
+type page struct {
+	headerType byte   // Header type flags
+	granule    uint64 // Granule position
+	serial     uint32 // Bitstream serial number
+	sequence   uint32 // Page sequence number
+	segments   []byte // Lacing values
+	data       []byte // Page payload
+}
+
+// crcTable is the lookup table for the Ogg CRC-32 (polynomial 0x04c11db7, no reflection)
+var crcTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		r := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		table[i] = r
+	}
+	return table
+}()
+
+// readPage reads the next page from r; it returns io.EOF at the end of the stream
+func readPage(r io.Reader) (*page, error) {
+	// Read fixed header
+	header := make([]byte, 27)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("Truncated Ogg page header")
+		}
+		return nil, err
+	}
+	if string(header[:4]) != "OggS" {
+		return nil, fmt.Errorf("Invalid Ogg page (capture pattern not found)")
+	}
+	if header[4] != 0 {
+		return nil, fmt.Errorf("Unsupported Ogg version %d", header[4])
+	}
+
+	p := &page{
+		headerType: header[5],
+		granule:    binary.LittleEndian.Uint64(header[6:14]),
+		serial:     binary.LittleEndian.Uint32(header[14:18]),
+		sequence:   binary.LittleEndian.Uint32(header[18:22]),
+		segments:   make([]byte, header[26]),
+	}
+
+	// Read segment table and payload
+	if _, err := io.ReadFull(r, p.segments); err != nil {
+		return nil, fmt.Errorf("Truncated Ogg segment table")
+	}
+	size := 0
+	for _, lacing := range p.segments {
+		size += int(lacing)
+	}
+	p.data = make([]byte, size)
+	if _, err := io.ReadFull(r, p.data); err != nil {
+		return nil, fmt.Errorf("Truncated Ogg page data")
+	}
+
+	return p, nil
+}
+
+// encode serializes the page including its checksum
+func (p *page) encode() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("OggS")
+	buf.WriteByte(0) // Version
+	buf.WriteByte(p.headerType)
+	binary.Write(&buf, binary.LittleEndian, p.granule)
+	binary.Write(&buf, binary.LittleEndian, p.serial)
+	binary.Write(&buf, binary.LittleEndian, p.sequence)
+	buf.Write([]byte{0, 0, 0, 0}) // Checksum placeholder
+	buf.WriteByte(byte(len(p.segments)))
+	buf.Write(p.segments)
+	buf.Write(p.data)
+
+	data := buf.Bytes()
+	binary.LittleEndian.PutUint32(data[22:26], checksum(data))
+	return data
+}
+
+// endsPacket reports whether the last packet on the page is complete
+func (p *page) endsPacket() bool {
+	return len(p.segments) > 0 && p.segments[len(p.segments)-1] < 255
+}
+
+// checksum computes the Ogg CRC-32 of data
+func checksum(data []byte) uint32 {
+	var crc uint32
+	for _, b := range data {
+		crc = crc<<8 ^ crcTable[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+// paginate splits packets into pages of one logical stream, starting at the given sequence number
+func paginate(packets [][]byte, serial uint32, sequence uint32) []*page {
+	var pages []*page
+	current := &page{serial: serial, sequence: sequence}
+
+	for _, packet := range packets {
+		remaining := packet
+		for {
+			// Start a new page when the segment table is full
+			if len(current.segments) == maxSegments {
+				pages = append(pages, current)
+				sequence++
+				current = &page{serial: serial, sequence: sequence}
+				if len(remaining) < len(packet) {
+					current.headerType = flagContinued
+				}
+			}
+
+			// Add one lacing value; a value below 255 terminates the packet
+			n := min(len(remaining), 255)
+			current.segments = append(current.segments, byte(n))
+			current.data = append(current.data, remaining[:n]...)
+			remaining = remaining[n:]
+			if n < 255 {
+				break
+			}
+		}
+	}
+
+	if len(current.segments) > 0 {
+		pages = append(pages, current)
+	}
+	return pages
+}
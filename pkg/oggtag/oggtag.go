@@ -0,0 +1,365 @@
+package oggtag
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/lockfile"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/prompt"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/safefile"
+)
+
+// Chapter represents a single chapter stored as CHAPTERxxx comments
+type Chapter struct {
+	Title     string        // Chapter title
+	StartTime time.Duration // Start time of the chapter
+}
+
+// chapterKeyPattern matches CHAPTERxxx, CHAPTERxxxNAME and CHAPTERxxxURL comment keys
+var chapterKeyPattern = regexp.MustCompile(`^CHAPTER(\d+)(NAME|URL)?$`)
+
+// headers holds the header packets of an Ogg stream and the pages they occupy
+type headers struct {
+	codec   codec    // Detected codec
+	first   *page    // First page containing the identification header
+	pages   []*page  // Pages containing the remaining header packets
+	packets [][]byte // All header packets
+}
+
+// IsOggFile reports whether path has an Ogg audio file extension
+func IsOggFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ogg", ".oga", ".opus":
+		return true
+	}
+	return false
+}
+
+// AddChapters writes chapter comments into an Ogg Opus or Vorbis file, asking prompter before an existing file is modified
+func AddChapters(oggPath string, markers []csvparser.MarkerEntry, outputPath string, prompter *prompt.Prompter) error {
+	// If output path is not specified, create a new filename with "_with_chapters" suffix
+	if outputPath == "" {
+		ext := filepath.Ext(oggPath)
+		outputPath = oggPath[:len(oggPath)-len(ext)] + "_with_chapters" + ext
+	}
+
+	// Keep other processes from writing the same output at the same time
+	lock, err := lockfile.Acquire(outputPath)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	// Confirm before modifying the original file or overwriting an existing one
+	if oggPath == outputPath {
+		if err := prompter.Confirm(fmt.Sprintf("This will modify the original file '%s'. Continue? (y/n): ", oggPath)); err != nil {
+			return err
+		}
+	} else if fileExists(outputPath) {
+		if err := prompter.Confirm(fmt.Sprintf("File '%s' already exists. Overwrite? (y/n): ", outputPath)); err != nil {
+			return err
+		}
+	}
+
+	// Ensure output directory exists
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("Failed to create output directory: %w", err)
+	}
+
+	// Write to a temporary file first
+	tempPath, err := safefile.TempPath(outputPath)
+	if err != nil {
+		return err
+	}
+	if err := rewriteWithChapters(oggPath, markers, tempPath); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	// On success, move the temporary file to the final output file
+	if err := safefile.Replace(tempPath, outputPath); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("Failed to create final file: %w", err)
+	}
+
+	return nil
+}
+
+// rewriteWithChapters copies an Ogg file to outputPath with a rebuilt comment header
+func rewriteWithChapters(oggPath string, markers []csvparser.MarkerEntry, outputPath string) error {
+	// Open input file
+	inputFile, err := os.Open(oggPath)
+	if err != nil {
+		return fmt.Errorf("Cannot open Ogg file: %w", err)
+	}
+	defer inputFile.Close()
+	reader := bufio.NewReader(inputFile)
+
+	// Read header packets
+	h, err := readHeaders(reader)
+	if err != nil {
+		return err
+	}
+
+	// Replace chapter comments
+	block, err := parseComments(h.packets[1], h.codec)
+	if err != nil {
+		return err
+	}
+	block.comments = append(removeChapterComments(block.comments), chapterComments(markers)...)
+
+	// Paginate the new header packets
+	newPackets := append([][]byte{block.encode(h.codec)}, h.packets[2:]...)
+	newPages := paginate(newPackets, h.first.serial, h.first.sequence+1)
+	delta := int64(len(newPages)) - int64(len(h.pages))
+
+	// Create output file
+	outputFile, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("Failed to create temporary file: %w", err)
+	}
+	defer outputFile.Close()
+	writer := bufio.NewWriter(outputFile)
+
+	// Write header pages
+	writer.Write(h.first.encode())
+	for _, p := range newPages {
+		writer.Write(p.encode())
+	}
+
+	// Copy audio pages, renumbering pages of this stream
+	for {
+		p, err := readPage(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if p.serial == h.first.serial {
+			p.sequence = uint32(int64(p.sequence) + delta)
+		}
+		if _, err := writer.Write(p.encode()); err != nil {
+			return fmt.Errorf("Failed to write Ogg page: %w", err)
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("Failed to write Ogg file: %w", err)
+	}
+	return nil
+}
+
+// ReadChapters reads chapter information from an Ogg Opus or Vorbis file
+func ReadChapters(oggPath string) ([]Chapter, error) {
+	// Open Ogg file
+	file, err := os.Open(oggPath)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot open Ogg file: %w", err)
+	}
+	defer file.Close()
+
+	// Read comment header
+	h, err := readHeaders(bufio.NewReader(file))
+	if err != nil {
+		return nil, err
+	}
+	block, err := parseComments(h.packets[1], h.codec)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect chapter comments by number
+	byNumber := make(map[string]*Chapter)
+	for _, comment := range block.comments {
+		key, value, ok := strings.Cut(comment, "=")
+		if !ok {
+			continue
+		}
+		m := chapterKeyPattern.FindStringSubmatch(strings.ToUpper(key))
+		if m == nil {
+			continue
+		}
+
+		chapter := byNumber[m[1]]
+		if chapter == nil {
+			chapter = &Chapter{}
+			byNumber[m[1]] = chapter
+		}
+
+		switch m[2] {
+		case "":
+			startTime, err := parseChapterTime(value)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid time in %s: %w", key, err)
+			}
+			chapter.StartTime = startTime
+		case "NAME":
+			chapter.Title = value
+		}
+	}
+
+	// Sort chapters by start time
+	chapters := make([]Chapter, 0, len(byNumber))
+	for _, chapter := range byNumber {
+		chapters = append(chapters, *chapter)
+	}
+	sort.Slice(chapters, func(i, j int) bool {
+		return chapters[i].StartTime < chapters[j].StartTime
+	})
+
+	return chapters, nil
+}
+
+// readHeaders reads pages until all header packets of the first logical stream are complete
+func readHeaders(r io.Reader) (*headers, error) {
+	// Read first page
+	first, err := readPage(r)
+	if err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("Ogg file is empty")
+		}
+		return nil, err
+	}
+	if first.headerType&flagBOS == 0 {
+		return nil, fmt.Errorf("First Ogg page is not a beginning-of-stream page")
+	}
+
+	h := &headers{first: first}
+
+	// The identification header must be alone on the first page
+	packets := splitPackets(first)
+	if len(packets) != 1 || !first.endsPacket() {
+		return nil, fmt.Errorf("Unexpected layout of the Ogg identification header")
+	}
+	h.packets = packets
+	if h.codec, err = detectCodec(packets[0]); err != nil {
+		return nil, err
+	}
+
+	// Assemble remaining header packets
+	var pending []byte
+	for len(h.packets) < h.codec.headerCount {
+		p, err := readPage(r)
+		if err != nil {
+			if err == io.EOF {
+				return nil, fmt.Errorf("Ogg file ends before the %s headers are complete", h.codec.name)
+			}
+			return nil, err
+		}
+		if p.serial != first.serial {
+			return nil, fmt.Errorf("Multiplexed Ogg streams are not supported")
+		}
+		h.pages = append(h.pages, p)
+
+		offset := 0
+		for i, lacing := range p.segments {
+			pending = append(pending, p.data[offset:offset+int(lacing)]...)
+			offset += int(lacing)
+			if lacing < 255 {
+				h.packets = append(h.packets, pending)
+				pending = nil
+
+				// Audio data must start on a fresh page
+				if len(h.packets) == h.codec.headerCount && i != len(p.segments)-1 {
+					return nil, fmt.Errorf("Audio data shares a page with the %s headers", h.codec.name)
+				}
+			}
+		}
+	}
+
+	return h, nil
+}
+
+// splitPackets returns the complete packets that start on a page
+func splitPackets(p *page) [][]byte {
+	var packets [][]byte
+	var current []byte
+	offset := 0
+	for _, lacing := range p.segments {
+		current = append(current, p.data[offset:offset+int(lacing)]...)
+		offset += int(lacing)
+		if lacing < 255 {
+			packets = append(packets, current)
+			current = nil
+		}
+	}
+	return packets
+}
+
+// removeChapterComments drops existing chapter comments
+func removeChapterComments(comments []string) []string {
+	var result []string
+	for _, comment := range comments {
+		key, _, _ := strings.Cut(comment, "=")
+		if chapterKeyPattern.MatchString(strings.ToUpper(key)) {
+			continue
+		}
+		result = append(result, comment)
+	}
+	return result
+}
+
+// chapterComments converts markers into CHAPTERxxx and CHAPTERxxxNAME comments
+func chapterComments(markers []csvparser.MarkerEntry) []string {
+	var comments []string
+	index := 0
+	for _, marker := range markers {
+		// Skip markers with empty names
+		if strings.TrimSpace(marker.Name) == "" {
+			continue
+		}
+
+		comments = append(comments,
+			fmt.Sprintf("CHAPTER%03d=%s", index, formatChapterTime(marker.StartTime)),
+			fmt.Sprintf("CHAPTER%03dNAME=%s", index, marker.Name),
+		)
+		index++
+	}
+	return comments
+}
+
+// formatChapterTime formats a duration as HH:MM:SS.mmm
+func formatChapterTime(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// parseChapterTime parses a HH:MM:SS.mmm chapter time
+func parseChapterTime(value string) (time.Duration, error) {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("expected HH:MM:SS.mmm, got '%s'", value)
+	}
+
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid hours in '%s'", value)
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid minutes in '%s'", value)
+	}
+	seconds, err := csvparser.ParseSeconds(parts[2])
+	if err != nil {
+		return 0, fmt.Errorf("invalid seconds in '%s'", value)
+	}
+
+	total := time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute
+	return total + seconds.Round(time.Millisecond), nil
+}
+
+// fileExists checks if a file exists
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
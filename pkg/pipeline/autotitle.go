@@ -0,0 +1,168 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/mp3frame"
+)
+
+// UntitledMarker matches the default marker names Adobe Audition assigns ("Marker 01")
+var UntitledMarker = regexp.MustCompile(`^(?i:marker)\s*\d+$`)
+
+// sentenceEnd matches the end of the first sentence in a transcription
+var sentenceEnd = regexp.MustCompile(`[.!?。！？]`)
+
+// maxAutoTitleLength is the longest generated chapter title, in characters
+const maxAutoTitleLength = 80
+
+// autoTitleMarkers titles untitled markers with the first sentence the transcription command outputs for the audio after each marker
+func autoTitleMarkers(audioPath string, markers []csvparser.MarkerEntry, options Options) ([]csvparser.MarkerEntry, error) {
+	report := options.reporter()
+	titled := make([]csvparser.MarkerEntry, len(markers))
+	copy(titled, markers)
+
+	for i, marker := range markers {
+		if !UntitledMarker.MatchString(strings.TrimSpace(marker.Name)) {
+			continue
+		}
+
+		// Transcribe the audio from the marker up to the window or the next marker
+		end := marker.StartTime + options.TitleWindow
+		if i+1 < len(markers) && markers[i+1].StartTime < end {
+			end = markers[i+1].StartTime
+		}
+		text, err := transcribeRange(audioPath, marker.StartTime, end, options.TitleCommand, options.TitleStderr)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to transcribe '%s': %w", marker.Name, err)
+		}
+
+		title := firstSentence(text)
+		if title == "" {
+			report.Printf("Kept '%s': transcription was empty", marker.Name)
+			continue
+		}
+		report.Printf("Titled '%s' at %s: %s", marker.Name, id3tag.FormatDuration(marker.StartTime), title)
+		titled[i].Name = title
+	}
+
+	return titled, nil
+}
+
+// transcribeRange cuts the audio between from and to into a temporary file and returns the command's output for it
+func transcribeRange(audioPath string, from, to time.Duration, command string, stderr io.Writer) (string, error) {
+	// Write the excerpt
+	excerpt, err := os.CreateTemp("", "audition-marker-*.mp3")
+	if err != nil {
+		return "", fmt.Errorf("Failed to create temporary file: %w", err)
+	}
+	defer os.Remove(excerpt.Name())
+	err = mp3frame.Cut(excerpt, audioPath, from, to)
+	excerpt.Close()
+	if err != nil {
+		return "", err
+	}
+
+	// Split command line and substitute placeholders
+	args, err := SplitCommandLine(command)
+	if err != nil {
+		return "", err
+	}
+	if len(args) == 0 {
+		return "", fmt.Errorf("Transcription command is empty")
+	}
+	replacer := strings.NewReplacer(
+		"{audio}", excerpt.Name(),
+		"{input}", audioPath,
+		"{start}", fmt.Sprintf("%.3f", from.Seconds()),
+	)
+	for i, arg := range args {
+		args[i] = replacer.Replace(arg)
+	}
+
+	// Run the command and capture its output
+	var stdout bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("Transcription command '%s' failed: %w", filepath.Base(args[0]), err)
+	}
+
+	return stdout.String(), nil
+}
+
+// firstSentence returns the first sentence of text on a single line, shortened to maxAutoTitleLength characters
+func firstSentence(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if loc := sentenceEnd.FindStringIndex(text); loc != nil {
+		text = text[:loc[0]]
+	}
+
+	runes := []rune(strings.TrimSpace(text))
+	if len(runes) > maxAutoTitleLength {
+		return strings.TrimSpace(string(runes[:maxAutoTitleLength-1])) + "…"
+	}
+	return string(runes)
+}
+
+// SplitCommandLine splits a command string into arguments, honoring single quotes, double quotes and backslash escapes
+func SplitCommandLine(command string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case quote != 0:
+			// Inside quotes: only the matching quote ends the section
+			if r == quote {
+				quote = 0
+			} else if r == '\\' && quote == '"' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\') {
+				i++
+				current.WriteRune(runes[i])
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == '\\' && i+1 < len(runes) && strings.ContainsRune(" \t'\"\\", runes[i+1]):
+			// Only escape special characters so Windows paths keep their separators
+			i++
+			current.WriteRune(runes[i])
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("Unterminated quote in command: %s", command)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
@@ -0,0 +1,180 @@
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+)
+
+// Strategies for markers of different sources at the same time
+const (
+	ConflictPreferCSV      = "prefer-csv"      // Markers from Audition CSV files win, then the earlier marker file
+	ConflictPreferExisting = "prefer-existing" // Existing chapters of the input win, then the earlier marker file
+	ConflictNewestWins     = "newest-wins"     // The most recently modified file wins
+	ConflictInteractive    = "interactive"     // Options.Choose picks the marker for each conflict
+)
+
+// ConflictStrategies lists the valid values of Options.OnConflict
+var ConflictStrategies = []string{ConflictPreferCSV, ConflictPreferExisting, ConflictNewestWins, ConflictInteractive}
+
+// Source describes where a list of markers comes from
+type Source struct {
+	Name    string    // Path or URL of the marker file, or "existing chapter"
+	CSV     bool      // Whether the file is an Audition CSV file
+	ModTime time.Time // Modification time of the file (zero for URLs)
+}
+
+// Candidate is a marker or an existing chapter taking part in a conflict
+type Candidate struct {
+	Marker    csvparser.MarkerEntry
+	Source    Source // Where the marker comes from
+	ElementID string // Element ID of an existing chapter (empty for markers)
+	source    int    // Index of the marker source, or -1 for an existing chapter
+	index     int    // Index of the marker in its source
+}
+
+// resolveConflicts finds pairs of markers of different sources that start within the window of each other but have different titles,
+// keeps one of each pair according to the strategy and reports the decisions. Markers of the same source never conflict,
+// and a dropped marker takes part in no further conflicts.
+// It returns the sources without the dropped markers and the element IDs of the dropped existing chapters.
+func resolveConflicts(options Options, sources [][]csvparser.MarkerEntry, infos []Source, existingInfo Source) ([][]csvparser.MarkerEntry, []string, error) {
+	report := options.reporter()
+	window := options.ConflictWindow
+	if window <= 0 {
+		window = time.Millisecond
+	}
+	strategy := options.OnConflict
+	if strategy == "" {
+		strategy = ConflictPreferCSV
+	}
+	if !slices.Contains(ConflictStrategies, strategy) {
+		return nil, nil, fmt.Errorf("Invalid conflict strategy '%s': use %s", strategy, strings.Join(ConflictStrategies, ", "))
+	}
+	if strategy == ConflictInteractive && options.Choose == nil {
+		return nil, nil, fmt.Errorf("Conflict strategy '%s' needs a function choosing the marker to keep", strategy)
+	}
+
+	var candidates []Candidate
+	for i, markers := range sources {
+		for j, marker := range markers {
+			candidates = append(candidates, Candidate{Marker: marker, Source: infos[i], source: i, index: j})
+		}
+	}
+	for _, chapter := range options.Existing {
+		candidates = append(candidates, Candidate{
+			Marker:    csvparser.MarkerEntry{Name: chapter.Title, StartTime: chapter.StartTime},
+			Source:    existingInfo,
+			ElementID: chapter.ElementID,
+			source:    -1,
+		})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Marker.StartTime < candidates[j].Marker.StartTime })
+
+	// Resolve each conflicting pair in start time order
+	lost := make([]bool, len(candidates))
+	conflicts := 0
+	for i := range candidates {
+		for j := i + 1; j < len(candidates) && !lost[i]; j++ {
+			if candidates[j].Marker.StartTime-candidates[i].Marker.StartTime > window {
+				break
+			}
+			if lost[j] || !isConflict(candidates[i], candidates[j]) {
+				continue
+			}
+
+			conflicts++
+			pair := []Candidate{candidates[i], candidates[j]}
+			winner, err := chooseCandidate(strategy, pair, options.Choose)
+			if err != nil {
+				return nil, nil, err
+			}
+			loser := j
+			if winner == 1 {
+				loser = i
+			}
+			lost[loser] = true
+			keep, drop := pair[winner], pair[1-winner]
+			report.Printf("Conflict at %s: kept '%s' (%s), dropped '%s' (%s)", id3tag.FormatDuration(keep.Marker.StartTime), keep.Marker.Name, keep.Source.Name, drop.Marker.Name, drop.Source.Name)
+		}
+	}
+	if conflicts > 0 {
+		report.Printf("Resolved %d conflicts (%s)", conflicts, strategy)
+		if strategy != ConflictInteractive {
+			report.Record(fmt.Sprintf("%d conflicting markers dropped by -on-conflict %s", conflicts, strategy))
+		}
+	}
+
+	// Remove the dropped markers from their sources
+	dropped := make(map[[2]int]bool)
+	var replaced []string
+	for i, candidate := range candidates {
+		if !lost[i] {
+			continue
+		}
+		if candidate.source < 0 {
+			replaced = append(replaced, candidate.ElementID)
+		} else {
+			dropped[[2]int{candidate.source, candidate.index}] = true
+		}
+	}
+	resolved := make([][]csvparser.MarkerEntry, len(sources))
+	for i, markers := range sources {
+		for j, marker := range markers {
+			if !dropped[[2]int{i, j}] {
+				resolved[i] = append(resolved[i], marker)
+			}
+		}
+	}
+	return resolved, replaced, nil
+}
+
+// isConflict reports whether two candidates come from different sources and have different titles
+func isConflict(a, b Candidate) bool {
+	return a.source != b.source && !strings.EqualFold(strings.TrimSpace(a.Marker.Name), strings.TrimSpace(b.Marker.Name))
+}
+
+// chooseCandidate returns the index of the candidate kept by the strategy
+func chooseCandidate(strategy string, group []Candidate, choose func([]Candidate) (int, error)) (int, error) {
+	if strategy == ConflictInteractive {
+		return choose(group)
+	}
+
+	// Rank candidates by the strategy; earlier marker files win ties, and existing chapters come last
+	rank := func(candidate Candidate) (int, int64) {
+		switch strategy {
+		case ConflictPreferCSV:
+			if candidate.Source.CSV {
+				return 0, 0
+			}
+		case ConflictPreferExisting:
+			if candidate.source < 0 {
+				return 0, 0
+			}
+		case ConflictNewestWins:
+			return 0, -candidate.Source.ModTime.UnixNano()
+		}
+		return 1, 0
+	}
+	order := func(candidate Candidate) int {
+		if candidate.source < 0 {
+			return math.MaxInt // After all marker files
+		}
+		return candidate.source
+	}
+
+	best := 0
+	for i, candidate := range group[1:] {
+		primary, secondary := rank(candidate)
+		bestPrimary, bestSecondary := rank(group[best])
+		if primary < bestPrimary || (primary == bestPrimary && (secondary < bestSecondary || (secondary == bestSecondary && order(candidate) < order(group[best])))) {
+			best = i + 1
+		}
+	}
+	return best, nil
+}
@@ -0,0 +1,135 @@
+package pipeline
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+)
+
+// at returns a marker starting at the given number of milliseconds
+func at(name string, ms int) csvparser.MarkerEntry {
+	return csvparser.MarkerEntry{Name: name, StartTime: time.Duration(ms) * time.Millisecond}
+}
+
+// names returns the names of markers
+func names(markers []csvparser.MarkerEntry) []string {
+	var result []string
+	for _, marker := range markers {
+		result = append(result, marker.Name)
+	}
+	return result
+}
+
+func TestResolveConflicts(t *testing.T) {
+	infos := []Source{{Name: "a.csv", CSV: true}, {Name: "b.csv", CSV: true}}
+	existingInfo := Source{Name: "existing chapter"}
+	tests := []struct {
+		name     string
+		options  Options
+		infos    []Source // Sources of the markers (default: two CSV files)
+		sources  [][]csvparser.MarkerEntry
+		want     [][]string
+		replaced []string
+	}{
+		{
+			name:    "same source markers within the window are kept",
+			options: Options{ConflictWindow: 500 * time.Millisecond},
+			sources: [][]csvparser.MarkerEntry{{at("Intro", 0), at("Cold open", 200)}, {at("Opening", 100)}},
+			want:    [][]string{{"Intro", "Cold open"}, nil},
+		},
+		{
+			name:    "conflicts are not chained through a dropped marker",
+			options: Options{ConflictWindow: 500 * time.Millisecond},
+			sources: [][]csvparser.MarkerEntry{{at("A", 0), at("C", 800)}, {at("B", 400)}},
+			want:    [][]string{{"A", "C"}, nil},
+		},
+		{
+			name:    "same titles are duplicates, not conflicts",
+			options: Options{ConflictWindow: 500 * time.Millisecond},
+			sources: [][]csvparser.MarkerEntry{{at("Intro", 0)}, {at("intro ", 100)}},
+			want:    [][]string{{"Intro"}, {"intro "}},
+		},
+		{
+			name: "zero window treats markers within a millisecond as conflicts",
+			sources: [][]csvparser.MarkerEntry{
+				{{Name: "Intro", StartTime: 1000400 * time.Microsecond}},
+				{{Name: "Opening", StartTime: 1000100 * time.Microsecond}, at("Talk", 1002)},
+			},
+			want: [][]string{{"Intro"}, {"Talk"}},
+		},
+		{
+			name:    "newest file wins with newest-wins",
+			options: Options{OnConflict: ConflictNewestWins},
+			infos: []Source{
+				{Name: "a.csv", CSV: true, ModTime: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)},
+				{Name: "b.csv", CSV: true, ModTime: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+				{Name: "c.csv", CSV: true, ModTime: time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC)},
+			},
+			sources: [][]csvparser.MarkerEntry{{at("A", 0), at("A2", 5000)}, {at("B", 0), at("B2", 5000)}, {at("C", 5000)}},
+			want:    [][]string{{"A"}, nil, {"C"}},
+		},
+		{
+			name: "existing chapters win with prefer-existing",
+			options: Options{OnConflict: ConflictPreferExisting, Existing: []id3tag.Chapter{
+				{ElementID: "chp0", Title: "Old intro", StartTime: 0},
+				{ElementID: "chp1", Title: "Talk", StartTime: 10 * time.Second},
+			}},
+			sources:  [][]csvparser.MarkerEntry{{at("Intro", 0), at("Guest", 10000)}, {at("Music", 5000)}},
+			want:     [][]string{nil, {"Music"}},
+			replaced: nil,
+		},
+		{
+			name: "markers replace existing chapters with prefer-csv",
+			options: Options{Existing: []id3tag.Chapter{
+				{ElementID: "chp0", Title: "Old intro", StartTime: 0},
+			}},
+			sources:  [][]csvparser.MarkerEntry{{at("Intro", 0)}, {at("Music", 5000)}},
+			want:     [][]string{{"Intro"}, {"Music"}},
+			replaced: []string{"chp0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sourceInfos := infos
+			if tt.infos != nil {
+				sourceInfos = tt.infos
+			}
+			resolved, replaced, err := resolveConflicts(tt.options, tt.sources, sourceInfos, existingInfo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range tt.want {
+				if got := names(resolved[i]); !slices.Equal(got, tt.want[i]) {
+					t.Errorf("source %d kept %q, want %q", i, got, tt.want[i])
+				}
+			}
+			if !slices.Equal(replaced, tt.replaced) {
+				t.Errorf("replaced existing chapters %q, want %q", replaced, tt.replaced)
+			}
+		})
+	}
+}
+
+func TestResolveConflictsInteractive(t *testing.T) {
+	var asked [][]string
+	options := Options{OnConflict: ConflictInteractive, Choose: func(pair []Candidate) (int, error) {
+		asked = append(asked, []string{pair[0].Marker.Name, pair[1].Marker.Name})
+		return 1, nil
+	}}
+	sources := [][]csvparser.MarkerEntry{{at("Intro", 0)}, {at("Opening", 0)}}
+	resolved, _, err := resolveConflicts(options, sources, []Source{{Name: "a.csv"}, {Name: "b.csv"}}, Source{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(asked) != 1 || len(resolved[0]) != 0 || len(resolved[1]) != 1 {
+		t.Errorf("asked %q and kept %v, want one question keeping the second marker", asked, resolved)
+	}
+
+	if _, _, err := resolveConflicts(Options{OnConflict: ConflictInteractive}, sources, []Source{{}, {}}, Source{}); err == nil {
+		t.Error("interactive strategy without Choose succeeded, want an error")
+	}
+}
@@ -0,0 +1,364 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/container"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/importer"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/mp3frame"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/remote"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/silence"
+)
+
+// Reporter receives the progress and warnings of the pipeline; the command line tool prints them
+type Reporter interface {
+	Printf(format string, args ...any) // Reports progress on a line of its own, such as an adjusted marker
+	Warnf(format string, args ...any)  // Reports a warning, such as markers that end up at the same time
+	Record(message string)             // Records an event reported with Printf that -strict treats as a warning, such as merged markers
+}
+
+// discard is the Reporter of pipelines without one
+type discard struct{}
+
+func (discard) Printf(string, ...any) {}
+func (discard) Warnf(string, ...any)  {}
+func (discard) Record(string)         {}
+
+// Options configures the pipeline; the zero value reads the marker files and keeps their markers unchanged
+type Options struct {
+	CSV    csvparser.ParseOptions // Options of Audition CSV files; sample positions without a sample rate use the rate of an MP3 input
+	Import importer.Options       // Options of marker files in other import formats
+	Track  string                 // Track whose markers are kept from marker files with a Track column (empty for all)
+
+	OnConflict     string                         // Strategy for markers of different files at the same time with different titles (default: prefer-csv)
+	ConflictWindow time.Duration                  // Largest start time difference of conflicting markers (default: 1ms)
+	Existing       []id3tag.Chapter               // Existing chapters kept when merging, which take part in conflicts
+	Choose         func([]Candidate) (int, error) // Asks which candidate of a conflict to keep with the interactive strategy
+
+	PartDelimiter string        // Separator of part names in marker names (empty to keep the names)
+	MinGap        time.Duration // Markers starting closer than this to the preceding one are merged into it (0 disables merging)
+	TitleCommand  string        // Transcription command titling untitled markers (empty to keep them)
+	TitleWindow   time.Duration // Length of audio transcribed for each title
+	TitleStderr   io.Writer     // Standard error of the transcription command (nil to discard)
+	ChapterTitles bool          // Whether the remaining untitled markers are titled "Chapter N"
+	Snap          time.Duration // Window for snapping markers to the nearest silence (0 disables snapping)
+	Round         time.Duration // Grid marker times are rounded to (0 disables rounding)
+	Align         bool          // Whether to round marker start times to MP3 frame boundaries
+	Number        bool          // Whether to number chapter titles
+	NumberStart   int           // First chapter number
+	NumberWidth   int           // Minimum digits of chapter numbers
+
+	Reporter Reporter // Receives progress and warnings (nil to discard them)
+}
+
+// reporter returns the reporter of the options
+func (o Options) reporter() Reporter {
+	if o.Reporter == nil {
+		return discard{}
+	}
+	return o.Reporter
+}
+
+// Read reads the markers of the marker files at paths for the audio file at audioPath, merging them if there are several.
+// The track selection applies to the files with a Track column, keeping all markers of the other files.
+// Markers of different files, and existing chapters, at the same time with different titles are resolved by OnConflict.
+// It returns the markers and the element IDs of the existing chapters dropped in conflicts.
+func Read(paths []string, audioPath string, options Options) ([]csvparser.MarkerEntry, []string, error) {
+	report := options.reporter()
+	sources := make([][]csvparser.MarkerEntry, 0, len(paths))
+	infos := make([]Source, 0, len(paths))
+	withTracks := 0
+	for _, path := range paths {
+		markers, err := readSource(path, audioPath, options)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// Keep only the markers of the selected track of a multitrack session
+		if options.Track != "" && len(csvparser.Tracks(markers)) > 0 {
+			if markers, err = csvparser.SelectTrack(markers, options.Track); err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", path, err)
+			}
+			withTracks++
+		}
+		sources = append(sources, markers)
+		infos = append(infos, newSource(path, IsAuditionSource(path)))
+	}
+	if options.Track != "" && withTracks == 0 {
+		return nil, nil, fmt.Errorf("No markers on track '%s': no marker file has a Track column", options.Track)
+	}
+
+	existingInfo := newSource(audioPath, false)
+	existingInfo.Name = "existing chapter"
+	sources, replaced, err := resolveConflicts(options, sources, infos, existingInfo)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	markers := sources[0]
+	if len(sources) > 1 {
+		var duplicates int
+		markers, duplicates = csvparser.MergeMarkers(sources...)
+		report.Printf("Merged %d markers from %d files (%d duplicates removed)", len(markers), len(sources), duplicates)
+		if duplicates > 0 {
+			report.Record(fmt.Sprintf("%d duplicate markers removed", duplicates))
+		}
+	}
+	if tracks := csvparser.Tracks(markers); options.Track == "" && len(tracks) > 1 {
+		report.Warnf("markers from %d tracks (%s) become chapters; select one with -track", len(tracks), strings.Join(tracks, ", "))
+	}
+	return markers, replaced, nil
+}
+
+// readSource reads the markers of a marker file: an Audition CSV file or URL, or a file in another import format.
+// Markers given in samples without a known sample rate are converted with the sample rate of an MP3 input, which usually matches the session.
+func readSource(path, audioPath string, options Options) ([]csvparser.MarkerEntry, error) {
+	report := options.reporter()
+	if !IsAuditionSource(path) {
+		format, _ := importer.Detect(path)
+		report.Printf("Reading %s file '%s'...", format.Name, path)
+		markers, err := importer.ReadFile(path, format, options.Import)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot read '%s': %w", path, err)
+		}
+		return markers, nil
+	}
+
+	report.Printf("Parsing CSV file '%s'...", path)
+	csvOptions := options.CSV
+	markers, err := ParseCSV(path, csvOptions)
+	if errors.Is(err, csvparser.ErrUnknownSampleRate) && csvOptions.SampleRate == 0 && container.Of(audioPath) == container.MP3 {
+		info, scanErr := mp3frame.ScanFile(audioPath)
+		if scanErr != nil || info.SampleRate == 0 {
+			return nil, fmt.Errorf("%w; set it with -sample-rate", err)
+		}
+		report.Printf("Converting sample positions with the sample rate of the input (%d Hz)", info.SampleRate)
+		csvOptions.SampleRate = info.SampleRate
+		markers, err = ParseCSV(path, csvOptions)
+	}
+
+	// Rows skipped in lenient mode are reported without stopping the run
+	var problems csvparser.ParseErrors
+	if errors.As(err, &problems) {
+		report.Warnf("%v", problems)
+		return markers, nil
+	}
+	return markers, err
+}
+
+// ParseCSV parses markers from a local Audition CSV file or an HTTP(S) URL with options
+func ParseCSV(path string, options csvparser.ParseOptions) ([]csvparser.MarkerEntry, error) {
+	if !remote.IsURL(path) {
+		return csvparser.ParseAuditionCSVWithOptions(path, options)
+	}
+
+	// Download remote CSV with size limit
+	data, err := remote.Fetch(path, remote.DefaultMaxSize)
+	if err != nil {
+		return nil, err
+	}
+	options.Source = path
+	return csvparser.ParseAuditionCSVReaderWithOptions(bytes.NewReader(data), options)
+}
+
+// IsAuditionSource reports whether a marker file is parsed as an Audition CSV file rather than read by another importer
+func IsAuditionSource(path string) bool {
+	format, err := importer.Detect(path)
+	return err != nil || format.Name == "audition" || remote.IsURL(path)
+}
+
+// Transform turns the markers read for the audio file at audioPath into the chapters to write. The steps run in a fixed order:
+// part names, merging close markers, titles from the transcription command and "Chapter N", snapping to silences,
+// rounding, alignment to frames and numbering, so the numbers are contiguous.
+// ctx is checked before each step that reads the audio.
+func Transform(ctx context.Context, markers []csvparser.MarkerEntry, audioPath string, options Options) ([]csvparser.MarkerEntry, error) {
+	report := options.reporter()
+
+	// Move part names from marker names into the parts
+	if options.PartDelimiter != "" {
+		markers = csvparser.SplitParts(markers, options.PartDelimiter)
+	}
+
+	// Merge markers that are too close to the preceding one
+	if options.MinGap > 0 {
+		markers = mergeCloseMarkers(markers, options.MinGap, report)
+	}
+	ReportMarkers(markers, report)
+
+	// Title untitled markers from a transcription
+	if options.TitleCommand != "" {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		titled, err := autoTitleMarkers(audioPath, markers, options)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to title markers: %w", err)
+		}
+		markers = titled
+	}
+
+	// Title the remaining untitled markers "Chapter N"
+	if options.ChapterTitles {
+		markers = titleChapters(markers, report)
+	}
+
+	// Snap markers to nearby silences
+	if options.Snap > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		snapped, err := snapMarkers(audioPath, markers, options.Snap, report)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to snap markers: %w", err)
+		}
+		markers = snapped
+	}
+
+	// Round markers to a grid
+	if options.Round > 0 {
+		markers = roundMarkers(markers, options.Round, report)
+	}
+
+	// Align markers to frame boundaries
+	if options.Align {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		aligned, err := alignMarkers(audioPath, markers, report)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to align markers: %w", err)
+		}
+		markers = aligned
+	}
+
+	// Number chapters once the final list of markers is known
+	if options.Number {
+		markers = csvparser.NumberMarkers(markers, options.NumberStart, options.NumberWidth)
+	}
+	return markers, nil
+}
+
+// ReportMarkers reports the number of markers and of the parts of multi-part programs, each of which gets its own table of contents
+func ReportMarkers(markers []csvparser.MarkerEntry, report Reporter) {
+	if len(markers) == 0 {
+		report.Warnf("No markers found in CSV file")
+	} else {
+		report.Printf("Loaded %d markers", len(markers))
+	}
+
+	parts := 0
+	for i, marker := range markers {
+		if marker.Part != "" && (i == 0 || marker.Part != markers[i-1].Part) {
+			parts++
+		}
+	}
+	if parts > 0 {
+		report.Printf("Markers are grouped into %d parts", parts)
+	}
+}
+
+// mergeCloseMarkers merges markers starting within gap of the preceding chapter into it and lists the merged markers
+func mergeCloseMarkers(markers []csvparser.MarkerEntry, gap time.Duration, report Reporter) []csvparser.MarkerEntry {
+	kept, absorbed := csvparser.MergeCloseMarkers(markers, gap)
+	for _, a := range absorbed {
+		report.Printf("Merged marker '%s' (%s) into '%s' (%s)", a.Marker.Name, id3tag.FormatDuration(a.Marker.StartTime), a.Into.Name, id3tag.FormatDuration(a.Into.StartTime))
+	}
+	if len(absorbed) > 0 {
+		report.Printf("Merged %d markers closer than %s to the preceding chapter", len(absorbed), gap)
+		report.Record(fmt.Sprintf("%d markers merged by -min-gap", len(absorbed)))
+	}
+	return kept
+}
+
+// titleChapters titles untitled markers, with no name or Audition's default name ("Marker 01"), "Chapter N" by their position
+func titleChapters(markers []csvparser.MarkerEntry, report Reporter) []csvparser.MarkerEntry {
+	titled := make([]csvparser.MarkerEntry, len(markers))
+	count := 0
+	for i, marker := range markers {
+		if name := strings.TrimSpace(marker.Name); name == "" || UntitledMarker.MatchString(name) {
+			marker.Name = fmt.Sprintf("Chapter %d", i+1)
+			count++
+		}
+		titled[i] = marker
+	}
+	if count > 0 {
+		report.Printf("Titled %d untitled markers \"Chapter N\"", count)
+	}
+	return titled
+}
+
+// snapMarkers shifts markers to the nearest silence within window and reports each adjustment
+func snapMarkers(audioPath string, markers []csvparser.MarkerEntry, window time.Duration, report Reporter) ([]csvparser.MarkerEntry, error) {
+	result, err := silence.DetectFile(audioPath, silence.Options{Threshold: silence.DefaultThreshold, MinDuration: silence.SnapMinDuration})
+	if err != nil {
+		return nil, err
+	}
+
+	snapped := silence.Snap(markers, result.Silences, window)
+	reportAdjustments("Snapped", markers, snapped, report)
+	return snapped, nil
+}
+
+// reportAdjustments reports the markers whose start time differs between before and after
+func reportAdjustments(verb string, before, after []csvparser.MarkerEntry, report Reporter) {
+	moved := 0
+	for i := range before {
+		if delta := after[i].StartTime - before[i].StartTime; delta != 0 {
+			report.Printf("%s '%s': %s -> %s (%+.3fs)", verb, before[i].Name,
+				id3tag.FormatDuration(before[i].StartTime), id3tag.FormatDuration(after[i].StartTime), delta.Seconds())
+			moved++
+		}
+	}
+	report.Printf("%s %d of %d markers", verb, moved, len(before))
+}
+
+// alignMarkers rounds marker start times to the nearest MP3 frame boundary and reports each adjustment
+func alignMarkers(audioPath string, markers []csvparser.MarkerEntry, report Reporter) ([]csvparser.MarkerEntry, error) {
+	info, err := mp3frame.ScanFile(audioPath)
+	if err != nil {
+		return nil, err
+	}
+
+	aligned := make([]csvparser.MarkerEntry, len(markers))
+	for i, marker := range markers {
+		frame, start := info.NearestFrame(marker.StartTime)
+		aligned[i] = marker
+		aligned[i].StartTime = start
+		report.Printf("Aligned '%s': %s -> %s (%+.3fs, byte offset %d in input)", marker.Name,
+			id3tag.FormatDuration(marker.StartTime), id3tag.FormatDuration(start), (start - marker.StartTime).Seconds(), frame.Offset)
+	}
+	return aligned, nil
+}
+
+// roundMarkers rounds marker times to a grid, reporting each adjustment and markers that end up at the same time
+func roundMarkers(markers []csvparser.MarkerEntry, grid time.Duration, report Reporter) []csvparser.MarkerEntry {
+	rounded := csvparser.RoundMarkers(markers, grid)
+	reportAdjustments("Rounded", markers, rounded, report)
+	for i := 1; i < len(rounded); i++ {
+		if rounded[i].StartTime == rounded[i-1].StartTime && markers[i].StartTime != markers[i-1].StartTime {
+			report.Warnf("'%s' and '%s' start at the same time (%s) after rounding",
+				rounded[i-1].Name, rounded[i].Name, id3tag.FormatDuration(rounded[i].StartTime))
+		}
+	}
+	return rounded
+}
+
+// newSource describes the marker file or URL at path
+func newSource(path string, csv bool) Source {
+	source := Source{Name: path, CSV: csv}
+	if !remote.IsURL(path) {
+		if info, err := os.Stat(path); err == nil {
+			source.ModTime = info.ModTime()
+		}
+	}
+	return source
+}
@@ -0,0 +1,157 @@
+package preset
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/config"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+)
+
+// sectionPrefix is the prefix of config file sections defining custom presets ("[preset.name]")
+const sectionPrefix = "preset."
+
+// Preset configures the chapter writer for a target player ecosystem
+type Preset struct {
+	Name        string // Preset name used on the command line
+	Description string // Short description for help messages
+	Version     byte   // ID3v2 major version (3 or 4)
+	Encoding    string // Text encoding: utf8, utf16 or latin1
+	EndTimes    bool   // Whether chapters carry end times
+	TOC         bool   // Whether a table of contents (CTOC) frame is written
+
+	ChapterTitles bool // Whether untitled markers are titled "Chapter N" by their position
+}
+
+// builtins are the presets available without a config file
+var builtins = map[string]Preset{
+	"apple": {
+		Name:        "apple",
+		Description: "Apple Podcasts / iTunes: ID3v2.3, UTF-16, end times, table of contents",
+		Version:     3,
+		Encoding:    "utf16",
+		EndTimes:    true,
+		TOC:         true,
+	},
+	"spotify": {
+		Name:        "spotify",
+		Description: "Spotify and Android players: ID3v2.4, UTF-8, end times, table of contents",
+		Version:     4,
+		Encoding:    "utf8",
+		EndTimes:    true,
+		TOC:         true,
+	},
+	"audiobook": {
+		Name:          "audiobook",
+		Description:   "Audiobooks from unnamed cue points: ID3v2.3, UTF-16, end times, table of contents, \"Chapter N\" titles",
+		Version:       3,
+		Encoding:      "utf16",
+		EndTimes:      true,
+		TOC:           true,
+		ChapterTitles: true,
+	},
+	"generic": {
+		Name:        "generic",
+		Description: "Widest compatibility: ID3v2.3, UTF-16, end times, table of contents",
+		Version:     3,
+		Encoding:    "utf16",
+		EndTimes:    true,
+		TOC:         true,
+	},
+}
+
+// Lookup returns the built-in or custom preset with the given name; custom presets override built-in ones
+func Lookup(name string, file *config.File) (Preset, error) {
+	name = strings.ToLower(name)
+	if file != nil {
+		if section := file.Section(sectionPrefix + name); section != nil {
+			return parseCustom(name, section)
+		}
+	}
+	if preset, ok := builtins[name]; ok {
+		return preset, nil
+	}
+	return Preset{}, fmt.Errorf("Unknown preset '%s' (available: %s)", name, strings.Join(Names(file), ", "))
+}
+
+// Names returns the names of all built-in and custom presets in alphabetical order
+func Names(file *config.File) []string {
+	seen := make(map[string]bool)
+	for name := range builtins {
+		seen[name] = true
+	}
+	if file != nil {
+		for _, name := range file.SectionsWithPrefix(sectionPrefix) {
+			seen[strings.ToLower(name)] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseCustom builds a preset from a config file section.
+// A "base" key names a preset to start from; other keys override its settings.
+func parseCustom(name string, section map[string]string) (Preset, error) {
+	preset := Preset{Name: name, Version: 4, Encoding: "utf8", TOC: true}
+	if base, ok := section["base"]; ok {
+		basePreset, ok := builtins[strings.ToLower(base)]
+		if !ok {
+			return Preset{}, fmt.Errorf("Preset '%s': unknown base preset '%s'", name, base)
+		}
+		preset = basePreset
+		preset.Name = name
+	}
+	preset.Description = "Custom preset from config file"
+
+	for key, value := range section {
+		var err error
+		switch key {
+		case "base":
+		case "description":
+			preset.Description = value
+		case "version":
+			var version int
+			version, err = strconv.Atoi(strings.TrimPrefix(value, "2."))
+			if err == nil && version != 3 && version != 4 {
+				err = fmt.Errorf("must be 3 or 4")
+			}
+			preset.Version = byte(version)
+		case "encoding":
+			preset.Encoding = strings.ToLower(value)
+			if _, encodingErr := id3tag.ParseEncoding(preset.Encoding); encodingErr != nil {
+				err = encodingErr
+			}
+		case "end-times":
+			preset.EndTimes, err = config.Bool(value)
+		case "toc":
+			preset.TOC, err = config.Bool(value)
+		case "chapter-titles":
+			preset.ChapterTitles, err = config.Bool(value)
+		default:
+			err = fmt.Errorf("unknown setting")
+		}
+		if err != nil {
+			return Preset{}, fmt.Errorf("Preset '%s': invalid %s '%s': %v", name, key, value, err)
+		}
+	}
+
+	if preset.Version == 3 && preset.Encoding == "utf8" {
+		return Preset{}, fmt.Errorf("Preset '%s': UTF-8 text is not allowed in ID3v2.3", name)
+	}
+	return preset, nil
+}
+
+// Apply sets the writer settings of the preset in options
+func (p Preset) Apply(options *id3tag.Options) {
+	options.Version = p.Version
+	options.Encoding = p.Encoding
+	options.EndTimes = p.EndTimes
+	options.OmitTOC = !p.TOC
+}
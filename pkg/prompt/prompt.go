@@ -0,0 +1,53 @@
+package prompt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrConfirmationRequired is returned, wrapped, when an operation needs confirmation but prompting is disabled
+var ErrConfirmationRequired = errors.New("Confirmation required, but prompting is disabled")
+
+// Prompter asks for confirmation before a file is overwritten or modified in place; a nil prompter uses the standard streams
+type Prompter struct {
+	Input    io.Reader // Answers, one per line (nil for os.Stdin); pass one *bufio.Reader to share its read-ahead between prompts
+	Output   io.Writer // Where questions are written (nil for os.Stdout)
+	Disabled bool      // Whether to fail with ErrConfirmationRequired instead of asking, e.g. in servers
+}
+
+// Confirm asks question, e.g. "Overwrite 'a.mp3'? (y/n): ", and returns an error unless the answer is y or yes
+func (p *Prompter) Confirm(question string) error {
+	var input io.Reader = os.Stdin
+	var output io.Writer = os.Stdout
+	if p != nil {
+		if p.Disabled {
+			return fmt.Errorf("%w: %s", ErrConfirmationRequired, strings.TrimSuffix(question, " (y/n): "))
+		}
+		if p.Input != nil {
+			input = p.Input
+		}
+		if p.Output != nil {
+			output = p.Output
+		}
+	}
+
+	fmt.Fprint(output, question)
+	reader, ok := input.(*bufio.Reader)
+	if !ok {
+		reader = bufio.NewReader(input)
+	}
+	response, err := reader.ReadString('\n')
+	if err != nil && (err != io.EOF || response == "") {
+		return fmt.Errorf("Error reading input: %w", err)
+	}
+
+	response = strings.TrimSpace(strings.ToLower(response))
+	if response != "y" && response != "yes" {
+		return fmt.Errorf("Operation cancelled by user")
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package publisher
+
+import (
+	"fmt"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/auphonic"
+)
+
+// auphonicPublisher replaces the chapters of an Auphonic production
+type auphonicPublisher struct {
+	client     *auphonic.Client
+	production string // Production UUID, may be {episode}
+}
+
+// newAuphonic builds an Auphonic publisher from the token, production and api-url settings
+func newAuphonic(settings map[string]string) (Publisher, error) {
+	if err := checkSettings(settings, "token", "production", "api-url"); err != nil {
+		return nil, err
+	}
+	if settings["token"] == "" {
+		return nil, fmt.Errorf("token is required")
+	}
+	production := settings["production"]
+	if production == "" {
+		production = "{episode}"
+	}
+	return &auphonicPublisher{
+		client:     &auphonic.Client{BaseURL: settings["api-url"], Token: settings["token"]},
+		production: production,
+	}, nil
+}
+
+// Publish replaces the chapters of the production with the episode's chapters
+func (p *auphonicPublisher) Publish(episode Episode) error {
+	production := expand(p.production, episode)
+	if production == "" {
+		return fmt.Errorf("No Auphonic production given")
+	}
+	return p.client.SetChapters(production, auphonic.NewChapters(episode.Markers))
+}
@@ -0,0 +1,78 @@
+package publisher
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/exporter"
+)
+
+// jsonPublisher sends Podcasting 2.0 JSON chapters to a URL
+type jsonPublisher struct {
+	url    string // Endpoint, may contain {episode}
+	method string // HTTP method
+	token  string // Bearer token (empty for none)
+	header string // Extra "Name: value" header (empty for none)
+}
+
+// newJSON builds a generic JSON publisher from the url, method, token and header settings
+func newJSON(settings map[string]string) (Publisher, error) {
+	if err := checkSettings(settings, "url", "method", "token", "header"); err != nil {
+		return nil, err
+	}
+	publisher := &jsonPublisher{url: settings["url"], method: strings.ToUpper(settings["method"]), token: settings["token"], header: settings["header"]}
+	if publisher.url == "" {
+		return nil, fmt.Errorf("url is required")
+	}
+	switch publisher.method {
+	case "":
+		publisher.method = http.MethodPost
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return nil, fmt.Errorf("method must be POST, PUT or PATCH")
+	}
+	if publisher.header != "" && !strings.Contains(publisher.header, ":") {
+		return nil, fmt.Errorf("header must be 'Name: value'")
+	}
+	return publisher, nil
+}
+
+// client is the HTTP client used by the JSON publisher
+var client = &http.Client{Timeout: 30 * time.Second}
+
+// Publish sends the chapters of the episode as a Podcasting 2.0 JSON chapters document
+func (p *jsonPublisher) Publish(episode Episode) error {
+	format, err := exporter.Lookup("json")
+	if err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	if err := format.Write(&body, episode.Markers, exporter.Options{Duration: episode.Duration}); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(p.method, expand(p.url, episode), &body)
+	if err != nil {
+		return fmt.Errorf("Invalid publish URL: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+	if name, value, ok := strings.Cut(p.header, ":"); ok {
+		req.Header.Set(strings.TrimSpace(name), strings.TrimSpace(expand(value, episode)))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Failed to publish chapters: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Publish endpoint returned status %s", resp.Status)
+	}
+	return nil
+}
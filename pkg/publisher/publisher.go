@@ -0,0 +1,76 @@
+package publisher
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/config"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+// sectionPrefix is the prefix of config file sections defining publishers ("[publish.name]")
+const sectionPrefix = "publish."
+
+// Episode is what is published after tagging
+type Episode struct {
+	ID        string                  // Episode identifier on the platform, substituted for {episode} in settings
+	Markers   []csvparser.MarkerEntry // Chapters written
+	AudioFile string                  // Path of the tagged audio file
+	Duration  time.Duration           // Audio length (0 if unknown)
+}
+
+// Publisher pushes the chapters of an episode to a hosting platform
+type Publisher interface {
+	Publish(episode Episode) error
+}
+
+// factories build publishers by the "type" setting of a config file section
+var factories = map[string]func(settings map[string]string) (Publisher, error){
+	"json":     newJSON,
+	"auphonic": newAuphonic,
+}
+
+// Lookup builds the publisher defined in the "[publish.name]" section of the config file
+func Lookup(name string, file *config.File) (Publisher, error) {
+	section := file.Section(sectionPrefix + name)
+	if section == nil {
+		return nil, fmt.Errorf("Unknown publisher '%s': define it in a [%s%s] section of the config file", name, sectionPrefix, name)
+	}
+	factory, ok := factories[strings.ToLower(section["type"])]
+	if !ok {
+		return nil, fmt.Errorf("Publisher '%s': unknown type '%s' (available: %s)", name, section["type"], strings.Join(Types(), ", "))
+	}
+	publisher, err := factory(section)
+	if err != nil {
+		return nil, fmt.Errorf("Publisher '%s': %w", name, err)
+	}
+	return publisher, nil
+}
+
+// Types returns the names of the publisher types in alphabetical order
+func Types() []string {
+	types := make([]string, 0, len(factories))
+	for name := range factories {
+		types = append(types, name)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// expand substitutes the episode ID for {episode} in a setting
+func expand(value string, episode Episode) string {
+	return strings.ReplaceAll(value, "{episode}", episode.ID)
+}
+
+// checkSettings reports settings other than the known ones, which are likely typos
+func checkSettings(settings map[string]string, known ...string) error {
+	for key := range settings {
+		if key != "type" && !slices.Contains(known, key) {
+			return fmt.Errorf("unknown setting '%s'", key)
+		}
+	}
+	return nil
+}
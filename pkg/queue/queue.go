@@ -0,0 +1,39 @@
+package queue
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Queue delivers job messages and takes result messages
+type Queue interface {
+	// Receive waits up to timeout for the next job message; it returns nil without an error if none arrived
+	Receive(timeout time.Duration) ([]byte, error)
+	// Publish sends a result message
+	Publish(message []byte) error
+	// Close releases the connection
+	Close() error
+}
+
+// Options names the queues used on the broker
+type Options struct {
+	Jobs    string // Queue job messages are read from
+	Results string // Queue result messages are written to (empty to not publish results)
+}
+
+// Open connects to the broker at rawURL; redis:// and rediss:// URLs are supported
+func Open(rawURL string, options Options) (Queue, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid queue URL: %w", err)
+	}
+	switch u.Scheme {
+	case "redis", "rediss":
+		return openRedis(u, options)
+	case "amqp", "amqps":
+		return nil, fmt.Errorf("AMQP brokers are not supported by this build; use a redis:// queue or implement queue.Queue")
+	default:
+		return nil, fmt.Errorf("Unsupported queue URL scheme '%s' (available: redis, rediss)", u.Scheme)
+	}
+}
@@ -0,0 +1,184 @@
+package queue
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redisQueue uses Redis lists: jobs are popped from the left of one list and results pushed to the right of another
+type redisQueue struct {
+	options  Options
+	receiver *redisConn // Connection blocked in BLPOP
+	mu       sync.Mutex // Guards publisher
+	sender   *redisConn // Connection for RPUSH
+}
+
+// redisConn is a connection speaking the Redis serialization protocol (RESP)
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// errRedisNil is returned for a nil reply
+var errRedisNil = errors.New("nil reply")
+
+// openRedis connects two connections to the Redis server of u, authenticating and selecting the database of the URL
+func openRedis(u *url.URL, options Options) (Queue, error) {
+	if options.Jobs == "" {
+		return nil, fmt.Errorf("Job queue name is required")
+	}
+	receiver, err := dialRedis(u)
+	if err != nil {
+		return nil, err
+	}
+	sender, err := dialRedis(u)
+	if err != nil {
+		receiver.conn.Close()
+		return nil, err
+	}
+	return &redisQueue{options: options, receiver: receiver, sender: sender}, nil
+}
+
+// dialRedis opens one connection to the Redis server of u
+func dialRedis(u *url.URL) (*redisConn, error) {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if u.Scheme == "rediss" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, &tls.Config{ServerName: u.Hostname()})
+	} else {
+		conn, err = dialer.Dial("tcp", host)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Cannot connect to Redis at %s: %w", host, err)
+	}
+	c := &redisConn{conn: conn, reader: bufio.NewReader(conn)}
+
+	if password, ok := u.User.Password(); ok {
+		args := []string{"AUTH", password}
+		if name := u.User.Username(); name != "" {
+			args = []string{"AUTH", name, password}
+		}
+		if _, err := c.do(args...); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("Redis authentication failed: %w", err)
+		}
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if _, err := strconv.Atoi(db); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("Invalid Redis database '%s'", db)
+		}
+		if _, err := c.do("SELECT", db); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("Cannot select Redis database %s: %w", db, err)
+		}
+	}
+	return c, nil
+}
+
+// Receive pops the next job with BLPOP
+func (q *redisQueue) Receive(timeout time.Duration) ([]byte, error) {
+	seconds := strconv.FormatFloat(max(timeout.Seconds(), 0.01), 'f', 2, 64)
+	q.receiver.conn.SetDeadline(time.Now().Add(timeout + 10*time.Second))
+	reply, err := q.receiver.do("BLPOP", q.options.Jobs, seconds)
+	if errors.Is(err, errRedisNil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	items, ok := reply.([]any)
+	if !ok || len(items) != 2 {
+		return nil, fmt.Errorf("Unexpected BLPOP reply")
+	}
+	message, _ := items[1].([]byte)
+	return message, nil
+}
+
+// Publish pushes a result with RPUSH
+func (q *redisQueue) Publish(message []byte) error {
+	if q.options.Results == "" {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.sender.conn.SetDeadline(time.Now().Add(10 * time.Second))
+	_, err := q.sender.do("RPUSH", q.options.Results, string(message))
+	return err
+}
+
+// Close closes both connections
+func (q *redisQueue) Close() error {
+	return errors.Join(q.receiver.conn.Close(), q.sender.conn.Close())
+}
+
+// do sends a command and reads its reply
+func (c *redisConn) do(args ...string) (any, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, b.String()); err != nil {
+		return nil, fmt.Errorf("Redis write failed: %w", err)
+	}
+	return c.readReply()
+}
+
+// readReply reads one RESP reply: a status, error, integer, bulk string or array
+func (c *redisConn) readReply() (any, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("Redis read failed: %w", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("Empty Redis reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, fmt.Errorf("Redis error: %s", line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$', '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid Redis reply length: %s", line)
+		}
+		if n < 0 {
+			return nil, errRedisNil
+		}
+		if line[0] == '$' {
+			data := make([]byte, n+2)
+			if _, err := io.ReadFull(c.reader, data); err != nil {
+				return nil, fmt.Errorf("Redis read failed: %w", err)
+			}
+			return data[:n], nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = c.readReply(); err != nil && !errors.Is(err, errRedisNil) {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("Unknown Redis reply type '%c'", line[0])
+}
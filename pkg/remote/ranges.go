@@ -0,0 +1,111 @@
+package remote
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// DefaultChunkSize is the smallest range requested by a RangeReader; ID3 tags without artwork fit into one request
+const DefaultChunkSize = 64 << 10 // 64 KiB
+
+// RangeReader reads a remote resource sequentially with HTTP range requests, downloading only about as much as is read.
+// Servers that ignore ranges are read as a normal download that stops when the reader is closed.
+type RangeReader struct {
+	url        string
+	chunkSize  int64
+	offset     int64         // Position of the next byte to read
+	body       io.ReadCloser // Body of the current response
+	remaining  int64         // Bytes left in the current response, or -1 for a full download
+	Requests   int           // Number of HTTP requests sent
+	Downloaded int64         // Number of bytes received
+}
+
+// NewRangeReader creates a reader for the resource at url requesting at least chunkSize bytes at a time
+func NewRangeReader(url string, chunkSize int64) *RangeReader {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	return &RangeReader{url: url, chunkSize: chunkSize}
+}
+
+// Read reads from the current response, requesting the next range when it is exhausted
+func (r *RangeReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if r.body == nil || r.remaining == 0 {
+		if err := r.request(max(r.chunkSize, int64(len(p)))); err != nil {
+			return 0, err
+		}
+	}
+
+	if r.remaining > 0 && int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	r.Downloaded += int64(n)
+	if r.remaining > 0 {
+		r.remaining -= int64(n)
+		if err == io.EOF && r.remaining > 0 {
+			err = io.ErrUnexpectedEOF
+		} else if err == io.EOF {
+			err = nil // The next range is requested by the following call
+		}
+	}
+	return n, err
+}
+
+// Close closes the current response
+func (r *RangeReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
+
+// request opens a response starting at the current offset with up to length bytes
+func (r *RangeReader) request(length int64) error {
+	r.Close()
+
+	req, err := http.NewRequest(http.MethodGet, r.url, nil)
+	if err != nil {
+		return fmt.Errorf("Failed to download '%s': %w", r.url, err)
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", r.offset, r.offset+length-1))
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Failed to download '%s': %w", r.url, err)
+	}
+	r.Requests++
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		r.body, r.remaining = resp.Body, resp.ContentLength
+		if r.remaining < 0 {
+			r.remaining = length
+		}
+		if r.remaining == 0 {
+			r.Close()
+			return io.EOF
+		}
+	case http.StatusOK:
+		// Ranges are not supported; skip to the offset in the full download
+		r.body, r.remaining = resp.Body, -1
+		if _, err := io.CopyN(io.Discard, resp.Body, r.offset); err != nil {
+			r.Close()
+			return fmt.Errorf("Failed to download '%s': %w", r.url, err)
+		}
+		r.Downloaded += r.offset
+	case http.StatusRequestedRangeNotSatisfiable:
+		resp.Body.Close()
+		return io.EOF
+	default:
+		resp.Body.Close()
+		return fmt.Errorf("Failed to download '%s': server returned %s", r.url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package remote
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultMaxSize is the default download size limit in bytes
+const DefaultMaxSize = 10 << 20 // 10 MiB
+
+// client is the HTTP client used for downloads; TLS certificates are always verified
+var client = &http.Client{Timeout: 30 * time.Second}
+
+// IsURL reports whether path is an HTTP or HTTPS URL
+func IsURL(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "http://")
+}
+
+// Fetch downloads the resource at url, failing if it is larger than maxSize bytes
+func Fetch(url string, maxSize int64) ([]byte, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+
+	// Send request
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to download '%s': %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	// Check response status
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Failed to download '%s': server returned %s", url, resp.Status)
+	}
+
+	// Reject oversized responses early when the size is announced
+	if resp.ContentLength > maxSize {
+		return nil, fmt.Errorf("Remote file '%s' is too large (%d bytes, limit %d)", url, resp.ContentLength, maxSize)
+	}
+
+	// Read body, reading one extra byte to detect oversized responses
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to download '%s': %w", url, err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("Remote file '%s' exceeds the size limit of %d bytes", url, maxSize)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,132 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Status values of reports
+const (
+	StatusDone               = "done"                // Output was written and verified
+	StatusFailed             = "failed"              // The run stopped with an error
+	StatusVerificationFailed = "verification-failed" // Output was written but its chapters do not match the markers
+	StatusDryRun             = "dry-run"             // Nothing was written because of -dry-run
+)
+
+// Roles of the files of a run
+const (
+	RoleInput     = "input"     // Audio file chapters were added to
+	RoleMarkers   = "markers"   // Marker file the chapters were read from
+	RoleOutput    = "output"    // Written audio or sidecar file
+	RoleExport    = "export"    // Chapter file written by -also-export
+	RoleAudit     = "audit"     // Audit record
+	RoleChecksums = "checksums" // Checksum manifest
+)
+
+// Report is the summary of one invocation, written as JSON for dashboards and CI
+type Report struct {
+	Tool       string           `json:"tool"`            // Module path of the tool
+	Version    string           `json:"version"`         // Module version and VCS revision of the tool
+	Command    string           `json:"command"`         // Subcommand, or "add-chapters" for the main command
+	Status     string           `json:"status"`          // One of the Status constants
+	ExitCode   int              `json:"exit_code"`       // Exit code of the process
+	Error      string           `json:"error,omitempty"` // Error that stopped the run
+	Started    time.Time        `json:"started"`         // Start of the run (UTC)
+	Finished   time.Time        `json:"finished"`        // End of the run (UTC)
+	DurationMS int64            `json:"duration_ms"`     // Length of the run in milliseconds
+	Timings    map[string]int64 `json:"timings_ms"`      // Milliseconds spent in each phase, e.g. "parse", "write", "verify"
+	Files      []File           `json:"files"`           // Files read and written, in order
+	Markers    int              `json:"markers"`         // Markers loaded from the marker files
+	Chapters   int              `json:"chapters"`        // Chapters in the output, including existing chapters kept by -merge
+	Warnings   []string         `json:"warnings"`        // Warnings reported during the run
+
+	phaseStart time.Time // Start of the current phase
+}
+
+// File is a file read or written by the run
+type File struct {
+	Path string `json:"path"`
+	Role string `json:"role"` // One of the Role constants
+	Size int64  `json:"size,omitempty"`
+}
+
+// New starts the report of a run of command
+func New(tool, version, command string) *Report {
+	now := time.Now().UTC()
+	return &Report{
+		Tool:       tool,
+		Version:    version,
+		Command:    command,
+		Started:    now,
+		Timings:    map[string]int64{},
+		Files:      []File{},
+		Warnings:   []string{},
+		phaseStart: now,
+	}
+}
+
+// Phase records the time since the previous phase ended, or since the start, as the time of the named phase; a nil report records nothing
+func (r *Report) Phase(name string) {
+	if r == nil {
+		return
+	}
+	now := time.Now().UTC()
+	r.Timings[name] += now.Sub(r.phaseStart).Milliseconds()
+	r.phaseStart = now
+}
+
+// AddFile records a file of the run with its size if it exists
+func (r *Report) AddFile(path, role string) {
+	if r == nil {
+		return
+	}
+	file := File{Path: path, Role: role}
+	if info, err := os.Stat(path); err == nil {
+		file.Size = info.Size()
+	}
+	r.Files = append(r.Files, file)
+}
+
+// SetMarkers records the number of markers loaded from the marker files
+func (r *Report) SetMarkers(n int) {
+	if r != nil {
+		r.Markers = n
+	}
+}
+
+// SetChapters records the number of chapters written
+func (r *Report) SetChapters(n int) {
+	if r != nil {
+		r.Chapters = n
+	}
+}
+
+// Fail records the error that stopped the run
+func (r *Report) Fail(err error) {
+	if r != nil && err != nil {
+		r.Error = err.Error()
+	}
+}
+
+// Finish sets the outcome of the run and its warnings
+func (r *Report) Finish(exitCode int, status string, warnings []string) {
+	r.Finished = time.Now().UTC()
+	r.DurationMS = r.Finished.Sub(r.Started).Milliseconds()
+	r.ExitCode = exitCode
+	r.Status = status
+	r.Warnings = append(r.Warnings, warnings...)
+}
+
+// WriteFile writes the report as indented JSON to path
+func WriteFile(path string, r *Report) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Failed to encode run report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("Failed to write run report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package safefile
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+)
+
+// maxPrefix is the number of bytes of the target file name kept in temporary file names, so they stay within name length limits
+const maxPrefix = 64
+
+// TempPath creates an empty temporary file with a unique name in the directory of path and returns its path.
+// Writing to a new name next to the target lets Replace move it into place, and keeps concurrent runs from sharing a temporary file.
+func TempPath(path string) (string, error) {
+	file, err := os.CreateTemp(filepath.Dir(path), namePrefix(path)+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("Failed to create temporary file: %w", err)
+	}
+	// CreateTemp makes the file private; the output is moved into place with the permissions of the file it replaces,
+	// or of a newly created file
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	err = file.Chmod(mode)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("Failed to create temporary file: %w", err)
+	}
+	return file.Name(), nil
+}
+
+// namePrefix returns the file name of path shortened to maxPrefix bytes on a character boundary
+func namePrefix(path string) string {
+	name := filepath.Base(path)
+	for len(name) > maxPrefix {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+// Replace moves tempPath to path, replacing an existing file. Some network shares (SMB/CIFS) refuse to rename over an
+// existing or open file; the content is then copied into path instead and tempPath removed.
+// If copying fails as well, path may be truncated, so the content of tempPath is kept in a new file next to path,
+// named in the error, where the caller's cleanup of tempPath does not remove it.
+func Replace(tempPath, path string) error {
+	renameErr := os.Rename(tempPath, path)
+	if renameErr == nil {
+		return nil
+	}
+	if _, err := os.Stat(tempPath); err != nil {
+		return fmt.Errorf("Failed to move temporary file to %s: %w", path, renameErr)
+	}
+	if err := copyInto(tempPath, path); err != nil {
+		kept, keepErr := keep(tempPath, path)
+		if keepErr != nil {
+			return fmt.Errorf("Failed to move temporary file to %s: %w (copying failed as well: %v, and keeping the new content failed: %v)", path, renameErr, err, keepErr)
+		}
+		return fmt.Errorf("Failed to move temporary file to %s: %w (copying failed as well: %v); the new content is kept in %s", path, renameErr, err, kept)
+	}
+	os.Remove(tempPath)
+	return nil
+}
+
+// keep moves tempPath to a new file next to path whose name ends in .recovered, or copies it there if the share refuses
+// the rename, and returns the name of the new file
+func keep(tempPath, path string) (string, error) {
+	file, err := os.CreateTemp(filepath.Dir(path), namePrefix(path)+".*.recovered")
+	if err != nil {
+		return "", err
+	}
+	file.Close()
+	if err := os.Rename(tempPath, file.Name()); err == nil {
+		return file.Name(), nil
+	}
+	if err := copyInto(tempPath, file.Name()); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	os.Remove(tempPath)
+	return file.Name(), nil
+}
+
+// copyInto overwrites path with the content of src
+func copyInto(src, path string) error {
+	input, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	output, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(output, input); err != nil {
+		output.Close()
+		return err
+	}
+	if err := output.Sync(); err != nil {
+		output.Close()
+		return err
+	}
+	return output.Close()
+}
+
+// Same reports whether both paths name the same existing file, following symbolic links
+func Same(a, b string) bool {
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	infoB, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(infoA, infoB)
+}
+
+// CheckWritable returns an error if path cannot be written: its directory, or the nearest existing parent if the directory
+// is still to be created, must accept new files, which the temporary file of an output is, and an existing file at path must
+// be writable. Checking before the work starts gives a clear error instead of a failed rename at the end.
+func CheckWritable(path string) error {
+	dir := filepath.Dir(path)
+	info, err := os.Stat(dir)
+	for os.IsNotExist(err) && filepath.Dir(dir) != dir {
+		dir = filepath.Dir(dir)
+		info, err = os.Stat(dir)
+	}
+	if err != nil {
+		return fmt.Errorf("Output directory '%s' cannot be accessed: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("Output directory '%s' is not a directory", dir)
+	}
+	probe, err := os.CreateTemp(dir, ".write-test.*.tmp")
+	if err != nil {
+		return fmt.Errorf("Output directory '%s' is not writable: %w", dir, err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	if _, err := os.Stat(path); err == nil {
+		file, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return fmt.Errorf("Output file '%s' is not writable: %w", path, err)
+		}
+		file.Close()
+	}
+	return nil
+}
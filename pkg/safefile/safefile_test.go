@@ -0,0 +1,69 @@
+package safefile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplace(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "エピソード.mp3")
+	if err := os.WriteFile(path, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	temp, err := TempPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(temp, []byte("new"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Replace(temp, path); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "new" {
+		t.Errorf("content = %q, want %q", data, "new")
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("mode of the replaced file = %v (%v), want the mode of the original 0600", info.Mode().Perm(), err)
+	}
+	if _, err := os.Stat(temp); !os.IsNotExist(err) {
+		t.Errorf("temporary file still exists after Replace()")
+	}
+}
+
+func TestReplaceKeepsContentWhenCopyFails(t *testing.T) {
+	// Neither renaming nor copying over a directory works, like an output that a share keeps locked
+	dir := t.TempDir()
+	path := filepath.Join(dir, "output.mp3")
+	if err := os.MkdirAll(filepath.Join(path, "locked"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	temp, err := TempPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(temp, []byte("new"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	err = Replace(temp, path)
+	if err == nil {
+		t.Fatal("Replace() over a directory succeeded, want an error")
+	}
+	os.Remove(temp) // Cleanup of callers must not lose the content
+
+	kept, _ := filepath.Glob(filepath.Join(dir, "output.mp3.*.recovered"))
+	if len(kept) != 1 {
+		t.Fatalf("kept files = %v, want one", kept)
+	}
+	if !strings.Contains(err.Error(), kept[0]) {
+		t.Errorf("error %q does not name the kept file %s", err, kept[0])
+	}
+	if data, _ := os.ReadFile(kept[0]); string(data) != "new" {
+		t.Errorf("kept content = %q, want %q", data, "new")
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// durationBuckets are the upper bounds (in seconds) of the processing duration histogram
+var durationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// metrics collects processing statistics in Prometheus format
+type metrics struct {
+	mu             sync.Mutex
+	processed      uint64   // Files tagged successfully
+	failed         uint64   // Requests that failed
+	bytesWritten   uint64   // Total size of tagged output files
+	durationCounts []uint64 // Cumulative count per duration bucket
+	durationSum    float64  // Sum of processing durations in seconds
+	durationCount  uint64   // Number of observed durations
+}
+
+// newMetrics creates an empty metrics collector
+func newMetrics() *metrics {
+	return &metrics{
+		durationCounts: make([]uint64, len(durationBuckets)),
+	}
+}
+
+// observeSuccess records a successfully processed file
+func (m *metrics) observeSuccess(duration time.Duration, outputSize int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.processed++
+	m.bytesWritten += uint64(outputSize)
+	m.observeDuration(duration)
+}
+
+// observeFailure records a failed request
+func (m *metrics) observeFailure() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.failed++
+}
+
+// observeDuration adds a processing duration to the histogram; the caller must hold the lock
+func (m *metrics) observeDuration(duration time.Duration) {
+	seconds := duration.Seconds()
+	for i, bound := range durationBuckets {
+		if seconds <= bound {
+			m.durationCounts[i]++
+		}
+	}
+	m.durationSum += seconds
+	m.durationCount++
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	fmt.Fprintln(w, "# HELP audition_marker_files_processed_total Files tagged successfully.")
+	fmt.Fprintln(w, "# TYPE audition_marker_files_processed_total counter")
+	fmt.Fprintf(w, "audition_marker_files_processed_total %d\n", m.processed)
+
+	fmt.Fprintln(w, "# HELP audition_marker_failures_total Requests that failed.")
+	fmt.Fprintln(w, "# TYPE audition_marker_failures_total counter")
+	fmt.Fprintf(w, "audition_marker_failures_total %d\n", m.failed)
+
+	fmt.Fprintln(w, "# HELP audition_marker_bytes_written_total Total size of tagged output files in bytes.")
+	fmt.Fprintln(w, "# TYPE audition_marker_bytes_written_total counter")
+	fmt.Fprintf(w, "audition_marker_bytes_written_total %d\n", m.bytesWritten)
+
+	fmt.Fprintln(w, "# HELP audition_marker_processing_duration_seconds Time spent tagging a file.")
+	fmt.Fprintln(w, "# TYPE audition_marker_processing_duration_seconds histogram")
+	for i, bound := range durationBuckets {
+		fmt.Fprintf(w, "audition_marker_processing_duration_seconds_bucket{le=\"%g\"} %d\n", bound, m.durationCounts[i])
+	}
+	fmt.Fprintf(w, "audition_marker_processing_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.durationCount)
+	fmt.Fprintf(w, "audition_marker_processing_duration_seconds_sum %g\n", m.durationSum)
+	fmt.Fprintf(w, "audition_marker_processing_duration_seconds_count %d\n", m.durationCount)
+}
+
+// handleHealth reports that the server is up
+func handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintln(w, "ok")
+}
@@ -0,0 +1,244 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/prompt"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/queue"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/storage"
+)
+
+// QueueOptions holds the settings of queue consumption
+type QueueOptions struct {
+	Concurrency int           // Job messages processed at the same time, tagging at most Options.Concurrency files
+	Retries     int           // Extra attempts after a failed attempt
+	Backoff     time.Duration // Wait before the first retry, doubled for each further retry
+}
+
+// QueueJob is a job message: the files are paths on storage shared with the server, or storage URLs such as s3://bucket/key
+type QueueJob struct {
+	ID        string        `json:"id"`
+	Input     string        `json:"input"`
+	Output    string        `json:"output"`
+	CSV       string        `json:"csv,omitempty"`
+	Markers   []ChapterJSON `json:"markers,omitempty"`
+	Overwrite bool          `json:"overwrite,omitempty"`
+}
+
+// QueueResult is the result message published for each job message
+type QueueResult struct {
+	ID         string `json:"id"`
+	Status     string `json:"status"`
+	Input      string `json:"input,omitempty"`
+	Output     string `json:"output,omitempty"`
+	Chapters   int    `json:"chapters"`
+	Attempts   int    `json:"attempts"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// permanentError is a job error that a retry cannot fix, such as an invalid message or marker file
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+
+// Consume processes job messages from q until receiving fails.
+// Jobs are also registered like uploaded ones, so their status is available from the API and they are counted in the metrics.
+func (s *Server) Consume(q queue.Queue, options QueueOptions) error {
+	if options.Concurrency <= 0 {
+		options.Concurrency = 1
+	}
+	if options.Backoff <= 0 {
+		options.Backoff = time.Second
+	}
+
+	slots := make(chan struct{}, options.Concurrency)
+	for {
+		// Only take a message when a worker is free, leaving the rest to other consumers
+		slots <- struct{}{}
+		message, err := q.Receive(5 * time.Second)
+		if err != nil {
+			return fmt.Errorf("Failed to receive job: %w", err)
+		}
+		if message == nil {
+			<-slots
+			continue
+		}
+		go func() {
+			defer func() { <-slots }()
+			result := s.processQueueJob(message, options)
+			data, _ := json.Marshal(result)
+			if err := q.Publish(data); err != nil {
+				s.options.Logger.Printf("Warning: Job %s: Failed to publish result: %v", result.ID, err)
+			}
+		}()
+	}
+}
+
+// processQueueJob runs a job message with retries and returns its result
+func (s *Server) processQueueJob(message []byte, options QueueOptions) QueueResult {
+	started := time.Now()
+
+	var request QueueJob
+	if err := json.Unmarshal(message, &request); err != nil {
+		s.metrics.observeFailure()
+		return QueueResult{Status: StatusFailed, Error: fmt.Sprintf("Invalid job message: %v", err)}
+	}
+	if request.ID == "" {
+		request.ID, _ = newJobID()
+	}
+
+	job := &Job{ID: request.ID, Status: StatusPending, Input: request.Input, Filename: request.Output, CreatedAt: time.Now().UTC()}
+	s.registerJob(job)
+
+	result := QueueResult{ID: job.ID, Input: request.Input, Output: request.Output}
+	var err error
+	for backoff := options.Backoff; ; backoff *= 2 {
+		result.Attempts++
+		err = s.runQueueJob(request, job)
+		if err == nil || errors.As(err, &permanentError{}) || result.Attempts > options.Retries {
+			break
+		}
+		s.options.Logger.Printf("Warning: Job %s: Attempt %d failed, retrying in %s: %v", job.ID, result.Attempts, backoff, err)
+		time.Sleep(backoff)
+	}
+	if err != nil {
+		s.failJob(job, err)
+	}
+	s.notify(job, started)
+
+	current := s.snapshot(job)
+	result.Status = current.Status
+	result.Chapters = current.Chapters
+	result.Error = current.Error
+	result.DurationMS = time.Since(started).Milliseconds()
+	if result.Status == StatusFailed {
+		result.Output = ""
+	}
+	return result
+}
+
+// runQueueJob makes one attempt at a job message once a tagging slot is free
+func (s *Server) runQueueJob(request QueueJob, job *Job) error {
+	s.slots <- struct{}{}
+	defer func() { <-s.slots }()
+	started := time.Now()
+	if request.Input == "" || request.Output == "" {
+		return permanentError{fmt.Errorf("'input' and 'output' are required")}
+	}
+
+	// Locations may be storage URLs; file:// URLs name local files
+	local := request
+	for _, location := range []*string{&local.Input, &local.CSV} {
+		if *location == "" {
+			continue
+		}
+		store, name, err := storage.For(*location)
+		if err != nil {
+			return permanentError{err}
+		}
+		if store == (storage.Local{}) {
+			*location = name
+		}
+	}
+	output, outputName, err := storage.For(request.Output)
+	if err != nil {
+		return permanentError{err}
+	}
+	if output == (storage.Local{}) {
+		local.Output = outputName
+	}
+	if !request.Overwrite {
+		if _, err := output.Stat(outputName); err == nil {
+			return permanentError{fmt.Errorf("Output file %s already exists", request.Output)}
+		}
+	}
+
+	// Remote files are tagged in a local directory
+	if storage.IsRemote(request.Input) || storage.IsRemote(request.CSV) || storage.IsRemote(request.Output) {
+		dir, err := os.MkdirTemp(s.options.WorkDir, "queue-")
+		if err != nil {
+			return fmt.Errorf("Failed to create job directory: %w", err)
+		}
+		defer os.RemoveAll(dir)
+		if storage.IsRemote(request.Input) {
+			local.Input = filepath.Join(dir, "input.mp3")
+			if err := downloadQueueFile(request.Input, local.Input); err != nil {
+				return err
+			}
+		}
+		if storage.IsRemote(request.CSV) {
+			local.CSV = filepath.Join(dir, "markers.csv")
+			if err := downloadQueueFile(request.CSV, local.CSV); err != nil {
+				return err
+			}
+		}
+		if storage.IsRemote(request.Output) {
+			local.Output = filepath.Join(dir, "output.mp3")
+		}
+	}
+
+	markers, err := s.queueMarkers(local)
+	if err != nil {
+		return permanentError{err}
+	}
+	options := id3tag.Options{Overwrite: request.Overwrite, Prompt: &prompt.Prompter{Disabled: true}}
+	if err := id3tag.AddChaptersWithOptions(local.Input, markers, local.Output, options); err != nil {
+		if errors.Is(err, prompt.ErrConfirmationRequired) {
+			return permanentError{err}
+		}
+		return err
+	}
+
+	var outputSize int64
+	if info, err := os.Stat(local.Output); err == nil {
+		outputSize = info.Size()
+	}
+	if storage.IsRemote(request.Output) {
+		if err := storage.Put(local.Output, output, outputName); err != nil {
+			return err
+		}
+	}
+	s.metrics.observeSuccess(time.Since(started), outputSize)
+
+	s.mu.Lock()
+	job.Status = StatusDone
+	job.Chapters = len(markers)
+	job.outputPath = request.Output
+	job.finishedAt = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+// downloadQueueFile copies a remote file of a job message to a local path; a missing file is a permanent error
+func downloadQueueFile(location, localPath string) error {
+	err := storage.Download(location, localPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return permanentError{fmt.Errorf("File %s not found", location)}
+	}
+	return err
+}
+
+// queueMarkers returns the markers of a job message, preferring its marker list over its CSV file
+func (s *Server) queueMarkers(request QueueJob) ([]csvparser.MarkerEntry, error) {
+	if len(request.Markers) > 0 {
+		return s.listMarkers(request.Markers, request.Input)
+	}
+	if request.CSV == "" {
+		return nil, fmt.Errorf("Either 'csv' or 'markers' is required")
+	}
+	return csvparser.ParseAuditionCSVWithOptions(request.CSV, csvparser.ParseOptions{
+		MaxBytes: s.options.MaxCSVSize,
+		MaxRows:  s.options.MaxMarkers,
+	})
+}
@@ -0,0 +1,654 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/id3tag"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/mp3frame"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/prompt"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/storage"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/webhook"
+)
+
+// Options holds the server settings
+type Options struct {
+	WorkDir       string      // Directory where uploaded and tagged files are stored
+	MaxUploadSize int64       // Maximum size of a multipart upload in bytes
+	MaxCSVSize    int64       // Maximum size of an uploaded marker CSV file in bytes
+	MaxMarkers    int         // Maximum number of rows of an uploaded marker CSV file
+	WebhookURL    string      // URL notified with a JSON payload after each processed file
+	Logger        *log.Logger // Logger of problems that are not reported to a client, such as retried jobs (default: standard error)
+
+	Concurrency int           // Files tagged at the same time, uploads and queued jobs together (default: number of CPUs)
+	JobTTL      time.Duration // How long finished jobs and their files are kept (default: 24 hours)
+
+	// Storage where tagged uploads are kept for download, e.g. an S3 bucket (default: the job directories in WorkDir)
+	Outputs      storage.Storage
+	OutputPrefix string // Name prefix of tagged uploads in Outputs, e.g. a key prefix
+}
+
+// Job status values
+const (
+	StatusPending = "pending" // Job is waiting or being processed
+	StatusDone    = "done"    // Tagged file is available
+	StatusFailed  = "failed"  // Processing failed, see Error
+)
+
+// Job represents a single tagging request
+type Job struct {
+	ID        string    `json:"id"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Input     string    `json:"input"`
+	Filename  string    `json:"filename"`
+	Chapters  int       `json:"chapters"`
+	CreatedAt time.Time `json:"created_at"`
+
+	output     storage.Storage // Storage of a tagged upload (nil for queued jobs, whose output is a location)
+	outputPath string          // Name of the tagged file in output, or its location
+	finishedAt time.Time       // When the job finished (zero while pending)
+}
+
+// ChapterJSON is the JSON representation of a chapter
+type ChapterJSON struct {
+	Title     string `json:"title"`
+	Start     string `json:"start"`
+	StartTime int64  `json:"start_ms"`
+}
+
+// Server serves the chapter tagging REST API
+type Server struct {
+	options Options
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	slots   chan struct{} // Semaphore limiting the files tagged at the same time
+	metrics *metrics
+}
+
+// New creates a new server with the given options
+func New(options Options) (*Server, error) {
+	if options.WorkDir == "" {
+		dir, err := os.MkdirTemp("", "audition-marker-")
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create work directory: %w", err)
+		}
+		options.WorkDir = dir
+	} else if err := os.MkdirAll(options.WorkDir, 0755); err != nil {
+		return nil, fmt.Errorf("Failed to create work directory: %w", err)
+	}
+
+	if options.MaxUploadSize <= 0 {
+		options.MaxUploadSize = 1 << 30 // 1 GiB
+	}
+	if options.MaxCSVSize <= 0 {
+		options.MaxCSVSize = 10 << 20 // 10 MiB
+	}
+	if options.MaxMarkers <= 0 {
+		options.MaxMarkers = 10000
+	}
+	if options.Concurrency <= 0 {
+		options.Concurrency = runtime.NumCPU()
+	}
+	if options.JobTTL <= 0 {
+		options.JobTTL = 24 * time.Hour
+	}
+	if options.Logger == nil {
+		options.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+	if options.Outputs == nil {
+		options.Outputs = storage.Local{}
+		options.OutputPrefix = options.WorkDir
+	}
+
+	return &Server{
+		options: options,
+		jobs:    make(map[string]*Job),
+		slots:   make(chan struct{}, options.Concurrency),
+		metrics: newMetrics(),
+	}, nil
+}
+
+// Handler returns the HTTP handler exposing the API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /", webHandler())
+	mux.HandleFunc("GET /healthz", handleHealth)
+	mux.Handle("GET /metrics", s.metrics)
+	mux.HandleFunc("POST /api/markers", s.handleParseMarkers)
+	mux.HandleFunc("POST /api/chapters", s.handleAddChapters)
+	mux.HandleFunc("GET /api/jobs/{id}", s.handleGetJob)
+	mux.HandleFunc("GET /api/jobs/{id}/result", s.handleGetResult)
+	mux.HandleFunc("GET /api/jobs/{id}/chapters", s.handleGetChapters)
+	mux.HandleFunc("DELETE /api/jobs/{id}", s.handleDeleteJob)
+	return mux
+}
+
+// handleAddChapters accepts an MP3 and a marker CSV and tags the MP3
+func (s *Server) handleAddChapters(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.options.MaxUploadSize)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("Invalid multipart upload: %w", err))
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	// Create job and its directory
+	job, jobDir, err := s.createJob()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Store uploaded files
+	mp3Name, err := saveFormFile(r, "mp3", jobDir, "input.mp3")
+	if err != nil {
+		s.failJob(job, err)
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.mu.Lock()
+	job.Input = mp3Name
+	job.Filename = outputFilename(mp3Name)
+	s.mu.Unlock()
+
+	// Get markers from the edited JSON list or the uploaded CSV
+	markers, err := s.readMarkers(r, jobDir)
+	if err != nil {
+		s.failJob(job, err)
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Async mode: return job ID immediately
+	if isTrue(r.URL.Query().Get("async")) {
+		go s.processJob(job, jobDir, markers)
+		writeJSON(w, http.StatusAccepted, s.snapshot(job))
+		return
+	}
+
+	// Sync mode: process and return the tagged file
+	s.processJob(job, jobDir, markers)
+	current := s.snapshot(job)
+	if current.Status != StatusDone {
+		writeError(w, http.StatusUnprocessableEntity, fmt.Errorf("%s", current.Error))
+		return
+	}
+
+	w.Header().Set("X-Job-ID", job.ID)
+	s.serveMP3(w, r, current)
+}
+
+// handleParseMarkers parses an uploaded CSV and returns its markers as JSON for preview
+func (s *Server) handleParseMarkers(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.options.MaxUploadSize)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("Invalid multipart upload: %w", err))
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	// Store the CSV in a temporary directory
+	dir, err := os.MkdirTemp(s.options.WorkDir, "preview-")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("Failed to create temporary directory: %w", err))
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := saveFormFile(r, "csv", dir, "markers.csv"); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	markers, err := s.parseUploadedCSV(filepath.Join(dir, "markers.csv"))
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	result := make([]ChapterJSON, 0, len(markers))
+	for _, marker := range markers {
+		result = append(result, ChapterJSON{
+			Title:     marker.Name,
+			Start:     id3tag.FormatDuration(marker.StartTime),
+			StartTime: marker.StartTime.Milliseconds(),
+		})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleGetJob returns the status of a job
+func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.lookupJob(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("Job not found"))
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// handleGetResult returns the tagged MP3 of a finished job
+func (s *Server) handleGetResult(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.lookupJob(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("Job not found"))
+		return
+	}
+	if job.Status != StatusDone {
+		writeError(w, http.StatusConflict, fmt.Errorf("Job is %s", job.Status))
+		return
+	}
+	s.serveMP3(w, r, job)
+}
+
+// handleGetChapters returns the chapters of a tagged file as JSON
+func (s *Server) handleGetChapters(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.lookupJob(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("Job not found"))
+		return
+	}
+	if job.Status != StatusDone {
+		writeError(w, http.StatusConflict, fmt.Errorf("Job is %s", job.Status))
+		return
+	}
+
+	file, _, err := s.openOutput(job)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer file.Close()
+	chapters, err := id3tag.ReadChaptersFrom(file)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	result := make([]ChapterJSON, 0, len(chapters))
+	for _, chapter := range chapters {
+		result = append(result, ChapterJSON{
+			Title:     chapter.Title,
+			Start:     id3tag.FormatDuration(chapter.StartTime),
+			StartTime: chapter.StartTime.Milliseconds(),
+		})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleDeleteJob removes a job and its files
+func (s *Server) handleDeleteJob(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	s.mu.Lock()
+	job, ok := s.jobs[id]
+	pending := ok && job.Status == StatusPending
+	if ok && !pending {
+		delete(s.jobs, id)
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("Job not found"))
+		return
+	}
+	if pending {
+		writeError(w, http.StatusConflict, fmt.Errorf("Job is still running"))
+		return
+	}
+
+	s.removeJobFiles(job)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// removeJobFiles removes the job directory and the tagged upload of a job
+func (s *Server) removeJobFiles(job *Job) {
+	if job.output != nil && job.outputPath != "" {
+		if err := job.output.Remove(job.outputPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			s.options.Logger.Printf("Warning: Job %s: Failed to remove the tagged file: %v", job.ID, err)
+		}
+	}
+	os.RemoveAll(filepath.Join(s.options.WorkDir, job.ID))
+}
+
+// processJob writes chapters into the uploaded MP3 once a tagging slot is free
+func (s *Server) processJob(job *Job, jobDir string, markers []csvparser.MarkerEntry) {
+	s.slots <- struct{}{}
+	defer func() { <-s.slots }()
+	started := time.Now()
+
+	outputPath := filepath.Join(jobDir, "output.mp3")
+	options := id3tag.Options{Prompt: &prompt.Prompter{Disabled: true}}
+	if err := id3tag.AddChaptersWithOptions(filepath.Join(jobDir, "input.mp3"), markers, outputPath, options); err != nil {
+		s.failJob(job, err)
+		s.notify(job, started)
+		return
+	}
+
+	// Record processing statistics
+	var outputSize int64
+	if info, err := os.Stat(outputPath); err == nil {
+		outputSize = info.Size()
+	}
+	name, err := s.storeOutput(job.ID, outputPath)
+	if err != nil {
+		s.failJob(job, err)
+		s.notify(job, started)
+		return
+	}
+	s.metrics.observeSuccess(time.Since(started), outputSize)
+
+	s.mu.Lock()
+	job.Status = StatusDone
+	job.Chapters = len(markers)
+	job.output = s.options.Outputs
+	job.outputPath = name
+	job.finishedAt = time.Now()
+	s.mu.Unlock()
+
+	s.notify(job, started)
+}
+
+// storeOutput moves a tagged upload from its job directory to the output storage and returns its name there
+func (s *Server) storeOutput(id, localPath string) (string, error) {
+	name := path.Join(s.options.OutputPrefix, id, "output.mp3")
+	if _, ok := s.options.Outputs.(storage.Local); ok {
+		name = filepath.Join(s.options.OutputPrefix, id, "output.mp3")
+		if filepath.Clean(name) == filepath.Clean(localPath) {
+			return localPath, nil
+		}
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			return "", fmt.Errorf("Failed to store tagged file: %w", err)
+		}
+	}
+	if err := storage.Put(localPath, s.options.Outputs, name); err != nil {
+		return "", fmt.Errorf("Failed to store tagged file: %w", err)
+	}
+	os.Remove(localPath)
+	return name, nil
+}
+
+// openOutput opens the tagged file of a finished job: in the output storage for uploads, at the output location for queued jobs
+func (s *Server) openOutput(job Job) (io.ReadCloser, storage.Info, error) {
+	store, name := job.output, job.outputPath
+	if store == nil {
+		var err error
+		if store, name, err = storage.For(job.outputPath); err != nil {
+			return nil, storage.Info{}, err
+		}
+	}
+	info, err := store.Stat(name)
+	if err != nil {
+		return nil, storage.Info{}, fmt.Errorf("Tagged file not available: %w", err)
+	}
+	file, err := store.Open(name)
+	if err != nil {
+		return nil, storage.Info{}, fmt.Errorf("Tagged file not available: %w", err)
+	}
+	return file, info, nil
+}
+
+// notify posts the job result to the configured webhook
+func (s *Server) notify(job *Job, started time.Time) {
+	if s.options.WebhookURL == "" {
+		return
+	}
+
+	current := s.snapshot(job)
+	event := webhook.Event{
+		Status:     webhook.StatusDone,
+		Input:      current.Input,
+		Output:     current.Filename,
+		Chapters:   current.Chapters,
+		DurationMS: time.Since(started).Milliseconds(),
+	}
+	if current.Status == StatusFailed {
+		event.Status = webhook.StatusFailed
+		event.Output = ""
+		event.Error = current.Error
+	}
+
+	if err := webhook.Notify(s.options.WebhookURL, event); err != nil {
+		s.options.Logger.Printf("Warning: Job %s: %v", current.ID, err)
+	}
+}
+
+// readMarkers returns the markers of a request, preferring an edited JSON list over the uploaded CSV
+func (s *Server) readMarkers(r *http.Request, jobDir string) ([]csvparser.MarkerEntry, error) {
+	// Use the edited marker list if present
+	if value := r.FormValue("markers"); value != "" {
+		var chapters []ChapterJSON
+		if err := json.Unmarshal([]byte(value), &chapters); err != nil {
+			return nil, fmt.Errorf("Invalid 'markers' field: %w", err)
+		}
+		return s.listMarkers(chapters, filepath.Join(jobDir, "input.mp3"))
+	}
+
+	// Otherwise parse the uploaded CSV
+	if _, err := saveFormFile(r, "csv", jobDir, "markers.csv"); err != nil {
+		return nil, err
+	}
+	return s.parseUploadedCSV(filepath.Join(jobDir, "markers.csv"))
+}
+
+// listMarkers converts an edited marker list to markers sorted by start time.
+// It rejects lists with negative or duplicate start times, empty titles or markers starting after the end of the audio in mp3Path.
+func (s *Server) listMarkers(chapters []ChapterJSON, mp3Path string) ([]csvparser.MarkerEntry, error) {
+	if len(chapters) > s.options.MaxMarkers {
+		return nil, fmt.Errorf("Too many markers: %d exceed the limit of %d", len(chapters), s.options.MaxMarkers)
+	}
+
+	markers := make([]csvparser.MarkerEntry, 0, len(chapters))
+	for i, chapter := range chapters {
+		if chapter.StartTime < 0 {
+			return nil, fmt.Errorf("Marker %d starts at a negative time: %d ms", i+1, chapter.StartTime)
+		}
+		if strings.TrimSpace(chapter.Title) == "" {
+			return nil, fmt.Errorf("Marker %d has no title", i+1)
+		}
+		markers = append(markers, csvparser.MarkerEntry{
+			Name:      chapter.Title,
+			StartTime: time.Duration(chapter.StartTime) * time.Millisecond,
+		})
+	}
+	sort.SliceStable(markers, func(i, j int) bool { return markers[i].StartTime < markers[j].StartTime })
+
+	for i := 1; i < len(markers); i++ {
+		if markers[i].StartTime == markers[i-1].StartTime {
+			return nil, fmt.Errorf("Markers '%s' and '%s' both start at %s", markers[i-1].Name, markers[i].Name, id3tag.FormatDuration(markers[i].StartTime))
+		}
+	}
+	if info, err := mp3frame.ScanFile(mp3Path); err == nil && len(markers) > 0 {
+		if last := markers[len(markers)-1]; last.StartTime >= info.Duration() {
+			return nil, fmt.Errorf("Marker '%s' starts at %s, after the end of the audio (%s)", last.Name, id3tag.FormatDuration(last.StartTime), id3tag.FormatDuration(info.Duration()))
+		}
+	}
+	return markers, nil
+}
+
+// parseUploadedCSV parses an uploaded marker CSV file within the size and row limits of the server
+func (s *Server) parseUploadedCSV(csvPath string) ([]csvparser.MarkerEntry, error) {
+	return csvparser.ParseAuditionCSVWithOptions(csvPath, csvparser.ParseOptions{
+		Source:   "markers.csv",
+		MaxBytes: s.options.MaxCSVSize,
+		MaxRows:  s.options.MaxMarkers,
+	})
+}
+
+// createJob registers a new pending job and creates its working directory
+func (s *Server) createJob() (*Job, string, error) {
+	id, err := newJobID()
+	if err != nil {
+		return nil, "", err
+	}
+
+	jobDir := filepath.Join(s.options.WorkDir, id)
+	if err := os.MkdirAll(jobDir, 0755); err != nil {
+		return nil, "", fmt.Errorf("Failed to create job directory: %w", err)
+	}
+
+	job := &Job{
+		ID:        id,
+		Status:    StatusPending,
+		CreatedAt: time.Now().UTC(),
+	}
+	s.registerJob(job)
+
+	return job, jobDir, nil
+}
+
+// registerJob adds a job to the job list and evicts the finished jobs older than the job TTL
+func (s *Server) registerJob(job *Job) {
+	var expired []*Job
+	s.mu.Lock()
+	for id, existing := range s.jobs {
+		if !existing.finishedAt.IsZero() && time.Since(existing.finishedAt) > s.options.JobTTL {
+			expired = append(expired, existing)
+			delete(s.jobs, id)
+		}
+	}
+	s.jobs[job.ID] = job
+	s.mu.Unlock()
+
+	for _, existing := range expired {
+		s.removeJobFiles(existing)
+	}
+}
+
+// failJob marks a job as failed
+func (s *Server) failJob(job *Job, err error) {
+	s.metrics.observeFailure()
+
+	s.mu.Lock()
+	job.Status = StatusFailed
+	job.Error = err.Error()
+	job.finishedAt = time.Now()
+	s.mu.Unlock()
+}
+
+// lookupJob returns a copy of the job with the given ID
+func (s *Server) lookupJob(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// snapshot returns a copy of the job taken under the lock
+func (s *Server) snapshot(job *Job) Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *job
+}
+
+// saveFormFile stores a multipart file field in dir under name and returns the original filename
+func saveFormFile(r *http.Request, field string, dir string, name string) (string, error) {
+	file, header, err := r.FormFile(field)
+	if err != nil {
+		return "", fmt.Errorf("Missing '%s' file field: %w", field, err)
+	}
+	defer file.Close()
+
+	if err := writeUpload(file, filepath.Join(dir, name)); err != nil {
+		return "", err
+	}
+	return header.Filename, nil
+}
+
+// writeUpload copies an uploaded file to path
+func writeUpload(file multipart.File, path string) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("Failed to store upload: %w", err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, file); err != nil {
+		return fmt.Errorf("Failed to store upload: %w", err)
+	}
+	return nil
+}
+
+// serveMP3 sends a tagged MP3 as an attachment, with range requests if its storage can seek
+func (s *Server) serveMP3(w http.ResponseWriter, r *http.Request, job Job) {
+	file, info, err := s.openOutput(job)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "audio/mpeg")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", job.Filename))
+	if seeker, ok := file.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, job.Filename, info.ModTime, seeker)
+		return
+	}
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	if r.Method != http.MethodHead {
+		io.Copy(w, file)
+	}
+}
+
+// outputFilename derives the download filename from the uploaded filename
+func outputFilename(uploaded string) string {
+	base := filepath.Base(uploaded)
+	if base == "." || base == string(filepath.Separator) {
+		base = "output.mp3"
+	}
+	ext := filepath.Ext(base)
+	return strings.TrimSuffix(base, ext) + "_with_chapters.mp3"
+}
+
+// newJobID generates a random job identifier
+func newJobID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("Failed to generate job ID: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// isTrue reports whether a query value enables an option
+func isTrue(value string) bool {
+	switch strings.ToLower(value) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error as a JSON response
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
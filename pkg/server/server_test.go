@@ -0,0 +1,87 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// silentMP3 returns an untagged MP3 of 1000 silent frames, about 26 seconds
+func silentMP3() []byte {
+	frame := make([]byte, 417) // Silent MPEG-1 Layer III frame, 128 kbit/s, 44.1 kHz
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0x00})
+	return bytes.Repeat(frame, 1000)
+}
+
+// postChapters uploads the MP3 with an edited marker list and returns the response
+func postChapters(t *testing.T, handler http.Handler, markers string) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("mp3", "episode.mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(silentMP3())
+	form.WriteField("markers", markers)
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/chapters", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAddChaptersMarkerList(t *testing.T) {
+	srv, err := New(Options{WorkDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := srv.Handler()
+
+	tests := []struct {
+		name    string
+		markers string
+		code    int
+		err     string // Substring expected in the error message
+	}{
+		{"negative start", `[{"title":"Intro","start_ms":-1}]`, http.StatusBadRequest, "negative time"},
+		{"empty title", `[{"title":"Intro","start_ms":0},{"title":" ","start_ms":5000}]`, http.StatusBadRequest, "Marker 2 has no title"},
+		{"duplicate start", `[{"title":"Intro","start_ms":5000},{"title":"Talk","start_ms":5000}]`, http.StatusBadRequest, "both start at"},
+		{"after the end", `[{"title":"Intro","start_ms":0},{"title":"Outro","start_ms":60000}]`, http.StatusBadRequest, "after the end of the audio"},
+		{"unsorted", `[{"title":"Talk","start_ms":10000},{"title":"Intro","start_ms":0}]`, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := postChapters(t, handler, tt.markers)
+			if rec.Code != tt.code {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.code, rec.Body.String())
+			}
+			if tt.err != "" {
+				if !strings.Contains(rec.Body.String(), tt.err) {
+					t.Errorf("error %s does not contain %q", rec.Body.String(), tt.err)
+				}
+				return
+			}
+
+			// The chapters of the tagged file are sorted by start time
+			req := httptest.NewRequest(http.MethodGet, "/api/jobs/"+rec.Header().Get("X-Job-ID")+"/chapters", nil)
+			chaptersRec := httptest.NewRecorder()
+			handler.ServeHTTP(chaptersRec, req)
+			data, _ := io.ReadAll(chaptersRec.Body)
+			var chapters []ChapterJSON
+			if err := json.Unmarshal(data, &chapters); err != nil {
+				t.Fatalf("invalid chapter list %s: %v", data, err)
+			}
+			if len(chapters) != 2 || chapters[0].Title != "Intro" || chapters[1].Title != "Talk" {
+				t.Errorf("chapters = %+v, want Intro then Talk", chapters)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed web
+var webFiles embed.FS
+
+// webHandler serves the embedded drag-and-drop web UI
+func webHandler() http.Handler {
+	root, err := fs.Sub(webFiles, "web")
+	if err != nil {
+		panic(err) // The embedded directory always exists
+	}
+	return http.FileServerFS(root)
+}
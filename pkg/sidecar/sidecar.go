@@ -0,0 +1,78 @@
+package sidecar
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/exporter"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/importer"
+)
+
+// Formats lists the export and import formats that can be used as sidecar files, in the order they are looked up
+var Formats = []string{"json", "webvtt"}
+
+// Path returns the sidecar path for an audio file, e.g. episode.chapters.json for episode.mp3
+func Path(audioPath string, format exporter.Format) string {
+	ext := filepath.Ext(audioPath)
+	return audioPath[:len(audioPath)-len(ext)] + ".chapters" + format.Extension
+}
+
+// Write writes markers to the sidecar file of an audio file and returns its path
+// duration is the audio length used as the end of the last chapter (0 if unknown)
+func Write(audioPath string, formatName string, markers []csvparser.MarkerEntry, duration time.Duration) (string, error) {
+	format, err := Lookup(formatName)
+	if err != nil {
+		return "", err
+	}
+
+	path := Path(audioPath, format)
+	file, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("Cannot create sidecar file: %w", err)
+	}
+	defer file.Close()
+
+	if err := format.Write(file, markers, exporter.Options{Duration: duration}); err != nil {
+		return "", err
+	}
+	return path, file.Close()
+}
+
+// Find returns the path of an existing sidecar file of an audio file
+func Find(audioPath string) (string, bool) {
+	for _, name := range Formats {
+		format, _ := exporter.Lookup(name)
+		path := Path(audioPath, format)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// Read reads the markers of a JSON or WebVTT sidecar file, recognized by its extension
+func Read(path string) ([]csvparser.MarkerEntry, error) {
+	for _, name := range Formats {
+		format, _ := importer.Lookup(name)
+		for _, ext := range format.Extensions {
+			if strings.EqualFold(filepath.Ext(path), ext) {
+				return importer.ReadFile(path, format, importer.Options{})
+			}
+		}
+	}
+	return nil, fmt.Errorf("Unknown sidecar file type '%s' (expected .json or .vtt)", filepath.Ext(path))
+}
+
+// Lookup returns the export format of a sidecar format name
+func Lookup(name string) (exporter.Format, error) {
+	for _, supported := range Formats {
+		if strings.EqualFold(name, supported) {
+			return exporter.Lookup(supported)
+		}
+	}
+	return exporter.Format{}, fmt.Errorf("Unknown sidecar format '%s' (available: %s)", name, strings.Join(Formats, ", "))
+}
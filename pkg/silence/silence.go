@@ -0,0 +1,158 @@
+package silence
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/mp3frame"
+)
+
+// Default detection settings
+const (
+	DefaultThreshold   = -50.0                   // Level in dBFS below which a frame counts as silent
+	DefaultMinDuration = 1500 * time.Millisecond // Shortest run of silent frames reported as a silence
+	SnapMinDuration    = 200 * time.Millisecond  // Shortest pause a marker is snapped to
+)
+
+// Options configures silence detection
+type Options struct {
+	Threshold   float64       // Level in dBFS below which a frame counts as silent
+	MinDuration time.Duration // Shortest run of silent frames reported as a silence
+}
+
+// Interval is a detected silence
+type Interval struct {
+	Start time.Duration // Start of the silence
+	End   time.Duration // End of the silence (where sound resumes)
+}
+
+// Result holds the silences of an MP3 file
+type Result struct {
+	Silences []Interval    // Detected silences in time order
+	Duration time.Duration // Total audio duration
+}
+
+// DetectFile finds silences in the MP3 file at path using levels estimated from each frame
+func DetectFile(path string, options Options) (*Result, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot open MP3 file: %w", err)
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("Cannot stat MP3 file: %w", err)
+	}
+
+	info, err := mp3frame.Scan(file, stat.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	return detect(file, info, options)
+}
+
+// detect walks the frames of a scanned stream and collects runs of silent frames
+func detect(r io.ReaderAt, info *mp3frame.Info, options Options) (*Result, error) {
+	result := &Result{Duration: info.Duration()}
+
+	start := info.Frames[0].Offset
+	reader := bufio.NewReaderSize(io.NewSectionReader(r, start, info.AudioEnd-start), 64*1024)
+	position := start
+
+	var samples int64
+	silentFrom := int64(-1)
+	flush := func(until int64) {
+		if silentFrom < 0 {
+			return
+		}
+		interval := Interval{
+			Start: samplesToDuration(silentFrom, info.SampleRate),
+			End:   samplesToDuration(until, info.SampleRate),
+		}
+		if interval.End-interval.Start >= options.MinDuration {
+			result.Silences = append(result.Silences, interval)
+		}
+		silentFrom = -1
+	}
+
+	for _, frame := range info.Frames {
+		// Skip bytes between frames
+		if _, err := reader.Discard(int(frame.Offset - position)); err != nil {
+			return nil, fmt.Errorf("Failed to read MP3 frames: %w", err)
+		}
+		body, err := reader.Peek(frame.Header.Size)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read MP3 frames: %w", err)
+		}
+
+		if frame.Header.Level(body) < options.Threshold {
+			if silentFrom < 0 {
+				silentFrom = samples
+			}
+		} else {
+			flush(samples)
+		}
+
+		reader.Discard(frame.Header.Size)
+		position = frame.Offset + int64(frame.Header.Size)
+		samples += int64(frame.Header.Samples)
+	}
+	flush(samples)
+
+	return result, nil
+}
+
+// samplesToDuration converts a sample count to a duration
+func samplesToDuration(samples int64, sampleRate int) time.Duration {
+	return time.Duration(samples) * time.Second / time.Duration(sampleRate)
+}
+
+// Markers proposes a chapter at the start and wherever sound resumes after a silence.
+// Chapter names are generated from nameFormat with the 1-based chapter number (e.g. "Chapter %d").
+func (result *Result) Markers(nameFormat string) []csvparser.MarkerEntry {
+	markers := []csvparser.MarkerEntry{{Name: fmt.Sprintf(nameFormat, 1), StartTime: 0}}
+	for _, silence := range result.Silences {
+		// Silences at the very start or end do not separate chapters
+		if silence.Start == 0 || silence.End >= result.Duration {
+			continue
+		}
+		markers = append(markers, csvparser.MarkerEntry{
+			Name:      fmt.Sprintf(nameFormat, len(markers)+1),
+			StartTime: silence.End,
+		})
+	}
+	return markers
+}
+
+// Snap moves each marker to the middle of the nearest silence whose middle lies within window of it.
+// Markers without a silence in range are returned unchanged.
+func Snap(markers []csvparser.MarkerEntry, silences []Interval, window time.Duration) []csvparser.MarkerEntry {
+	snapped := make([]csvparser.MarkerEntry, len(markers))
+	for i, marker := range markers {
+		snapped[i] = marker
+
+		best := window + 1
+		for _, silence := range silences {
+			middle := silence.Start + (silence.End-silence.Start)/2
+			if distance := absDuration(middle - marker.StartTime); distance <= window && distance < best {
+				best = distance
+				snapped[i].StartTime = middle
+			}
+		}
+	}
+	return snapped
+}
+
+// absDuration returns the absolute value of d
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
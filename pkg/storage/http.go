@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+func init() {
+	for _, scheme := range []string{"http", "https"} {
+		Register(scheme, func(u *url.URL) (Storage, string, error) {
+			return &HTTP{}, u.String(), nil
+		})
+	}
+}
+
+// HTTP reads files with GET and writes them with PUT and DELETE, as WebDAV servers and presigned upload URLs accept;
+// names are URLs, which may carry basic authentication credentials as user:password@host
+type HTTP struct {
+	Client *http.Client // Client used for requests (nil for a client with a 10 minute timeout)
+	Header http.Header  // Headers sent with every request, e.g. Authorization (nil for none)
+}
+
+// defaultHTTPClient is the client of HTTP storage without one; the timeout allows for uploads of long episodes
+var defaultHTTPClient = &http.Client{Timeout: 10 * time.Minute}
+
+// Open downloads the file at the URL
+func (h *HTTP) Open(name string) (io.ReadCloser, error) {
+	resp, err := h.do(http.MethodGet, name, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Create returns a writer whose data is uploaded to the URL with PUT when it is closed
+func (h *HTTP) Create(name string) (io.WriteCloser, error) {
+	return newSpool(func(body io.ReadSeeker, size int64, _ []byte) error {
+		resp, err := h.do(http.MethodPut, name, body, size)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	})
+}
+
+// Stat returns the size and modification time of the file at the URL from a HEAD request
+func (h *HTTP) Stat(name string) (Info, error) {
+	resp, err := h.do(http.MethodHead, name, nil, 0)
+	if err != nil {
+		return Info{}, err
+	}
+	resp.Body.Close()
+	info := Info{Size: resp.ContentLength}
+	if modified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = modified
+	}
+	return info, nil
+}
+
+// Remove deletes the file at the URL
+func (h *HTTP) Remove(name string) error {
+	resp, err := h.do(http.MethodDelete, name, nil, 0)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// do sends a request and returns the response if its status is successful; 404 returns an error wrapping fs.ErrNotExist
+func (h *HTTP) do(method, rawURL string, body io.Reader, size int64) (*http.Response, error) {
+	req, err := http.NewRequest(method, rawURL, body)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid URL '%s': %w", redactURL(rawURL), err)
+	}
+	req.ContentLength = size
+	for key, values := range h.Header {
+		req.Header[key] = values
+	}
+	client := h.Client
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s '%s' failed: %w", method, redactURL(rawURL), err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("'%s': %w", redactURL(rawURL), fs.ErrNotExist)
+	}
+	return nil, fmt.Errorf("%s '%s' failed: server returned %s", method, redactURL(rawURL), resp.Status)
+}
+
+// redactURL removes the password and query string of a URL for messages, as they may hold credentials or signatures
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	u.RawQuery = ""
+	return u.Redacted()
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/safefile"
+)
+
+// Local stores files in the local file system; names are paths
+type Local struct{}
+
+// Open opens the file at path
+func (Local) Open(path string) (io.ReadCloser, error) {
+	return os.Open(path)
+}
+
+// Create writes to a temporary file next to path, which replaces path when closed
+func (Local) Create(path string) (io.WriteCloser, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("Cannot create directory of '%s': %w", path, err)
+	}
+	temp, err := safefile.TempPath(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(temp, os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		os.Remove(temp)
+		return nil, err
+	}
+	return &localFile{File: file, path: path}, nil
+}
+
+// Stat returns the size and modification time of the file at path
+func (Local) Stat(path string) (Info, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Info{}, err
+	}
+	return Info{Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// Remove deletes the file at path
+func (Local) Remove(path string) error {
+	return os.Remove(path)
+}
+
+// localFile is a temporary file that replaces path when closed
+type localFile struct {
+	*os.File
+	path string
+}
+
+// Close closes the temporary file and moves it to the path
+func (f *localFile) Close() error {
+	if err := f.File.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return safefile.Replace(f.Name(), f.path)
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Memory stores files in memory, e.g. as a stand-in for remote storage in tests; the zero value is empty and ready to use
+type Memory struct {
+	mu    sync.Mutex
+	files map[string]memoryFile
+}
+
+// memoryFile is a file held by Memory
+type memoryFile struct {
+	data    []byte
+	modTime time.Time
+}
+
+// Open returns a reader of a copy of the file
+func (m *Memory) Open(name string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	file, ok := m.files[name]
+	if !ok {
+		return nil, fmt.Errorf("'%s': %w", name, fs.ErrNotExist)
+	}
+	return io.NopCloser(bytes.NewReader(file.data)), nil
+}
+
+// Create returns a writer whose data becomes the file when it is closed
+func (m *Memory) Create(name string) (io.WriteCloser, error) {
+	return &memoryWriter{memory: m, name: name}, nil
+}
+
+// Stat returns the size and modification time of the file
+func (m *Memory) Stat(name string) (Info, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	file, ok := m.files[name]
+	if !ok {
+		return Info{}, fmt.Errorf("'%s': %w", name, fs.ErrNotExist)
+	}
+	return Info{Size: int64(len(file.data)), ModTime: file.modTime}, nil
+}
+
+// Remove deletes the file
+func (m *Memory) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.files[name]; !ok {
+		return fmt.Errorf("'%s': %w", name, fs.ErrNotExist)
+	}
+	delete(m.files, name)
+	return nil
+}
+
+// Names returns the names of all files in alphabetical order
+func (m *Memory) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.files))
+	for name := range m.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// memoryWriter collects the data of a file written to Memory
+type memoryWriter struct {
+	bytes.Buffer
+	memory *Memory
+	name   string
+}
+
+// Close stores the written data as the file
+func (w *memoryWriter) Close() error {
+	w.memory.mu.Lock()
+	defer w.memory.mu.Unlock()
+	if w.memory.files == nil {
+		w.memory.files = make(map[string]memoryFile)
+	}
+	w.memory.files[w.name] = memoryFile{data: bytes.Clone(w.Bytes()), modTime: time.Now()}
+	return nil
+}
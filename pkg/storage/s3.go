@@ -0,0 +1,253 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+func init() {
+	Register("s3", func(u *url.URL) (Storage, string, error) {
+		if u.Host == "" {
+			return nil, "", fmt.Errorf("S3 URL '%s' has no bucket; use s3://bucket/key", u)
+		}
+		key := strings.TrimPrefix(u.Path, "/")
+		if key == "" {
+			return nil, "", fmt.Errorf("S3 URL '%s' has no object key; use s3://bucket/key", u)
+		}
+		s3, err := NewS3FromEnv(u.Host)
+		if err != nil {
+			return nil, "", err
+		}
+		return s3, key, nil
+	})
+}
+
+// S3 stores files as objects of an Amazon S3 bucket, or of a bucket of an S3-compatible object store such as MinIO or R2,
+// with requests signed with AWS Signature Version 4; names are object keys
+type S3 struct {
+	Bucket       string       // Bucket name
+	Region       string       // Region of the bucket, e.g. us-east-1
+	Endpoint     string       // Endpoint of an S3-compatible store, e.g. https://minio.example.com, addressed path-style (empty for AWS)
+	AccessKey    string       // Access key ID
+	SecretKey    string       // Secret access key
+	SessionToken string       // Session token of temporary credentials (empty for none)
+	Client       *http.Client // Client used for requests (nil for a client with a 10 minute timeout)
+}
+
+// NewS3FromEnv returns the S3 storage of a bucket with the credentials, region and endpoint of the standard AWS environment variables:
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN, AWS_REGION or AWS_DEFAULT_REGION, and AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL
+func NewS3FromEnv(bucket string) (*S3, error) {
+	s3 := &S3{
+		Bucket:       bucket,
+		Region:       firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"),
+		Endpoint:     firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"),
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if s3.AccessKey == "" || s3.SecretKey == "" {
+		return nil, fmt.Errorf("S3 credentials not found: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	if s3.Region == "" {
+		s3.Region = "us-east-1"
+	}
+	return s3, nil
+}
+
+// firstEnv returns the value of the first set environment variable
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// emptySHA256 is the hex SHA-256 hash of an empty request body
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Open downloads the object
+func (s *S3) Open(key string) (io.ReadCloser, error) {
+	resp, err := s.do(http.MethodGet, key, nil, 0, emptySHA256)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Create returns a writer whose data is uploaded as the object when it is closed
+func (s *S3) Create(key string) (io.WriteCloser, error) {
+	return newSpool(func(body io.ReadSeeker, size int64, sum []byte) error {
+		resp, err := s.do(http.MethodPut, key, body, size, hex.EncodeToString(sum))
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	})
+}
+
+// Stat returns the size and modification time of the object
+func (s *S3) Stat(key string) (Info, error) {
+	resp, err := s.do(http.MethodHead, key, nil, 0, emptySHA256)
+	if err != nil {
+		return Info{}, err
+	}
+	resp.Body.Close()
+	info := Info{Size: resp.ContentLength}
+	if modified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = modified
+	}
+	return info, nil
+}
+
+// Remove deletes the object
+func (s *S3) Remove(key string) error {
+	resp, err := s.do(http.MethodDelete, key, nil, 0, emptySHA256)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// objectURL returns the URL of an object: virtual-hosted style on AWS, path style on other endpoints
+func (s *S3) objectURL(key string) string {
+	if s.Endpoint != "" {
+		return strings.TrimSuffix(s.Endpoint, "/") + "/" + uriEncode(s.Bucket, false) + "/" + uriEncode(key, false)
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.Bucket, s.Region, uriEncode(key, false))
+}
+
+// do sends a signed request for an object and returns the response if its status is successful;
+// a missing object returns an error wrapping fs.ErrNotExist
+func (s *S3) do(method, key string, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	location := fmt.Sprintf("s3://%s/%s", s.Bucket, key)
+	req, err := http.NewRequest(method, s.objectURL(key), body)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid S3 request for '%s': %w", location, err)
+	}
+	req.ContentLength = size
+	s.sign(req, payloadHash, time.Now().UTC())
+
+	client := s.Client
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("S3 %s '%s' failed: %w", method, location, err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	var s3Err struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&s3Err)
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("'%s': %w", location, fs.ErrNotExist)
+	}
+	if s3Err.Code != "" {
+		return nil, fmt.Errorf("S3 %s '%s' failed: %s: %s", method, location, s3Err.Code, s3Err.Message)
+	}
+	return nil, fmt.Errorf("S3 %s '%s' failed: server returned %s", method, location, resp.Status)
+}
+
+// sign adds the AWS Signature Version 4 authorization header of a request with the given payload hash
+func (s *S3) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	// Canonical request over the host and x-amz-* headers
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	// String to sign and signature with the key derived for the day, region and service
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.AccessKey, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery returns the query parameters sorted by name with their names and values URI-encoded
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes every byte except unreserved characters as Signature Version 4 requires, keeping slashes unless encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
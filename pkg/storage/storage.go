@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Storage reads and writes whole files of a backend by name: a path for local storage, a key or URL for remote ones.
+// The main command, join and serve (including queued jobs) read and write files through a Storage;
+// tags are rewritten in a local copy of a remote file, which Download and Upload (or Get and Put) move in and out.
+type Storage interface {
+	// Open opens a file for reading; a missing file returns an error wrapping fs.ErrNotExist
+	Open(name string) (io.ReadCloser, error)
+	// Create returns a writer for a new version of a file, which replaces the old one once Close returns nil
+	Create(name string) (io.WriteCloser, error)
+	// Stat returns the size and modification time of a file
+	Stat(name string) (Info, error)
+	// Remove deletes a file
+	Remove(name string) error
+}
+
+// Info describes a stored file
+type Info struct {
+	Size    int64     // Size in bytes
+	ModTime time.Time // Last modification (zero if unknown)
+}
+
+// Opener returns the storage of a URL and the name of the file within it
+type Opener func(u *url.URL) (Storage, string, error)
+
+// schemes holds the storage backends by URL scheme
+var schemes = make(map[string]Opener)
+
+// Register adds a storage backend for the URL scheme, e.g. "sftp", so its URLs can be used wherever files are read or written
+func Register(scheme string, open Opener) {
+	schemes[strings.ToLower(scheme)] = open
+}
+
+func init() {
+	Register("file", func(u *url.URL) (Storage, string, error) {
+		return Local{}, filepath.FromSlash(u.Path), nil
+	})
+}
+
+// Schemes returns the URL schemes of all registered backends in alphabetical order
+func Schemes() []string {
+	names := make([]string, 0, len(schemes))
+	for name := range schemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// For returns the storage of a location and the name of the file within it: a URL of a registered scheme,
+// such as s3://bucket/key or https://host/path, or a local path
+func For(location string) (Storage, string, error) {
+	scheme, ok := urlScheme(location)
+	if !ok {
+		return Local{}, location, nil
+	}
+	open, ok := schemes[scheme]
+	if !ok {
+		return nil, "", fmt.Errorf("Unsupported storage '%s://' in '%s' (available: %s)", scheme, location, strings.Join(Schemes(), ", "))
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, "", fmt.Errorf("Invalid storage URL '%s': %w", location, err)
+	}
+	return open(u)
+}
+
+// IsRemote reports whether location is a URL of a storage other than the local file system
+func IsRemote(location string) bool {
+	scheme, ok := urlScheme(location)
+	return ok && scheme != "file"
+}
+
+// urlScheme returns the lowercase scheme of a location given as a URL; Windows drive letters are not schemes
+func urlScheme(location string) (string, bool) {
+	scheme, rest, found := strings.Cut(location, "://")
+	if !found || len(scheme) < 2 || rest == "" {
+		return "", false
+	}
+	for i, r := range scheme {
+		letter := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+		if !letter && (i == 0 || !(r >= '0' && r <= '9' || r == '+' || r == '-' || r == '.')) {
+			return "", false
+		}
+	}
+	return strings.ToLower(scheme), true
+}
+
+// Sibling returns the location of the file named base in the directory of location, e.g. for files written next to a remote output
+func Sibling(location, base string) string {
+	if _, ok := urlScheme(location); ok {
+		if u, err := url.Parse(location); err == nil {
+			u.Path = path.Join(path.Dir(u.Path), base)
+			u.RawPath = ""
+			return u.String()
+		}
+	}
+	return filepath.Join(filepath.Dir(location), base)
+}
+
+// Download copies the file at location to the local path
+func Download(location, localPath string) error {
+	s, name, err := For(location)
+	if err != nil {
+		return err
+	}
+	return Get(s, name, localPath)
+}
+
+// Upload copies the local file at localPath to location
+func Upload(localPath, location string) error {
+	s, name, err := For(location)
+	if err != nil {
+		return err
+	}
+	return Put(localPath, s, name)
+}
+
+// Get copies the file name of a storage to the local path
+func Get(s Storage, name, localPath string) error {
+	src, err := s.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(localPath)
+	if err != nil {
+		return fmt.Errorf("Cannot create '%s': %w", localPath, err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return fmt.Errorf("Failed to download '%s': %w", name, err)
+	}
+	return dst.Close()
+}
+
+// Put copies the local file at localPath to the file name of a storage
+func Put(localPath string, s Storage, name string) error {
+	src, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("Cannot open '%s': %w", localPath, err)
+	}
+	defer src.Close()
+
+	dst, err := s.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return fmt.Errorf("Failed to upload '%s': %w", name, err)
+	}
+	return dst.Close()
+}
+
+// spool buffers a file written to a remote storage in a temporary file, so it can be sent with its size and SHA-256 hash once complete
+type spool struct {
+	file   *os.File
+	hash   hash.Hash
+	size   int64
+	send   func(body io.ReadSeeker, size int64, sha256 []byte) error // Sends the complete file
+	closed bool
+}
+
+// newSpool creates a spool that calls send when it is closed
+func newSpool(send func(body io.ReadSeeker, size int64, sha256 []byte) error) (*spool, error) {
+	file, err := os.CreateTemp("", "audition-marker-upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("Cannot create temporary upload file: %w", err)
+	}
+	return &spool{file: file, hash: sha256.New(), send: send}, nil
+}
+
+func (s *spool) Write(p []byte) (int, error) {
+	n, err := s.file.Write(p)
+	s.hash.Write(p[:n])
+	s.size += int64(n)
+	return n, err
+}
+
+// Close sends the buffered file and removes the temporary file
+func (s *spool) Close() error {
+	if s.closed {
+		return errors.New("upload already closed")
+	}
+	s.closed = true
+	defer os.Remove(s.file.Name())
+	defer s.file.Close()
+
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("Failed to read temporary upload file: %w", err)
+	}
+	return s.send(s.file, s.size, s.hash.Sum(nil))
+}
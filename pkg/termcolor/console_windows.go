@@ -0,0 +1,25 @@
+package termcolor
+
+import (
+	"os"
+	"syscall"
+)
+
+// enableVirtualTerminalProcessing is the console mode flag that makes the Windows console interpret ANSI escape sequences
+const enableVirtualTerminalProcessing = 0x0004
+
+var setConsoleMode = syscall.NewLazyDLL("kernel32.dll").NewProc("SetConsoleMode")
+
+// enableEscapes turns on escape sequence processing of the console, which older consoles lack; false if it cannot be enabled
+func enableEscapes(file *os.File) bool {
+	handle := syscall.Handle(file.Fd())
+	var mode uint32
+	if err := syscall.GetConsoleMode(handle, &mode); err != nil {
+		return false
+	}
+	if mode&enableVirtualTerminalProcessing != 0 {
+		return true
+	}
+	result, _, _ := setConsoleMode.Call(uintptr(handle), uintptr(mode|enableVirtualTerminalProcessing))
+	return result != 0
+}
@@ -0,0 +1,105 @@
+package termcolor
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+)
+
+// ANSI escape sequences of the colors used
+const (
+	Red    = "\x1b[31m"
+	Green  = "\x1b[32m"
+	Yellow = "\x1b[33m"
+	reset  = "\x1b[0m"
+)
+
+// Rule colors the lines a match function accepts
+type Rule struct {
+	Match func(line string) bool
+	Color string
+}
+
+// Prefix returns a rule coloring the lines that start with one of the prefixes
+func Prefix(color string, prefixes ...string) Rule {
+	return Rule{Color: color, Match: func(line string) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(line, prefix) {
+				return true
+			}
+		}
+		return false
+	}}
+}
+
+// Enabled reports whether colors should be written to w: w must be a terminal, NO_COLOR must be unset or empty
+// (https://no-color.org) and TERM must not be "dumb". Pipes, files and other writers get plain output.
+func Enabled(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := file.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	return enableEscapes(file)
+}
+
+// Writer colors whole lines written through it by the first matching rule
+type Writer struct {
+	w       io.Writer
+	rules   []Rule
+	color   string // Color of the current line, decided at its start
+	midLine bool   // Whether the last write ended within a line
+}
+
+// NewWriter returns a writer that colors the lines written to w by rules
+func NewWriter(w io.Writer, rules ...Rule) *Writer {
+	return &Writer{w: w, rules: rules}
+}
+
+// Write writes p, wrapping each line or part of a line in the color of its line. A line's color is decided by the text written
+// up to its first line break in the write that starts it, which is the whole line for output printed with the fmt functions.
+func (cw *Writer) Write(p []byte) (int, error) {
+	var out bytes.Buffer
+	for rest := p; len(rest) > 0; {
+		line := rest
+		newline := bytes.IndexByte(rest, '\n')
+		if newline >= 0 {
+			line = rest[:newline]
+		}
+		if !cw.midLine {
+			cw.color = cw.match(string(line))
+		}
+		if cw.color != "" && len(line) > 0 {
+			out.WriteString(cw.color)
+			out.Write(line)
+			out.WriteString(reset)
+		} else {
+			out.Write(line)
+		}
+		if newline < 0 {
+			cw.midLine = true
+			break
+		}
+		out.WriteByte('\n')
+		cw.midLine = false
+		rest = rest[newline+1:]
+	}
+	if _, err := cw.w.Write(out.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// match returns the color of the first rule matching a line, or "" for none
+func (cw *Writer) match(line string) string {
+	for _, rule := range cw.rules {
+		if rule.Match(line) {
+			return rule.Color
+		}
+	}
+	return ""
+}
@@ -0,0 +1,219 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Exporter sends spans to an OpenTelemetry collector with OTLP over HTTP, encoded as JSON
+type Exporter struct {
+	Endpoint string            // URL spans are posted to, e.g. http://localhost:4318/v1/traces
+	Header   map[string]string // Headers sent with every request, e.g. an API key of a tracing service
+	Client   *http.Client      // Client used for requests (nil for a client with a 10 second timeout)
+}
+
+// defaultClient is the client of exporters without one; a slow collector must not hold up the run for long
+var defaultClient = &http.Client{Timeout: 10 * time.Second}
+
+// exporterFromEnv returns the exporter of the endpoint, or of the OTLP endpoint variables if it is empty; nil if none is set
+func exporterFromEnv(endpoint string) (*Exporter, error) {
+	traces := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") // Used as is, as the specification asks
+	if endpoint == "" {
+		endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	} else {
+		traces = ""
+	}
+	if endpoint == "" && traces == "" {
+		return nil, nil
+	}
+	for _, value := range []string{endpoint, traces} {
+		if u, err := url.Parse(value); value != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+			return nil, fmt.Errorf("Invalid OTLP endpoint '%s': use an http:// or https:// URL, e.g. http://localhost:4318", value)
+		}
+	}
+	if traces == "" {
+		traces = tracesURL(endpoint)
+	}
+	protocol := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")
+	if protocol == "" {
+		protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+	}
+	if protocol == "grpc" {
+		return nil, fmt.Errorf("OTLP protocol 'grpc' is not supported: use the OTLP/HTTP endpoint of the collector (port 4318)")
+	}
+
+	header := parseKeyValues(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+	for key, value := range parseKeyValues(os.Getenv("OTEL_EXPORTER_OTLP_TRACES_HEADERS")) {
+		header[key] = value
+	}
+	return &Exporter{Endpoint: traces, Header: header}, nil
+}
+
+// tracesURL returns the traces URL of an OTLP/HTTP base endpoint
+func tracesURL(base string) string {
+	return strings.TrimSuffix(base, "/") + "/v1/traces"
+}
+
+// export posts spans as an OTLP/JSON ExportTraceServiceRequest
+func (e *Exporter) export(ctx context.Context, t *Tracer, spans []*Span) error {
+	body, err := json.Marshal(encodeRequest(t, spans))
+	if err != nil {
+		return fmt.Errorf("Failed to encode trace spans: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("Invalid OTLP endpoint '%s': %w", e.Endpoint, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.Header {
+		req.Header.Set(key, value)
+	}
+	client := e.Client
+	if client == nil {
+		client = defaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Failed to send trace spans: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Failed to send trace spans: collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// OTLP/JSON messages; IDs are hex strings and 64-bit integers are decimal strings, as the OTLP specification defines for JSON
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              int             `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Events            []otlpEvent     `json:"events,omitempty"`
+		Status            otlpStatus      `json:"status"`
+	}
+	otlpEvent struct {
+		TimeUnixNano string          `json:"timeUnixNano"`
+		Name         string          `json:"name"`
+		Attributes   []otlpAttribute `json:"attributes"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpAttribute struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	}
+)
+
+// OTLP enumeration values
+const (
+	spanKindInternal = 1
+	statusCodeError  = 2
+)
+
+// scopeName is the instrumentation scope of the spans
+const scopeName = "github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/tracing"
+
+// encodeRequest converts the spans of t to an OTLP request
+func encodeRequest(t *Tracer, spans []*Span) otlpRequest {
+	resource := map[string]any{"service.name": t.Service}
+	for key, value := range t.Resource {
+		resource[key] = value
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	encoded := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		span := otlpSpan{
+			TraceID:           hex.EncodeToString(s.traceID[:]),
+			SpanID:            hex.EncodeToString(s.spanID[:]),
+			Name:              s.name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        encodeAttributes(s.attributes),
+		}
+		if s.parentID != [8]byte{} {
+			span.ParentSpanID = hex.EncodeToString(s.parentID[:])
+		}
+		if s.err != nil {
+			span.Status = otlpStatus{Code: statusCodeError, Message: s.err.Error()}
+			span.Events = []otlpEvent{{
+				TimeUnixNano: span.EndTimeUnixNano,
+				Name:         "exception",
+				Attributes:   encodeAttributes(map[string]any{"exception.message": s.err.Error()}),
+			}}
+		}
+		encoded = append(encoded, span)
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: encodeAttributes(resource)},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: scopeName}, Spans: encoded}},
+	}}}
+}
+
+// encodeAttributes converts attributes to OTLP key-value pairs sorted by key
+func encodeAttributes(attributes map[string]any) []otlpAttribute {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	encoded := make([]otlpAttribute, 0, len(keys))
+	for _, key := range keys {
+		var value map[string]any
+		switch v := attributes[key].(type) {
+		case string:
+			value = map[string]any{"stringValue": v}
+		case bool:
+			value = map[string]any{"boolValue": v}
+		case int:
+			value = map[string]any{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]any{"doubleValue": v}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		encoded = append(encoded, otlpAttribute{Key: key, Value: value})
+	}
+	return encoded
+}
@@ -0,0 +1,204 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tracer collects the spans of a run and sends them to an OpenTelemetry collector with Flush
+type Tracer struct {
+	Exporter *Exporter         // Destination of the spans
+	Service  string            // Service name of the spans (resource attribute service.name)
+	Resource map[string]string // Further resource attributes, e.g. deployment.environment
+	Parent   string            // W3C traceparent of a span in another process the root spans continue (empty for a new trace)
+
+	mu    sync.Mutex
+	ended []*Span
+}
+
+// Span is a timed step of a run; the methods of a nil span do nothing, so code can be traced unconditionally
+type Span struct {
+	tracer     *Tracer
+	traceID    [16]byte
+	spanID     [8]byte
+	parentID   [8]byte // Zero for a root span
+	name       string
+	start      time.Time
+	end        time.Time
+	attributes map[string]any
+	err        error
+}
+
+// NewFromEnv returns a tracer configured like OpenTelemetry SDKs from OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or OTEL_EXPORTER_OTLP_ENDPOINT,
+// OTEL_EXPORTER_OTLP_HEADERS, OTEL_SERVICE_NAME, OTEL_RESOURCE_ATTRIBUTES and TRACEPARENT. A non-empty endpoint overrides the
+// environment. It returns nil without an endpoint, or if OTEL_SDK_DISABLED is true or OTEL_TRACES_EXPORTER is none.
+func NewFromEnv(endpoint, defaultService string) (*Tracer, error) {
+	if endpoint == "" && (strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") || os.Getenv("OTEL_TRACES_EXPORTER") == "none") {
+		return nil, nil
+	}
+	exporter, err := exporterFromEnv(endpoint)
+	if err != nil || exporter == nil {
+		return nil, err
+	}
+	t := &Tracer{
+		Exporter: exporter,
+		Service:  defaultService,
+		Resource: parseKeyValues(os.Getenv("OTEL_RESOURCE_ATTRIBUTES")),
+		Parent:   os.Getenv("TRACEPARENT"),
+	}
+	if service := os.Getenv("OTEL_SERVICE_NAME"); service != "" {
+		t.Service = service
+	} else if service := t.Resource["service.name"]; service != "" {
+		t.Service = service
+	}
+	delete(t.Resource, "service.name")
+	if t.Parent != "" {
+		if _, _, err := parseTraceParent(t.Parent); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Start starts a root span, which continues the trace of Parent if it is set
+func (t *Tracer) Start(name string) *Span {
+	if t == nil {
+		return nil
+	}
+	span := &Span{tracer: t, name: name, start: time.Now()}
+	if traceID, parentID, err := parseTraceParent(t.Parent); err == nil {
+		span.traceID, span.parentID = traceID, parentID
+	} else {
+		rand.Read(span.traceID[:])
+	}
+	rand.Read(span.spanID[:])
+	return span
+}
+
+// Flush sends the ended spans to the exporter and forgets them
+func (t *Tracer) Flush(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	spans := t.ended
+	t.ended = nil
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+	return t.Exporter.export(ctx, t, spans)
+}
+
+// Start starts a child span
+func (s *Span) Start(name string) *Span {
+	if s == nil {
+		return nil
+	}
+	span := &Span{tracer: s.tracer, traceID: s.traceID, parentID: s.spanID, name: name, start: time.Now()}
+	rand.Read(span.spanID[:])
+	return span
+}
+
+// SetAttribute sets an attribute of the span; values are strings, bools, integers or floats, other values are formatted with %v
+func (s *Span) SetAttribute(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	if s.attributes == nil {
+		s.attributes = make(map[string]any)
+	}
+	s.attributes[key] = value
+}
+
+// RecordError marks the span as failed with err; a nil error is ignored
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.err = err
+}
+
+// End ends the span, queueing it for the next Flush; later calls do nothing
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	if !s.end.IsZero() {
+		return
+	}
+	s.end = time.Now()
+	s.tracer.ended = append(s.tracer.ended, s)
+}
+
+// TraceParent returns the W3C traceparent of the span, e.g. for the TRACEPARENT variable of child processes
+func (s *Span) TraceParent() string {
+	if s == nil {
+		return ""
+	}
+	return fmt.Sprintf("00-%s-%s-01", hex.EncodeToString(s.traceID[:]), hex.EncodeToString(s.spanID[:]))
+}
+
+// spanKey is the context key of the current span
+type spanKey struct{}
+
+// ContextWithSpan returns a copy of ctx carrying span, under which library functions start their spans
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span carried by ctx, or nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// parseTraceParent returns the trace and span IDs of a W3C traceparent value such as 00-<32 hex digits>-<16 hex digits>-01
+func parseTraceParent(value string) ([16]byte, [8]byte, error) {
+	var traceID [16]byte
+	var spanID [8]byte
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return traceID, spanID, fmt.Errorf("Invalid TRACEPARENT '%s': use 00-<trace ID>-<span ID>-<flags>", value)
+	}
+	if _, err := hex.Decode(traceID[:], []byte(parts[1])); err != nil {
+		return traceID, spanID, fmt.Errorf("Invalid trace ID in TRACEPARENT '%s'", value)
+	}
+	if _, err := hex.Decode(spanID[:], []byte(parts[2])); err != nil {
+		return traceID, spanID, fmt.Errorf("Invalid span ID in TRACEPARENT '%s'", value)
+	}
+	if traceID == [16]byte{} || spanID == [8]byte{} {
+		return traceID, spanID, fmt.Errorf("Invalid TRACEPARENT '%s': IDs must not be all zeros", value)
+	}
+	return traceID, spanID, nil
+}
+
+// parseKeyValues parses a comma-separated list of key=value pairs with percent-encoded values, as in OTEL_RESOURCE_ATTRIBUTES
+func parseKeyValues(list string) map[string]string {
+	values := make(map[string]string)
+	for _, pair := range strings.Split(list, ",") {
+		key, value, found := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			continue
+		}
+		if unescaped, err := url.PathUnescape(strings.TrimSpace(value)); err == nil {
+			value = unescaped
+		}
+		values[key] = value
+	}
+	return values
+}
@@ -0,0 +1,69 @@
+package transcript
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+// Rules select the cues that start a chapter
+type Rules struct {
+	Prefixes       []string      // Cues whose text starts with one of these (case-insensitive) start a chapter
+	Keywords       []string      // Cues whose text contains one of these (case-insensitive) start a chapter
+	SpeakerChanges bool          // Cues whose speaker differs from the previous named speaker start a chapter
+	MinGap         time.Duration // Matches closer than this to the previous chapter are skipped
+}
+
+// maxTitleLength is the longest chapter title taken from cue text, in characters
+const maxTitleLength = 80
+
+// Chapters creates a marker at each cue matching the rules.
+// Prefix and keyword matches are titled with the cue text, speaker changes with the speaker name.
+func Chapters(cues []Cue, rules Rules) []csvparser.MarkerEntry {
+	var markers []csvparser.MarkerEntry
+	previousSpeaker := ""
+
+	for _, cue := range cues {
+		title := ""
+		lower := strings.ToLower(cue.Text)
+
+		// Text rules take precedence over speaker changes
+		for _, prefix := range rules.Prefixes {
+			if prefix != "" && strings.HasPrefix(lower, strings.ToLower(prefix)) {
+				title = cue.Text
+				break
+			}
+		}
+		for _, keyword := range rules.Keywords {
+			if title == "" && keyword != "" && strings.Contains(lower, strings.ToLower(keyword)) {
+				title = cue.Text
+			}
+		}
+		if title == "" && rules.SpeakerChanges && cue.Speaker != "" && previousSpeaker != "" && cue.Speaker != previousSpeaker {
+			title = cue.Speaker
+		}
+		if cue.Speaker != "" {
+			previousSpeaker = cue.Speaker
+		}
+
+		if title == "" {
+			continue
+		}
+		if len(markers) > 0 && cue.Start-markers[len(markers)-1].StartTime < rules.MinGap {
+			continue
+		}
+		markers = append(markers, csvparser.MarkerEntry{Name: truncateTitle(title), StartTime: cue.Start})
+	}
+
+	return markers
+}
+
+// truncateTitle shortens long cue text to maxTitleLength characters
+func truncateTitle(title string) string {
+	runes := []rune(title)
+	if len(runes) <= maxTitleLength {
+		return title
+	}
+	return strings.TrimSpace(string(runes[:maxTitleLength-1])) + "…"
+}
@@ -0,0 +1,152 @@
+package transcript
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ROBO358/audition-marker_2_mp3-id3-tag/pkg/csvparser"
+)
+
+// Cue is a single timed line of a transcript
+type Cue struct {
+	Start   time.Duration // Start time of the cue
+	End     time.Duration // End time of the cue
+	Speaker string        // Speaker name if the cue names one, otherwise empty
+	Text    string        // Cue text without markup or speaker prefix; lines are joined with spaces
+}
+
+var (
+	// voiceTag matches a WebVTT voice span such as <v Alice>
+	voiceTag = regexp.MustCompile(`^<v(?:\.[^ >]*)?\s+([^>]+)>`)
+	// markupTag matches any other WebVTT/SRT markup tag such as <i> or {\an8}
+	markupTag = regexp.MustCompile(`<[^>]*>|\{\\[^}]*\}`)
+	// speakerPrefix matches a "[Name]" or upper-case "NAME:" speaker prefix as used in captions
+	speakerPrefix = regexp.MustCompile(`^(?:\[([^\]]{1,40})\]|(\p{Lu}[\p{Lu}\p{N}._-]*(?: [\p{Lu}\p{N}._-]+){0,2}):)\s*`)
+)
+
+// ParseFile parses an SRT or WebVTT transcript file
+func ParseFile(path string) ([]Cue, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot open transcript file: %w", err)
+	}
+	defer file.Close()
+
+	return Parse(file)
+}
+
+// Parse parses SRT or WebVTT transcript data; both formats are recognized by their timing lines
+func Parse(r io.Reader) ([]Cue, error) {
+	var cues []Cue
+	var current *Cue
+	var lines []string
+
+	// finish completes the current cue with the collected text lines
+	finish := func() {
+		if current != nil {
+			current.Text = strings.Join(lines, " ")
+			cues = append(cues, *current)
+		}
+		current = nil
+		lines = nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+
+		switch {
+		case line == "":
+			finish()
+		case strings.Contains(line, "-->"):
+			finish()
+			start, end, err := parseTiming(line)
+			if err != nil {
+				return nil, err
+			}
+			current = &Cue{Start: start, End: end}
+		case current != nil:
+			// Take the speaker from the first text line only
+			if len(lines) == 0 {
+				current.Speaker, line = splitSpeaker(line)
+			}
+			if text := strings.TrimSpace(markupTag.ReplaceAllString(line, "")); text != "" {
+				lines = append(lines, text)
+			}
+		}
+		// Other lines (header, cue numbers and identifiers, NOTE blocks) are ignored
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to read transcript: %w", err)
+	}
+	finish()
+
+	return cues, nil
+}
+
+// splitSpeaker removes a speaker marker from the start of a cue line and returns the speaker name
+func splitSpeaker(line string) (string, string) {
+	if m := voiceTag.FindStringSubmatch(line); m != nil {
+		return strings.TrimSpace(m[1]), line[len(m[0]):]
+	}
+	line = markupTag.ReplaceAllString(line, "")
+	if m := speakerPrefix.FindStringSubmatch(line); m != nil {
+		speaker := m[1]
+		if speaker == "" {
+			speaker = m[2]
+		}
+		return strings.TrimSpace(speaker), line[len(m[0]):]
+	}
+	return "", line
+}
+
+// parseTiming parses a timing line such as "00:01:02,500 --> 00:01:05,000 align:start"
+func parseTiming(line string) (time.Duration, time.Duration, error) {
+	parts := strings.SplitN(line, "-->", 2)
+	startText := strings.TrimSpace(parts[0])
+	endFields := strings.Fields(parts[1])
+	if len(endFields) == 0 {
+		return 0, 0, fmt.Errorf("Invalid cue timing '%s'", line)
+	}
+
+	start, err := parseTimestamp(startText)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := parseTimestamp(endFields[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+// parseTimestamp parses HH:MM:SS.mmm, MM:SS.mmm or the SRT form HH:MM:SS,mmm
+func parseTimestamp(text string) (time.Duration, error) {
+	fields := strings.Split(strings.Replace(text, ",", ".", 1), ":")
+	if len(fields) < 2 || len(fields) > 3 {
+		return 0, fmt.Errorf("Invalid timestamp '%s'", text)
+	}
+
+	// Parse the seconds exactly, and the leading minutes and hours as integers
+	total, err := csvparser.ParseSeconds(fields[len(fields)-1])
+	if err != nil {
+		return 0, fmt.Errorf("Invalid timestamp '%s'", text)
+	}
+	unit := time.Minute
+	for i := len(fields) - 2; i >= 0; i-- {
+		value, err := strconv.ParseInt(fields[i], 10, 64)
+		if err != nil || value < 0 {
+			return 0, fmt.Errorf("Invalid timestamp '%s'", text)
+		}
+		total += time.Duration(value) * unit
+		unit *= 60
+	}
+	return total, nil
+}
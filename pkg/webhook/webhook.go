@@ -0,0 +1,53 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Status values reported in events
+const (
+	StatusDone   = "done"    // File was tagged successfully
+	StatusFailed = "failed"  // Processing failed
+	StatusDryRun = "dry-run" // Nothing was written because of -dry-run
+)
+
+// Event is the JSON payload posted to the webhook after each file
+type Event struct {
+	Status     string `json:"status"`           // "done", "failed" or "dry-run"
+	Input      string `json:"input"`            // Path or name of the input MP3
+	CSV        string `json:"csv,omitempty"`    // Path or name of the marker CSV
+	Output     string `json:"output,omitempty"` // Path or name of the tagged MP3
+	Chapters   int    `json:"chapters"`         // Number of chapters written
+	DurationMS int64  `json:"duration_ms"`      // Processing time in milliseconds
+	Error      string `json:"error,omitempty"`  // Error message if processing failed
+}
+
+// client is the HTTP client used for notifications
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// Notify posts an event as JSON to the webhook URL
+func Notify(url string, event Event) error {
+	// Encode payload
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("Failed to encode webhook payload: %w", err)
+	}
+
+	// Send request
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("Failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Check response status
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Webhook returned status %s", resp.Status)
+	}
+
+	return nil
+}